@@ -0,0 +1,28 @@
+package main
+
+import (
+	"math/rand"
+	"time"
+)
+
+// Distributions the gaps between traces and between log records can be
+// drawn from with --arrival-distribution.
+const (
+	arrivalUniform  = "uniform"
+	arrivalPoisson  = "poisson"
+	arrivalConstant = "constant"
+)
+
+// arrivalDelay returns a gap averaging mean drawn from distribution.
+// Uniform gaps fall anywhere between zero and twice the mean. Poisson
+// arrivals have exponentially distributed gaps, so they bunch into bursts
+// separated by lulls. Constant gaps are always the mean.
+func arrivalDelay(distribution string, mean time.Duration) time.Duration {
+	switch distribution {
+	case arrivalPoisson:
+		return time.Duration(rand.ExpFloat64() * float64(mean))
+	case arrivalConstant:
+		return mean
+	}
+	return time.Duration(rand.Float64() * float64(mean) * 2)
+}
@@ -0,0 +1,153 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"maps"
+	"os"
+	"os/signal"
+	"slices"
+	"strings"
+	"syscall"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+// benchShortfall is the share of its target rate below which a signal is
+// reported as falling short.
+const benchShortfall = 0.9
+
+// benchMinIntervals is how many of a signal's intervals a run must span
+// before its rate is judged, since the first one only completes after a
+// full interval.
+const benchMinIntervals = 10
+
+// newBenchCmd returns the bench command, which runs a preset and reports
+// the rates it achieved against the ones it is configured for.
+func newBenchCmd() *cobra.Command {
+	var duration time.Duration
+	cmd := &cobra.Command{
+		Use:   "bench PRESET",
+		Short: "Run a preset and report achieved against target rates and export latency",
+		Long: "Run a preset like its own command does, then report the traces, metric collections and " +
+			"log records per second it achieved against the rates it is configured for, along with " +
+			"the export error rate and the p50 and p99 export latency of each signal.",
+		Args:      cobra.ExactArgs(1),
+		ValidArgs: slices.Sorted(maps.Keys(presets)),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			config, ok := presets[args[0]]
+			if !ok {
+				return fmt.Errorf("unknown preset %q, expected low, medium, high or stress", args[0])
+			}
+			if duration < 0 {
+				return errors.New("--duration must not be negative")
+			}
+			if err := opts.load(); err != nil {
+				return err
+			}
+			defer opts.decisions.Close()
+			defer opts.stream.Close()
+			if opts.Schedule != "" {
+				return errors.New("bench runs a single preset, it can't be combined with --schedule")
+			}
+			if opts.OutputFormat != summaryTable {
+				return errors.New("bench always reports as a table, it can't be combined with --output-format")
+			}
+			config = opts.apply(config)
+			config.bench = true
+			if duration > 0 {
+				config.Duration = duration
+			}
+
+			// Stop early on Ctrl-C but still report what was reached
+			ctx, stop := signal.NotifyContext(cmd.Context(), os.Interrupt, syscall.SIGTERM)
+			defer stop()
+			return runGenerator(ctx, config)
+		},
+	}
+	cmd.Flags().DurationVar(&duration, "duration", 0, "run for this long instead of the preset's duration")
+	return cmd
+}
+
+// recordLatencies makes the exporter wrappers keep every export call's
+// duration.
+func (s *runStats) recordLatencies() {
+	s.spans.latencies = &latencyRecorder{}
+	s.metrics.latencies = &latencyRecorder{}
+	s.logs.latencies = &latencyRecorder{}
+}
+
+// benchReport prints the rates a run of elapsed achieved against the ones
+// config targets, as a table, or as one structured line with --log-format
+// json. Rates are in the units the preset paces: traces, metric
+// collections and log records per second.
+func (s *runStats) benchReport(config Config, elapsed time.Duration) {
+	rows := []struct {
+		name  string
+		rate  time.Duration
+		stats *signalStats
+	}{
+		{"traces", config.TraceRate, &s.spans},
+		{"metrics", config.MetricRate, &s.metrics},
+		{"logs", config.LogRate, &s.logs},
+	}
+
+	var table strings.Builder
+	tw := tabwriter.NewWriter(&table, 0, 0, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprintln(tw, "signal\ttarget/s\tachieved/s\tof target\titems/s\texport errors\tp50 export\tp99 export\t")
+	args := []any{"preset", getConfigName(config), "elapsed", elapsed.String()}
+	var short, unjudged []string
+	for _, row := range rows {
+		target := float64(time.Second) / float64(row.rate)
+		achieved := float64(row.stats.passes.Load()) / elapsed.Seconds()
+		items := float64(row.stats.generated.Load()) / elapsed.Seconds()
+		ratio := achieved / target
+		errorRate := 0.0
+		if exports := row.stats.exports.Load(); exports > 0 {
+			errorRate = float64(row.stats.exportErrors.Load()) / float64(exports)
+		}
+		p50, ok := row.stats.latencies.percentile(0.50)
+		p99, _ := row.stats.latencies.percentile(0.99)
+		p50Text, p99Text := "-", "-"
+		if ok {
+			p50Text, p99Text = p50.Round(time.Microsecond).String(), p99.Round(time.Microsecond).String()
+		}
+		fmt.Fprintf(tw, "%s\t%.2f\t%.2f\t%.0f%%\t%.2f\t%.1f%%\t%s\t%s\t\n",
+			row.name, target, achieved, ratio*100, items, errorRate*100, p50Text, p99Text)
+		args = append(args,
+			row.name+"_target_per_sec", target,
+			row.name+"_achieved_per_sec", achieved,
+			row.name+"_items_per_sec", items,
+			row.name+"_export_error_rate", errorRate,
+			row.name+"_export_p50", p50.String(),
+			row.name+"_export_p99", p99.String())
+		switch {
+		case elapsed < benchMinIntervals*row.rate:
+			unjudged = append(unjudged, row.name)
+		case ratio < benchShortfall:
+			short = append(short, row.name)
+		}
+	}
+	tw.Flush()
+
+	out.info(fmt.Sprintf("🏁 %s bench over %v:\n%s", getConfigName(config), elapsed.Round(time.Millisecond),
+		strings.TrimRight(table.String(), "\n")), "bench report", args...)
+
+	if len(unjudged) > 0 {
+		out.info(fmt.Sprintf("⏳ The run was too short to judge the rate of %s, pass a longer --duration", strings.Join(unjudged, ", ")),
+			"run too short to judge rates", "signals", strings.Join(unjudged, ","))
+	}
+	for _, name := range short {
+		if name == "traces" {
+			// Each trace sleeps through its simulated processing before the
+			// gap to the next one starts, on the one generator goroutine
+			out.warn("⚠️  traces fell short of the target rate: traces are generated one at a time, so each trace's "+
+				"simulated processing time adds to the gap between traces; lower --max-latency to get closer",
+				"traces below target rate", "min_latency", config.MinLatency.String(), "max_latency", config.MaxLatency.String())
+			continue
+		}
+		out.warn(fmt.Sprintf("⚠️  %s fell short of the target rate", name), "signal below target rate", "signal", name)
+	}
+}
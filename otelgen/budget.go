@@ -0,0 +1,184 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/log"
+	sdklog "go.opentelemetry.io/otel/sdk/log"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+)
+
+// Fixed per-item overheads of the OTLP encoding: IDs, timestamps, flags
+// and field tags. They only need to be in the right ballpark.
+const (
+	spanOverhead      = 60
+	eventOverhead     = 12
+	linkOverhead      = 30
+	dataPointOverhead = 30
+	logOverhead       = 40
+	attributeOverhead = 4
+)
+
+// byteBudget ends a run once the estimated size of exported telemetry
+// reaches a limit. A nil budget is unlimited.
+type byteBudget struct {
+	limit     int64
+	used      atomic.Int64
+	once      sync.Once
+	exhausted chan struct{}
+}
+
+func newByteBudget(limit int64) *byteBudget {
+	if limit <= 0 {
+		return nil
+	}
+	return &byteBudget{limit: limit, exhausted: make(chan struct{})}
+}
+
+// spend adds n exported bytes.
+func (b *byteBudget) spend(n int) {
+	if b == nil {
+		return
+	}
+	if b.used.Add(int64(n)) >= b.limit {
+		b.once.Do(func() { close(b.exhausted) })
+	}
+}
+
+// done is closed once the budget is spent. It never is for a nil budget.
+func (b *byteBudget) done() <-chan struct{} {
+	if b == nil {
+		return nil
+	}
+	return b.exhausted
+}
+
+// parseByteSize parses sizes such as 500000, 500KB, 10MB or 1GiB. KB, MB
+// and GB are powers of 1000, KiB, MiB and GiB powers of 1024.
+func parseByteSize(s string) (int64, error) {
+	if s == "" {
+		return 0, nil
+	}
+	units := []struct {
+		suffix string
+		size   int64
+	}{
+		{"KiB", 1 << 10}, {"MiB", 1 << 20}, {"GiB", 1 << 30},
+		{"KB", 1e3}, {"MB", 1e6}, {"GB", 1e9},
+		{"B", 1},
+	}
+	number, unit := strings.TrimSpace(s), int64(1)
+	for _, u := range units {
+		if strings.HasSuffix(strings.ToUpper(number), strings.ToUpper(u.suffix)) {
+			number, unit = strings.TrimSpace(number[:len(number)-len(u.suffix)]), u.size
+			break
+		}
+	}
+	n, err := strconv.ParseFloat(number, 64)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("invalid size %q, expected e.g. 500KB, 10MB or 1GiB", s)
+	}
+	return int64(n * float64(unit)), nil
+}
+
+// attributesSize estimates the encoded size of attrs.
+func attributesSize(attrs []attribute.KeyValue) int {
+	n := 0
+	for _, kv := range attrs {
+		n += attributeOverhead + len(kv.Key)
+		switch kv.Value.Type() {
+		case attribute.STRING:
+			n += len(kv.Value.AsString())
+		case attribute.STRINGSLICE:
+			for _, v := range kv.Value.AsStringSlice() {
+				n += len(v) + 2
+			}
+		default:
+			n += 8
+		}
+	}
+	return n
+}
+
+func resourceSize(res *resource.Resource) int {
+	if res == nil {
+		return 0
+	}
+	return attributesSize(res.Attributes())
+}
+
+// spansSize estimates the encoded size of an export of spans.
+func spansSize(spans []sdktrace.ReadOnlySpan) int {
+	if len(spans) == 0 {
+		return 0
+	}
+	n := resourceSize(spans[0].Resource())
+	for _, span := range spans {
+		n += spanOverhead + len(span.Name()) + len(span.Status().Description) + attributesSize(span.Attributes())
+		for _, event := range span.Events() {
+			n += eventOverhead + len(event.Name) + attributesSize(event.Attributes)
+		}
+		for _, link := range span.Links() {
+			n += linkOverhead + attributesSize(link.Attributes)
+		}
+	}
+	return n
+}
+
+// metricsSize estimates the encoded size of an export of gauge, sum and
+// histogram data points.
+func metricsSize(rm *metricdata.ResourceMetrics) int {
+	n := resourceSize(rm.Resource)
+	for _, sm := range rm.ScopeMetrics {
+		for _, m := range sm.Metrics {
+			n += len(m.Name) + len(m.Description) + len(m.Unit)
+			switch data := m.Data.(type) {
+			case metricdata.Gauge[float64]:
+				n += dataPointsSize(data.DataPoints)
+			case metricdata.Gauge[int64]:
+				n += dataPointsSize(data.DataPoints)
+			case metricdata.Sum[float64]:
+				n += dataPointsSize(data.DataPoints)
+			case metricdata.Sum[int64]:
+				n += dataPointsSize(data.DataPoints)
+			case metricdata.Histogram[float64]:
+				for _, dp := range data.DataPoints {
+					n += dataPointOverhead + attributesSize(dp.Attributes.ToSlice()) + 16*len(dp.Bounds)
+				}
+			}
+		}
+	}
+	return n
+}
+
+func dataPointsSize[N int64 | float64](points []metricdata.DataPoint[N]) int {
+	n := 0
+	for _, dp := range points {
+		n += dataPointOverhead + attributesSize(dp.Attributes.ToSlice())
+	}
+	return n
+}
+
+// logsSize estimates the encoded size of an export of log records.
+func logsSize(records []sdklog.Record) int {
+	if len(records) == 0 {
+		return 0
+	}
+	n := resourceSize(records[0].Resource())
+	for i := range records {
+		r := &records[i]
+		n += logOverhead + len(r.SeverityText()) + len(r.Body().String())
+		r.WalkAttributes(func(kv log.KeyValue) bool {
+			n += attributeOverhead + len(kv.Key) + len(kv.Value.String())
+			return true
+		})
+	}
+	return n
+}
@@ -0,0 +1,34 @@
+package main
+
+import "time"
+
+// clock is the generators' source of time. Every timestamp, sleep and tick
+// goes through it, so a fake clock can drive the generators step by step
+// and make emission counts exact instead of timing-dependent.
+type clock interface {
+	Now() time.Time
+	After(d time.Duration) <-chan time.Time
+	NewTicker(d time.Duration) ticker
+}
+
+// ticker is the part of time.Ticker the generators use.
+type ticker interface {
+	C() <-chan time.Time
+	Stop()
+}
+
+// realClock is the wall clock.
+type realClock struct{}
+
+func (realClock) Now() time.Time                         { return time.Now() }
+func (realClock) After(d time.Duration) <-chan time.Time { return time.After(d) }
+func (realClock) NewTicker(d time.Duration) ticker       { return realTicker{time.NewTicker(d)} }
+
+type realTicker struct{ *time.Ticker }
+
+func (t realTicker) C() <-chan time.Time { return t.Ticker.C }
+
+// sleep blocks for d on c.
+func sleep(c clock, d time.Duration) {
+	<-c.After(d)
+}
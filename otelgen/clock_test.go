@@ -0,0 +1,138 @@
+package main
+
+import (
+	"sync"
+	"time"
+)
+
+// fakeClock is a clock that only moves when advanced. Ticks are handed to
+// a ticker's reader one at a time, so a generator handles each tick before
+// the next one comes, and timers fire into a buffered channel like
+// time.After's.
+type fakeClock struct {
+	mu      sync.Mutex
+	changed *sync.Cond
+	now     time.Time
+	timers  []*fakeTimer
+	tickers []*fakeTicker
+}
+
+type fakeTimer struct {
+	at time.Time
+	c  chan time.Time
+}
+
+type fakeTicker struct {
+	clock    *fakeClock
+	period   time.Duration
+	next     time.Time
+	c        chan time.Time
+	stopped  chan struct{}
+	stopOnce sync.Once
+}
+
+func newFakeClock() *fakeClock {
+	c := &fakeClock{now: time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)}
+	c.changed = sync.NewCond(&c.mu)
+	return c
+}
+
+func (c *fakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *fakeClock) After(d time.Duration) <-chan time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	ch := make(chan time.Time, 1)
+	if d <= 0 {
+		ch <- c.now
+		return ch
+	}
+	c.timers = append(c.timers, &fakeTimer{at: c.now.Add(d), c: ch})
+	c.changed.Broadcast()
+	return ch
+}
+
+func (c *fakeClock) NewTicker(d time.Duration) ticker {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	t := &fakeTicker{clock: c, period: d, next: c.now.Add(d), c: make(chan time.Time), stopped: make(chan struct{})}
+	c.tickers = append(c.tickers, t)
+	c.changed.Broadcast()
+	return t
+}
+
+func (t *fakeTicker) C() <-chan time.Time { return t.c }
+
+func (t *fakeTicker) Stop() {
+	t.stopOnce.Do(func() { close(t.stopped) })
+	c := t.clock
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for i, other := range c.tickers {
+		if other == t {
+			c.tickers = append(c.tickers[:i], c.tickers[i+1:]...)
+			break
+		}
+	}
+}
+
+// waitFor blocks until at least n timers and tickers are pending, so an
+// advance doesn't race the goroutine that is about to wait on the clock.
+func (c *fakeClock) waitFor(n int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for len(c.timers)+len(c.tickers) < n {
+		c.changed.Wait()
+	}
+}
+
+// advance moves the clock forward by d, firing every timer and tick due
+// on the way in order. Each tick waits for its ticker to be read or
+// stopped.
+func (c *fakeClock) advance(d time.Duration) {
+	c.mu.Lock()
+	target := c.now.Add(d)
+	c.mu.Unlock()
+	for {
+		c.mu.Lock()
+		var (
+			found bool
+			at    time.Time
+			timer int
+			tick  *fakeTicker
+		)
+		for i, t := range c.timers {
+			if !t.at.After(target) && (!found || t.at.Before(at)) {
+				found, at, timer = true, t.at, i
+			}
+		}
+		for _, t := range c.tickers {
+			if !t.next.After(target) && (!found || t.next.Before(at)) {
+				found, at, tick = true, t.next, t
+			}
+		}
+		if !found {
+			c.now = target
+			c.mu.Unlock()
+			return
+		}
+		c.now = at
+		if tick != nil {
+			tick.next = tick.next.Add(tick.period)
+			c.mu.Unlock()
+			select {
+			case tick.c <- at:
+			case <-tick.stopped:
+			}
+			continue
+		}
+		t := c.timers[timer]
+		c.timers = append(c.timers[:timer], c.timers[timer+1:]...)
+		c.mu.Unlock()
+		t.c <- at
+	}
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"net"
+	"time"
+)
+
+// Backoff between attempts to reach the collector with --connect-retry.
+// Each wait doubles the last, with up to a quarter added as jitter so
+// several generators started together don't retry in lockstep.
+const (
+	connectBackoffMin = 500 * time.Millisecond
+	connectBackoffMax = 10 * time.Second
+	// connectDialTimeout bounds each attempt to open a connection.
+	connectDialTimeout = 2 * time.Second
+)
+
+// connectExporter creates one signal's exporter with create. With
+// --connect-retry it first checks that the collector at the signal's
+// endpoint accepts connections, since the gRPC exporters dial lazily and
+// would otherwise start without it, and retries a failed check or
+// creation with backoff until the collector is up or the retry window
+// runs out. Without it, or with --output, create is called once.
+func connectExporter[E any](ctx context.Context, config Config, signal string,
+	create func(context.Context, Config) (E, error)) (E, error) {
+	if config.ConnectRetry == 0 || config.stream != nil {
+		return create(ctx, config)
+	}
+
+	endpoint := config.Endpoints.get(signal)
+	giveUp := time.Now().Add(config.ConnectRetry)
+	backoff := connectBackoffMin
+	for attempt := 1; ; attempt++ {
+		exporter, err := tryConnect(ctx, config, endpoint, create)
+		if err == nil {
+			if attempt > 1 {
+				out.info(fmt.Sprintf("🔌 Connected to the collector at %s for %s", endpoint, signal),
+					"connected to collector", "signal", signal, "endpoint", endpoint, "attempts", attempt)
+			}
+			return exporter, nil
+		}
+		wait := min(backoff+time.Duration(rand.Int63n(int64(backoff/4)+1)), time.Until(giveUp))
+		if wait <= 0 {
+			return exporter, fmt.Errorf("collector at %s still unreachable after %v: %w", endpoint, config.ConnectRetry, err)
+		}
+		out.warn(fmt.Sprintf("⏳ Collector at %s not reachable for %s (%v), retrying in %v", endpoint, signal, err, wait.Round(time.Millisecond)),
+			"collector not reachable", "signal", signal, "endpoint", endpoint, "error", err.Error(), "retry_in", wait.String())
+		select {
+		case <-ctx.Done():
+			return exporter, ctx.Err()
+		case <-time.After(wait):
+		}
+		backoff = min(2*backoff, connectBackoffMax)
+	}
+}
+
+// tryConnect makes one attempt at reaching endpoint and creating the
+// exporter.
+func tryConnect[E any](ctx context.Context, config Config, endpoint string,
+	create func(context.Context, Config) (E, error)) (E, error) {
+	dialer := net.Dialer{Timeout: connectDialTimeout}
+	conn, err := dialer.DialContext(ctx, "tcp", endpoint)
+	if err != nil {
+		var zero E
+		return zero, err
+	}
+	conn.Close()
+	return create(ctx, config)
+}
@@ -0,0 +1,33 @@
+package main
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestConnectExporterProbesSignalEndpoint(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer ln.Close()
+	closed, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	closed.Close()
+
+	config := Config{
+		ConnectRetry: 100 * time.Millisecond,
+		Endpoints:    endpoints{Traces: closed.Addr().String(), Metrics: closed.Addr().String(), Logs: ln.Addr().String()},
+	}
+	create := func(context.Context, Config) (string, error) { return "exporter", nil }
+
+	exporter, err := connectExporter(context.Background(), config, "logs", create)
+	require.NoError(t, err)
+	assert.Equal(t, "exporter", exporter)
+
+	_, err = connectExporter(context.Background(), config, "traces", create)
+	assert.ErrorContains(t, err, closed.Addr().String())
+}
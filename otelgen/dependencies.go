@@ -0,0 +1,73 @@
+package main
+
+import (
+	"fmt"
+	"math/rand"
+	"net"
+	"strconv"
+	"strings"
+)
+
+// dependency is a simulated downstream system called from a request.
+type dependency struct {
+	Name    string
+	Address string
+	Port    int
+}
+
+// defaultDependencies is the --dependencies default: a database, a cache
+// and a payment API.
+const defaultDependencies = "postgres=db.internal:5432,redis=cache.internal:6379,payments-api=api.payments.example.com:443"
+
+// maxDependencyCalls bounds the downstream calls made by one request.
+const maxDependencyCalls = 2
+
+// parseDependencies parses a list like "postgres=db.internal:5432,redis=cache.internal:6379".
+func parseDependencies(spec string) ([]dependency, error) {
+	var deps []dependency
+	for _, part := range strings.Split(spec, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		name, hostPort, found := strings.Cut(part, "=")
+		if !found || name == "" {
+			return nil, fmt.Errorf("invalid dependency %q, expected name=host:port", part)
+		}
+		host, portStr, err := net.SplitHostPort(hostPort)
+		if err != nil {
+			return nil, fmt.Errorf("invalid dependency %q: %w", part, err)
+		}
+		port, err := strconv.Atoi(portStr)
+		if err != nil || port <= 0 || port > 65535 {
+			return nil, fmt.Errorf("invalid port in dependency %q", part)
+		}
+		deps = append(deps, dependency{Name: name, Address: host, Port: port})
+	}
+	return deps, nil
+}
+
+// pickDependencies returns the names of up to maxDependencyCalls distinct
+// dependencies for one request to call.
+func pickDependencies(deps []dependency) []string {
+	if len(deps) == 0 {
+		return nil
+	}
+	n := rand.Intn(min(maxDependencyCalls, len(deps)) + 1)
+	var names []string
+	for _, i := range rand.Perm(len(deps))[:n] {
+		names = append(names, deps[i].Name)
+	}
+	return names
+}
+
+// findDependency returns the dependency called name. Replayed scripts may
+// name dependencies that are no longer configured; those get no address.
+func findDependency(deps []dependency, name string) dependency {
+	for _, dep := range deps {
+		if dep.Name == name {
+			return dep
+		}
+	}
+	return dependency{Name: name}
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"time"
+
+	"go.opentelemetry.io/otel/log"
+)
+
+const (
+	// deployEventName is the event name of the log records marking a
+	// simulated deployment, for the sonifier to recognize.
+	deployEventName = "deployment"
+	// deployBlipContention is how hard requests are hit during the blip
+	// after a deployment, on the same 0 to 1 scale as resource contention.
+	deployBlipContention = 0.5
+)
+
+// generateDeployments emits a deployment marker log at each --deploy-at
+// offset from the start of the run. With --deploy-blip, requests slow
+// down and fail more for that long after each one, like a rollout
+// hitting cold caches.
+func generateDeployments(ctx context.Context, pool *instancePool, config Config, load *systemLoad, stats *signalStats, done <-chan struct{}) {
+	offsets := slices.Clone(config.DeployAt)
+	slices.Sort(offsets)
+	started := config.clock.Now()
+
+	for i, offset := range offsets {
+		select {
+		case <-done:
+			return
+		case <-ctx.Done():
+			return
+		case <-config.clock.After(offset - config.clock.Now().Sub(started)):
+		}
+
+		now := config.clock.Now()
+		version := fmt.Sprintf("v1.%d.0", i+1)
+		record := log.Record{}
+		record.SetTimestamp(now)
+		record.SetEventName(deployEventName)
+		record.SetSeverity(log.SeverityInfo)
+		record.SetBody(log.StringValue(fmt.Sprintf("Deployment of %s started", version)))
+		record.AddAttributes(
+			log.String("event.name", deployEventName),
+			log.String("deployment.id", fmt.Sprintf("deploy-%d", i+1)),
+			log.String("service.version", version),
+		)
+		pool.pick().logger.Emit(ctx, record)
+		stats.generated.Add(1)
+
+		if config.DeployBlip > 0 {
+			load.blipUntil.Store(now.Add(config.DeployBlip).UnixNano())
+		}
+		out.info(fmt.Sprintf("🚢 Deployment %s at %v", version, offset),
+			"deployment", "version", version, "at", offset.String(), "blip", config.DeployBlip.String())
+	}
+}
+
+// deployBlip returns the contention a deployment blip adds at now, or zero
+// outside of one.
+func (l *systemLoad) deployBlip(now time.Time) float64 {
+	if now.UnixNano() < l.blipUntil.Load() {
+		return deployBlipContention
+	}
+	return 0
+}
@@ -0,0 +1,50 @@
+package main
+
+import (
+	"fmt"
+	"net"
+	"net/url"
+	"strings"
+)
+
+// normalizeEndpoint turns the value of an endpoint flag, such as
+// --endpoint, into the bare host:port the exporters expect. A URL's
+// http:// or https:// scheme is stripped with a warning, as long as it
+// agrees with --insecure; anything the exporters would fail on later is
+// reported here instead.
+func normalizeEndpoint(flag, endpoint string, insecure bool) (string, error) {
+	endpoint = strings.TrimSpace(endpoint)
+	if endpoint == "" {
+		return "", fmt.Errorf("%s must not be empty", flag)
+	}
+
+	if strings.Contains(endpoint, "://") {
+		u, err := url.Parse(endpoint)
+		if err != nil {
+			return "", fmt.Errorf("invalid %s %q: %w", flag, endpoint, err)
+		}
+		switch strings.ToLower(u.Scheme) {
+		case "http":
+			if !insecure {
+				return "", fmt.Errorf("%s %q is plaintext http:// but --insecure=false asks for TLS; use https:// or drop --insecure=false", flag, endpoint)
+			}
+		case "https":
+			if insecure {
+				return "", fmt.Errorf("%s %q asks for TLS but --insecure is set; pass --insecure=false to connect over TLS", flag, endpoint)
+			}
+		default:
+			return "", fmt.Errorf("%s %q has unsupported scheme %q, expected host:port", flag, endpoint, u.Scheme)
+		}
+		if u.Path != "" && u.Path != "/" {
+			return "", fmt.Errorf("%s %q has a path, but endpoints are only host:port; OTLP/HTTP paths are fixed per signal", flag, endpoint)
+		}
+		out.warn(fmt.Sprintf("⚠️  Stripped %s:// from %s; the exporters take host:port, using %s", u.Scheme, flag, u.Host),
+			"stripped endpoint scheme", "flag", flag, "endpoint", endpoint, "using", u.Host)
+		endpoint = u.Host
+	}
+
+	if _, port, err := net.SplitHostPort(endpoint); err != nil || port == "" {
+		return "", fmt.Errorf("%s %q must be host:port, e.g. localhost:4317", flag, endpoint)
+	}
+	return endpoint, nil
+}
@@ -0,0 +1,145 @@
+package main
+
+import (
+	"fmt"
+	"math/rand"
+	"strings"
+	"time"
+	"unicode"
+
+	semconv "go.opentelemetry.io/otel/semconv/v1.24.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// exceptionTemplate is an error a simulated Go service might fail with.
+// Frames are the innermost stack frames, above the handler's.
+type exceptionTemplate struct {
+	Type    string
+	Message string
+	Frames  []string
+}
+
+// upstreamExceptions are what a 502 or 503 fails with: a downstream call
+// that timed out or was refused.
+var upstreamExceptions = []exceptionTemplate{
+	{
+		Type:    "*net.OpError",
+		Message: "dial tcp 10.0.3.7:5432: connect: connection refused",
+		Frames: []string{
+			"net.(*Dialer).DialContext(...)\n\t/usr/local/go/src/net/dial.go:586 +0x4a8",
+			"github.com/jackc/pgx/v5/pgconn.connectOne(...)\n\t/go/pkg/mod/github.com/jackc/pgx/v5@v5.5.5/pgconn/pgconn.go:297 +0x1f4",
+		},
+	},
+	{
+		Type:    "*url.Error",
+		Message: `Post "https://api.payments.example.com/v1/charges": context deadline exceeded`,
+		Frames: []string{
+			"net/http.(*Client).do(...)\n\t/usr/local/go/src/net/http/client.go:724 +0x8e5",
+			"net/http.(*Client).Do(...)\n\t/usr/local/go/src/net/http/client.go:590",
+		},
+	},
+	{
+		Type:    "context.deadlineExceededError",
+		Message: "context deadline exceeded",
+		Frames: []string{
+			"github.com/redis/go-redis/v9.(*baseClient).process(...)\n\t/go/pkg/mod/github.com/redis/go-redis/v9@v9.5.1/redis.go:390 +0x6c",
+		},
+	},
+}
+
+// internalExceptions are what a 500 fails with: a bug or a failed query.
+var internalExceptions = []exceptionTemplate{
+	{
+		Type:    "runtime.boundsError",
+		Message: "runtime error: index out of range [3] with length 3",
+	},
+	{
+		Type:    "*runtime.TypeAssertionError",
+		Message: "interface conversion: interface {} is nil, not map[string]interface {}",
+	},
+	{
+		Type:    "*pgconn.PgError",
+		Message: "ERROR: deadlock detected (SQLSTATE 40P01)",
+		Frames: []string{
+			"github.com/jackc/pgx/v5/pgconn.(*PgConn).Exec(...)\n\t/go/pkg/mod/github.com/jackc/pgx/v5@v5.5.5/pgconn/pgconn.go:1043 +0x2e5",
+			"github.com/jackc/pgx/v5.(*Conn).Exec(...)\n\t/go/pkg/mod/github.com/jackc/pgx/v5@v5.5.5/conn.go:453 +0x1b0",
+		},
+	},
+	{
+		Type:    "*json.SyntaxError",
+		Message: "invalid character '}' looking for beginning of object key string",
+		Frames: []string{
+			"encoding/json.(*Decoder).Decode(...)\n\t/usr/local/go/src/encoding/json/stream.go:63 +0x7b",
+		},
+	},
+}
+
+// handlerVerbs name the handler method serving each HTTP method.
+var handlerVerbs = map[string]string{
+	"GET": "Get", "HEAD": "Get", "POST": "Create", "PUT": "Update", "PATCH": "Update", "DELETE": "Delete",
+}
+
+// recordException adds an exception event to a failed span, as
+// span.RecordError does, with an exception type, message and stack trace
+// like a Go service serving route would report for statusCode.
+// RecordError itself isn't used, since it would name the type after the
+// generator's own error and take the stack from the generator.
+func recordException(span trace.Span, method, route string, statusCode int, at time.Time) {
+	pool := internalExceptions
+	if statusCode == 502 || statusCode == 503 {
+		pool = upstreamExceptions
+	}
+	ex := pool[rand.Intn(len(pool))]
+	span.AddEvent(semconv.ExceptionEventName, trace.WithTimestamp(at), trace.WithAttributes(
+		semconv.ExceptionType(ex.Type),
+		semconv.ExceptionMessage(ex.Message),
+		semconv.ExceptionStacktrace(stackTrace(ex, method, route)),
+		semconv.ExceptionEscaped(true),
+	))
+}
+
+// stackTrace renders a goroutine dump, as debug.Stack does, of ex raised
+// in the handler for route.
+func stackTrace(ex exceptionTemplate, method, route string) string {
+	pkg, verb := routePackage(route), handlerVerb(method)
+	var b strings.Builder
+	fmt.Fprintf(&b, "goroutine %d [running]:\n", 20+rand.Intn(2000))
+	for _, frame := range ex.Frames {
+		b.WriteString(frame + "\n")
+	}
+	fmt.Fprintf(&b, "github.com/example/shop/internal/%s.(*Handler).%s(...)\n\t/app/internal/%s/handler.go:%d +0x%x\n",
+		pkg, verb, pkg, 40+rand.Intn(200), 0x40+rand.Intn(0x200))
+	b.WriteString("net/http.HandlerFunc.ServeHTTP(...)\n\t/usr/local/go/src/net/http/server.go:2220 +0x29\n")
+	b.WriteString("net/http.serverHandler.ServeHTTP(...)\n\t/usr/local/go/src/net/http/server.go:3210 +0x8e\n")
+	b.WriteString("net/http.(*conn).serve(...)\n\t/usr/local/go/src/net/http/server.go:2092 +0x5f4\n")
+	b.WriteString("created by net/http.(*Server).Serve in goroutine 1\n\t/usr/local/go/src/net/http/server.go:3360 +0x485")
+	return b.String()
+}
+
+// handlerVerb names the handler method serving an HTTP method.
+func handlerVerb(method string) string {
+	if verb, ok := handlerVerbs[method]; ok {
+		return verb
+	}
+	return "Serve"
+}
+
+// routePackage names the package handling route after its first segment
+// that isn't "api", a version or a parameter, as in /api/v1/orders/{id}.
+func routePackage(route string) string {
+	for _, segment := range strings.Split(route, "/") {
+		if segment == "" || segment == "api" || strings.HasPrefix(segment, "{") || strings.HasPrefix(segment, ":") {
+			continue
+		}
+		if len(segment) > 1 && segment[0] == 'v' && unicode.IsDigit(rune(segment[1])) {
+			continue
+		}
+		return strings.Map(func(r rune) rune {
+			if unicode.IsLetter(r) || unicode.IsDigit(r) {
+				return unicode.ToLower(r)
+			}
+			return -1
+		}, segment)
+	}
+	return "api"
+}
@@ -0,0 +1,188 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"sync"
+	"time"
+
+	"github.com/spf13/cobra"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.24.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// fixtureStart is when the fixture trace starts. Every timestamp in it is
+// a fixed offset from here.
+var fixtureStart = time.Date(2025, time.January, 1, 12, 0, 0, 0, time.UTC)
+
+// seededIDs generates trace and span IDs from a fixed seed, so the same
+// seed always gives the same IDs in the same order.
+type seededIDs struct {
+	mu  sync.Mutex
+	rnd *rand.Rand
+}
+
+func newSeededIDs(seed int64) *seededIDs {
+	return &seededIDs{rnd: rand.New(rand.NewSource(seed))}
+}
+
+func (g *seededIDs) NewIDs(ctx context.Context) (trace.TraceID, trace.SpanID) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	var tid trace.TraceID
+	g.rnd.Read(tid[:])
+	var sid trace.SpanID
+	g.rnd.Read(sid[:])
+	return tid, sid
+}
+
+func (g *seededIDs) NewSpanID(ctx context.Context, traceID trace.TraceID) trace.SpanID {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	var sid trace.SpanID
+	g.rnd.Read(sid[:])
+	return sid
+}
+
+// fixtureSpan is one span of the fixture trace, as offsets from
+// fixtureStart. Spans without a parent hang off the request span.
+type fixtureSpan struct {
+	Name       string
+	Kind       trace.SpanKind
+	Start, End time.Duration
+	Attrs      []attribute.KeyValue
+	Failed     string
+}
+
+// fixtureChildren are the request's downstream calls and its own work, in
+// the order they start: a successful database query, the handler's
+// compute span and a failed call to an external API.
+var fixtureChildren = []fixtureSpan{
+	{
+		Name: "SELECT shop", Kind: trace.SpanKindClient, Start: 5 * time.Millisecond, End: 17 * time.Millisecond,
+		Attrs: []attribute.KeyValue{
+			semconv.PeerService("postgres"),
+			semconv.ServerAddress("db.internal"),
+			semconv.ServerPort(5432),
+			semconv.DBSystemPostgreSQL,
+			semconv.DBName("shop"),
+			semconv.DBStatement("SELECT id, status, total FROM orders WHERE id = $1"),
+		},
+	},
+	{Name: "orders.Create", Kind: trace.SpanKindInternal, Start: 20 * time.Millisecond, End: 35 * time.Millisecond},
+	{
+		Name: "POST", Kind: trace.SpanKindClient, Start: 40 * time.Millisecond, End: 115 * time.Millisecond,
+		Attrs: []attribute.KeyValue{
+			semconv.PeerService("payments-api"),
+			semconv.ServerAddress("api.payments.example.com"),
+			semconv.ServerPort(443),
+			semconv.HTTPRequestMethodPost,
+			semconv.URLFull("https://api.payments.example.com/v1/charges"),
+			semconv.HTTPResponseStatusCode(503),
+		},
+		Failed: "Downstream call failed",
+	},
+}
+
+// fixtureRequest is the request span the children belong to. It fails
+// because its last call did.
+var fixtureRequest = fixtureSpan{
+	Name: "POST /api/orders", Kind: trace.SpanKindServer, End: 120 * time.Millisecond,
+	Attrs: []attribute.KeyValue{
+		attribute.String("http.method", "POST"),
+		attribute.String("http.route", "/api/orders"),
+		attribute.String("user.id", "user_42"),
+		attribute.Int("http.status_code", 502),
+	},
+	Failed: "Request failed",
+}
+
+// newFixtureCmd returns the fixture command, which emits one fully
+// specified piece of telemetry and exits.
+func newFixtureCmd() *cobra.Command {
+	var emitTrace bool
+	var seed int64
+	cmd := &cobra.Command{
+		Use:   "fixture",
+		Short: "Emit one deterministic trace for snapshot tests and exit",
+		Long: "Emit one fully specified trace, with IDs derived from --seed and fixed timestamps, " +
+			"attributes and a failed child span, so tests get the same input on every run.",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if !emitTrace {
+				return errors.New("nothing to emit, pass --trace")
+			}
+			if err := opts.load(); err != nil {
+				return err
+			}
+			defer opts.stream.Close()
+			return emitFixtureTrace(cmd.Context(), opts.apply(Config{}), seed)
+		},
+	}
+	cmd.Flags().BoolVar(&emitTrace, "trace", false, "emit the fixture trace")
+	cmd.Flags().Int64Var(&seed, "seed", 1, "seed for the trace and span IDs")
+	return cmd
+}
+
+// emitFixtureTrace exports the fixture trace in a single request through
+// config's trace exporter, so it goes wherever --endpoint or --output
+// sends generated telemetry.
+func emitFixtureTrace(ctx context.Context, config Config, seed int64) error {
+	exporter, err := connectExporter(ctx, config, "traces", newTraceExporter)
+	if err != nil {
+		return fmt.Errorf("failed to create trace exporter: %w", err)
+	}
+	// A fixed resource, since the environment and SDK version would
+	// otherwise leak into it
+	res := resource.NewWithAttributes(semconv.SchemaURL,
+		semconv.ServiceName("otelgen"),
+		semconv.ServiceVersion("1.0.0"),
+		attribute.String("environment", "fixture"),
+	)
+	tp := sdktrace.NewTracerProvider(
+		sdktrace.WithResource(res),
+		sdktrace.WithIDGenerator(newSeededIDs(seed)),
+		sdktrace.WithSampler(sdktrace.AlwaysSample()),
+		sdktrace.WithBatcher(exporter),
+	)
+	tracer := tp.Tracer("otelgen")
+
+	at := func(offset time.Duration) time.Time { return fixtureStart.Add(offset) }
+	spanCtx, request := tracer.Start(ctx, fixtureRequest.Name,
+		trace.WithSpanKind(fixtureRequest.Kind),
+		trace.WithTimestamp(at(fixtureRequest.Start)),
+		trace.WithAttributes(fixtureRequest.Attrs...))
+	for _, s := range fixtureChildren {
+		_, child := tracer.Start(spanCtx, s.Name,
+			trace.WithSpanKind(s.Kind),
+			trace.WithTimestamp(at(s.Start)),
+			trace.WithAttributes(s.Attrs...))
+		if s.Failed != "" {
+			child.AddEvent(semconv.ExceptionEventName, trace.WithTimestamp(at(s.End)), trace.WithAttributes(
+				semconv.ExceptionType("*url.Error"),
+				semconv.ExceptionMessage(`Post "https://api.payments.example.com/v1/charges": context deadline exceeded`),
+				semconv.ExceptionEscaped(true),
+			))
+			child.SetStatus(codes.Error, s.Failed)
+		} else {
+			child.SetStatus(codes.Ok, "")
+		}
+		child.End(trace.WithTimestamp(at(s.End)))
+	}
+	request.SetStatus(codes.Error, fixtureRequest.Failed)
+	request.End(trace.WithTimestamp(at(fixtureRequest.End)))
+	traceID := request.SpanContext().TraceID()
+
+	// Shutting down flushes every span as one export request
+	if err := tp.Shutdown(ctx); err != nil {
+		return fmt.Errorf("failed to export the fixture trace: %w", err)
+	}
+	out.info(fmt.Sprintf("🧪 Emitted fixture trace %s with %d spans", traceID, len(fixtureChildren)+1),
+		"emitted fixture trace", "trace_id", traceID.String(), "spans", len(fixtureChildren)+1)
+	return nil
+}
@@ -0,0 +1,99 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"runtime"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	// heapCheckInterval is how often the heap guard reads the heap size.
+	// Reading it briefly stops the world, so it shouldn't be much more
+	// often.
+	heapCheckInterval = time.Second
+	// heapResumeShare is the share of --max-heap the heap must fall back
+	// under before delays return to normal, so shedding doesn't flap.
+	heapResumeShare = 0.8
+	// maxHeapSlowdown bounds how far delays are stretched.
+	maxHeapSlowdown = 16
+)
+
+// heapGuard sheds load while otelgen's heap is over --max-heap, stretching
+// the delays between traces and between log records. The stretch doubles
+// on every check the heap is still over the limit, and ends once it has
+// fallen well under it. A nil guard never sheds.
+type heapGuard struct {
+	limit    uint64
+	slowdown atomic.Int64
+}
+
+func newHeapGuard(limit int64) *heapGuard {
+	if limit <= 0 {
+		return nil
+	}
+	g := &heapGuard{limit: uint64(limit)}
+	g.slowdown.Store(1)
+	return g
+}
+
+// run checks the heap on every tick of c until ctx is done.
+func (g *heapGuard) run(ctx context.Context, c clock) {
+	if g == nil {
+		return
+	}
+	ticker := c.NewTicker(heapCheckInterval)
+	defer ticker.Stop()
+	var stats runtime.MemStats
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C():
+			runtime.ReadMemStats(&stats)
+			g.check(stats.HeapAlloc)
+		}
+	}
+}
+
+// check adjusts the slowdown for a heap of heap bytes.
+func (g *heapGuard) check(heap uint64) {
+	slowdown := g.slowdown.Load()
+	switch {
+	case heap > g.limit:
+		next := min(slowdown*2, maxHeapSlowdown)
+		if next == slowdown {
+			return
+		}
+		g.slowdown.Store(next)
+		out.warn(fmt.Sprintf("🐘 Heap at %s is over --max-heap %s, slowing traces and logs down %dx",
+			formatBytes(heap), formatBytes(g.limit), next),
+			"heap over limit, shedding load", "heap_bytes", heap, "max_heap_bytes", g.limit, "slowdown", next)
+	case slowdown > 1 && float64(heap) < heapResumeShare*float64(g.limit):
+		g.slowdown.Store(1)
+		out.info(fmt.Sprintf("🐘 Heap back down to %s, resuming the normal rates", formatBytes(heap)),
+			"heap under limit, resuming", "heap_bytes", heap, "max_heap_bytes", g.limit)
+	}
+}
+
+// stretch returns d lengthened by the current slowdown.
+func (g *heapGuard) stretch(d time.Duration) time.Duration {
+	if g == nil {
+		return d
+	}
+	return d * time.Duration(g.slowdown.Load())
+}
+
+// formatBytes formats n in the largest binary unit it fills.
+func formatBytes(n uint64) string {
+	switch {
+	case n >= 1<<30:
+		return fmt.Sprintf("%.1fGiB", float64(n)/(1<<30))
+	case n >= 1<<20:
+		return fmt.Sprintf("%.1fMiB", float64(n)/(1<<20))
+	case n >= 1<<10:
+		return fmt.Sprintf("%.1fKiB", float64(n)/(1<<10))
+	}
+	return fmt.Sprintf("%dB", n)
+}
@@ -0,0 +1,167 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"slices"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+)
+
+// latencyMetric is the histogram of request latencies, named after the
+// HTTP server semantic conventions.
+const latencyMetric = "http.server.request.duration"
+
+// autoBucketCount is how many buckets --auto-buckets spreads between the
+// smallest and largest warmup latency.
+const autoBucketCount = 16
+
+// defaultLatencyBuckets are the boundaries the semantic conventions
+// recommend for request durations in seconds.
+var defaultLatencyBuckets = []float64{0.005, 0.01, 0.025, 0.05, 0.075, 0.1, 0.25, 0.5, 0.75, 1, 2.5, 5, 7.5, 10}
+
+// latencySample is a latency seen during warmup, kept until the buckets
+// are known.
+type latencySample struct {
+	seconds float64
+	attrs   []attribute.KeyValue
+}
+
+// latencyHistogram records the duration of every generated request. With
+// a warmup it holds the first latencies back, derives bucket boundaries
+// from their range, and only then creates the instrument and records them,
+// so no measurement is lost. A nil latencyHistogram records nothing.
+type latencyHistogram struct {
+	meter  metric.Meter
+	meta   metricMeta
+	warmup int
+
+	mu      sync.Mutex
+	samples []latencySample
+	hist    metric.Float64Histogram
+}
+
+// newLatencyHistogram returns a histogram on meter using the default
+// buckets, or buckets derived from the first warmup latencies when warmup
+// is positive.
+func newLatencyHistogram(meter metric.Meter, warmup int, meta metricMeta) (*latencyHistogram, error) {
+	h := &latencyHistogram{meter: meter, meta: meta, warmup: warmup}
+	if warmup > 0 {
+		return h, nil
+	}
+	if err := h.create(defaultLatencyBuckets); err != nil {
+		return nil, err
+	}
+	return h, nil
+}
+
+func (h *latencyHistogram) create(bounds []float64) error {
+	hist, err := h.meter.Float64Histogram(h.meta.Name,
+		metric.WithDescription(h.meta.Description),
+		metric.WithUnit(h.meta.Unit),
+		metric.WithExplicitBucketBoundaries(bounds...))
+	if err != nil {
+		return fmt.Errorf("failed to create %s histogram: %w", h.meta.Name, err)
+	}
+	h.hist = hist
+	return nil
+}
+
+// record adds one request latency.
+func (h *latencyHistogram) record(ctx context.Context, latency time.Duration, attrs ...attribute.KeyValue) {
+	if h == nil {
+		return
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if h.hist != nil {
+		h.hist.Record(ctx, latency.Seconds(), metric.WithAttributes(attrs...))
+		return
+	}
+	h.samples = append(h.samples, latencySample{latency.Seconds(), attrs})
+	if len(h.samples) >= h.warmup {
+		h.flushWarmup(ctx)
+	}
+}
+
+// finish ends a warmup cut short by the end of the run, so the latencies
+// seen so far are still exported.
+func (h *latencyHistogram) finish(ctx context.Context) {
+	if h == nil {
+		return
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.hist == nil && len(h.samples) > 0 {
+		h.flushWarmup(ctx)
+	}
+}
+
+// flushWarmup derives the buckets from the warmup samples, creates the
+// instrument and records the samples. h.mu must be held.
+func (h *latencyHistogram) flushWarmup(ctx context.Context) {
+	seconds := make([]float64, len(h.samples))
+	for i, s := range h.samples {
+		seconds[i] = s.seconds
+	}
+	bounds := autoBuckets(seconds, autoBucketCount)
+	if err := h.create(bounds); err != nil {
+		out.warn(fmt.Sprintf("⚠️  %v", err), "latency histogram failed", "error", err.Error())
+		h.samples = nil
+		return
+	}
+	out.info(fmt.Sprintf("🪣 Derived %d latency buckets from %d requests: %s to %s",
+		len(bounds)+1, len(h.samples), formatSeconds(bounds[0]), formatSeconds(bounds[len(bounds)-1])),
+		"derived latency buckets", "samples", len(h.samples), "boundaries", bounds)
+	for _, s := range h.samples {
+		h.hist.Record(ctx, s.seconds, metric.WithAttributes(s.attrs...))
+	}
+	h.samples = nil
+}
+
+// autoBuckets returns n buckets spanning the range of samples, plus the
+// under- and overflow buckets. Boundaries grow geometrically, so short and
+// long latencies get the same relative resolution, and fall back to even
+// steps when the range starts at zero.
+func autoBuckets(samples []float64, n int) []float64 {
+	lo, hi := slices.Min(samples), slices.Max(samples)
+	if hi <= lo {
+		return []float64{lo}
+	}
+	bounds := make([]float64, 0, n+1)
+	for i := 0; i <= n; i++ {
+		var b float64
+		if lo > 0 {
+			b = lo * math.Pow(hi/lo, float64(i)/float64(n))
+		} else {
+			b = lo + (hi-lo)*float64(i)/float64(n)
+		}
+		// Microsecond precision is plenty and keeps the boundaries readable
+		b = math.Round(b*1e6) / 1e6
+		if len(bounds) == 0 || b > bounds[len(bounds)-1] {
+			bounds = append(bounds, b)
+		}
+	}
+	return bounds
+}
+
+func formatSeconds(s float64) string {
+	return time.Duration(s * float64(time.Second)).String()
+}
+
+// latencyTemporality exports histograms as deltas, so each export holds
+// only the requests since the previous one, and everything else with the
+// exporter's default cumulative temporality.
+func latencyTemporality(kind sdkmetric.InstrumentKind) metricdata.Temporality {
+	if kind == sdkmetric.InstrumentKindHistogram {
+		return metricdata.DeltaTemporality
+	}
+	return sdkmetric.DefaultTemporalitySelector(kind)
+}
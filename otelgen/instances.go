@@ -0,0 +1,234 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sync/atomic"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/log"
+	"go.opentelemetry.io/otel/metric"
+	sdklog "go.opentelemetry.io/otel/sdk/log"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.24.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// instance is one simulated replica of the service. Each exports through
+// its own providers under its own resource, so backends and the sonifier
+// can tell replicas apart.
+type instance struct {
+	host   string
+	tracer trace.Tracer
+	logger log.Logger
+
+	// The gauges are nil with --async-gauges, which reports them from a
+	// callback instead
+	cpuGauge, memoryGauge    metric.Float64Gauge
+	diskCounter, httpCounter metric.Int64Counter
+	latency                  *latencyHistogram
+	// diskTotal is the cumulative disk I/O that an observable counter
+	// reports in place of diskCounter with --counter-resets, which sets
+	// it back to zero now and then
+	diskTotal atomic.Int64
+
+	tp *sdktrace.TracerProvider
+	mp *sdkmetric.MeterProvider
+	lp *sdklog.LoggerProvider
+}
+
+// instancePool holds the simulated replicas. Traces and log records take
+// turns between them, and every replica reports its own metrics.
+type instancePool struct {
+	instances []*instance
+	next      atomic.Uint64
+}
+
+// exporters are the exporters shared by every instance's providers.
+type exporters struct {
+	spans   sdktrace.SpanExporter
+	metrics sdkmetric.Exporter
+	logs    sdklog.Exporter
+}
+
+// newInstancePool creates config.Instances replicas exporting to exp. A
+// single instance keeps the plain otelgen resource; with several, each
+// gets its own service.instance.id and host.name.
+func newInstancePool(ctx context.Context, config Config, exp exporters, load *systemLoad, stats *runStats) (*instancePool, error) {
+	pool := &instancePool{}
+	// Providers shut their exporter down with them, so they get views
+	// that leave the shared exporters open for the other instances
+	shared := exporters{
+		spans:   sharedSpanExporter{exp.spans},
+		metrics: sharedMetricExporter{exp.metrics},
+		logs:    sharedLogExporter{exp.logs},
+	}
+	for i := 0; i < max(config.Instances, 1); i++ {
+		inst, err := newInstance(ctx, config, i, shared, load, stats)
+		if err != nil {
+			pool.shutdown(ctx)
+			return nil, err
+		}
+		pool.instances = append(pool.instances, inst)
+	}
+	otel.SetTracerProvider(pool.instances[0].tp)
+	otel.SetMeterProvider(pool.instances[0].mp)
+	return pool, nil
+}
+
+func newInstance(ctx context.Context, config Config, i int, exp exporters, load *systemLoad, stats *runStats) (*instance, error) {
+	inst := &instance{host: fmt.Sprintf("app-server-%02d", i+1)}
+
+	options := []resource.Option{
+		resource.WithAttributes(
+			semconv.ServiceName("otelgen"),
+			semconv.ServiceVersion("1.0.0"),
+			attribute.String("load.level", getConfigName(config)),
+		),
+		// OTEL_RESOURCE_ATTRIBUTES and OTEL_SERVICE_NAME override the defaults above
+		resource.WithFromEnv(),
+	}
+	if config.Instances > 1 {
+		// Applied last so replicas stay distinct whatever the environment says
+		options = append(options, resource.WithAttributes(
+			semconv.ServiceInstanceID(fmt.Sprintf("otelgen-%d", i+1)),
+			semconv.HostName(inst.host),
+		))
+	}
+	res, err := resource.New(ctx, options...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create resource: %w", err)
+	}
+
+	// Setup providers with immediate export (no batching)
+	inst.tp = sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exp.spans,
+			sdktrace.WithBatchTimeout(1*time.Millisecond), // Export immediately
+			sdktrace.WithMaxExportBatchSize(1),            // One trace at a time
+			sdktrace.WithExportTimeout(100*time.Millisecond),
+		),
+		sdktrace.WithResource(res),
+	)
+	inst.mp = sdkmetric.NewMeterProvider(
+		sdkmetric.WithReader(sdkmetric.NewPeriodicReader(
+			exp.metrics,
+			sdkmetric.WithInterval(2*time.Second), // Export metrics every 2 seconds
+		)),
+		sdkmetric.WithResource(res),
+	)
+	inst.lp = sdklog.NewLoggerProvider(
+		sdklog.WithProcessor(sdklog.NewBatchProcessor(exp.logs)),
+		sdklog.WithResource(res),
+	)
+
+	// Create telemetry instruments
+	inst.tracer = inst.tp.Tracer("otelgen")
+	inst.logger = inst.lp.Logger("otelgen")
+	meter := inst.mp.Meter("otelgen")
+	if err := inst.createInstruments(meter, config, load, stats); err != nil {
+		inst.shutdown(ctx)
+		return nil, err
+	}
+	return inst, nil
+}
+
+func (inst *instance) createInstruments(meter metric.Meter, config Config, load *systemLoad, stats *runStats) error {
+	if config.AsyncGauges {
+		if err := registerUtilizationObservers(meter, config.Metrics, inst.host, load, &stats.metrics); err != nil {
+			return fmt.Errorf("failed to register observable gauges: %w", err)
+		}
+	} else {
+		cpu, memory := config.Metrics.get("system.cpu.utilization"), config.Metrics.get("system.memory.utilization")
+		inst.cpuGauge, _ = meter.Float64Gauge(cpu.Name,
+			metric.WithUnit(cpu.Unit), metric.WithDescription(cpu.Description))
+		inst.memoryGauge, _ = meter.Float64Gauge(memory.Name,
+			metric.WithUnit(memory.Unit), metric.WithDescription(memory.Description))
+	}
+	disk, requests := config.Metrics.get("system.disk.io"), config.Metrics.get("http.server.requests")
+	if config.CounterResets > 0 {
+		// A synchronous counter's sum can only grow, but the SDK passes an
+		// observed total through as is, drops included
+		device := metric.WithAttributes(attribute.String("device", "/dev/sda1"))
+		_, err := meter.Int64ObservableCounter(disk.Name,
+			metric.WithUnit(disk.Unit), metric.WithDescription(disk.Description),
+			metric.WithInt64Callback(func(_ context.Context, o metric.Int64Observer) error {
+				o.Observe(inst.diskTotal.Load(), device)
+				return nil
+			}))
+		if err != nil {
+			return fmt.Errorf("failed to register observable counter: %w", err)
+		}
+	} else {
+		inst.diskCounter, _ = meter.Int64Counter(disk.Name,
+			metric.WithUnit(disk.Unit), metric.WithDescription(disk.Description))
+	}
+	inst.httpCounter, _ = meter.Int64Counter(requests.Name,
+		metric.WithUnit(requests.Unit), metric.WithDescription(requests.Description))
+	if config.LatencyHistogram {
+		latency, err := newLatencyHistogram(meter, config.BucketWarmup, config.Metrics.get(latencyMetric))
+		if err != nil {
+			return err
+		}
+		inst.latency = latency
+	}
+	return nil
+}
+
+// addDiskIO counts n bytes of disk I/O.
+func (inst *instance) addDiskIO(ctx context.Context, n int64) {
+	if inst.diskCounter == nil {
+		inst.diskTotal.Add(n)
+		return
+	}
+	inst.diskCounter.Add(ctx, n, metric.WithAttributes(attribute.String("device", "/dev/sda1")))
+}
+
+// resetDiskIO sets the disk I/O total back to zero, as a restarted
+// process would count it, and returns what it was.
+func (inst *instance) resetDiskIO() int64 {
+	return inst.diskTotal.Swap(0)
+}
+
+// pick returns the instance whose turn it is.
+func (p *instancePool) pick() *instance {
+	return p.instances[(p.next.Add(1)-1)%uint64(len(p.instances))]
+}
+
+// finish ends every latency histogram's warmup.
+func (p *instancePool) finish(ctx context.Context) {
+	for _, inst := range p.instances {
+		inst.latency.finish(ctx)
+	}
+}
+
+// shutdown flushes and stops every instance's providers. The shared
+// exporters stay open.
+func (p *instancePool) shutdown(ctx context.Context) {
+	for _, inst := range p.instances {
+		inst.shutdown(ctx)
+	}
+}
+
+func (inst *instance) shutdown(ctx context.Context) {
+	inst.tp.Shutdown(ctx)
+	inst.mp.Shutdown(ctx)
+	inst.lp.Shutdown(ctx)
+}
+
+// sharedSpanExporter, sharedMetricExporter and sharedLogExporter ignore
+// Shutdown, leaving the exporter to whoever created it.
+type sharedSpanExporter struct{ sdktrace.SpanExporter }
+
+func (sharedSpanExporter) Shutdown(context.Context) error { return nil }
+
+type sharedMetricExporter struct{ sdkmetric.Exporter }
+
+func (sharedMetricExporter) Shutdown(context.Context) error { return nil }
+
+type sharedLogExporter struct{ sdklog.Exporter }
+
+func (sharedLogExporter) Shutdown(context.Context) error { return nil }
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+)
+
+// metricMeta is the name, unit and description a generated metric is
+// created with, so backends can label and scale it. Name is the exported
+// name, which differs from the catalog key with --metric-prefix.
+type metricMeta struct {
+	Name        string
+	Unit        string
+	Description string
+}
+
+// metricCatalog maps each generated metric's name to its metadata.
+type metricCatalog map[string]metricMeta
+
+// defaultMetricMeta follows the semantic conventions' units: "1" for
+// ratios, "By" for bytes, "s" for durations and a {annotation} for counts.
+var defaultMetricMeta = metricCatalog{
+	"system.cpu.utilization":    {Unit: "1", Description: "Fraction of CPU time in use, from 0 to 1."},
+	"system.memory.utilization": {Unit: "1", Description: "Fraction of memory in use, from 0 to 1."},
+	"system.disk.io":            {Unit: "By", Description: "Bytes transferred to and from disk."},
+	"http.server.requests":      {Unit: "{request}", Description: "Number of HTTP server requests handled."},
+	latencyMetric:               {Unit: "s", Description: "Duration of generated HTTP server requests."},
+}
+
+// get returns the metadata of the named metric.
+func (c metricCatalog) get(name string) metricMeta {
+	meta, ok := c[name]
+	if !ok {
+		meta = defaultMetricMeta[name]
+	}
+	if meta.Name == "" {
+		meta.Name = name
+	}
+	return meta
+}
+
+// newMetricCatalog prepends --metric-prefix to every metric name and
+// applies --metric-unit and --metric-description overrides, keyed by the
+// unprefixed metric name, to the defaults.
+func newMetricCatalog(prefix string, units, descriptions map[string]string) (metricCatalog, error) {
+	if err := checkMetricPrefix(prefix); err != nil {
+		return nil, err
+	}
+	catalog := maps.Clone(defaultMetricMeta)
+	for name, meta := range catalog {
+		meta.Name = prefix + name
+		catalog[name] = meta
+	}
+	for name, unit := range units {
+		meta, err := catalog.lookup(name, "--metric-unit")
+		if err != nil {
+			return nil, err
+		}
+		meta.Unit = unit
+		catalog[name] = meta
+	}
+	for name, description := range descriptions {
+		meta, err := catalog.lookup(name, "--metric-description")
+		if err != nil {
+			return nil, err
+		}
+		meta.Description = description
+		catalog[name] = meta
+	}
+	return catalog, nil
+}
+
+// checkMetricPrefix rejects prefixes that would make instrument names
+// invalid: names start with a letter and hold letters, digits, '_', '.',
+// '-' and '/'.
+func checkMetricPrefix(prefix string) error {
+	for i, r := range prefix {
+		letter := r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z'
+		if i == 0 && !letter {
+			return fmt.Errorf("--metric-prefix %q must start with a letter", prefix)
+		}
+		if !letter && !(r >= '0' && r <= '9') && !strings.ContainsRune("_.-/", r) {
+			return fmt.Errorf("--metric-prefix %q may only contain letters, digits, '_', '.', '-' and '/'", prefix)
+		}
+	}
+	return nil
+}
+
+func (c metricCatalog) lookup(name, flag string) (metricMeta, error) {
+	meta, ok := c[name]
+	if !ok {
+		return meta, fmt.Errorf("unknown metric %q in %s, expected one of %s",
+			name, flag, strings.Join(slices.Sorted(maps.Keys(c)), ", "))
+	}
+	return meta, nil
+}
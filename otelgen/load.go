@@ -0,0 +1,139 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	// spikeDuration is how long a resource-contention spike lasts.
+	spikeDuration = 15 * time.Second
+	// spikeUtilization is the CPU and memory utilization during a spike.
+	spikeUtilization = 0.95
+	// spikeLatencyFactor is how many times slower spans get at full
+	// contention.
+	spikeLatencyFactor = 4.0
+	// spikeErrorShare is the share of otherwise successful requests that
+	// fail at full contention.
+	spikeErrorShare = 0.5
+
+	// defaultLeakPeriod is how long a leak takes to reach the ceiling when
+	// the run has no duration.
+	defaultLeakPeriod = 10 * time.Minute
+	// defaultSawtoothPeriod is how long each sawtooth climb takes.
+	defaultSawtoothPeriod = time.Minute
+)
+
+// Memory trends for --memory-trend.
+const (
+	memoryStable   = "stable"
+	memoryLeak     = "leak"
+	memorySawtooth = "sawtooth"
+)
+
+// systemLoad is the simulated utilization shared by the generators. The
+// metric generator updates it on every tick, and the trace generator reads
+// it so spans slow down and fail more while the system is under pressure.
+type systemLoad struct {
+	cpu        atomic.Uint64 // math.Float64bits of the CPU utilization
+	memory     atomic.Uint64 // math.Float64bits of the memory utilization
+	spikeUntil time.Time     // only touched by the metric generator
+	blipUntil  atomic.Int64  // unix nanoseconds until a deployment blip ends
+
+	// started is when the memory trend began, and cycle the number of
+	// completed climbs; both only touched by the metric generator
+	started time.Time
+	cycle   int
+}
+
+func newSystemLoad(config Config) *systemLoad {
+	l := &systemLoad{started: config.clock.Now()}
+	cpu, memory := utilization(config)
+	l.store(cpu, memory)
+	return l
+}
+
+func (l *systemLoad) store(cpu, memory float64) {
+	l.cpu.Store(math.Float64bits(cpu))
+	l.memory.Store(math.Float64bits(memory))
+}
+
+// utilization returns the current CPU and memory utilization.
+func (l *systemLoad) utilization() (cpu, memory float64) {
+	return math.Float64frombits(l.cpu.Load()), math.Float64frombits(l.memory.Load())
+}
+
+// update applies the memory trend, starts or ends spikes and stores the
+// resulting utilization. With --spikes, a spike starts on each tick with a
+// probability that averages one per interval.
+func (l *systemLoad) update(config Config, tick time.Duration, now time.Time) (cpu, memory float64) {
+	cpu, memory = utilization(config)
+	memory = l.trend(config, memory, now)
+	if config.SpikeEvery > 0 {
+		if now.After(l.spikeUntil) && rand.Float64() < float64(tick)/float64(config.SpikeEvery) {
+			l.spikeUntil = now.Add(spikeDuration)
+			out.info(fmt.Sprintf("🔥 Resource contention spike for %v", spikeDuration),
+				"resource contention spike", "duration", spikeDuration.String())
+		}
+		if now.Before(l.spikeUntil) {
+			cpu, memory = max(cpu, spikeUtilization), max(memory, spikeUtilization)
+		}
+	}
+	l.store(cpu, memory)
+	return cpu, memory
+}
+
+// trend returns the memory utilization the --memory-trend puts at now,
+// starting from the preset's baseline. A leak climbs steadily to the
+// ceiling and stays there, or with --oom-restart drops back to the
+// baseline as if the process had been killed and restarted, and climbs
+// again. A sawtooth climbs and drops the same way, on a shorter period,
+// like a garbage-collected heap.
+func (l *systemLoad) trend(config Config, baseline float64, now time.Time) float64 {
+	ceiling := config.MemoryCeiling / 100
+	if config.MemoryTrend == memoryStable || config.MemoryTrend == "" || ceiling <= baseline {
+		return baseline
+	}
+	period := memoryPeriod(config)
+	progress := float64(now.Sub(l.started)) / float64(period)
+	if config.MemoryTrend == memoryLeak && !config.OOMRestart {
+		return baseline + (ceiling-baseline)*min(progress, 1)
+	}
+
+	cycle := int(progress)
+	if cycle > l.cycle {
+		l.cycle = cycle
+		if config.MemoryTrend == memoryLeak {
+			out.info(fmt.Sprintf("💥 Memory reached %.0f%%, simulating an out-of-memory restart", config.MemoryCeiling),
+				"out of memory restart", "ceiling", config.MemoryCeiling, "restarts", cycle)
+		}
+	}
+	return baseline + (ceiling-baseline)*(progress-float64(cycle))
+}
+
+// memoryPeriod returns how long one climb of the memory trend takes.
+func memoryPeriod(config Config) time.Duration {
+	switch {
+	case config.MemoryPeriod > 0:
+		return config.MemoryPeriod
+	case config.MemoryTrend == memorySawtooth:
+		return defaultSawtoothPeriod
+	case config.Duration > 0:
+		return config.Duration
+	}
+	return defaultLeakPeriod
+}
+
+// contention returns how far CPU utilization is above the preset's
+// baseline, from 0 at the baseline to 1 when saturated.
+func (l *systemLoad) contention(config Config) float64 {
+	baseline, _ := utilization(config)
+	if baseline >= 1 {
+		return 0
+	}
+	cpu, _ := l.utilization()
+	return max(0, min(1, (cpu-baseline)/(1-baseline)))
+}
@@ -5,27 +5,23 @@ import (
 	"fmt"
 	"math/rand"
 	"os"
+	"os/signal"
 	"strings"
+	"sync"
+	"syscall"
 	"time"
 
 	"github.com/spf13/cobra"
-	"go.opentelemetry.io/otel"
 	"go.opentelemetry.io/otel/attribute"
 	"go.opentelemetry.io/otel/codes"
-	"go.opentelemetry.io/otel/exporters/otlp/otlplog/otlploggrpc"
-	"go.opentelemetry.io/otel/exporters/otlp/otlpmetric/otlpmetricgrpc"
-	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracegrpc"
 	"go.opentelemetry.io/otel/log"
 	"go.opentelemetry.io/otel/metric"
-	sdklog "go.opentelemetry.io/otel/sdk/log"
-	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
-	"go.opentelemetry.io/otel/sdk/resource"
-	sdktrace "go.opentelemetry.io/otel/sdk/trace"
 	semconv "go.opentelemetry.io/otel/semconv/v1.24.0"
 	"go.opentelemetry.io/otel/trace"
 )
 
 type Config struct {
+	Name         string
 	Duration     time.Duration
 	TraceRate    time.Duration
 	MetricRate   time.Duration
@@ -35,56 +31,385 @@ type Config struct {
 	MaxCPU       float64
 	MaxMemory    float64
 	MaxDiskIO    float64
-	Endpoint     string
+	// Endpoints is the collector address of each signal
+	Endpoints    endpoints
 	Insecure     bool
+	Operations   []operation
+	AsyncGauges  bool
+	MinLatency   time.Duration
+	MaxLatency   time.Duration
+	ClockSkew    time.Duration
+	Dependencies []dependency
+	Tenants      []tenant
+	SpikeEvery   time.Duration
+	// CounterResets sets each replica's system.disk.io total back to zero
+	// about this often, like a restarted process; zero never does
+	CounterResets time.Duration
+	MaxTraces     int
+	MaxLogs       int
+	MaxBytes      int64
+	// MaxHeap is the heap size in bytes above which traces and logs slow
+	// down until it falls again; zero never sheds
+	MaxHeap int64
+	// OutputFormat is the format of the run summary: table, json or prom
+	OutputFormat string
+	// MemoryTrend shapes memory utilization over the run: stable, leak or
+	// sawtooth, climbing from MaxMemory to MemoryCeiling percent over
+	// MemoryPeriod
+	MemoryTrend   string
+	MemoryCeiling float64
+	MemoryPeriod  time.Duration
+	OOMRestart    bool
+	// LatencyHistogram emits request latencies as a delta histogram
+	LatencyHistogram bool
+	// BucketWarmup derives the histogram's buckets from this many
+	// requests; zero uses fixed buckets
+	BucketWarmup int
+	// Metrics holds the unit and description of each generated metric
+	Metrics metricCatalog
+	// Instances is how many replicas, each with its own resource, take
+	// turns emitting traces and logs
+	Instances int
+	// ClockSkewMode is run, offsetting every span by the same ClockSkew,
+	// or span, also skewing each child span's start and end on its own
+	ClockSkewMode string
+	// Protocols is the OTLP transport, grpc or http, of each signal
+	Protocols protocols
+	// DeployAt are the offsets from the start of the run at which
+	// deployment markers are emitted, each followed by a DeployBlip of
+	// slower, failing requests
+	DeployAt   []time.Duration
+	DeployBlip time.Duration
+	// SemanticSpans gives dependency spans database or HTTP client
+	// attributes drawn from these pools; nil leaves them plain
+	SemanticSpans *semanticPools
+	// ExceptionDetails gives failed spans an exception event with a
+	// realistic type, message and stack trace
+	ExceptionDetails bool
+	// Arrival is the distribution of the gaps between traces and between
+	// log records: uniform, poisson or constant. Empty keeps uniform gaps
+	// between traces and logs on a steady tick
+	Arrival string
+	// SpanLatencies replaces the MinLatency to MaxLatency timing of the
+	// span kinds it has a profile for
+	SpanLatencies kindLatencies
+	// ConnectRetry is how long to keep retrying, with backoff, until the
+	// collector accepts connections; zero starts without waiting for it
+	ConnectRetry time.Duration
+
+	// bench times every export and ends the run with a report of the
+	// achieved rates instead of the summary
+	bench     bool
+	heap      *heapGuard
+	decisions *decider
+	// stream replaces the collector connection when --output is set
+	stream *otlpStream
+	// clock drives the generators; nil means the wall clock
+	clock clock
+	// prom collects a --schedule's Prometheus summaries, which are printed
+	// together once the schedule stops
+	prom *promReport
+}
+
+// options holds flags shared by all presets.
+type options struct {
+	Quiet          bool
+	LogFormat      string
+	Schedule       string
+	OperationsFile string
+	RecordScript   string
+	Script         string
+	AsyncGauges    bool
+	MinLatency     time.Duration
+	MaxLatency     time.Duration
+	ClockSkew      time.Duration
+	Dependencies   string
+	Tenants        string
+	SpikeEvery     time.Duration
+	CounterResets  time.Duration
+	MaxTraces      int
+	MaxLogs        int
+	MaxBytes       string
+	MaxHeap        string
+	Endpoint       string
+	Insecure       bool
+
+	LatencyHistogram bool
+	AutoBuckets      bool
+	BucketWarmup     int
+	Output           string
+	OutputEncoding   string
+	OutputFormat     string
+	MemoryTrend      string
+	MemoryCeiling    float64
+	MemoryPeriod     time.Duration
+	OOMRestart       bool
+	ClockSkewMode    string
+	Instances        int
+	Protocol         string
+	TracesProtocol   string
+	MetricsProtocol  string
+	LogsProtocol     string
+	TracesEndpoint   string
+	MetricsEndpoint  string
+	LogsEndpoint     string
+	DeployAt         []time.Duration
+	DeployBlip       time.Duration
+
+	MetricUnits        map[string]string
+	MetricDescriptions map[string]string
+	MetricPrefix       string
+
+	SemanticSpans    bool
+	ExceptionDetails bool
+	DBStatements     []string
+	DBNames          []string
+	ExternalRequests []string
+	SpanLatency      []string
+	Arrival          string
+	ConnectRetry     time.Duration
+
+	endpoints    endpoints
+	maxBytes     int64
+	maxHeap      int64
+	operations   []operation
+	dependencies []dependency
+	tenants      []tenant
+	metrics      metricCatalog
+	protocols    protocols
+	semantic     *semanticPools
+	latencies    kindLatencies
+	decisions    *decider
+	stream       *otlpStream
+}
+
+var opts options
+
+// load reads any files referenced by flags.
+func (o *options) load() error {
+	if o.OperationsFile != "" {
+		ops, err := loadOperations(o.OperationsFile)
+		if err != nil {
+			return err
+		}
+		o.operations = ops
+	}
+	if o.MinLatency <= 0 || o.MaxLatency < o.MinLatency {
+		return fmt.Errorf("--min-latency must be positive and no greater than --max-latency")
+	}
+	if o.ClockSkew < 0 {
+		return fmt.Errorf("--clock-skew must not be negative")
+	}
+	switch o.ClockSkewMode {
+	case clockSkewRun:
+	case clockSkewSpan:
+		if o.ClockSkew == 0 {
+			return fmt.Errorf("--clock-skew-mode span requires --clock-skew")
+		}
+	default:
+		return fmt.Errorf("unknown --clock-skew-mode %q, expected run or span", o.ClockSkewMode)
+	}
+	for _, at := range o.DeployAt {
+		if at < 0 {
+			return fmt.Errorf("--deploy-at must not be negative, got %v", at)
+		}
+	}
+	if o.DeployBlip < 0 {
+		return fmt.Errorf("--deploy-blip must not be negative")
+	}
+	if o.Instances < 1 {
+		return fmt.Errorf("--instances must be at least 1")
+	}
+	if o.SpikeEvery < 0 {
+		return fmt.Errorf("--spikes must not be negative")
+	}
+	if o.CounterResets < 0 {
+		return fmt.Errorf("--counter-resets must not be negative")
+	}
+	switch o.MemoryTrend {
+	case memoryStable, memoryLeak, memorySawtooth:
+	default:
+		return fmt.Errorf("unknown --memory-trend %q, expected stable, leak or sawtooth", o.MemoryTrend)
+	}
+	if o.MemoryCeiling <= 0 || o.MemoryCeiling > 100 {
+		return fmt.Errorf("--memory-ceiling must be between 0 and 100")
+	}
+	if o.MemoryPeriod < 0 {
+		return fmt.Errorf("--memory-period must not be negative")
+	}
+	if o.AutoBuckets && o.BucketWarmup <= 0 {
+		return fmt.Errorf("--bucket-warmup must be positive with --auto-buckets")
+	}
+	switch o.Arrival {
+	case "", arrivalUniform, arrivalPoisson, arrivalConstant:
+	default:
+		return fmt.Errorf("unknown --arrival-distribution %q, expected poisson, uniform or constant", o.Arrival)
+	}
+	if o.MaxTraces < 0 || o.MaxLogs < 0 {
+		return fmt.Errorf("--max-traces and --max-logs must not be negative")
+	}
+	resolved, err := resolveProtocols(o.Protocol, protocols{Traces: o.TracesProtocol, Metrics: o.MetricsProtocol, Logs: o.LogsProtocol})
+	if err != nil {
+		return err
+	}
+	o.protocols = resolved
+	o.endpoints, err = resolveEndpoints(o.Endpoint, o.Protocol, endpoints{Traces: o.TracesEndpoint, Metrics: o.MetricsEndpoint, Logs: o.LogsEndpoint},
+		o.protocols, o.Insecure)
+	if err != nil {
+		return err
+	}
+	if o.ConnectRetry < 0 {
+		return fmt.Errorf("--connect-retry must not be negative")
+	}
+	maxBytes, err := parseByteSize(o.MaxBytes)
+	if err != nil {
+		return fmt.Errorf("--max-bytes: %w", err)
+	}
+	o.maxBytes = maxBytes
+	if o.maxHeap, err = parseByteSize(o.MaxHeap); err != nil {
+		return fmt.Errorf("--max-heap: %w", err)
+	}
+	deps, err := parseDependencies(o.Dependencies)
+	if err != nil {
+		return err
+	}
+	o.dependencies = deps
+	if o.SemanticSpans {
+		if o.semantic, err = newSemanticPools(o.DBStatements, o.DBNames, o.ExternalRequests); err != nil {
+			return err
+		}
+	}
+	if o.latencies, err = parseSpanLatencies(o.SpanLatency); err != nil {
+		return err
+	}
+	if o.metrics, err = newMetricCatalog(o.MetricPrefix, o.MetricUnits, o.MetricDescriptions); err != nil {
+		return err
+	}
+	tenants, err := parseTenants(o.Tenants)
+	if err != nil {
+		return err
+	}
+	o.tenants = tenants
+	if o.RecordScript != "" && o.Script != "" {
+		return fmt.Errorf("--record-script and --script cannot be used together")
+	}
+	if o.RecordScript != "" {
+		d, err := newRecorder(o.RecordScript)
+		if err != nil {
+			return err
+		}
+		o.decisions = d
+	}
+	if o.Script != "" {
+		d, err := newReplayer(o.Script)
+		if err != nil {
+			return err
+		}
+		o.decisions = d
+	}
+	if o.OutputEncoding != "json" && o.OutputEncoding != "proto" {
+		return fmt.Errorf("unknown --output-encoding %q, expected json or proto", o.OutputEncoding)
+	}
+	switch o.OutputFormat {
+	case summaryTable, summaryJSON, summaryProm:
+	default:
+		return fmt.Errorf("unknown --output-format %q, expected table, json or prom", o.OutputFormat)
+	}
+	if o.Output != "" {
+		stream, err := openStream(o.Output, o.OutputEncoding)
+		if err != nil {
+			return err
+		}
+		o.stream = stream
+	}
+	return nil
+}
+
+// apply overlays flag values onto a preset.
+func (o *options) apply(config Config) Config {
+	if o.operations != nil {
+		config.Operations = o.operations
+	}
+	config.decisions = o.decisions
+	config.stream = o.stream
+	config.clock = realClock{}
+	config.AsyncGauges = o.AsyncGauges
+	config.MinLatency, config.MaxLatency = o.MinLatency, o.MaxLatency
+	config.SpanLatencies = o.latencies
+	config.Arrival = o.Arrival
+	config.ClockSkew, config.ClockSkewMode = o.ClockSkew, o.ClockSkewMode
+	config.Dependencies = o.dependencies
+	config.SemanticSpans = o.semantic
+	config.ExceptionDetails = o.ExceptionDetails
+	config.Tenants = o.tenants
+	config.Metrics = o.metrics
+	config.Instances = o.Instances
+	config.SpikeEvery = o.SpikeEvery
+	config.CounterResets = o.CounterResets
+	config.MemoryTrend, config.MemoryCeiling = o.MemoryTrend, o.MemoryCeiling
+	config.MemoryPeriod, config.OOMRestart = o.MemoryPeriod, o.OOMRestart
+	config.MaxTraces, config.MaxLogs = o.MaxTraces, o.MaxLogs
+	config.MaxBytes = o.maxBytes
+	config.MaxHeap = o.maxHeap
+	config.OutputFormat = o.OutputFormat
+	config.Endpoints, config.Insecure = o.endpoints, o.Insecure
+	config.ConnectRetry = o.ConnectRetry
+	config.Protocols = o.protocols
+	config.DeployAt, config.DeployBlip = o.DeployAt, o.DeployBlip
+	config.LatencyHistogram = o.LatencyHistogram || o.AutoBuckets
+	if o.AutoBuckets {
+		config.BucketWarmup = o.BucketWarmup
+	}
+	return config
 }
 
 var (
 	lowConfig = Config{
+		Name:         "Low",
 		Duration:     30 * time.Second,
 		TraceRate:    5000 * time.Millisecond, // 0.2 traces/sec (just a handful)
-		MetricRate:   5 * time.Second,  
+		MetricRate:   5 * time.Second,
 		LogRate:      3 * time.Second,
 		ErrorRate:    0.05,
 		HighSeverity: 0.1,
-		MaxCPU:       10.0,  // Constant 10%
-		MaxMemory:    10.0,  // Constant 10%
-		MaxDiskIO:    10.0,  // Constant 10%
-		Endpoint:     "localhost:4317",
+		MaxCPU:       10.0, // Constant 10%
+		MaxMemory:    10.0, // Constant 10%
+		MaxDiskIO:    10.0, // Constant 10%
 		Insecure:     true,
 	}
-	
+
 	mediumConfig = Config{
+		Name:         "Medium",
 		Duration:     60 * time.Second,
-		TraceRate:    100 * time.Millisecond,  // 10 traces/sec 
+		TraceRate:    100 * time.Millisecond, // 10 traces/sec
 		MetricRate:   2 * time.Second,
 		LogRate:      1 * time.Second,
 		ErrorRate:    0.15,
 		HighSeverity: 0.3,
-		MaxCPU:       30.0,  // Constant 30%
-		MaxMemory:    30.0,  // Constant 30%
-		MaxDiskIO:    30.0,  // Constant 30%
-		Endpoint:     "localhost:4317",
+		MaxCPU:       30.0, // Constant 30%
+		MaxMemory:    30.0, // Constant 30%
+		MaxDiskIO:    30.0, // Constant 30%
 		Insecure:     true,
 	}
-	
+
 	highConfig = Config{
+		Name:         "High",
 		Duration:     90 * time.Second,
-		TraceRate:    10 * time.Millisecond,   // 100 traces/sec
+		TraceRate:    10 * time.Millisecond, // 100 traces/sec
 		MetricRate:   500 * time.Millisecond,
 		LogRate:      200 * time.Millisecond,
 		ErrorRate:    0.35,
 		HighSeverity: 0.6,
-		MaxCPU:       60.0,  // Constant 60%
-		MaxMemory:    60.0,  // Constant 60%
-		MaxDiskIO:    60.0,  // Constant 60%
-		Endpoint:     "localhost:4317",
+		MaxCPU:       60.0, // Constant 60%
+		MaxMemory:    60.0, // Constant 60%
+		MaxDiskIO:    60.0, // Constant 60%
 		Insecure:     true,
 	}
 
 	stressConfig = Config{
+		Name:         "Stress",
 		Duration:     120 * time.Second,
-		TraceRate:    1 * time.Millisecond,    // 1000 traces/sec (maximum)
+		TraceRate:    1 * time.Millisecond, // 1000 traces/sec (maximum)
 		MetricRate:   500 * time.Millisecond,
 		LogRate:      100 * time.Millisecond,
 		ErrorRate:    0.5,
@@ -92,7 +417,6 @@ var (
 		MaxCPU:       100.0, // Constant 100%
 		MaxMemory:    100.0, // Constant 100%
 		MaxDiskIO:    100.0, // Constant 100%
-		Endpoint:     "localhost:4317",
 		Insecure:     true,
 	}
 )
@@ -103,206 +427,500 @@ func main() {
 		Short: "Generate OpenTelemetry data at various load levels",
 		Long:  "A utility to generate traces, metrics, and logs for system stress testing",
 	}
+	rootCmd.PersistentPreRunE = func(cmd *cobra.Command, args []string) error {
+		return out.setup(opts.LogFormat, opts.Quiet)
+	}
+	rootCmd.PersistentFlags().BoolVar(&opts.Quiet, "quiet", false,
+		"suppress startup, progress and shutdown messages")
+	rootCmd.PersistentFlags().StringVar(&opts.LogFormat, "log-format", "text",
+		"message format: text for friendly output or json for structured logs")
+	rootCmd.PersistentFlags().StringVar(&opts.Schedule, "schedule", "",
+		`quiet hours as comma-separated windows, e.g. "22:00-07:00=low,12:00-13:00=idle"`)
+	rootCmd.PersistentFlags().StringVar(&opts.OperationsFile, "operations-file", "",
+		"file listing simulated operations, one \"METHOD /route [error_rate=N]\" per line")
+	rootCmd.PersistentFlags().StringVar(&opts.RecordScript, "record-script", "",
+		"record every trace and log decision to this file for later replay")
+	rootCmd.PersistentFlags().StringVar(&opts.Script, "script", "",
+		"replay trace and log decisions from a file written by --record-script")
+	rootCmd.PersistentFlags().StringVar(&opts.Dependencies, "dependencies", defaultDependencies,
+		"downstream systems requests call, as comma-separated name=host:port; empty disables them")
+	rootCmd.PersistentFlags().BoolVar(&opts.SemanticSpans, "semantic-spans", false,
+		"give dependency spans semantic convention attributes: db.system, db.name and db.statement for databases, url.full and http.response.status_code for other calls")
+	rootCmd.PersistentFlags().BoolVar(&opts.ExceptionDetails, "exception-details", false,
+		"record failed requests as exceptions with a realistic exception.type, exception.message and exception.stacktrace")
+	rootCmd.PersistentFlags().StringArrayVar(&opts.DBStatements, "db-statement", nil,
+		`with --semantic-spans, a statement for one database system as system=statement, e.g. "redis=GET user:?"; repeatable, replacing that system's defaults`)
+	rootCmd.PersistentFlags().StringSliceVar(&opts.DBNames, "db-names", strings.Split(defaultDBNames, ","),
+		"with --semantic-spans, database names to draw db.name from")
+	rootCmd.PersistentFlags().StringSliceVar(&opts.ExternalRequests, "external-requests", strings.Split(defaultExternalRequests, ","),
+		`with --semantic-spans, requests to draw external calls from, as "METHOD /path"`)
+	rootCmd.PersistentFlags().StringVar(&opts.Endpoint, "endpoint", "",
+		"collector address as host:port; defaults to localhost:4317 for gRPC and localhost:4318 for HTTP. Signals using another protocol than --protocol keep its host but use their protocol's standard port")
+	rootCmd.PersistentFlags().StringVar(&opts.TracesEndpoint, "traces-endpoint", "",
+		"collector address for traces as host:port, overriding --endpoint")
+	rootCmd.PersistentFlags().StringVar(&opts.MetricsEndpoint, "metrics-endpoint", "",
+		"collector address for metrics as host:port, overriding --endpoint")
+	rootCmd.PersistentFlags().StringVar(&opts.LogsEndpoint, "logs-endpoint", "",
+		"collector address for logs as host:port, overriding --endpoint")
+	rootCmd.PersistentFlags().StringVar(&opts.Protocol, "protocol", protocolGRPC,
+		"OTLP transport for every signal: grpc or http")
+	rootCmd.PersistentFlags().StringVar(&opts.TracesProtocol, "traces-protocol", "",
+		"OTLP transport for traces, overriding --protocol: grpc or http")
+	rootCmd.PersistentFlags().StringVar(&opts.MetricsProtocol, "metrics-protocol", "",
+		"OTLP transport for metrics, overriding --protocol: grpc or http")
+	rootCmd.PersistentFlags().StringVar(&opts.LogsProtocol, "logs-protocol", "",
+		"OTLP transport for logs, overriding --protocol: grpc or http")
+	rootCmd.PersistentFlags().DurationSliceVar(&opts.DeployAt, "deploy-at", nil,
+		"emit a deployment marker log this long after the run starts, e.g. 30s; repeatable or comma-separated")
+	rootCmd.PersistentFlags().DurationVar(&opts.DeployBlip, "deploy-blip", 10*time.Second,
+		"after each --deploy-at marker, slow requests down and fail more of them for this long; 0 disables")
+	rootCmd.PersistentFlags().BoolVar(&opts.Insecure, "insecure", true,
+		"connect without TLS; pass --insecure=false for collectors that require it")
+	rootCmd.PersistentFlags().DurationVar(&opts.ConnectRetry, "connect-retry", 0,
+		"at startup, wait up to this long for the collector to accept connections, retrying with backoff; 0 starts without waiting")
+	rootCmd.PersistentFlags().StringVar(&opts.Output, "output", "",
+		"write OTLP export requests to this file, or - for stdout, instead of sending them to --endpoint")
+	rootCmd.PersistentFlags().StringVar(&opts.OutputEncoding, "output-encoding", "json",
+		"encoding for --output: json for one OTLP JSON request per line or proto for length-delimited protobuf")
+	rootCmd.PersistentFlags().StringVar(&opts.OutputFormat, "output-format", summaryTable,
+		"run summary format: table, json for one JSON object, or prom for the Prometheus text format; json and prom are printed even with --quiet")
+	rootCmd.PersistentFlags().StringVar(&opts.Tenants, "tenants", "",
+		`weighted tenants to tag spans and logs with as tenant.id, e.g. "acme=5,globex=2:0.2" (name=weight[:error_rate]), or @file`)
+	rootCmd.PersistentFlags().BoolVar(&opts.AsyncGauges, "async-gauges", false,
+		"report CPU and memory through observable gauges read on each collection cycle")
+	rootCmd.PersistentFlags().DurationVar(&opts.MinLatency, "min-latency", time.Millisecond,
+		"shortest simulated processing time per span")
+	rootCmd.PersistentFlags().DurationVar(&opts.MaxLatency, "max-latency", 200*time.Millisecond,
+		"longest simulated processing time per span")
+	rootCmd.PersistentFlags().StringVar(&opts.Arrival, "arrival-distribution", "",
+		"distribution of the gaps between traces and between log records: poisson for bursty arrivals, uniform or constant; by default traces are uniform and logs steady")
+	rootCmd.PersistentFlags().StringArrayVar(&opts.SpanLatency, "span-latency", nil,
+		`latency of one span kind as kind=base[+jitter], e.g. "external=40ms+120ms", with jitter drawn from an exponential distribution; kind is server, internal, db, external or client for both; repeatable`)
+	rootCmd.PersistentFlags().BoolVar(&opts.LatencyHistogram, "latency-histogram", false,
+		"emit request latencies as the delta histogram "+latencyMetric)
+	rootCmd.PersistentFlags().BoolVar(&opts.AutoBuckets, "auto-buckets", false,
+		"derive the latency histogram's buckets from the range of the first --bucket-warmup requests; implies --latency-histogram")
+	rootCmd.PersistentFlags().IntVar(&opts.BucketWarmup, "bucket-warmup", 50,
+		"requests to sample before deriving buckets with --auto-buckets")
+	rootCmd.PersistentFlags().IntVar(&opts.MaxTraces, "max-traces", 0,
+		"stop generating traces after this many; the run ends once every capped generator is done")
+	rootCmd.PersistentFlags().IntVar(&opts.MaxLogs, "max-logs", 0,
+		"stop generating logs after this many; the run ends once every capped generator is done")
+	rootCmd.PersistentFlags().StringVar(&opts.MaxBytes, "max-bytes", "",
+		"end the run once the estimated size of exported telemetry reaches this budget, e.g. 500KB, 10MB or 1GiB")
+	rootCmd.PersistentFlags().StringVar(&opts.MaxHeap, "max-heap", "",
+		"slow traces and logs down while otelgen's own heap is over this size, e.g. 256MiB, to stay clear of running out of memory")
+	rootCmd.PersistentFlags().DurationVar(&opts.SpikeEvery, "spikes", 0,
+		"simulate resource-contention spikes about this often, raising CPU and memory while spans slow down and fail more")
+	rootCmd.PersistentFlags().DurationVar(&opts.CounterResets, "counter-resets", 0,
+		"set the cumulative system.disk.io counter back to zero about this often, like a restarted process, to test counter reset handling")
+	rootCmd.PersistentFlags().StringVar(&opts.MemoryTrend, "memory-trend", memoryStable,
+		"memory utilization over the run: stable, leak to climb steadily, or sawtooth to climb and drop repeatedly")
+	rootCmd.PersistentFlags().Float64Var(&opts.MemoryCeiling, "memory-ceiling", 95,
+		"memory utilization percentage that leak and sawtooth trends climb to")
+	rootCmd.PersistentFlags().DurationVar(&opts.MemoryPeriod, "memory-period", 0,
+		"how long one climb to --memory-ceiling takes; defaults to the run's duration for leak and 1m for sawtooth")
+	rootCmd.PersistentFlags().BoolVar(&opts.OOMRestart, "oom-restart", false,
+		"with --memory-trend leak, drop back to the baseline on reaching the ceiling, like an out-of-memory restart, and leak again")
+	rootCmd.PersistentFlags().DurationVar(&opts.ClockSkew, "clock-skew", 0,
+		"offset each simulated service's span timestamps by a random amount within ±this range")
+	rootCmd.PersistentFlags().IntVar(&opts.Instances, "instances", 1,
+		"simulate this many replicas, each with its own service.instance.id and host.name, taking turns emitting traces and logs")
+	rootCmd.PersistentFlags().StringToStringVar(&opts.MetricUnits, "metric-unit", nil,
+		"override a generated metric's unit, as name=unit, for example system.disk.io=KiBy; repeatable")
+	rootCmd.PersistentFlags().StringToStringVar(&opts.MetricDescriptions, "metric-description", nil,
+		"override a generated metric's description, as name=description; repeatable")
+	rootCmd.PersistentFlags().StringVar(&opts.MetricPrefix, "metric-prefix", "",
+		`prepend this to every generated metric's name, e.g. "mygen." for mygen.system.cpu.utilization`)
+	rootCmd.PersistentFlags().StringVar(&opts.ClockSkewMode, "clock-skew-mode", clockSkewRun,
+		"run offsets all spans alike; span also skews each downstream span's start and end within ±--clock-skew, so children can start before or end after their parent")
 
 	lowCmd := &cobra.Command{
 		Use:   "low",
 		Short: "Generate low activity telemetry data",
-		RunE:  func(cmd *cobra.Command, args []string) error { return runGenerator(lowConfig) },
+		RunE:  func(cmd *cobra.Command, args []string) error { return runPreset(cmd.Context(), lowConfig) },
 	}
 
 	mediumCmd := &cobra.Command{
-		Use:   "medium", 
+		Use:   "medium",
 		Short: "Generate medium activity telemetry data",
-		RunE:  func(cmd *cobra.Command, args []string) error { return runGenerator(mediumConfig) },
+		RunE:  func(cmd *cobra.Command, args []string) error { return runPreset(cmd.Context(), mediumConfig) },
 	}
 
 	highCmd := &cobra.Command{
 		Use:   "high",
-		Short: "Generate high activity telemetry data", 
-		RunE:  func(cmd *cobra.Command, args []string) error { return runGenerator(highConfig) },
+		Short: "Generate high activity telemetry data",
+		RunE:  func(cmd *cobra.Command, args []string) error { return runPreset(cmd.Context(), highConfig) },
 	}
 
 	stressCmd := &cobra.Command{
 		Use:   "stress",
-		Short: "Generate stress-level telemetry data with 10x more traces", 
-		RunE:  func(cmd *cobra.Command, args []string) error { return runGenerator(stressConfig) },
+		Short: "Generate stress-level telemetry data with 10x more traces",
+		RunE:  func(cmd *cobra.Command, args []string) error { return runPreset(cmd.Context(), stressConfig) },
+	}
+
+	serveCmd := &cobra.Command{
+		Use:   "serve",
+		Short: "Run idle and start or stop presets through an HTTP control API",
+		RunE:  func(cmd *cobra.Command, args []string) error { return runServe(cmd.Context(), serveAddr) },
 	}
+	serveCmd.Flags().StringVar(&serveAddr, "listen", "localhost:8090", "address for the control API")
 
-	rootCmd.AddCommand(lowCmd, mediumCmd, highCmd, stressCmd)
+	rootCmd.AddCommand(lowCmd, mediumCmd, highCmd, stressCmd, serveCmd, newValidateCmd(), newFixtureCmd(), newBenchCmd())
 
 	if err := rootCmd.Execute(); err != nil {
 		os.Exit(1)
 	}
 }
 
-func runGenerator(config Config) error {
-	fmt.Printf("🚀 Starting %s activity simulation for %v\n", 
-		getConfigName(config), config.Duration)
-	fmt.Printf("📊 Trace rate: %v, Metric rate: %v, Log rate: %v\n", 
-		config.TraceRate, config.MetricRate, config.LogRate)
-	fmt.Printf("⚠️  Error rate: %.0f%%, High severity: %.0f%%\n", 
-		config.ErrorRate*100, config.HighSeverity*100)
+// runPreset runs config once, or loops it against the quiet-hours schedule
+// when --schedule is set.
+func runPreset(ctx context.Context, config Config) error {
+	if err := opts.load(); err != nil {
+		return err
+	}
+	defer opts.decisions.Close()
+	defer opts.stream.Close()
+	config = opts.apply(config)
 
-	ctx, cancel := context.WithTimeout(context.Background(), config.Duration)
-	defer cancel()
+	// Stop early on Ctrl-C but still flush and print the run summary
+	ctx, stop := signal.NotifyContext(ctx, os.Interrupt, syscall.SIGTERM)
+	defer stop()
 
-	// Create resource
-	res, err := resource.New(ctx,
-		resource.WithAttributes(
-			semconv.ServiceName("otelgen"),
-			semconv.ServiceVersion("1.0.0"),
-			attribute.String("load.level", getConfigName(config)),
-		),
-	)
+	if opts.Schedule == "" {
+		return runGenerator(ctx, config)
+	}
+	sched, err := parseSchedule(opts.Schedule)
 	if err != nil {
-		return fmt.Errorf("failed to create resource: %w", err)
+		return err
 	}
+	return runSchedule(ctx, config, sched)
+}
 
-	// Setup exporters
-	traceExporter, err := otlptracegrpc.New(ctx,
-		otlptracegrpc.WithEndpoint(config.Endpoint),
-		otlptracegrpc.WithInsecure(),
-	)
+// runGenerator generates telemetry for config.Duration, or until parent is
+// canceled when the duration is zero.
+func runGenerator(parent context.Context, config Config) error {
+	if config.Duration > 0 {
+		out.info(fmt.Sprintf("🚀 Starting %s activity simulation for %v", getConfigName(config), config.Duration),
+			"starting simulation", "preset", getConfigName(config), "duration", config.Duration.String())
+	} else {
+		out.info(fmt.Sprintf("🚀 Starting %s activity simulation until stopped", getConfigName(config)),
+			"starting simulation", "preset", getConfigName(config))
+	}
+	out.info(fmt.Sprintf("📊 Trace rate: %v, Metric rate: %v, Log rate: %v", config.TraceRate, config.MetricRate, config.LogRate),
+		"rates", "trace_rate", config.TraceRate.String(), "metric_rate", config.MetricRate.String(), "log_rate", config.LogRate.String())
+	out.info(fmt.Sprintf("⚠️  Error rate: %.0f%%, High severity: %.0f%%", config.ErrorRate*100, config.HighSeverity*100),
+		"error rates", "error_rate", config.ErrorRate, "high_severity", config.HighSeverity)
+
+	if config.clock == nil {
+		config.clock = realClock{}
+	}
+	stats := &runStats{}
+	if config.bench {
+		stats.recordLatencies()
+	}
+	budget := newByteBudget(config.MaxBytes)
+
+	// Setup exporters, before the run's clock starts so waiting for the
+	// collector doesn't eat into the duration
+	traceExporter, err := connectExporter(parent, config, "traces", newTraceExporter)
 	if err != nil {
 		return fmt.Errorf("failed to create trace exporter: %w", err)
 	}
-	defer traceExporter.Shutdown(ctx)
+	defer traceExporter.Shutdown(parent)
+	countedTraceExporter := countingSpanExporter{traceExporter, &stats.spans, budget}
 
-	metricExporter, err := otlpmetricgrpc.New(ctx,
-		otlpmetricgrpc.WithEndpoint(config.Endpoint),
-		otlpmetricgrpc.WithInsecure(),
-	)
+	metricExporter, err := connectExporter(parent, config, "metrics", newMetricExporter)
 	if err != nil {
 		return fmt.Errorf("failed to create metric exporter: %w", err)
 	}
-	defer metricExporter.Shutdown(ctx)
+	defer metricExporter.Shutdown(parent)
+	countedMetricExporter := countingMetricExporter{metricExporter, &stats.metrics, budget}
 
-	logExporter, err := otlploggrpc.New(ctx,
-		otlploggrpc.WithEndpoint(config.Endpoint),
-		otlploggrpc.WithInsecure(),
-	)
+	logExporter, err := connectExporter(parent, config, "logs", newLogExporter)
 	if err != nil {
 		return fmt.Errorf("failed to create log exporter: %w", err)
 	}
-	defer logExporter.Shutdown(ctx)
-
-	// Setup providers with immediate export (no batching)
-	tp := sdktrace.NewTracerProvider(
-		sdktrace.WithBatcher(traceExporter,
-			sdktrace.WithBatchTimeout(1*time.Millisecond),  // Export immediately
-			sdktrace.WithMaxExportBatchSize(1),             // One trace at a time
-			sdktrace.WithExportTimeout(100*time.Millisecond),
-		),
-		sdktrace.WithResource(res),
-	)
-	defer tp.Shutdown(ctx)
-	otel.SetTracerProvider(tp)
-
-	mp := sdkmetric.NewMeterProvider(
-		sdkmetric.WithReader(sdkmetric.NewPeriodicReader(
-			metricExporter,
-			sdkmetric.WithInterval(2*time.Second), // Export metrics every 2 seconds
-		)),
-		sdkmetric.WithResource(res),
-	)
-	defer mp.Shutdown(ctx)
-	otel.SetMeterProvider(mp)
-
-	lp := sdklog.NewLoggerProvider(
-		sdklog.WithProcessor(sdklog.NewBatchProcessor(logExporter)),
-		sdklog.WithResource(res),
-	)
-	defer lp.Shutdown(ctx)
-
-	// Create telemetry instruments
-	tracer := otel.Tracer("otelgen")
-	meter := otel.Meter("otelgen")
-	logger := lp.Logger("otelgen")
-
-	// Create metrics
-	cpuGauge, _ := meter.Float64Gauge("system.cpu.utilization")
-	memoryGauge, _ := meter.Float64Gauge("system.memory.utilization")
-	diskCounter, _ := meter.Int64Counter("system.disk.io")
-	httpCounter, _ := meter.Int64Counter("http.server.requests")
-
-	// Start generators
+	defer logExporter.Shutdown(parent)
+	countedLogExporter := countingLogExporter{logExporter, &stats.logs, budget}
+
+	ctx, cancel := runContext(parent, config)
+	defer cancel()
+	load := newSystemLoad(config)
+	config.heap = newHeapGuard(config.MaxHeap)
+	go config.heap.run(ctx, config.clock)
+
+	pool, err := newInstancePool(ctx, config,
+		exporters{spans: countedTraceExporter, metrics: countedMetricExporter, logs: countedLogExporter}, load, stats)
+	if err != nil {
+		return err
+	}
+	defer pool.shutdown(ctx)
+
+	// Start generators; capped ones count towards ending the run early
+	started := config.clock.Now()
 	done := make(chan struct{})
-	
+	var capped sync.WaitGroup
+
 	// Trace generator
-	go generateTraces(ctx, tracer, config, done)
-	
-	// Metric generator  
-	go generateMetrics(ctx, cpuGauge, memoryGauge, diskCounter, httpCounter, config, done)
-	
+	if config.MaxTraces > 0 {
+		capped.Add(1)
+	}
+	go func() {
+		generateTraces(ctx, pool, config, load, &stats.spans, done)
+		if config.MaxTraces > 0 {
+			capped.Done()
+		}
+	}()
+
+	// Metric generator
+	go generateMetrics(ctx, pool, config, load, &stats.metrics, done)
+
+	if len(config.DeployAt) > 0 {
+		go generateDeployments(ctx, pool, config, load, &stats.logs, done)
+	}
+
 	// Log generator
-	go generateLogs(ctx, logger, config, done)
+	if config.MaxLogs > 0 {
+		capped.Add(1)
+	}
+	go func() {
+		generateLogs(ctx, pool, config, &stats.logs, done)
+		if config.MaxLogs > 0 {
+			capped.Done()
+		}
+	}()
+
+	allCapped := make(chan struct{})
+	if config.MaxTraces > 0 || config.MaxLogs > 0 {
+		go func() {
+			capped.Wait()
+			close(allCapped)
+		}()
+	}
 
-	<-ctx.Done()
+	select {
+	case <-ctx.Done():
+	case <-allCapped:
+		out.info("🎯 Reached the --max-traces/--max-logs caps", "reached caps",
+			"max_traces", config.MaxTraces, "max_logs", config.MaxLogs)
+	case <-budget.done():
+		out.info(fmt.Sprintf("💰 Reached the --max-bytes budget of %d bytes", config.MaxBytes), "reached byte budget",
+			"max_bytes", config.MaxBytes, "used_bytes", budget.used.Load())
+	}
+	elapsed := config.clock.Now().Sub(started)
 	close(done)
+	cancel()
+	pool.finish(context.Background())
 
-	fmt.Printf("✅ Activity simulation completed\n")
+	// Flush what is still queued so the summary reflects the final exports;
+	// ctx is done, so the deferred shutdowns can't do it
+	flushCtx, flushCancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer flushCancel()
+	pool.shutdown(flushCtx)
+	traceExporter.Shutdown(flushCtx)
+	metricExporter.Shutdown(flushCtx)
+	logExporter.Shutdown(flushCtx)
+
+	out.info("✅ Activity simulation completed", "simulation completed", "preset", getConfigName(config))
+	if config.bench {
+		stats.benchReport(config, elapsed)
+	} else if config.prom != nil {
+		config.prom.add(stats, getConfigName(config), elapsed, true)
+	} else {
+		stats.summary(getConfigName(config), config.OutputFormat, elapsed)
+	}
 	return nil
 }
 
-func generateTraces(ctx context.Context, tracer trace.Tracer, config Config, done <-chan struct{}) {
-	operations := []string{
-		"GET /api/users/{id}",
-		"POST /api/orders", 
-		"GET /api/products",
-		"PUT /api/users/{id}",
-		"DELETE /api/sessions/{id}",
-		"GET /api/health",
-		"POST /api/auth/login",
-		"GET /api/metrics",
+// runContext returns a context canceled once config.Duration has passed on
+// config.clock, or only when parent is when the duration is zero.
+func runContext(parent context.Context, config Config) (context.Context, context.CancelFunc) {
+	ctx, cancel := context.WithCancel(parent)
+	if config.Duration > 0 {
+		expired := config.clock.After(config.Duration)
+		go func() {
+			select {
+			case <-expired:
+				cancel()
+			case <-ctx.Done():
+			}
+		}()
 	}
+	return ctx, cancel
+}
 
-	for {
+func generateTraces(ctx context.Context, pool *instancePool, config Config, load *systemLoad,
+	stats *signalStats, done <-chan struct{}) {
+	operations := config.Operations
+	if len(operations) == 0 {
+		operations = defaultOperations
+	}
+	// The service's clock is off by a fixed amount for the whole run
+	skew := clockSkew(config.ClockSkew)
+	if skew != 0 {
+		out.info(fmt.Sprintf("🕰️  Simulated clock skew: %v", skew), "clock skew", "skew", skew.String())
+	}
+	if config.ClockSkewMode == clockSkewSpan {
+		out.info(fmt.Sprintf("🕰️  Skewing downstream spans against their parents by up to ±%v", config.ClockSkew),
+			"per-span clock skew", "max_skew", config.ClockSkew.String())
+	}
+	if len(config.SpanLatencies) > 0 {
+		out.info(fmt.Sprintf("⏱️  Span latency profiles: %v", config.SpanLatencies),
+			"span latency profiles", "profiles", config.SpanLatencies.String())
+	}
+
+	// Each pass emits one trace, so the loop ends at --max-traces
+	for traces := 0; config.MaxTraces == 0 || traces < config.MaxTraces; traces++ {
 		select {
 		case <-done:
 			return
 		case <-ctx.Done():
 			return
 		default:
-			operation := operations[rand.Intn(len(operations))]
-			
-			_, span := tracer.Start(ctx, operation)
-			
+			// Under resource contention or right after a deployment requests
+			// slow down and fail more often
+			contention := load.contention(config)
+			slowdown := max(contention, load.deployBlip(config.clock.Now()))
+			decision := config.decisions.trace(func() traceDecision {
+				op := operations[rand.Intn(len(operations))]
+				tenant := pickTenant(config.Tenants)
+				errorRate := op.errorRate(tenant.errorRate(config.ErrorRate))
+				errorRate += (1 - errorRate) * slowdown * spikeErrorShare
+				return traceDecision{
+					Operation:    op.Name,
+					StatusCode:   getStatusCode(errorRate),
+					Dependencies: pickDependencies(config.Dependencies),
+					Tenant:       tenant.Name,
+				}
+			})
+			operation := decision.Operation
+			inst := pool.pick()
+			tracer := inst.tracer
+
+			start := config.clock.Now()
+			spanCtx, span := tracer.Start(ctx, operation,
+				trace.WithSpanKind(trace.SpanKindServer),
+				trace.WithTimestamp(start.Add(skew)))
+
 			// Add attributes based on operation
 			spaceIdx := strings.Index(operation, " ")
 			method := operation[:spaceIdx]
 			route := operation[spaceIdx+1:]
-			
+
 			span.SetAttributes(
 				attribute.String("http.method", method),
 				attribute.String("http.route", route),
 				attribute.String("user.id", fmt.Sprintf("user_%d", rand.Intn(1000))),
-				attribute.Int("http.status_code", getStatusCode(config.ErrorRate)),
+				attribute.Int("http.status_code", decision.StatusCode),
 			)
-			
-			// Simulate processing time
-			processingTime := time.Duration(rand.Intn(200)) * time.Millisecond
-			time.Sleep(processingTime)
-			
-			// Set span status based on error rate
-			if rand.Float64() < config.ErrorRate {
-				span.RecordError(fmt.Errorf("%s failed", operation))
+			var tenantAttrs []attribute.KeyValue
+			if decision.Tenant != "" {
+				tenantAttrs = append(tenantAttrs, attribute.String(tenantIDKey, decision.Tenant))
+				span.SetAttributes(tenantAttrs...)
+			}
+
+			if contention > 0 {
+				cpu, _ := load.utilization()
+				span.AddEvent("resource_contention", trace.WithAttributes(
+					attribute.Float64("system.cpu.utilization", cpu)))
+			}
+
+			// Simulate processing time, part of it spent in downstream calls
+			remaining := processingTime(config)
+			if p, ok := config.SpanLatencies.get(kindServer); ok {
+				remaining = p.sample()
+			}
+			remaining = spikeSlowdown(remaining, slowdown)
+			for i, name := range decision.Dependencies {
+				dep := findDependency(config.Dependencies, name)
+				var callTime time.Duration
+				if p, ok := config.SpanLatencies.get(dependencyKind(dep)); ok {
+					// A profiled call takes its own time on top of the
+					// request's
+					callTime = spikeSlowdown(p.sample(), slowdown)
+				} else {
+					callTime = remaining/4 + time.Duration(rand.Int63n(int64(remaining/4)+1))
+					remaining -= callTime
+				}
+
+				attrs := append([]attribute.KeyValue{semconv.PeerService(dep.Name)}, tenantAttrs...)
+				if dep.Address != "" {
+					attrs = append(attrs, semconv.ServerAddress(dep.Address), semconv.ServerPort(dep.Port))
+				}
+				call := dependencyCall{Name: dep.Name}
+				if config.SemanticSpans != nil {
+					// A failed request is blamed on its last downstream call
+					call = config.SemanticSpans.call(dep, decision.failed() && i == len(decision.Dependencies)-1)
+					attrs = append(attrs, call.Attrs...)
+				}
+				childStart, childEnd := skew, skew
+				if config.ClockSkewMode == clockSkewSpan {
+					// Each timestamp disagrees with the parent's clock on its
+					// own, so a child can start before its parent, end after
+					// it, or even end before it starts
+					childStart += clockSkew(config.ClockSkew)
+					childEnd += clockSkew(config.ClockSkew)
+				}
+				_, child := tracer.Start(spanCtx, call.Name,
+					trace.WithSpanKind(trace.SpanKindClient),
+					trace.WithTimestamp(config.clock.Now().Add(childStart)),
+					trace.WithAttributes(attrs...))
+				sleep(config.clock, callTime)
+				if call.Failed {
+					child.SetStatus(codes.Error, "Downstream call failed")
+				}
+				child.End(trace.WithTimestamp(config.clock.Now().Add(childEnd)))
+				stats.generated.Add(1)
+			}
+			if p, ok := config.SpanLatencies.get(kindInternal); ok {
+				// The handler's own work, named like its stack frame
+				_, compute := tracer.Start(spanCtx, routePackage(route)+"."+handlerVerb(method),
+					trace.WithSpanKind(trace.SpanKindInternal),
+					trace.WithTimestamp(config.clock.Now().Add(skew)),
+					trace.WithAttributes(tenantAttrs...))
+				sleep(config.clock, spikeSlowdown(p.sample(), slowdown))
+				compute.End(trace.WithTimestamp(config.clock.Now().Add(skew)))
+				stats.generated.Add(1)
+			}
+			sleep(config.clock, remaining)
+
+			// The span status follows the response status code, so backends
+			// computing error rates from either agree
+			if decision.failed() {
+				if config.ExceptionDetails {
+					recordException(span, method, route, decision.StatusCode, config.clock.Now().Add(skew))
+				} else {
+					span.RecordError(fmt.Errorf("%s failed", operation))
+				}
 				span.SetStatus(codes.Error, "Request failed")
 			} else {
 				span.SetStatus(codes.Ok, "")
 			}
-			
-			span.End()
-			
+
+			end := config.clock.Now()
+			span.End(trace.WithTimestamp(end.Add(skew)))
+			stats.generated.Add(1)
+			stats.passes.Add(1)
+			inst.latency.record(ctx, end.Sub(start),
+				semconv.HTTPRequestMethodKey.String(method),
+				semconv.HTTPRoute(route),
+				semconv.HTTPResponseStatusCode(decision.StatusCode))
+			// Requests are counted with the span's status code, so metrics
+			// and traces report the same errors
+			inst.httpCounter.Add(ctx, 1,
+				metric.WithAttributes(
+					attribute.String("method", method),
+					attribute.String("status", fmt.Sprintf("%d", decision.StatusCode))))
+
 			// Random delay before next trace - much more natural
-			randomDelay := time.Duration(rand.Float64() * float64(config.TraceRate) * 2)
-			time.Sleep(randomDelay)
+			sleep(config.clock, config.heap.stretch(arrivalDelay(config.Arrival, config.TraceRate)))
 		}
 	}
 }
 
-func generateMetrics(ctx context.Context, cpuGauge, memoryGauge metric.Float64Gauge, 
-	diskCounter, httpCounter metric.Int64Counter, config Config, done <-chan struct{}) {
-	ticker := time.NewTicker(config.MetricRate)
+func generateMetrics(ctx context.Context, pool *instancePool, config Config, load *systemLoad, stats *signalStats, done <-chan struct{}) {
+	ticker := config.clock.NewTicker(config.MetricRate)
 	defer ticker.Stop()
 
 	for {
@@ -311,49 +929,65 @@ func generateMetrics(ctx context.Context, cpuGauge, memoryGauge metric.Float64Ga
 			return
 		case <-ctx.Done():
 			return
-		case <-ticker.C:
-			// Generate constant metrics based on config level
-			cpuUtil := config.MaxCPU / 100.0  // Convert percentage to decimal
-			memUtil := config.MaxMemory / 100.0  // Convert percentage to decimal
-			
-			cpuGauge.Record(ctx, cpuUtil, 
-				metric.WithAttributes(attribute.String("host", "app-server-01")))
-			memoryGauge.Record(ctx, memUtil,
-				metric.WithAttributes(attribute.String("host", "app-server-01")))
-			
-			// Disk I/O and HTTP requests based on constant level
-			diskCounter.Add(ctx, int64(config.MaxDiskIO*10.24), // Scale to reasonable values
-				metric.WithAttributes(attribute.String("device", "/dev/sda1")))
-			httpCounter.Add(ctx, int64(rand.Intn(10)+1),
-				metric.WithAttributes(
-					attribute.String("method", "GET"),
-					attribute.String("status", fmt.Sprintf("%d", getStatusCode(config.ErrorRate)))))
+		case now := <-ticker.C():
+			stats.passes.Add(1)
+			// Generate constant metrics based on config level, unless a
+			// spike is on; observable gauges report these from their
+			// callback instead
+			cpuUtil, memUtil := load.update(config, config.MetricRate, now)
+			// Every replica reports its own metrics
+			for _, inst := range pool.instances {
+				if inst.cpuGauge != nil {
+					inst.cpuGauge.Record(ctx, cpuUtil,
+						metric.WithAttributes(attribute.String("host", inst.host)))
+					inst.memoryGauge.Record(ctx, memUtil,
+						metric.WithAttributes(attribute.String("host", inst.host)))
+					stats.generated.Add(2)
+				}
+
+				// Disk I/O based on constant level; HTTP requests are
+				// counted as traces are generated
+				if config.CounterResets > 0 && rand.Float64() < float64(config.MetricRate)/float64(config.CounterResets) {
+					if total := inst.resetDiskIO(); total > 0 {
+						out.info(fmt.Sprintf("🔄 Reset system.disk.io on %s from %d", inst.host, total),
+							"counter reset", "host", inst.host, "previous", total)
+					}
+				}
+				inst.addDiskIO(ctx, int64(config.MaxDiskIO*10.24)) // Scale to reasonable values
+				stats.generated.Add(1)
+			}
 		}
 	}
 }
 
-func generateLogs(ctx context.Context, logger log.Logger, config Config, done <-chan struct{}) {
-	ticker := time.NewTicker(config.LogRate)
-	defer ticker.Stop()
+func generateLogs(ctx context.Context, pool *instancePool, config Config, stats *signalStats, done <-chan struct{}) {
+	// Log records come on a steady tick unless --arrival-distribution
+	// spaces them out
+	next := func() <-chan time.Time { return config.clock.After(arrivalDelay(config.Arrival, config.LogRate)) }
+	if config.Arrival == "" {
+		ticker := config.clock.NewTicker(config.LogRate)
+		defer ticker.Stop()
+		next = ticker.C
+	}
 
 	messages := map[log.Severity][]string{
 		log.SeverityInfo: {
 			"User authentication successful",
-			"Database connection established", 
+			"Database connection established",
 			"Cache hit for user profile",
 			"Background job completed",
 			"Health check passed",
 		},
 		log.SeverityWarn: {
 			"Cache miss for key: user_profile_123",
-			"API rate limit approaching", 
+			"API rate limit approaching",
 			"Memory usage above 80%",
 			"Slow database query detected",
 		},
 		log.SeverityError: {
 			"Database connection failed",
 			"Authentication failed for user",
-			"Service timeout occurred", 
+			"Service timeout occurred",
 			"Disk space critically low",
 		},
 		log.SeverityFatal: {
@@ -363,19 +997,27 @@ func generateLogs(ctx context.Context, logger log.Logger, config Config, done <-
 		},
 	}
 
-	for {
+	// Each pass emits one log record, so the loop ends at --max-logs
+	for records := 0; config.MaxLogs == 0 || records < config.MaxLogs; records++ {
 		select {
 		case <-done:
 			return
 		case <-ctx.Done():
 			return
-		case <-ticker.C:
-			severity := getSeverity(config.HighSeverity)
-			severityMessages := messages[severity]
-			message := severityMessages[rand.Intn(len(severityMessages))]
-			
+		case <-next():
+			decision := config.decisions.log(func() logDecision {
+				severity := getSeverity(config.HighSeverity)
+				severityMessages := messages[severity]
+				return logDecision{
+					Severity: severity,
+					Message:  severityMessages[rand.Intn(len(severityMessages))],
+					Tenant:   pickTenant(config.Tenants).Name,
+				}
+			})
+			severity, message := decision.Severity, decision.Message
+
 			record := log.Record{}
-			record.SetTimestamp(time.Now())
+			record.SetTimestamp(config.clock.Now())
 			record.SetBody(log.StringValue(message))
 			record.SetSeverity(severity)
 			record.AddAttributes(
@@ -383,15 +1025,85 @@ func generateLogs(ctx context.Context, logger log.Logger, config Config, done <-
 				log.String("user.id", fmt.Sprintf("user_%d", rand.Intn(1000))),
 				log.Int64("request.id", int64(rand.Intn(100000))),
 			)
-			
-			logger.Emit(ctx, record)
+			if decision.Tenant != "" {
+				record.AddAttributes(log.String(tenantIDKey, decision.Tenant))
+			}
+
+			pool.pick().logger.Emit(ctx, record)
+			stats.generated.Add(1)
+			stats.passes.Add(1)
+			// Shedding load skips the ticks slept through
+			if extra := config.heap.stretch(config.LogRate) - config.LogRate; extra > 0 {
+				sleep(config.clock, extra)
+			}
 		}
 	}
 }
 
+// processingTime returns a random span duration between the configured
+// minimum and maximum latency.
+func processingTime(config Config) time.Duration {
+	return config.MinLatency + time.Duration(rand.Int63n(int64(config.MaxLatency-config.MinLatency)+1))
+}
+
+// Clock skew modes.
+const (
+	clockSkewRun  = "run"
+	clockSkewSpan = "span"
+)
+
+// clockSkew returns a random offset within ±maxSkew.
+func clockSkew(maxSkew time.Duration) time.Duration {
+	if maxSkew <= 0 {
+		return 0
+	}
+	return time.Duration(rand.Int63n(int64(2*maxSkew)+1)) - maxSkew
+}
+
+// utilization returns the current CPU and memory utilization as fractions.
+func utilization(config Config) (cpu, memory float64) {
+	return config.MaxCPU / 100.0, config.MaxMemory / 100.0 // Convert percentage to decimal
+}
+
+// registerUtilizationObservers reports CPU and memory through asynchronous
+// gauges whose callback runs on every collection cycle.
+func registerUtilizationObservers(meter metric.Meter, metrics metricCatalog, hostName string, load *systemLoad, stats *signalStats) error {
+	cpu, memory := metrics.get("system.cpu.utilization"), metrics.get("system.memory.utilization")
+	cpuGauge, err := meter.Float64ObservableGauge(cpu.Name,
+		metric.WithUnit(cpu.Unit), metric.WithDescription(cpu.Description))
+	if err != nil {
+		return err
+	}
+	memoryGauge, err := meter.Float64ObservableGauge(memory.Name,
+		metric.WithUnit(memory.Unit), metric.WithDescription(memory.Description))
+	if err != nil {
+		return err
+	}
+	host := metric.WithAttributes(attribute.String("host", hostName))
+	_, err = meter.RegisterCallback(func(_ context.Context, o metric.Observer) error {
+		cpuUtil, memUtil := load.utilization()
+		o.ObserveFloat64(cpuGauge, cpuUtil, host)
+		o.ObserveFloat64(memoryGauge, memUtil, host)
+		stats.generated.Add(2)
+		return nil
+	}, cpuGauge, memoryGauge)
+	return err
+}
+
+// clientErrorRate is the share of requests that don't fail but are still
+// answered with a 4xx, as real clients send bad or unauthorized requests.
+const clientErrorRate = 0.1
+
+// getStatusCode returns a server error status code with probability
+// errorRate. Other requests get a client error status code with
+// probability clientErrorRate, and a success code otherwise.
 func getStatusCode(errorRate float64) int {
 	if rand.Float64() < errorRate {
-		codes := []int{400, 401, 403, 404, 500, 502, 503}
+		codes := []int{500, 502, 503}
+		return codes[rand.Intn(len(codes))]
+	}
+	if rand.Float64() < clientErrorRate {
+		codes := []int{400, 401, 403, 404}
 		return codes[rand.Intn(len(codes))]
 	}
 	codes := []int{200, 201, 202, 204}
@@ -407,11 +1119,14 @@ func getSeverity(highSeverityRate float64) log.Severity {
 }
 
 func getConfigName(config Config) string {
+	if config.Name != "" {
+		return config.Name
+	}
 	switch config.Duration {
 	case 30 * time.Second:
 		return "Low"
 	case 60 * time.Second:
-		return "Medium"  
+		return "Medium"
 	case 90 * time.Second:
 		return "High"
 	case 120 * time.Second:
@@ -419,4 +1134,4 @@ func getConfigName(config Config) string {
 	default:
 		return "Custom"
 	}
-}
\ No newline at end of file
+}
@@ -0,0 +1,204 @@
+package main
+
+import (
+	"context"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/codes"
+	sdklog "go.opentelemetry.io/otel/sdk/log"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// nopMetricExporter and nopLogExporter discard what they are given.
+type nopMetricExporter struct{}
+
+func (nopMetricExporter) Temporality(k sdkmetric.InstrumentKind) metricdata.Temporality {
+	return sdkmetric.DefaultTemporalitySelector(k)
+}
+
+func (nopMetricExporter) Aggregation(k sdkmetric.InstrumentKind) sdkmetric.Aggregation {
+	return sdkmetric.DefaultAggregationSelector(k)
+}
+
+func (nopMetricExporter) Export(context.Context, *metricdata.ResourceMetrics) error { return nil }
+func (nopMetricExporter) ForceFlush(context.Context) error                          { return nil }
+func (nopMetricExporter) Shutdown(context.Context) error                            { return nil }
+
+type nopLogExporter struct{}
+
+func (nopLogExporter) Export(context.Context, []sdklog.Record) error { return nil }
+func (nopLogExporter) ForceFlush(context.Context) error              { return nil }
+func (nopLogExporter) Shutdown(context.Context) error                { return nil }
+
+// newTestPool returns a pool of config.Instances instances whose exporters
+// discard everything, shut down when the test ends.
+func newTestPool(t *testing.T, config Config, stats *runStats) *instancePool {
+	t.Helper()
+	exp := exporters{spans: tracetest.NewNoopExporter(), metrics: nopMetricExporter{}, logs: nopLogExporter{}}
+	pool, err := newInstancePool(context.Background(), config, exp, newSystemLoad(config), stats)
+	require.NoError(t, err)
+	t.Cleanup(func() { pool.shutdown(context.Background()) })
+	return pool
+}
+
+// runUntil runs generate in the background and returns a function that
+// ends it and waits for it to return.
+func runUntil(generate func(done <-chan struct{})) (stop func()) {
+	done := make(chan struct{})
+	returned := make(chan struct{})
+	go func() {
+		defer close(returned)
+		generate(done)
+	}()
+	return func() {
+		close(done)
+		<-returned
+	}
+}
+
+func TestGenerateMetricsExactCount(t *testing.T) {
+	clock := newFakeClock()
+	config := Config{MetricRate: time.Second, Instances: 2, clock: clock}
+	stats := &runStats{}
+	pool := newTestPool(t, config, stats)
+	load := newSystemLoad(config)
+
+	stop := runUntil(func(done <-chan struct{}) {
+		generateMetrics(context.Background(), pool, config, load, &stats.metrics, done)
+	})
+	clock.waitFor(1)
+	clock.advance(10*time.Second + 500*time.Millisecond)
+	stop()
+
+	assert.Equal(t, int64(10), stats.metrics.passes.Load())
+	// Two gauges and disk I/O on each instance; requests are counted by
+	// the trace generator
+	assert.Equal(t, int64(10*2*3), stats.metrics.generated.Load())
+}
+
+func TestGenerateLogsExactCount(t *testing.T) {
+	for _, tc := range []struct {
+		name    string
+		maxLogs int
+		want    int64
+	}{
+		{name: "uncapped", want: 20},
+		{name: "capped", maxLogs: 7, want: 7},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			clock := newFakeClock()
+			config := Config{LogRate: 100 * time.Millisecond, MaxLogs: tc.maxLogs, clock: clock}
+			stats := &runStats{}
+			pool := newTestPool(t, config, stats)
+
+			stop := runUntil(func(done <-chan struct{}) {
+				generateLogs(context.Background(), pool, config, &stats.logs, done)
+			})
+			clock.waitFor(1)
+			clock.advance(2 * time.Second)
+			stop()
+
+			assert.Equal(t, tc.want, stats.logs.passes.Load())
+			assert.Equal(t, tc.want, stats.logs.generated.Load())
+		})
+	}
+}
+
+func TestRunContext(t *testing.T) {
+	clock := newFakeClock()
+	ctx, cancel := runContext(context.Background(), Config{Duration: 5 * time.Second, clock: clock})
+	defer cancel()
+
+	clock.waitFor(1)
+	clock.advance(5*time.Second - time.Nanosecond)
+	assert.NoError(t, ctx.Err(), "ended before --duration")
+	clock.advance(time.Nanosecond)
+	select {
+	case <-ctx.Done():
+	case <-time.After(5 * time.Second):
+		t.Fatal("didn't end at --duration")
+	}
+}
+
+// requestCounter is a metric exporter that keeps the last export's
+// http.server.requests counts by status.
+type requestCounter struct {
+	nopMetricExporter
+	mu       sync.Mutex
+	byStatus map[string]int64
+}
+
+func (r *requestCounter) Export(_ context.Context, rm *metricdata.ResourceMetrics) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.byStatus = make(map[string]int64)
+	for _, sm := range rm.ScopeMetrics {
+		for _, m := range sm.Metrics {
+			sum, ok := m.Data.(metricdata.Sum[int64])
+			if m.Name != "http.server.requests" || !ok {
+				continue
+			}
+			for _, dp := range sum.DataPoints {
+				status, _ := dp.Attributes.Value("status")
+				r.byStatus[status.AsString()] += dp.Value
+			}
+		}
+	}
+	return nil
+}
+
+func TestGenerateTracesStatusCodes(t *testing.T) {
+	config := Config{ErrorRate: 0.5, MaxTraces: 300, clock: realClock{}}
+	stats := &runStats{}
+	spans := tracetest.NewInMemoryExporter()
+	requests := &requestCounter{}
+	pool, err := newInstancePool(context.Background(), config,
+		exporters{spans: spans, metrics: requests, logs: nopLogExporter{}}, newSystemLoad(config), stats)
+	require.NoError(t, err)
+
+	generateTraces(context.Background(), pool, config, newSystemLoad(config), &stats.spans, make(chan struct{}))
+	pool.shutdown(context.Background())
+
+	fromSpans := make(map[string]int64)
+	var servers, failed int
+	for _, span := range spans.GetSpans() {
+		if span.SpanKind != trace.SpanKindServer {
+			continue
+		}
+		var status int64
+		for _, attr := range span.Attributes {
+			if attr.Key == "http.status_code" {
+				status = attr.Value.AsInt64()
+			}
+		}
+		fromSpans[strconv.FormatInt(status, 10)]++
+		servers++
+		if span.Status.Code == codes.Error {
+			failed++
+		}
+		if status >= 500 {
+			assert.Equal(t, codes.Error, span.Status.Code, "status %d", status)
+		} else {
+			assert.Equal(t, codes.Ok, span.Status.Code, "status %d", status)
+		}
+	}
+	assert.Len(t, spans.GetSpans(), 300)
+	assert.Equal(t, fromSpans, requests.byStatus)
+	// The error rate is the share of failed requests, 4xx responses aside
+	assert.InDelta(t, config.ErrorRate, float64(failed)/float64(servers), 0.12,
+		"%d of %d spans failed", failed, servers)
+
+	var clientErrors int64
+	for _, status := range []string{"400", "401", "403", "404"} {
+		clientErrors += fromSpans[status]
+	}
+	assert.Positive(t, clientErrors, "no 4xx responses in %v", fromSpans)
+}
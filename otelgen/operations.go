@@ -0,0 +1,91 @@
+package main
+
+import (
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// operation is a simulated endpoint. A negative ErrorRate means the
+// preset's global error rate applies.
+type operation struct {
+	Name      string
+	ErrorRate float64
+}
+
+var defaultOperations = []operation{
+	{Name: "GET /api/users/{id}", ErrorRate: -1},
+	{Name: "POST /api/orders", ErrorRate: -1},
+	{Name: "GET /api/products", ErrorRate: -1},
+	{Name: "PUT /api/users/{id}", ErrorRate: -1},
+	{Name: "DELETE /api/sessions/{id}", ErrorRate: -1},
+	{Name: "GET /api/health", ErrorRate: -1},
+	{Name: "POST /api/auth/login", ErrorRate: -1},
+	{Name: "GET /api/metrics", ErrorRate: -1},
+}
+
+// httpMethods are the methods an operation may use.
+var httpMethods = []string{"GET", "HEAD", "POST", "PUT", "PATCH", "DELETE", "OPTIONS", "CONNECT", "TRACE"}
+
+// errorRate returns the operation's own error rate, or fallback when unset.
+func (o operation) errorRate(fallback float64) float64 {
+	if o.ErrorRate < 0 {
+		return fallback
+	}
+	return o.ErrorRate
+}
+
+// loadOperations reads an operations file. Each non-empty line that isn't a
+// "#" comment holds "METHOD /route" followed by optional key=value settings:
+//
+//	GET /api/health error_rate=0.01
+//	POST /api/auth/login error_rate=0.4
+func loadOperations(path string) ([]operation, error) {
+	var ops []operation
+	err := readLines(path, "operations", func(line string) error {
+		op, err := parseOperation(line)
+		if err == nil {
+			ops = append(ops, op)
+		}
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	if len(ops) == 0 {
+		return nil, fmt.Errorf("operations file %s defines no operations", path)
+	}
+	return ops, nil
+}
+
+func parseOperation(line string) (operation, error) {
+	fields := strings.Fields(line)
+	if len(fields) < 2 {
+		return operation{}, fmt.Errorf("expected \"METHOD /route\", got %q", line)
+	}
+	if !slices.Contains(httpMethods, fields[0]) {
+		return operation{}, fmt.Errorf("unknown HTTP method %q", fields[0])
+	}
+	if !strings.HasPrefix(fields[1], "/") {
+		return operation{}, fmt.Errorf("route %q must start with /", fields[1])
+	}
+	op := operation{Name: fields[0] + " " + fields[1], ErrorRate: -1}
+	for _, field := range fields[2:] {
+		key, value, found := strings.Cut(field, "=")
+		if !found {
+			return operation{}, fmt.Errorf("expected key=value, got %q", field)
+		}
+		switch key {
+		case "error_rate":
+			rate, err := strconv.ParseFloat(value, 64)
+			if err != nil || rate < 0 || rate > 1 {
+				return operation{}, fmt.Errorf("error_rate must be between 0 and 1, got %q", value)
+			}
+			op.ErrorRate = rate
+		default:
+			return operation{}, fmt.Errorf("unknown setting %q", key)
+		}
+	}
+	return op, nil
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+
+	"go.opentelemetry.io/otel"
+)
+
+// output prints otelgen's startup, progress and shutdown messages. The
+// default is friendly text; --log-format json switches to structured log
+// lines and --quiet drops them entirely.
+type output struct {
+	logger *slog.Logger
+	quiet  bool
+	// w receives text messages; nil means stdout
+	w io.Writer
+}
+
+var out output
+
+// setup configures the output from the --log-format and --quiet flags.
+func (o *output) setup(format string, quiet bool) error {
+	o.quiet = quiet
+	switch format {
+	case "text":
+	case "json":
+		o.logger = slog.New(slog.NewJSONHandler(os.Stderr, nil))
+		// Route SDK export errors through the same logger so every line parses
+		otel.SetErrorHandler(otel.ErrorHandlerFunc(func(err error) {
+			o.logger.Error("opentelemetry error", "error", err)
+		}))
+	default:
+		return fmt.Errorf("unknown --log-format %q, expected text or json", format)
+	}
+	return nil
+}
+
+// info prints a progress message. text is the friendly line, and msg and
+// args are its structured form.
+func (o *output) info(text, msg string, args ...any) {
+	if o.quiet {
+		return
+	}
+	if o.logger != nil {
+		o.logger.Info(msg, args...)
+		return
+	}
+	if o.w != nil {
+		fmt.Fprintln(o.w, text)
+		return
+	}
+	fmt.Println(text)
+}
+
+// warn prints a message about questionable input. Warnings go to stderr
+// and are shown even with --quiet.
+func (o *output) warn(text, msg string, args ...any) {
+	if o.logger != nil {
+		o.logger.Warn(msg, args...)
+		return
+	}
+	fmt.Fprintln(os.Stderr, text)
+}
+
+// result prints a run's machine-readable output, next to the text
+// messages. Results are shown even with --quiet, since they are what the
+// run was for.
+func (o *output) result(text string) {
+	w := o.w
+	if w == nil {
+		w = os.Stdout
+	}
+	fmt.Fprint(w, text)
+}
@@ -0,0 +1,197 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net"
+
+	"go.opentelemetry.io/otel/exporters/otlp/otlplog/otlploggrpc"
+	"go.opentelemetry.io/otel/exporters/otlp/otlplog/otlploghttp"
+	"go.opentelemetry.io/otel/exporters/otlp/otlpmetric/otlpmetricgrpc"
+	"go.opentelemetry.io/otel/exporters/otlp/otlpmetric/otlpmetrichttp"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracegrpc"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	sdklog "go.opentelemetry.io/otel/sdk/log"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+)
+
+// OTLP transports a signal can be exported over.
+const (
+	protocolGRPC = "grpc"
+	protocolHTTP = "http"
+)
+
+// defaultEndpoints are the collector addresses used for each protocol when
+// --endpoint isn't set, matching the standard OTLP receiver ports.
+var defaultEndpoints = map[string]string{
+	protocolGRPC: "localhost:4317",
+	protocolHTTP: "localhost:4318",
+}
+
+// protocols is the transport chosen for each signal.
+type protocols struct {
+	Traces  string
+	Metrics string
+	Logs    string
+}
+
+// resolveProtocols checks the --protocol flags and fills each signal's
+// unset protocol from the global one.
+func resolveProtocols(global string, perSignal protocols) (protocols, error) {
+	if err := checkProtocol("--protocol", global); err != nil {
+		return protocols{}, err
+	}
+	resolved := perSignal
+	for _, p := range []struct {
+		flag  string
+		value *string
+	}{
+		{"--traces-protocol", &resolved.Traces},
+		{"--metrics-protocol", &resolved.Metrics},
+		{"--logs-protocol", &resolved.Logs},
+	} {
+		if *p.value == "" {
+			*p.value = global
+		} else if err := checkProtocol(p.flag, *p.value); err != nil {
+			return protocols{}, err
+		}
+	}
+	return resolved, nil
+}
+
+func checkProtocol(flag, value string) error {
+	if value != protocolGRPC && value != protocolHTTP {
+		return fmt.Errorf("unknown %s %q, expected grpc or http", flag, value)
+	}
+	return nil
+}
+
+// endpoints is the collector address of each signal.
+type endpoints struct {
+	Traces  string
+	Metrics string
+	Logs    string
+}
+
+// get returns the address of a signal: traces, metrics or logs.
+func (e endpoints) get(signal string) string {
+	switch signal {
+	case "traces":
+		return e.Traces
+	case "metrics":
+		return e.Metrics
+	default:
+		return e.Logs
+	}
+}
+
+// resolveEndpoints checks the --endpoint flags and works out each signal's
+// address. A signal's own flag wins. Otherwise a signal exported over the
+// global --protocol goes to --endpoint, and one exported over the other
+// protocol goes to the same host on its protocol's standard port, since
+// --endpoint's port belongs to the receiver of --protocol. Without any
+// endpoint flag, signals go to their protocol's standard local address.
+func resolveEndpoints(global, globalProtocol string, perSignal endpoints, protocols protocols, insecure bool) (endpoints, error) {
+	var host string
+	if global != "" {
+		var err error
+		if global, err = normalizeEndpoint("--endpoint", global, insecure); err != nil {
+			return endpoints{}, err
+		}
+		host, _, _ = net.SplitHostPort(global)
+	}
+	resolved := perSignal
+	for _, s := range []struct {
+		flag     string
+		value    *string
+		protocol string
+	}{
+		{"--traces-endpoint", &resolved.Traces, protocols.Traces},
+		{"--metrics-endpoint", &resolved.Metrics, protocols.Metrics},
+		{"--logs-endpoint", &resolved.Logs, protocols.Logs},
+	} {
+		switch {
+		case *s.value != "":
+			endpoint, err := normalizeEndpoint(s.flag, *s.value, insecure)
+			if err != nil {
+				return endpoints{}, err
+			}
+			*s.value = endpoint
+		case global == "":
+			*s.value = defaultEndpoints[s.protocol]
+		case s.protocol == globalProtocol:
+			*s.value = global
+		default:
+			_, port, _ := net.SplitHostPort(defaultEndpoints[s.protocol])
+			*s.value = net.JoinHostPort(host, port)
+		}
+	}
+	return resolved, nil
+}
+
+// newTraceExporter returns the trace exporter for config's traces
+// protocol. --output always goes through the gRPC exporter, whose requests
+// the stream captures.
+func newTraceExporter(ctx context.Context, config Config) (sdktrace.SpanExporter, error) {
+	if config.stream == nil && config.Protocols.Traces == protocolHTTP {
+		options := []otlptracehttp.Option{otlptracehttp.WithEndpoint(config.Endpoints.Traces)}
+		if config.Insecure {
+			options = append(options, otlptracehttp.WithInsecure())
+		}
+		return otlptracehttp.New(ctx, options...)
+	}
+
+	options := []otlptracegrpc.Option{otlptracegrpc.WithEndpoint(config.Endpoints.Traces)}
+	if config.stream != nil {
+		options = append(options, otlptracegrpc.WithGRPCConn(config.stream.conn))
+	} else if config.Insecure {
+		options = append(options, otlptracegrpc.WithInsecure())
+	}
+	return otlptracegrpc.New(ctx, options...)
+}
+
+// newMetricExporter returns the metric exporter for config's metrics
+// protocol.
+func newMetricExporter(ctx context.Context, config Config) (sdkmetric.Exporter, error) {
+	if config.stream == nil && config.Protocols.Metrics == protocolHTTP {
+		options := []otlpmetrichttp.Option{otlpmetrichttp.WithEndpoint(config.Endpoints.Metrics)}
+		if config.Insecure {
+			options = append(options, otlpmetrichttp.WithInsecure())
+		}
+		if config.LatencyHistogram {
+			options = append(options, otlpmetrichttp.WithTemporalitySelector(latencyTemporality))
+		}
+		return otlpmetrichttp.New(ctx, options...)
+	}
+
+	options := []otlpmetricgrpc.Option{otlpmetricgrpc.WithEndpoint(config.Endpoints.Metrics)}
+	if config.stream != nil {
+		options = append(options, otlpmetricgrpc.WithGRPCConn(config.stream.conn))
+	} else if config.Insecure {
+		options = append(options, otlpmetricgrpc.WithInsecure())
+	}
+	if config.LatencyHistogram {
+		options = append(options, otlpmetricgrpc.WithTemporalitySelector(latencyTemporality))
+	}
+	return otlpmetricgrpc.New(ctx, options...)
+}
+
+// newLogExporter returns the log exporter for config's logs protocol.
+func newLogExporter(ctx context.Context, config Config) (sdklog.Exporter, error) {
+	if config.stream == nil && config.Protocols.Logs == protocolHTTP {
+		options := []otlploghttp.Option{otlploghttp.WithEndpoint(config.Endpoints.Logs)}
+		if config.Insecure {
+			options = append(options, otlploghttp.WithInsecure())
+		}
+		return otlploghttp.New(ctx, options...)
+	}
+
+	options := []otlploggrpc.Option{otlploggrpc.WithEndpoint(config.Endpoints.Logs)}
+	if config.stream != nil {
+		options = append(options, otlploggrpc.WithGRPCConn(config.stream.conn))
+	} else if config.Insecure {
+		options = append(options, otlploggrpc.WithInsecure())
+	}
+	return otlploggrpc.New(ctx, options...)
+}
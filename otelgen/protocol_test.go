@@ -0,0 +1,73 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestResolveEndpoints(t *testing.T) {
+	for _, tc := range []struct {
+		name           string
+		global         string
+		globalProtocol string
+		perSignal      endpoints
+		protocols      protocols
+		want           endpoints
+		wantErr        string
+	}{
+		{
+			name:           "defaults",
+			globalProtocol: protocolGRPC,
+			protocols:      protocols{Traces: protocolGRPC, Metrics: protocolGRPC, Logs: protocolHTTP},
+			want:           endpoints{Traces: "localhost:4317", Metrics: "localhost:4317", Logs: "localhost:4318"},
+		},
+		{
+			name:           "global endpoint with one protocol",
+			global:         "collector:14317",
+			globalProtocol: protocolGRPC,
+			protocols:      protocols{Traces: protocolGRPC, Metrics: protocolGRPC, Logs: protocolGRPC},
+			want:           endpoints{Traces: "collector:14317", Metrics: "collector:14317", Logs: "collector:14317"},
+		},
+		{
+			name:           "signal on the other protocol keeps the host",
+			global:         "collector:4317",
+			globalProtocol: protocolGRPC,
+			protocols:      protocols{Traces: protocolGRPC, Metrics: protocolGRPC, Logs: protocolHTTP},
+			want:           endpoints{Traces: "collector:4317", Metrics: "collector:4317", Logs: "collector:4318"},
+		},
+		{
+			name:           "global http",
+			global:         "http://collector:4318",
+			globalProtocol: protocolHTTP,
+			protocols:      protocols{Traces: protocolGRPC, Metrics: protocolHTTP, Logs: protocolHTTP},
+			want:           endpoints{Traces: "collector:4317", Metrics: "collector:4318", Logs: "collector:4318"},
+		},
+		{
+			name:           "per-signal endpoints win",
+			global:         "collector:4317",
+			globalProtocol: protocolGRPC,
+			perSignal:      endpoints{Logs: "logs-gateway:9000"},
+			protocols:      protocols{Traces: protocolGRPC, Metrics: protocolGRPC, Logs: protocolHTTP},
+			want:           endpoints{Traces: "collector:4317", Metrics: "collector:4317", Logs: "logs-gateway:9000"},
+		},
+		{
+			name:           "invalid per-signal endpoint",
+			globalProtocol: protocolGRPC,
+			perSignal:      endpoints{Metrics: "collector"},
+			protocols:      protocols{Traces: protocolGRPC, Metrics: protocolGRPC, Logs: protocolGRPC},
+			wantErr:        `--metrics-endpoint "collector" must be host:port`,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := resolveEndpoints(tc.global, tc.globalProtocol, tc.perSignal, tc.protocols, true)
+			if tc.wantErr != "" {
+				require.ErrorContains(t, err, tc.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tc.want, got)
+		})
+	}
+}
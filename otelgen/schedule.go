@@ -0,0 +1,160 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// idlePreset is the schedule target that pauses generation entirely.
+const idlePreset = "idle"
+
+var presets = map[string]Config{
+	"low":    lowConfig,
+	"medium": mediumConfig,
+	"high":   highConfig,
+	"stress": stressConfig,
+}
+
+// scheduleWindow is a daily time window, in minutes since midnight, during
+// which preset replaces the command's preset. Windows may wrap past midnight.
+type scheduleWindow struct {
+	start  int
+	end    int
+	preset string
+}
+
+type schedule []scheduleWindow
+
+// parseSchedule parses windows like "22:00-07:00=low,12:00-13:00=idle".
+// A window without "=preset" drops to the low preset.
+func parseSchedule(spec string) (schedule, error) {
+	var sched schedule
+	for _, part := range strings.Split(spec, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		span, preset, found := strings.Cut(part, "=")
+		if !found {
+			preset = "low"
+		}
+		preset = strings.ToLower(strings.TrimSpace(preset))
+		if _, ok := presets[preset]; !ok && preset != idlePreset {
+			return nil, fmt.Errorf("schedule window %q: unknown preset %q", part, preset)
+		}
+		from, to, found := strings.Cut(span, "-")
+		if !found {
+			return nil, fmt.Errorf("schedule window %q: expected HH:MM-HH:MM", part)
+		}
+		start, err := parseClock(from)
+		if err != nil {
+			return nil, fmt.Errorf("schedule window %q: %w", part, err)
+		}
+		end, err := parseClock(to)
+		if err != nil {
+			return nil, fmt.Errorf("schedule window %q: %w", part, err)
+		}
+		if start == end {
+			return nil, fmt.Errorf("schedule window %q: start and end are equal", part)
+		}
+		sched = append(sched, scheduleWindow{start: start, end: end, preset: preset})
+	}
+	if len(sched) == 0 {
+		return nil, fmt.Errorf("schedule %q has no windows", spec)
+	}
+	return sched, nil
+}
+
+func parseClock(s string) (int, error) {
+	hh, mm, found := strings.Cut(strings.TrimSpace(s), ":")
+	if !found {
+		return 0, fmt.Errorf("invalid time %q", s)
+	}
+	h, err := strconv.Atoi(hh)
+	if err != nil || h < 0 || h > 23 {
+		return 0, fmt.Errorf("invalid hour in %q", s)
+	}
+	m, err := strconv.Atoi(mm)
+	if err != nil || m < 0 || m > 59 {
+		return 0, fmt.Errorf("invalid minute in %q", s)
+	}
+	return h*60 + m, nil
+}
+
+func (w scheduleWindow) contains(minute int) bool {
+	if w.start < w.end {
+		return minute >= w.start && minute < w.end
+	}
+	return minute >= w.start || minute < w.end
+}
+
+// resolve returns the preset name active at now (empty for the command's own
+// preset) and the time of the next window boundary.
+func (s schedule) resolve(now time.Time) (string, time.Time) {
+	minute := now.Hour()*60 + now.Minute()
+	preset := ""
+	for _, w := range s {
+		if w.contains(minute) {
+			preset = w.preset
+			break
+		}
+	}
+
+	midnight := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+	next := midnight.Add(24 * time.Hour)
+	for _, w := range s {
+		for _, m := range []int{w.start, w.end} {
+			boundary := midnight.Add(time.Duration(m) * time.Minute)
+			if !boundary.After(now) {
+				boundary = boundary.Add(24 * time.Hour)
+			}
+			if boundary.Before(next) {
+				next = boundary
+			}
+		}
+	}
+	return preset, next
+}
+
+// runSchedule loops until ctx is cancelled, running config outside the
+// schedule's windows and the window's preset (or nothing) inside them.
+// With --output-format prom, every run's summary is printed at the end,
+// labeled with its step.
+func runSchedule(ctx context.Context, config Config, sched schedule) error {
+	if config.OutputFormat == summaryProm && config.prom == nil {
+		config.prom = &promReport{}
+		defer func() {
+			if len(config.prom.runs) > 0 {
+				out.result(config.prom.String())
+			}
+		}()
+	}
+	for ctx.Err() == nil {
+		preset, until := sched.resolve(config.clock.Now())
+		active := config
+		if preset != "" {
+			active = opts.apply(presets[preset])
+			active.prom = config.prom
+		}
+
+		if preset == idlePreset {
+			out.info(fmt.Sprintf("🌙 Idle until %s", until.Format("15:04")), "idle", "until", until)
+			select {
+			case <-ctx.Done():
+			case <-config.clock.After(until.Sub(config.clock.Now())):
+			}
+			continue
+		}
+
+		out.info(fmt.Sprintf("🕒 Running %s preset until %s", getConfigName(active), until.Format("15:04")),
+			"running scheduled preset", "preset", getConfigName(active), "until", until)
+		active.Duration = until.Sub(config.clock.Now())
+		if err := runGenerator(ctx, active); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,195 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+
+	"go.opentelemetry.io/otel/log"
+)
+
+// traceDecision holds the random choices made for one trace.
+type traceDecision struct {
+	Operation    string
+	StatusCode   int
+	Dependencies []string
+	Tenant       string
+}
+
+// failed reports whether the request failed, which is the case for 5xx
+// status codes only.
+func (d traceDecision) failed() bool {
+	return d.StatusCode >= 500
+}
+
+// logDecision holds the random choices made for one log record.
+type logDecision struct {
+	Severity log.Severity
+	Message  string
+	Tenant   string
+}
+
+// scriptEntry is one line of a decision script file. Decisions are stored
+// by value rather than as random draws, so a script keeps replaying the
+// same behavior after the generator's random logic changes.
+//
+// Error is written alongside the status code for readers of the script,
+// but replay derives a trace's status from the code alone.
+type scriptEntry struct {
+	Kind         string   `json:"kind"`
+	Operation    string   `json:"operation,omitempty"`
+	Error        bool     `json:"error,omitempty"`
+	StatusCode   int      `json:"status_code,omitempty"`
+	Dependencies []string `json:"dependencies,omitempty"`
+	Severity     string   `json:"severity,omitempty"`
+	Message      string   `json:"message,omitempty"`
+	Tenant       string   `json:"tenant,omitempty"`
+}
+
+var severityByName = map[string]log.Severity{
+	log.SeverityInfo.String():  log.SeverityInfo,
+	log.SeverityWarn.String():  log.SeverityWarn,
+	log.SeverityError.String(): log.SeverityError,
+	log.SeverityFatal.String(): log.SeverityFatal,
+}
+
+// decider supplies generator decisions, either rolling them and optionally
+// recording them to a script, or replaying a previously recorded script.
+// A nil decider just rolls.
+type decider struct {
+	mu     sync.Mutex
+	file   *os.File
+	replay map[string][]scriptEntry
+	pos    map[string]int
+}
+
+// newRecorder returns a decider that writes every decision to path.
+func newRecorder(path string) (*decider, error) {
+	f, err := os.Create(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create script file: %w", err)
+	}
+	return &decider{file: f}, nil
+}
+
+// newReplayer returns a decider that replays the script at path, looping
+// over each kind of decision independently.
+func newReplayer(path string) (*decider, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open script file: %w", err)
+	}
+	defer f.Close()
+
+	d := &decider{replay: make(map[string][]scriptEntry), pos: make(map[string]int)}
+	scanner := bufio.NewScanner(f)
+	for lineNo := 1; scanner.Scan(); lineNo++ {
+		if strings.TrimSpace(scanner.Text()) == "" {
+			continue
+		}
+		var entry scriptEntry
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			return nil, fmt.Errorf("%s:%d: %w", path, lineNo, err)
+		}
+		switch entry.Kind {
+		case "trace":
+			if !strings.Contains(entry.Operation, " ") {
+				return nil, fmt.Errorf("%s:%d: operation %q is not \"METHOD /route\"", path, lineNo, entry.Operation)
+			}
+		case "log":
+			if _, ok := severityByName[entry.Severity]; !ok {
+				return nil, fmt.Errorf("%s:%d: unknown severity %q", path, lineNo, entry.Severity)
+			}
+		default:
+			return nil, fmt.Errorf("%s:%d: unknown decision kind %q", path, lineNo, entry.Kind)
+		}
+		d.replay[entry.Kind] = append(d.replay[entry.Kind], entry)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read script file: %w", err)
+	}
+	return d, nil
+}
+
+// next returns the next replayed entry of kind, if the script has any.
+func (d *decider) next(kind string) (scriptEntry, bool) {
+	entries := d.replay[kind]
+	if len(entries) == 0 {
+		return scriptEntry{}, false
+	}
+	entry := entries[d.pos[kind]%len(entries)]
+	d.pos[kind]++
+	return entry, true
+}
+
+// record appends entry to the script. Lines are written straight through so
+// an interrupted run still leaves a usable script.
+func (d *decider) record(entry scriptEntry) {
+	if d.file == nil {
+		return
+	}
+	b, _ := json.Marshal(entry)
+	d.file.Write(append(b, '\n'))
+}
+
+// trace returns the next trace decision, calling roll when not replaying.
+func (d *decider) trace(roll func() traceDecision) traceDecision {
+	if d == nil {
+		return roll()
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if entry, ok := d.next("trace"); ok {
+		return traceDecision{
+			Operation:    entry.Operation,
+			StatusCode:   entry.StatusCode,
+			Dependencies: entry.Dependencies,
+			Tenant:       entry.Tenant,
+		}
+	}
+	decision := roll()
+	d.record(scriptEntry{
+		Kind:         "trace",
+		Operation:    decision.Operation,
+		Error:        decision.failed(),
+		StatusCode:   decision.StatusCode,
+		Dependencies: decision.Dependencies,
+		Tenant:       decision.Tenant,
+	})
+	return decision
+}
+
+// log returns the next log decision, calling roll when not replaying.
+func (d *decider) log(roll func() logDecision) logDecision {
+	if d == nil {
+		return roll()
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if entry, ok := d.next("log"); ok {
+		return logDecision{Severity: severityByName[entry.Severity], Message: entry.Message, Tenant: entry.Tenant}
+	}
+	decision := roll()
+	d.record(scriptEntry{
+		Kind:     "log",
+		Severity: decision.Severity.String(),
+		Message:  decision.Message,
+		Tenant:   decision.Tenant,
+	})
+	return decision
+}
+
+// Close closes a recording.
+func (d *decider) Close() error {
+	if d == nil || d.file == nil {
+		return nil
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.file.Close()
+}
@@ -0,0 +1,184 @@
+package main
+
+import (
+	"fmt"
+	"math/rand"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+
+	"go.opentelemetry.io/otel/attribute"
+	semconv "go.opentelemetry.io/otel/semconv/v1.24.0"
+)
+
+// sqlStatements is the default statement pool of SQL databases.
+var sqlStatements = []string{
+	"SELECT id, status, total FROM orders WHERE id = $1",
+	"SELECT id, name, price FROM products WHERE category_id = $1 LIMIT 20",
+	"INSERT INTO orders (customer_id, total) VALUES ($1, $2)",
+	"UPDATE inventory SET quantity = quantity - $1 WHERE sku = $2",
+}
+
+// dbSystems are the databases recognized in dependency names, in the order
+// they are matched, with the db.system they report and their default
+// statement pool.
+var dbSystems = []struct {
+	token      string
+	system     string
+	statements []string
+}{
+	{"postgres", "postgresql", sqlStatements},
+	{"mysql", "mysql", sqlStatements},
+	{"mariadb", "mariadb", sqlStatements},
+	{"mssql", "mssql", sqlStatements},
+	{"oracle", "oracle", sqlStatements},
+	{"cassandra", "cassandra", []string{
+		"SELECT * FROM events WHERE user_id = ? LIMIT 50",
+		"INSERT INTO events (user_id, ts, kind) VALUES (?, ?, ?)",
+	}},
+	{"mongo", "mongodb", []string{
+		`{"find":"orders","filter":{"customer_id":"?"}}`,
+		`{"insert":"carts","documents":[{"customer_id":"?"}]}`,
+	}},
+	{"redis", "redis", []string{"GET session:?", "SET cart:? ?", "HGETALL user:?", "EXPIRE session:? 1800"}},
+	{"memcached", "memcached", []string{"get session:?", "set cart:? 0 1800"}},
+	{"elasticsearch", "elasticsearch", []string{
+		`{"query":{"match":{"name":"?"}}}`,
+	}},
+}
+
+// defaultDBNames and defaultExternalRequests are the --db-names and
+// --external-requests defaults.
+const (
+	defaultDBNames          = "shop,accounts,inventory"
+	defaultExternalRequests = "POST /v1/charges,GET /v1/customers,POST /v1/refunds"
+)
+
+// externalRequest is a request sent to an external dependency.
+type externalRequest struct {
+	Method string
+	Path   string
+}
+
+// semanticPools are the values dependency spans draw their semantic
+// convention attributes from with --semantic-spans.
+type semanticPools struct {
+	// Statements holds the statement pool of each db.system
+	Statements map[string][]string
+	DBNames    []string
+	Requests   []externalRequest
+}
+
+// newSemanticPools builds the pools from the --db-statement,
+// --db-names and --external-requests flags. Statements given for a
+// db.system replace its defaults.
+func newSemanticPools(statements, dbNames, requests []string) (*semanticPools, error) {
+	pools := &semanticPools{Statements: make(map[string][]string)}
+	var systems []string
+	for _, db := range dbSystems {
+		pools.Statements[db.system] = db.statements
+		systems = append(systems, db.system)
+	}
+	overridden := make(map[string]bool)
+	for _, entry := range statements {
+		system, statement, found := strings.Cut(entry, "=")
+		if _, known := pools.Statements[system]; !found || !known || statement == "" {
+			return nil, fmt.Errorf("invalid --db-statement %q, expected system=statement with system one of %s",
+				entry, strings.Join(systems, ", "))
+		}
+		if !overridden[system] {
+			pools.Statements[system] = nil
+			overridden[system] = true
+		}
+		pools.Statements[system] = append(pools.Statements[system], statement)
+	}
+	for _, name := range dbNames {
+		if name = strings.TrimSpace(name); name != "" {
+			pools.DBNames = append(pools.DBNames, name)
+		}
+	}
+	if len(pools.DBNames) == 0 {
+		return nil, fmt.Errorf("--db-names must name at least one database")
+	}
+	for _, entry := range requests {
+		method, path, found := strings.Cut(strings.TrimSpace(entry), " ")
+		if !found || !slices.Contains(httpMethods, method) || !strings.HasPrefix(path, "/") {
+			return nil, fmt.Errorf("invalid --external-requests entry %q, expected \"METHOD /path\"", entry)
+		}
+		pools.Requests = append(pools.Requests, externalRequest{Method: method, Path: path})
+	}
+	if len(pools.Requests) == 0 {
+		return nil, fmt.Errorf("--external-requests must list at least one request")
+	}
+	return pools, nil
+}
+
+// dbSystem returns the db.system of a dependency whose name mentions a
+// known database, such as postgres or orders-redis.
+func dbSystem(dep dependency) (string, bool) {
+	name := strings.ToLower(dep.Name)
+	for _, db := range dbSystems {
+		if strings.Contains(name, db.token) {
+			return db.system, true
+		}
+	}
+	return "", false
+}
+
+// dependencyCall is how a dependency span looks with --semantic-spans.
+type dependencyCall struct {
+	Name   string
+	Attrs  []attribute.KeyValue
+	Failed bool
+}
+
+// call returns the span name and attributes for a call to dep: a database
+// query when its name mentions a known database, otherwise an HTTP request
+// to an external API. failed makes an HTTP request return 503.
+func (p *semanticPools) call(dep dependency, failed bool) dependencyCall {
+	if system, ok := dbSystem(dep); ok {
+		statement := pick(p.Statements[system])
+		dbName := pick(p.DBNames)
+		operation, _, _ := strings.Cut(statement, " ")
+		if strings.HasPrefix(statement, "{") {
+			// Document databases name the command first instead
+			operation, _, _ = strings.Cut(strings.Trim(statement, `{"`), `"`)
+		}
+		return dependencyCall{
+			Name: operation + " " + dbName,
+			Attrs: []attribute.KeyValue{
+				semconv.DBSystemKey.String(system),
+				semconv.DBName(dbName),
+				semconv.DBStatement(statement),
+			},
+		}
+	}
+
+	req := p.Requests[rand.Intn(len(p.Requests))]
+	status := http.StatusOK
+	if failed {
+		status = http.StatusServiceUnavailable
+	}
+	attrs := []attribute.KeyValue{
+		semconv.HTTPRequestMethodKey.String(req.Method),
+		semconv.HTTPResponseStatusCode(status),
+	}
+	// Dependencies with an address already carry server.address and
+	// server.port; replayed ones that are no longer configured don't
+	host := dep.Address
+	if host == "" {
+		host = dep.Name
+		attrs = append(attrs, semconv.ServerAddress(host))
+	}
+	url := "https://" + host
+	if dep.Port != 0 && dep.Port != 443 {
+		url += ":" + strconv.Itoa(dep.Port)
+	}
+	attrs = append(attrs, semconv.URLFull(url+req.Path))
+	return dependencyCall{Name: req.Method, Attrs: attrs, Failed: failed}
+}
+
+func pick(values []string) string {
+	return values[rand.Intn(len(values))]
+}
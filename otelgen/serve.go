@@ -0,0 +1,189 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
+)
+
+var serveAddr string
+
+// controller runs at most one preset at a time on behalf of the control API.
+type controller struct {
+	ctx context.Context
+
+	mu      sync.Mutex
+	preset  string
+	started time.Time
+	cancel  context.CancelFunc
+	done    chan struct{}
+	lastErr error
+}
+
+// startRequest is the body of POST /start. Duration is optional; without
+// it the preset runs until POST /stop.
+type startRequest struct {
+	Preset   string `json:"preset"`
+	Duration string `json:"duration,omitempty"`
+}
+
+// serveStatus is the body of GET /status and of successful start and stop
+// responses.
+type serveStatus struct {
+	Running   bool       `json:"running"`
+	Preset    string     `json:"preset,omitempty"`
+	StartedAt *time.Time `json:"started_at,omitempty"`
+	LastError string     `json:"last_error,omitempty"`
+}
+
+// runServe starts idle and serves the control API on addr until
+// interrupted.
+func runServe(parent context.Context, addr string) error {
+	if err := opts.load(); err != nil {
+		return err
+	}
+	defer opts.decisions.Close()
+	defer opts.stream.Close()
+	if opts.Schedule != "" {
+		return errors.New("--schedule cannot be used with serve")
+	}
+
+	ctx, stop := signal.NotifyContext(parent, os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	c := &controller{ctx: ctx}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/start", c.handleStart)
+	mux.HandleFunc("/stop", c.handleStop)
+	mux.HandleFunc("/status", c.handleStatus)
+	server := &http.Server{Addr: addr, Handler: mux}
+
+	errCh := make(chan error, 1)
+	go func() { errCh <- server.ListenAndServe() }()
+	out.info(fmt.Sprintf("🎛️  Control API listening on %s", addr), "control API listening", "address", addr)
+
+	select {
+	case err := <-errCh:
+		return err
+	case <-ctx.Done():
+	}
+	c.stop()
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	return server.Shutdown(shutdownCtx)
+}
+
+func (c *controller) handleStart(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	var req startRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request, expected {\"preset\": \"low\"}", http.StatusBadRequest)
+		return
+	}
+	config, ok := presets[req.Preset]
+	if !ok {
+		http.Error(w, fmt.Sprintf("Unknown preset %q, expected low, medium, high or stress", req.Preset), http.StatusBadRequest)
+		return
+	}
+	config = opts.apply(config)
+	config.Duration = 0
+	if req.Duration != "" {
+		d, err := time.ParseDuration(req.Duration)
+		if err != nil || d <= 0 {
+			http.Error(w, fmt.Sprintf("Invalid duration %q", req.Duration), http.StatusBadRequest)
+			return
+		}
+		config.Duration = d
+	}
+
+	if !c.start(req.Preset, config) {
+		http.Error(w, "A preset is already running, stop it first", http.StatusConflict)
+		return
+	}
+	c.writeStatus(w)
+}
+
+func (c *controller) handleStop(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	c.stop()
+	c.writeStatus(w)
+}
+
+func (c *controller) handleStatus(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	c.writeStatus(w)
+}
+
+// start runs config in the background unless a preset is already running.
+func (c *controller) start(preset string, config Config) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.done != nil {
+		return false
+	}
+	ctx, cancel := context.WithCancel(c.ctx)
+	done := make(chan struct{})
+	c.preset, c.started, c.cancel, c.done, c.lastErr = preset, time.Now(), cancel, done, nil
+
+	go func() {
+		err := runGenerator(ctx, config)
+		cancel()
+
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		c.lastErr = err
+		c.done = nil
+		close(done)
+	}()
+	return true
+}
+
+// stop cancels the running preset, if any, and waits for it to finish.
+func (c *controller) stop() {
+	c.mu.Lock()
+	cancel, done := c.cancel, c.done
+	c.mu.Unlock()
+
+	if done == nil {
+		return
+	}
+	cancel()
+	<-done
+}
+
+func (c *controller) status() serveStatus {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	status := serveStatus{Running: c.done != nil}
+	if status.Running {
+		started := c.started
+		status.Preset, status.StartedAt = c.preset, &started
+	}
+	if c.lastErr != nil {
+		status.LastError = c.lastErr.Error()
+	}
+	return status
+}
+
+func (c *controller) writeStatus(w http.ResponseWriter) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(c.status())
+}
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"fmt"
+	"math/rand"
+	"slices"
+	"strings"
+	"time"
+)
+
+// Span kinds --span-latency takes a profile for. Client spans are split
+// into database and external calls, which client sets at once.
+const (
+	kindServer   = "server"
+	kindInternal = "internal"
+	kindDB       = "db"
+	kindExternal = "external"
+	kindClient   = "client"
+)
+
+var latencyKinds = []string{kindServer, kindInternal, kindDB, kindExternal, kindClient}
+
+// latencyProfile is how long one kind of span takes: Base plus a jitter
+// drawn from an exponential distribution with mean Jitter. The larger
+// Jitter is next to Base, the fatter the tail.
+type latencyProfile struct {
+	Base   time.Duration
+	Jitter time.Duration
+}
+
+func (p latencyProfile) sample() time.Duration {
+	return p.Base + time.Duration(rand.ExpFloat64()*float64(p.Jitter))
+}
+
+func (p latencyProfile) String() string {
+	return p.Base.String() + "+" + p.Jitter.String()
+}
+
+// kindLatencies holds the latency profile of each span kind given one.
+// Kinds without a profile keep the --min-latency and --max-latency timing.
+type kindLatencies map[string]latencyProfile
+
+// parseSpanLatencies parses --span-latency entries like
+// "external=40ms+120ms" or "db=2ms" (kind=base[+jitter]).
+func parseSpanLatencies(entries []string) (kindLatencies, error) {
+	if len(entries) == 0 {
+		return nil, nil
+	}
+	latencies := make(kindLatencies)
+	for _, entry := range entries {
+		kind, spec, found := strings.Cut(strings.TrimSpace(entry), "=")
+		if !found || !slices.Contains(latencyKinds, kind) {
+			return nil, fmt.Errorf("invalid --span-latency %q, expected kind=base[+jitter] with kind one of %s",
+				entry, strings.Join(latencyKinds, ", "))
+		}
+		var p latencyProfile
+		base, jitter, hasJitter := strings.Cut(spec, "+")
+		var err error
+		if p.Base, err = time.ParseDuration(base); err == nil && hasJitter {
+			p.Jitter, err = time.ParseDuration(jitter)
+		}
+		if err != nil || p.Base < 0 || p.Jitter < 0 || p.Base+p.Jitter == 0 {
+			return nil, fmt.Errorf("invalid --span-latency %q, expected positive durations as kind=base[+jitter], e.g. external=40ms+120ms", entry)
+		}
+		if kind == kindClient {
+			latencies[kindDB], latencies[kindExternal] = p, p
+			continue
+		}
+		latencies[kind] = p
+	}
+	return latencies, nil
+}
+
+// get returns the profile of kind, if it has one.
+func (k kindLatencies) get(kind string) (latencyProfile, bool) {
+	p, ok := k[kind]
+	return p, ok
+}
+
+// String lists the profiles in a stable order for the startup banner.
+func (k kindLatencies) String() string {
+	var parts []string
+	for _, kind := range latencyKinds {
+		if p, ok := k[kind]; ok {
+			parts = append(parts, kind+"="+p.String())
+		}
+	}
+	return strings.Join(parts, ", ")
+}
+
+// dependencyKind is the --span-latency kind of a call to dep.
+func dependencyKind(dep dependency) string {
+	if _, ok := dbSystem(dep); ok {
+		return kindDB
+	}
+	return kindExternal
+}
+
+// spikeSlowdown stretches d as requests slow down under a spike, up to
+// spikeLatencyFactor times at full slowdown.
+func spikeSlowdown(d time.Duration, slowdown float64) time.Duration {
+	return d + time.Duration(float64(d)*slowdown*(spikeLatencyFactor-1))
+}
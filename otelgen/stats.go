@@ -0,0 +1,326 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"text/tabwriter"
+	"time"
+
+	sdklog "go.opentelemetry.io/otel/sdk/log"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+)
+
+// signalStats counts the items of one signal through a run. Generated
+// items are counted by the generators, exported and failed ones by the
+// exporter wrappers below.
+type signalStats struct {
+	generated    atomic.Int64
+	exported     atomic.Int64
+	failed       atomic.Int64
+	exportErrors atomic.Int64
+	// passes counts the generator's paced iterations: traces, metric
+	// collections or log records, the units the preset's rates are in
+	passes  atomic.Int64
+	exports atomic.Int64
+	// latencies holds the duration of every export call when set, for
+	// otelgen bench
+	latencies *latencyRecorder
+}
+
+// exportDone records the outcome of one export call of n items, which
+// took elapsed.
+func (s *signalStats) exportDone(n int, err error, elapsed time.Duration) {
+	s.exports.Add(1)
+	s.latencies.record(elapsed)
+	if err != nil {
+		s.failed.Add(int64(n))
+		s.exportErrors.Add(1)
+		return
+	}
+	s.exported.Add(int64(n))
+}
+
+// runStats holds the counters printed at the end of a run. Metrics count
+// recorded measurements as generated and data points as exported, so the
+// two columns aren't expected to match for them.
+type runStats struct {
+	spans   signalStats
+	metrics signalStats
+	logs    signalStats
+}
+
+// Run summary formats, chosen with --output-format.
+const (
+	summaryTable = "table"
+	summaryJSON  = "json"
+	summaryProm  = "prom"
+)
+
+// summary prints the counters of a run of elapsed in format: as a table,
+// or one structured line with --log-format json, for table, and as a
+// result for json and prom.
+func (s *runStats) summary(preset, format string, elapsed time.Duration) {
+	switch format {
+	case summaryJSON:
+		s.jsonSummary(preset, elapsed)
+		return
+	case summaryProm:
+		s.promSummary(preset, elapsed)
+		return
+	}
+
+	var table strings.Builder
+	tw := tabwriter.NewWriter(&table, 0, 0, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprintln(tw, "signal\tgenerated\texported\tfailed\texport errors\t")
+	args := []any{"preset", preset}
+	for _, row := range s.rows() {
+		generated, exported := row.stats.generated.Load(), row.stats.exported.Load()
+		failed, errs := row.stats.failed.Load(), row.stats.exportErrors.Load()
+		fmt.Fprintf(tw, "%s\t%d\t%d\t%d\t%d\t\n", row.name, generated, exported, failed, errs)
+		args = append(args,
+			row.name+"_generated", generated,
+			row.name+"_exported", exported,
+			row.name+"_failed", failed,
+			row.name+"_export_errors", errs)
+	}
+	tw.Flush()
+
+	out.info(fmt.Sprintf("📈 %s run summary:\n%s", preset, strings.TrimRight(table.String(), "\n")),
+		"run summary", args...)
+}
+
+// summaryRow is one signal of the run summary. items names what the
+// signal's generated column counts, for the Prometheus help text.
+type summaryRow struct {
+	name  string
+	items string
+	stats *signalStats
+}
+
+func (s *runStats) rows() []summaryRow {
+	return []summaryRow{
+		{"spans", "Spans", &s.spans},
+		{"metrics", "Metric measurements", &s.metrics},
+		{"logs", "Log records", &s.logs},
+	}
+}
+
+// signalSummary is one signal's counters in the JSON summary.
+type signalSummary struct {
+	Generated    int64 `json:"generated"`
+	Exported     int64 `json:"exported"`
+	Failed       int64 `json:"failed"`
+	ExportErrors int64 `json:"export_errors"`
+}
+
+// jsonSummary prints the counters as one JSON object on its own line, so
+// the summaries of several runs read as JSON Lines.
+func (s *runStats) jsonSummary(preset string, elapsed time.Duration) {
+	summary := struct {
+		Preset         string                   `json:"preset"`
+		ElapsedSeconds float64                  `json:"elapsed_seconds"`
+		Signals        map[string]signalSummary `json:"signals"`
+	}{Preset: strings.ToLower(preset), ElapsedSeconds: elapsed.Seconds(), Signals: make(map[string]signalSummary)}
+	for _, row := range s.rows() {
+		summary.Signals[row.name] = signalSummary{
+			Generated:    row.stats.generated.Load(),
+			Exported:     row.stats.exported.Load(),
+			Failed:       row.stats.failed.Load(),
+			ExportErrors: row.stats.exportErrors.Load(),
+		}
+	}
+	data, err := json.Marshal(summary)
+	if err != nil {
+		out.warn(fmt.Sprintf("⚠️  Failed to encode the run summary: %v", err), "failed to encode run summary", "error", err)
+		return
+	}
+	out.result(string(data) + "\n")
+}
+
+// promSummary prints the counters in the Prometheus text exposition
+// format, labeled with the preset, for a textfile collector or a
+// Pushgateway.
+func (s *runStats) promSummary(preset string, elapsed time.Duration) {
+	var report promReport
+	report.add(s, preset, elapsed, false)
+	out.result(report.String())
+}
+
+// promReport collects the counters of one or more runs for a single
+// Prometheus exposition, where each metric family may only appear once.
+// Metrics exports count data points, not measurements, so
+// otelgen_metrics_total and otelgen_metrics_exported_total don't match.
+type promReport struct {
+	runs []promRun
+}
+
+// promRun is one run of a promReport.
+type promRun struct {
+	labels  string
+	elapsed time.Duration
+	stats   *runStats
+}
+
+// add appends a run of preset that took elapsed. With step, it is also
+// labeled with its position in the report, counting from 1, as in the
+// steps of a --schedule.
+func (r *promReport) add(stats *runStats, preset string, elapsed time.Duration, step bool) {
+	labels := "preset=" + promLabelValue(strings.ToLower(preset))
+	if step {
+		labels += ",step=" + promLabelValue(strconv.Itoa(len(r.runs)+1))
+	}
+	r.runs = append(r.runs, promRun{labels: "{" + labels + "}", elapsed: elapsed, stats: stats})
+}
+
+// String formats the report, with every family's samples for all runs
+// under one HELP and TYPE.
+func (r *promReport) String() string {
+	var b strings.Builder
+	family := func(name, kind, help string, value func(run promRun) float64) {
+		fmt.Fprintf(&b, "# HELP otelgen_%s %s\n# TYPE otelgen_%s %s\n", name, help, name, kind)
+		for _, run := range r.runs {
+			fmt.Fprintf(&b, "otelgen_%s%s %s\n", name, run.labels, strconv.FormatFloat(value(run), 'g', -1, 64))
+		}
+	}
+	family("run_duration_seconds", "gauge", "How long the run generated telemetry.",
+		func(run promRun) float64 { return run.elapsed.Seconds() })
+	for i, row := range (&runStats{}).rows() {
+		signal := func(run promRun) *signalStats { return run.stats.rows()[i].stats }
+		family(row.name+"_total", "counter", row.items+" generated.",
+			func(run promRun) float64 { return float64(signal(run).generated.Load()) })
+		exported := row.items
+		if row.name == "metrics" {
+			exported = "Metric data points"
+		}
+		family(row.name+"_exported_total", "counter", exported+" exported.",
+			func(run promRun) float64 { return float64(signal(run).exported.Load()) })
+		family(row.name+"_failed_total", "counter", exported+" that failed to export.",
+			func(run promRun) float64 { return float64(signal(run).failed.Load()) })
+		family(row.name+"_export_errors_total", "counter", "Failed "+row.name+" export calls.",
+			func(run promRun) float64 { return float64(signal(run).exportErrors.Load()) })
+	}
+	return b.String()
+}
+
+// promLabelValue quotes v as a Prometheus label value, escaping only the
+// backslashes, double quotes and line feeds the exposition format escapes.
+func promLabelValue(v string) string {
+	return `"` + promLabelEscaper.Replace(v) + `"`
+}
+
+var promLabelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+// latencyRecorder keeps export call durations for percentiles. A nil
+// recorder keeps nothing.
+type latencyRecorder struct {
+	mu        sync.Mutex
+	durations []time.Duration
+}
+
+func (l *latencyRecorder) record(d time.Duration) {
+	if l == nil {
+		return
+	}
+	l.mu.Lock()
+	l.durations = append(l.durations, d)
+	l.mu.Unlock()
+}
+
+// percentile returns the duration p (0 to 1) of the recorded calls took
+// at most, and false when none were recorded.
+func (l *latencyRecorder) percentile(p float64) (time.Duration, bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if len(l.durations) == 0 {
+		return 0, false
+	}
+	sorted := slices.Sorted(slices.Values(l.durations))
+	i := int(math.Ceil(p*float64(len(sorted)))) - 1
+	return sorted[max(0, i)], true
+}
+
+// countingSpanExporter counts exported and failed spans, and spends the
+// size of exported ones from the byte budget.
+type countingSpanExporter struct {
+	sdktrace.SpanExporter
+	stats  *signalStats
+	budget *byteBudget
+}
+
+func (e countingSpanExporter) ExportSpans(ctx context.Context, spans []sdktrace.ReadOnlySpan) error {
+	start := time.Now()
+	err := e.SpanExporter.ExportSpans(ctx, spans)
+	e.stats.exportDone(len(spans), err, time.Since(start))
+	if err == nil && e.budget != nil {
+		e.budget.spend(spansSize(spans))
+	}
+	return err
+}
+
+// countingMetricExporter counts exported and failed metric data points,
+// and spends the size of exported ones from the byte budget.
+type countingMetricExporter struct {
+	sdkmetric.Exporter
+	stats  *signalStats
+	budget *byteBudget
+}
+
+func (e countingMetricExporter) Export(ctx context.Context, rm *metricdata.ResourceMetrics) error {
+	start := time.Now()
+	err := e.Exporter.Export(ctx, rm)
+	e.stats.exportDone(dataPoints(rm), err, time.Since(start))
+	if err == nil && e.budget != nil {
+		e.budget.spend(metricsSize(rm))
+	}
+	return err
+}
+
+// dataPoints returns the number of gauge, sum and histogram data points in
+// rm.
+func dataPoints(rm *metricdata.ResourceMetrics) int {
+	n := 0
+	for _, sm := range rm.ScopeMetrics {
+		for _, m := range sm.Metrics {
+			switch data := m.Data.(type) {
+			case metricdata.Gauge[float64]:
+				n += len(data.DataPoints)
+			case metricdata.Gauge[int64]:
+				n += len(data.DataPoints)
+			case metricdata.Sum[float64]:
+				n += len(data.DataPoints)
+			case metricdata.Sum[int64]:
+				n += len(data.DataPoints)
+			case metricdata.Histogram[float64]:
+				n += len(data.DataPoints)
+			}
+		}
+	}
+	return n
+}
+
+// countingLogExporter counts exported and failed log records, and spends
+// the size of exported ones from the byte budget.
+type countingLogExporter struct {
+	sdklog.Exporter
+	stats  *signalStats
+	budget *byteBudget
+}
+
+func (e countingLogExporter) Export(ctx context.Context, records []sdklog.Record) error {
+	start := time.Now()
+	err := e.Exporter.Export(ctx, records)
+	e.stats.exportDone(len(records), err, time.Since(start))
+	if err == nil && e.budget != nil {
+		e.budget.spend(logsSize(records))
+	}
+	return err
+}
@@ -0,0 +1,51 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPromLabelValue(t *testing.T) {
+	assert.Equal(t, `"low"`, promLabelValue("low"))
+	assert.Equal(t, `"a\\b\"c\nd"`, promLabelValue("a\\b\"c\nd"))
+	// Unlike %q, other characters are written as they are.
+	assert.Equal(t, `"café	x"`, promLabelValue("café\tx"))
+}
+
+func TestPromReportSteps(t *testing.T) {
+	var first, second runStats
+	first.spans.generated.Store(10)
+	second.spans.generated.Store(25)
+	second.logs.exportErrors.Store(2)
+
+	var report promReport
+	report.add(&first, "Low", 90*time.Second, true)
+	report.add(&second, "High", 30*time.Second, true)
+	text := report.String()
+
+	for _, name := range []string{"run_duration_seconds", "spans_total", "logs_export_errors_total"} {
+		assert.Equal(t, 1, strings.Count(text, "# HELP otelgen_"+name+" "), name)
+		assert.Equal(t, 1, strings.Count(text, "# TYPE otelgen_"+name+" "), name)
+	}
+	assert.Contains(t, text, "otelgen_run_duration_seconds{preset=\"low\",step=\"1\"} 90\n"+
+		"otelgen_run_duration_seconds{preset=\"high\",step=\"2\"} 30\n")
+	assert.Contains(t, text, "otelgen_spans_total{preset=\"low\",step=\"1\"} 10\n"+
+		"otelgen_spans_total{preset=\"high\",step=\"2\"} 25\n")
+	assert.Contains(t, text, "otelgen_logs_export_errors_total{preset=\"high\",step=\"2\"} 2\n")
+}
+
+func TestPromReportSingleRun(t *testing.T) {
+	var stats runStats
+	stats.metrics.exported.Store(4)
+
+	var report promReport
+	report.add(&stats, "Medium", time.Second, false)
+	text := report.String()
+
+	assert.Contains(t, text, "# TYPE otelgen_metrics_exported_total counter\n"+
+		"otelgen_metrics_exported_total{preset=\"medium\"} 4\n")
+	assert.NotContains(t, text, "step=")
+}
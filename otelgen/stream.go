@@ -0,0 +1,249 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"sync"
+
+	collogspb "go.opentelemetry.io/proto/otlp/collector/logs/v1"
+	colmetricpb "go.opentelemetry.io/proto/otlp/collector/metrics/v1"
+	coltracepb "go.opentelemetry.io/proto/otlp/collector/trace/v1"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/protobuf/encoding/protodelim"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/anypb"
+)
+
+// otlpStream writes telemetry to stdout or a file instead of a collector.
+// The regular gRPC exporters talk to an in-process OTLP server over an
+// in-memory connection, so the stream holds exactly the ExportRequests
+// they would have sent over the network: one OTLP JSON document per line,
+// or length-delimited protobuf messages. The three request types can't be
+// told apart from their bytes, so each protobuf record is a
+// google.protobuf.Any whose type URL names the request's message type,
+// such as type.googleapis.com/opentelemetry.proto.collector.trace.v1.ExportTraceServiceRequest.
+type otlpStream struct {
+	encoding string
+
+	mu     sync.Mutex
+	w      *bufio.Writer
+	closer io.Closer
+
+	server *grpc.Server
+	conn   *grpc.ClientConn
+}
+
+// openStream starts a stream to path, where "-" is stdout, in the given
+// encoding, json or proto. Writing to stdout moves otelgen's own messages
+// to stderr so they don't corrupt the stream.
+func openStream(path, encoding string) (*otlpStream, error) {
+	s := &otlpStream{encoding: encoding}
+	if path == "-" {
+		s.w = bufio.NewWriter(os.Stdout)
+		out.w = os.Stderr
+	} else {
+		f, err := os.Create(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to open --output: %w", err)
+		}
+		s.w, s.closer = bufio.NewWriter(f), f
+	}
+
+	lis := newPipeListener()
+	s.server = grpc.NewServer()
+	coltracepb.RegisterTraceServiceServer(s.server, streamTraces{s: s})
+	colmetricpb.RegisterMetricsServiceServer(s.server, streamMetrics{s: s})
+	collogspb.RegisterLogsServiceServer(s.server, streamLogs{s: s})
+	go s.server.Serve(lis)
+
+	conn, err := grpc.NewClient("passthrough:///otlp-stream",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return lis.dial(ctx)
+		}),
+		grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		s.server.Stop()
+		if s.closer != nil {
+			s.closer.Close()
+		}
+		return nil, fmt.Errorf("failed to connect to the output stream: %w", err)
+	}
+	s.conn = conn
+	return s, nil
+}
+
+// pipeListener is a net.Listener whose connections are net.Pipe pairs
+// made by dial, so the exporters reach the stream's server without a
+// network socket.
+type pipeListener struct {
+	conns     chan net.Conn
+	done      chan struct{}
+	closeOnce sync.Once
+}
+
+func newPipeListener() *pipeListener {
+	return &pipeListener{conns: make(chan net.Conn), done: make(chan struct{})}
+}
+
+// dial connects to the listener, waiting until Accept takes the other end.
+func (l *pipeListener) dial(ctx context.Context) (net.Conn, error) {
+	client, server := net.Pipe()
+	var err error
+	select {
+	case l.conns <- server:
+		return client, nil
+	case <-l.done:
+		err = net.ErrClosed
+	case <-ctx.Done():
+		err = ctx.Err()
+	}
+	client.Close()
+	server.Close()
+	return nil, err
+}
+
+func (l *pipeListener) Accept() (net.Conn, error) {
+	select {
+	case conn := <-l.conns:
+		return conn, nil
+	case <-l.done:
+		return nil, net.ErrClosed
+	}
+}
+
+func (l *pipeListener) Close() error {
+	l.closeOnce.Do(func() { close(l.done) })
+	return nil
+}
+
+func (l *pipeListener) Addr() net.Addr { return pipeAddr{} }
+
+// pipeAddr is the address of every pipeListener.
+type pipeAddr struct{}
+
+func (pipeAddr) Network() string { return "pipe" }
+func (pipeAddr) String() string  { return "otlp-stream" }
+
+// streamTraces, streamMetrics and streamLogs are the stream's OTLP
+// services, each writing the requests it receives.
+type streamTraces struct {
+	coltracepb.UnimplementedTraceServiceServer
+	s *otlpStream
+}
+
+func (t streamTraces) Export(ctx context.Context, req *coltracepb.ExportTraceServiceRequest) (*coltracepb.ExportTraceServiceResponse, error) {
+	return &coltracepb.ExportTraceServiceResponse{}, t.s.write(req)
+}
+
+type streamMetrics struct {
+	colmetricpb.UnimplementedMetricsServiceServer
+	s *otlpStream
+}
+
+func (m streamMetrics) Export(ctx context.Context, req *colmetricpb.ExportMetricsServiceRequest) (*colmetricpb.ExportMetricsServiceResponse, error) {
+	return &colmetricpb.ExportMetricsServiceResponse{}, m.s.write(req)
+}
+
+type streamLogs struct {
+	collogspb.UnimplementedLogsServiceServer
+	s *otlpStream
+}
+
+func (l streamLogs) Export(ctx context.Context, req *collogspb.ExportLogsServiceRequest) (*collogspb.ExportLogsServiceResponse, error) {
+	return &collogspb.ExportLogsServiceResponse{}, l.s.write(req)
+}
+
+// write appends one ExportRequest to the stream and flushes it, so a
+// reading process sees each request as soon as it is exported.
+func (s *otlpStream) write(msg proto.Message) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.encoding == "proto" {
+		record, err := anypb.New(msg)
+		if err != nil {
+			return err
+		}
+		if _, err := protodelim.MarshalTo(s.w, record); err != nil {
+			return err
+		}
+	} else {
+		b, err := marshalOTLPJSON(msg)
+		if err != nil {
+			return err
+		}
+		s.w.Write(b)
+		s.w.WriteByte('\n')
+	}
+	return s.w.Flush()
+}
+
+// Close stops the server and closes the output. A nil stream is a no-op.
+func (s *otlpStream) Close() error {
+	if s == nil {
+		return nil
+	}
+	s.conn.Close()
+	s.server.Stop()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	err := s.w.Flush()
+	if s.closer != nil {
+		if cerr := s.closer.Close(); err == nil {
+			err = cerr
+		}
+	}
+	return err
+}
+
+// otlpIDFields are the bytes fields OTLP JSON writes as hex rather than
+// the base64 of the standard protobuf JSON mapping.
+var otlpIDFields = map[string]bool{"traceId": true, "spanId": true, "parentSpanId": true}
+
+// marshalOTLPJSON encodes msg as OTLP JSON, which differs from the
+// protobuf JSON mapping in writing IDs as hex and enums as integers.
+func marshalOTLPJSON(msg proto.Message) ([]byte, error) {
+	b, err := protojson.MarshalOptions{UseEnumNumbers: true}.Marshal(msg)
+	if err != nil {
+		return nil, err
+	}
+	dec := json.NewDecoder(bytes.NewReader(b))
+	dec.UseNumber()
+	var doc any
+	if err := dec.Decode(&doc); err != nil {
+		return nil, err
+	}
+	hexIDs(doc)
+	return json.Marshal(doc)
+}
+
+// hexIDs rewrites the base64 ID fields in a decoded JSON document as hex.
+func hexIDs(v any) {
+	switch v := v.(type) {
+	case map[string]any:
+		for key, value := range v {
+			if s, ok := value.(string); ok && otlpIDFields[key] {
+				if id, err := base64.StdEncoding.DecodeString(s); err == nil {
+					v[key] = hex.EncodeToString(id)
+				}
+				continue
+			}
+			hexIDs(value)
+		}
+	case []any:
+		for _, item := range v {
+			hexIDs(item)
+		}
+	}
+}
@@ -0,0 +1,109 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	collogspb "go.opentelemetry.io/proto/otlp/collector/logs/v1"
+	coltracepb "go.opentelemetry.io/proto/otlp/collector/trace/v1"
+	commonpb "go.opentelemetry.io/proto/otlp/common/v1"
+	logspb "go.opentelemetry.io/proto/otlp/logs/v1"
+	tracepb "go.opentelemetry.io/proto/otlp/trace/v1"
+	"google.golang.org/protobuf/encoding/protodelim"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/anypb"
+)
+
+var (
+	streamTraceRequest = &coltracepb.ExportTraceServiceRequest{
+		ResourceSpans: []*tracepb.ResourceSpans{{
+			ScopeSpans: []*tracepb.ScopeSpans{{
+				Spans: []*tracepb.Span{{
+					TraceId: []byte{1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15, 16},
+					SpanId:  []byte{1, 2, 3, 4, 5, 6, 7, 8},
+					Name:    "GET /cart",
+				}},
+			}},
+		}},
+	}
+	streamLogsRequest = &collogspb.ExportLogsServiceRequest{
+		ResourceLogs: []*logspb.ResourceLogs{{
+			ScopeLogs: []*logspb.ScopeLogs{{
+				LogRecords: []*logspb.LogRecord{{
+					SeverityText: "ERROR",
+					Body:         &commonpb.AnyValue{Value: &commonpb.AnyValue_StringValue{StringValue: "boom"}},
+				}},
+			}},
+		}},
+	}
+)
+
+// exportToStream opens a stream to a file in encoding, exports a trace
+// and a logs request through it and returns the file's path.
+func exportToStream(t *testing.T, encoding string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "out")
+	s, err := openStream(path, encoding)
+	require.NoError(t, err)
+
+	ctx := context.Background()
+	_, err = coltracepb.NewTraceServiceClient(s.conn).Export(ctx, streamTraceRequest)
+	require.NoError(t, err)
+	_, err = collogspb.NewLogsServiceClient(s.conn).Export(ctx, streamLogsRequest)
+	require.NoError(t, err)
+	require.NoError(t, s.Close())
+	return path
+}
+
+func TestStreamProto(t *testing.T) {
+	f, err := os.Open(exportToStream(t, "proto"))
+	require.NoError(t, err)
+	defer f.Close()
+	r := bufio.NewReader(f)
+
+	var records []*anypb.Any
+	for {
+		record := &anypb.Any{}
+		if err := protodelim.UnmarshalFrom(r, record); err != nil {
+			break
+		}
+		records = append(records, record)
+	}
+	require.Len(t, records, 2)
+
+	assert.Equal(t, "type.googleapis.com/opentelemetry.proto.collector.trace.v1.ExportTraceServiceRequest", records[0].TypeUrl)
+	traces := &coltracepb.ExportTraceServiceRequest{}
+	require.NoError(t, records[0].UnmarshalTo(traces))
+	assert.True(t, proto.Equal(streamTraceRequest, traces))
+
+	assert.Equal(t, "type.googleapis.com/opentelemetry.proto.collector.logs.v1.ExportLogsServiceRequest", records[1].TypeUrl)
+	logs, err := records[1].UnmarshalNew()
+	require.NoError(t, err)
+	assert.True(t, proto.Equal(streamLogsRequest, logs))
+}
+
+func TestStreamJSON(t *testing.T) {
+	f, err := os.Open(exportToStream(t, "json"))
+	require.NoError(t, err)
+	defer f.Close()
+
+	var lines []map[string]any
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var doc map[string]any
+		require.NoError(t, json.Unmarshal(scanner.Bytes(), &doc))
+		lines = append(lines, doc)
+	}
+	require.Len(t, lines, 2)
+
+	span := lines[0]["resourceSpans"].([]any)[0].(map[string]any)["scopeSpans"].([]any)[0].(map[string]any)["spans"].([]any)[0].(map[string]any)
+	assert.Equal(t, "0102030405060708090a0b0c0d0e0f10", span["traceId"])
+	assert.Equal(t, "0102030405060708", span["spanId"])
+	assert.Contains(t, lines[1], "resourceLogs")
+}
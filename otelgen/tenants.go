@@ -0,0 +1,110 @@
+package main
+
+import (
+	"fmt"
+	"math/rand"
+	"strconv"
+	"strings"
+)
+
+// tenantIDKey is the span and log attribute naming the tenant.
+const tenantIDKey = "tenant.id"
+
+// tenant is a simulated customer of a multi-tenant service. A negative
+// ErrorRate means the preset's error rate applies.
+type tenant struct {
+	Name      string
+	Weight    float64
+	ErrorRate float64
+}
+
+// errorRate returns the tenant's own error rate, or fallback when unset.
+func (t tenant) errorRate(fallback float64) float64 {
+	if t.ErrorRate < 0 {
+		return fallback
+	}
+	return t.ErrorRate
+}
+
+// parseTenants parses --tenants: either a list like
+// "acme=5,globex=2:0.2,initech=1" of name=weight[:error_rate] entries, or
+// "@path" to read the same entries from a file, one per line.
+func parseTenants(spec string) ([]tenant, error) {
+	if path, ok := strings.CutPrefix(spec, "@"); ok {
+		return loadTenants(path)
+	}
+	var tenants []tenant
+	for _, part := range strings.Split(spec, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		t, err := parseTenant(part)
+		if err != nil {
+			return nil, err
+		}
+		tenants = append(tenants, t)
+	}
+	return tenants, nil
+}
+
+// loadTenants reads a tenants file. Each non-empty line that isn't a "#"
+// comment holds one name=weight[:error_rate] entry.
+func loadTenants(path string) ([]tenant, error) {
+	var tenants []tenant
+	err := readLines(path, "tenants", func(line string) error {
+		t, err := parseTenant(line)
+		if err == nil {
+			tenants = append(tenants, t)
+		}
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	if len(tenants) == 0 {
+		return nil, fmt.Errorf("tenants file %s defines no tenants", path)
+	}
+	return tenants, nil
+}
+
+func parseTenant(entry string) (tenant, error) {
+	name, rest, found := strings.Cut(entry, "=")
+	if !found || name == "" {
+		return tenant{}, fmt.Errorf("invalid tenant %q, expected name=weight[:error_rate]", entry)
+	}
+	weightStr, rateStr, hasRate := strings.Cut(rest, ":")
+	weight, err := strconv.ParseFloat(weightStr, 64)
+	if err != nil || weight <= 0 {
+		return tenant{}, fmt.Errorf("invalid tenant %q, weight must be a positive number", entry)
+	}
+	t := tenant{Name: name, Weight: weight, ErrorRate: -1}
+	if hasRate {
+		rate, err := strconv.ParseFloat(rateStr, 64)
+		if err != nil || rate < 0 || rate > 1 {
+			return tenant{}, fmt.Errorf("invalid tenant %q, error rate must be between 0 and 1", entry)
+		}
+		t.ErrorRate = rate
+	}
+	return t, nil
+}
+
+// pickTenant draws a tenant in proportion to the weights. It returns the
+// zero tenant when none are configured.
+func pickTenant(tenants []tenant) tenant {
+	total := 0.0
+	for _, t := range tenants {
+		total += t.Weight
+	}
+	roll := rand.Float64() * total
+	for _, t := range tenants {
+		if roll < t.Weight {
+			return t
+		}
+		roll -= t.Weight
+	}
+	if len(tenants) > 0 {
+		return tenants[len(tenants)-1]
+	}
+	return tenant{ErrorRate: -1}
+}
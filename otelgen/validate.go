@@ -0,0 +1,125 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+// lineError is an invalid line of an operations or tenants file.
+type lineError struct {
+	path string
+	line int
+	text string
+	err  error
+}
+
+func (e *lineError) Error() string {
+	return fmt.Sprintf("%s:%d: %v", e.path, e.line, e.err)
+}
+
+func (e *lineError) Unwrap() error {
+	return e.err
+}
+
+// readLines calls parse with each non-empty line of a kind file that isn't
+// a "#" comment. Every invalid line is reported, not just the first.
+func readLines(path, kind string, parse func(line string) error) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("failed to open %s file: %w", kind, err)
+	}
+	defer f.Close()
+
+	var errs []error
+	scanner := bufio.NewScanner(f)
+	for lineNo := 1; scanner.Scan(); lineNo++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		if err := parse(line); err != nil {
+			errs = append(errs, &lineError{path: path, line: lineNo, text: line, err: err})
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("failed to read %s file: %w", kind, err)
+	}
+	return errors.Join(errs...)
+}
+
+// validateKinds are the file kinds otelgen validate checks, with what a
+// valid file defines.
+var validateKinds = map[string]func(path string) (int, error){
+	"operations": func(path string) (int, error) {
+		ops, err := loadOperations(path)
+		return len(ops), err
+	},
+	"tenants": func(path string) (int, error) {
+		tenants, err := loadTenants(path)
+		return len(tenants), err
+	},
+}
+
+// newValidateCmd returns the validate command, which lints operations and
+// tenants files with the generator's own checks without generating
+// anything.
+func newValidateCmd() *cobra.Command {
+	var kind string
+	cmd := &cobra.Command{
+		Use:   "validate FILE...",
+		Short: "Check operations or tenants files for errors without generating telemetry",
+		Args:  cobra.MinimumNArgs(1),
+		// Problems are printed per line below; a usage dump wouldn't help
+		SilenceUsage:  true,
+		SilenceErrors: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			load, ok := validateKinds[kind]
+			if !ok {
+				err := fmt.Errorf("unknown --kind %q, expected operations or tenants", kind)
+				reportInvalid(err)
+				return err
+			}
+			var problems int
+			for _, path := range args {
+				n, err := load(path)
+				if err != nil {
+					problems += reportInvalid(err)
+					continue
+				}
+				out.info(fmt.Sprintf("✅ %s: %d %s", path, n, kind),
+					"valid file", "path", path, "kind", kind, "entries", n)
+			}
+			if problems > 0 {
+				return fmt.Errorf("found %d problems", problems)
+			}
+			return nil
+		},
+	}
+	cmd.Flags().StringVar(&kind, "kind", "operations",
+		"what the files hold: operations for --operations-file or tenants for --tenants @file")
+	return cmd
+}
+
+// reportInvalid prints each problem in err, quoting the offending line
+// where there is one, and returns how many there were.
+func reportInvalid(err error) int {
+	errs := []error{err}
+	if joined, ok := err.(interface{ Unwrap() []error }); ok {
+		errs = joined.Unwrap()
+	}
+	for _, err := range errs {
+		var lineErr *lineError
+		if errors.As(err, &lineErr) {
+			out.warn(fmt.Sprintf("❌ %v\n     %s", lineErr, lineErr.text),
+				"invalid line", "path", lineErr.path, "line", lineErr.line, "text", lineErr.text, "error", lineErr.err)
+			continue
+		}
+		out.warn(fmt.Sprintf("❌ %v", err), "invalid file", "error", err)
+	}
+	return len(errs)
+}
@@ -0,0 +1,230 @@
+package sonifierextension
+
+import (
+	"math"
+	"math/rand"
+	"sort"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/collector/pdata/plog"
+	"go.opentelemetry.io/collector/pdata/pmetric"
+	"go.opentelemetry.io/collector/pdata/ptrace"
+)
+
+// maxDurationSamples bounds the span durations kept per window; beyond it
+// durations are reservoir sampled for the percentile.
+const maxDurationSamples = 10000
+
+// summary is the compact per-window message broadcast in aggregation mode.
+type summary struct {
+	WindowStart   time.Time          `json:"window_start"`
+	WindowMillis  int64              `json:"window_ms"`
+	Spans         int                `json:"spans"`
+	ErrorSpans    int                `json:"error_spans"`
+	P95DurationMs float64            `json:"p95_duration_ms"`
+	Logs          map[string]int     `json:"logs"`
+	Metrics       map[string]float64 `json:"metrics"`
+}
+
+// aggregator accumulates parsed telemetry into rolling per-window totals.
+// Metric values persist across windows so each summary carries the latest
+// known value of every tracked metric.
+type aggregator struct {
+	metrics map[string]bool
+
+	mu         sync.Mutex
+	start      time.Time
+	spans      int
+	errorSpans int
+	durations  []float64
+	logs       map[string]int
+	latest     map[string]float64
+}
+
+func newAggregator(metricNames []string) *aggregator {
+	return &aggregator{
+		metrics: toSet(metricNames),
+		start:   time.Now(),
+		logs:    make(map[string]int),
+		latest:  make(map[string]float64),
+	}
+}
+
+// reset discards the current window and the latest metric values.
+func (a *aggregator) reset() {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	a.start = time.Now()
+	a.spans, a.errorSpans = 0, 0
+	a.durations = nil
+	a.logs = make(map[string]int)
+	a.latest = make(map[string]float64)
+}
+
+// observe adds a decoded payload to the current window.
+func (a *aggregator) observe(d *decodedTelemetry) {
+	if !d.parsed {
+		return
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	switch d.dataType {
+	case "traces":
+		a.observeTraces(d.traces)
+	case "metrics":
+		a.observeMetrics(d.metrics)
+	case "logs":
+		a.observeLogs(d.logs)
+	}
+}
+
+func (a *aggregator) observeTraces(td ptrace.Traces) {
+	rs := td.ResourceSpans()
+	for i := 0; i < rs.Len(); i++ {
+		ss := rs.At(i).ScopeSpans()
+		for j := 0; j < ss.Len(); j++ {
+			spans := ss.At(j).Spans()
+			for k := 0; k < spans.Len(); k++ {
+				span := spans.At(k)
+				a.spans++
+				if span.Status().Code() == ptrace.StatusCodeError {
+					a.errorSpans++
+				}
+				ms := float64(span.EndTimestamp().AsTime().Sub(span.StartTimestamp().AsTime())) / float64(time.Millisecond)
+				if len(a.durations) < maxDurationSamples {
+					a.durations = append(a.durations, ms)
+				} else if r := rand.Intn(a.spans); r < maxDurationSamples {
+					a.durations[r] = ms
+				}
+			}
+		}
+	}
+}
+
+func (a *aggregator) observeMetrics(md pmetric.Metrics) {
+	rm := md.ResourceMetrics()
+	for i := 0; i < rm.Len(); i++ {
+		sm := rm.At(i).ScopeMetrics()
+		for j := 0; j < sm.Len(); j++ {
+			metrics := sm.At(j).Metrics()
+			for k := 0; k < metrics.Len(); k++ {
+				m := metrics.At(k)
+				if a.metrics != nil && !a.metrics[m.Name()] {
+					continue
+				}
+				var points pmetric.NumberDataPointSlice
+				switch m.Type() {
+				case pmetric.MetricTypeGauge:
+					points = m.Gauge().DataPoints()
+				case pmetric.MetricTypeSum:
+					points = m.Sum().DataPoints()
+				default:
+					continue
+				}
+				if points.Len() > 0 {
+					a.latest[m.Name()] = numberValue(points.At(points.Len() - 1))
+				}
+			}
+		}
+	}
+}
+
+func (a *aggregator) observeLogs(ld plog.Logs) {
+	rl := ld.ResourceLogs()
+	for i := 0; i < rl.Len(); i++ {
+		sl := rl.At(i).ScopeLogs()
+		for j := 0; j < sl.Len(); j++ {
+			records := sl.At(j).LogRecords()
+			for k := 0; k < records.Len(); k++ {
+				a.logs[severityName(records.At(k).SeverityNumber())]++
+			}
+		}
+	}
+}
+
+// flush returns the summary of the window ending at now and starts a new one.
+func (a *aggregator) flush(now time.Time) summary {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	sum := summary{
+		WindowStart:   a.start,
+		WindowMillis:  now.Sub(a.start).Milliseconds(),
+		Spans:         a.spans,
+		ErrorSpans:    a.errorSpans,
+		P95DurationMs: percentile(a.durations, 0.95),
+		Logs:          a.logs,
+		Metrics:       make(map[string]float64, len(a.latest)),
+	}
+	for name, v := range a.latest {
+		sum.Metrics[name] = v
+	}
+
+	a.start = now
+	a.spans, a.errorSpans = 0, 0
+	a.durations = a.durations[:0]
+	a.logs = make(map[string]int)
+	return sum
+}
+
+// percentile returns the p-th percentile of values using the nearest-rank
+// method, sorting values in place.
+func percentile(values []float64, p float64) float64 {
+	if len(values) == 0 {
+		return 0
+	}
+	sort.Float64s(values)
+	rank := int(math.Ceil(p*float64(len(values)))) - 1
+	if rank < 0 {
+		rank = 0
+	}
+	return values[rank]
+}
+
+func numberValue(dp pmetric.NumberDataPoint) float64 {
+	if dp.ValueType() == pmetric.NumberDataPointValueTypeInt {
+		return float64(dp.IntValue())
+	}
+	return dp.DoubleValue()
+}
+
+// severityName maps a log severity number to its short name, grouping the
+// numbered sub-levels such as ERROR2..ERROR4 together.
+func severityName(sev plog.SeverityNumber) string {
+	switch {
+	case sev >= plog.SeverityNumberFatal:
+		return "FATAL"
+	case sev >= plog.SeverityNumberError:
+		return "ERROR"
+	case sev >= plog.SeverityNumberWarn:
+		return "WARN"
+	case sev >= plog.SeverityNumberInfo:
+		return "INFO"
+	case sev >= plog.SeverityNumberDebug:
+		return "DEBUG"
+	case sev >= plog.SeverityNumberTrace:
+		return "TRACE"
+	default:
+		return "UNSPECIFIED"
+	}
+}
+
+// runAggregation broadcasts a summary at the end of every window until stop
+// is closed.
+func (s *sonifierExtension) runAggregation(stop <-chan struct{}) {
+	ticker := time.NewTicker(s.config.Aggregation.Window)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-stop:
+			return
+		case now := <-ticker.C:
+			s.broadcastJSON("summary", s.aggregator.flush(now))
+		}
+	}
+}
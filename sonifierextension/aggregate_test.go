@@ -0,0 +1,118 @@
+package sonifierextension
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/collector/pdata/plog"
+)
+
+func TestAggregatorFlush(t *testing.T) {
+	a := newAggregator(nil)
+	start := a.start
+	for _, fixture := range []string{"traces", "metrics", "logs", "metrics"} {
+		a.observe(loadFixture(t, fixture))
+	}
+	a.observe(decodeTelemetry([]byte(`{"hello":"world"}`), ""))
+
+	// The gauge and sum keep their last points, the histogram is left
+	// out and the spans last 500ms, 100ms and 2000ms
+	end := start.Add(10 * time.Second)
+	assert.Equal(t, summary{
+		WindowStart:   start,
+		WindowMillis:  10000,
+		Spans:         3,
+		ErrorSpans:    1,
+		P95DurationMs: 2000,
+		Logs:          map[string]int{"INFO": 1, "WARN": 1, "ERROR": 1, "FATAL": 1},
+		Metrics:       map[string]float64{"system.cpu.utilization": 0.5, "http.server.requests": 150},
+	}, a.flush(end))
+
+	// A new window starts empty but keeps the latest metric values
+	a.observe(loadFixture(t, "logs"))
+	assert.Equal(t, summary{
+		WindowStart:  end,
+		WindowMillis: 1000,
+		Logs:         map[string]int{"INFO": 1, "WARN": 1, "ERROR": 1, "FATAL": 1},
+		Metrics:      map[string]float64{"system.cpu.utilization": 0.5, "http.server.requests": 150},
+	}, a.flush(end.Add(time.Second)))
+
+	a.reset()
+	sum := a.flush(time.Now())
+	assert.Zero(t, sum.Spans)
+	assert.Empty(t, sum.Logs)
+	assert.Empty(t, sum.Metrics)
+}
+
+func TestAggregatorMetricNames(t *testing.T) {
+	a := newAggregator([]string{"http.server.requests", "queue.depth"})
+	a.observe(loadFixture(t, "metrics"))
+	assert.Equal(t, map[string]float64{"http.server.requests": 150}, a.flush(time.Now()).Metrics)
+}
+
+func TestAggregatorSamplesDurations(t *testing.T) {
+	a := newAggregator(nil)
+	traces := loadFixture(t, "traces")
+	for range maxDurationSamples {
+		a.observe(traces)
+	}
+	assert.Len(t, a.durations, maxDurationSamples)
+	sum := a.flush(time.Now())
+	assert.Equal(t, 3*maxDurationSamples, sum.Spans)
+	assert.Equal(t, 2000.0, sum.P95DurationMs)
+}
+
+func TestPercentile(t *testing.T) {
+	assert.Zero(t, percentile(nil, 0.95))
+	assert.Equal(t, 7.0, percentile([]float64{7}, 0.95))
+	assert.Equal(t, 1.0, percentile([]float64{3, 1, 2}, 0))
+	assert.Equal(t, 2.0, percentile([]float64{3, 1, 2}, 0.5))
+	values := make([]float64, 100)
+	for i := range values {
+		values[i] = float64(100 - i)
+	}
+	assert.Equal(t, 95.0, percentile(values, 0.95))
+}
+
+func TestSeverityName(t *testing.T) {
+	for sev, want := range map[plog.SeverityNumber]string{
+		plog.SeverityNumberUnspecified: "UNSPECIFIED",
+		plog.SeverityNumberTrace:       "TRACE",
+		plog.SeverityNumberDebug4:      "DEBUG",
+		plog.SeverityNumberInfo:        "INFO",
+		plog.SeverityNumberWarn2:       "WARN",
+		plog.SeverityNumberError4:      "ERROR",
+		plog.SeverityNumberFatal:       "FATAL",
+	} {
+		assert.Equal(t, want, severityName(sev), sev)
+	}
+}
+
+func TestAggregationSummaries(t *testing.T) {
+	s, base := startTestExtension(t, func(cfg *Config) {
+		cfg.Aggregation = AggregationConfig{Enabled: true, Window: 50 * time.Millisecond}
+	})
+	conn := dialWebSocket(t, base, "")
+	waitSubscription(t, s, func(*subscription) bool { return true })
+	require.NoError(t, s.Ingest(context.Background(), "traces", []byte(testTraces)))
+
+	// Raw payloads give way to summaries, which carry the span once it
+	// falls in a window
+	for {
+		env := readEnvelope(t, conn)
+		require.Equal(t, "summary", env.Type)
+		var sum summary
+		require.NoError(t, json.Unmarshal(env.Payload, &sum))
+		if sum.Spans > 0 {
+			assert.Equal(t, 1, sum.Spans)
+			assert.Equal(t, 1, sum.ErrorSpans)
+			assert.InDelta(t, 2.0, sum.P95DurationMs, 0.001)
+			assert.Empty(t, sum.Logs)
+			return
+		}
+	}
+}
@@ -0,0 +1,168 @@
+package sonifierextension
+
+import (
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/collector/pdata/ptrace"
+)
+
+// alarmInterval is how often the rolling error ratio is evaluated. It is
+// also the granularity of the alarm's rolling window.
+const alarmInterval = time.Second
+
+// alarmState is the payload of an {"type":"alarm"} message, sent when the
+// alarm turns on and again when it clears.
+type alarmState struct {
+	Reason     string  `json:"reason"`
+	Active     bool    `json:"active"`
+	ErrorRatio float64 `json:"error_ratio"`
+}
+
+// alarmBucket holds the spans counted during one slice of the window.
+// epoch is the slice's index since the Unix epoch, plus one so that the
+// zero bucket is empty.
+type alarmBucket struct {
+	epoch  int64
+	spans  int
+	errors int
+}
+
+// errorAlarm tracks the ratio of error spans over a rolling window. The
+// alarm turns on once the ratio has stayed at or above the on threshold for
+// the sustain period, and off once it has stayed at or below the lower off
+// threshold for as long, so it doesn't chatter near a single boundary.
+type errorAlarm struct {
+	cfg   AlarmConfig
+	slice time.Duration
+
+	mu      sync.Mutex
+	buckets []alarmBucket
+	active  bool
+	since   time.Time
+}
+
+func newErrorAlarm(cfg AlarmConfig) *errorAlarm {
+	return newErrorAlarmSlices(cfg, int((cfg.Window+alarmInterval-1)/alarmInterval))
+}
+
+// newErrorAlarmSlices returns an alarm whose window is split into n
+// slices, which drop out of it one at a time.
+func newErrorAlarmSlices(cfg AlarmConfig, n int) *errorAlarm {
+	n = max(n, 1)
+	return &errorAlarm{
+		cfg:     cfg,
+		slice:   max(cfg.Window/time.Duration(n), 1),
+		buckets: make([]alarmBucket, n),
+	}
+}
+
+// observe counts the spans and error spans in a decoded traces payload
+// received at now.
+func (a *errorAlarm) observe(d *decodedTelemetry, now time.Time) {
+	if !d.parsed || d.dataType != "traces" {
+		return
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	epoch := a.epoch(now)
+	b := &a.buckets[epoch%int64(len(a.buckets))]
+	if b.epoch != epoch {
+		*b = alarmBucket{epoch: epoch}
+	}
+	rs := d.traces.ResourceSpans()
+	for i := 0; i < rs.Len(); i++ {
+		ss := rs.At(i).ScopeSpans()
+		for j := 0; j < ss.Len(); j++ {
+			spans := ss.At(j).Spans()
+			for k := 0; k < spans.Len(); k++ {
+				b.spans++
+				if spans.At(k).Status().Code() == ptrace.StatusCodeError {
+					b.errors++
+				}
+			}
+		}
+	}
+}
+
+// counts returns the spans and error spans counted over the window up to
+// now.
+func (a *errorAlarm) counts(now time.Time) (spans, errors int) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	return a.total(now)
+}
+
+func (a *errorAlarm) total(now time.Time) (spans, errors int) {
+	epoch := a.epoch(now)
+	for _, b := range a.buckets {
+		if b.epoch > epoch-int64(len(a.buckets)) && b.epoch <= epoch {
+			spans += b.spans
+			errors += b.errors
+		}
+	}
+	return spans, errors
+}
+
+func (a *errorAlarm) epoch(now time.Time) int64 {
+	return now.UnixNano()/int64(a.slice) + 1
+}
+
+// reset clears the rolling window and turns the alarm off.
+func (a *errorAlarm) reset() {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	clear(a.buckets)
+	a.active = false
+	a.since = time.Time{}
+}
+
+// evaluate returns the new state if the alarm turned on or off at now.
+func (a *errorAlarm) evaluate(now time.Time) (alarmState, bool) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	var total alarmBucket
+	total.spans, total.errors = a.total(now)
+	var ratio float64
+	if total.spans > 0 {
+		ratio = float64(total.errors) / float64(total.spans)
+	}
+
+	crossing := (!a.active && ratio >= a.cfg.OnThreshold) || (a.active && ratio <= a.cfg.OffThreshold)
+	if !crossing {
+		a.since = time.Time{}
+		return alarmState{}, false
+	}
+	if a.since.IsZero() {
+		a.since = now
+	}
+	if now.Sub(a.since) < a.cfg.Sustain {
+		return alarmState{}, false
+	}
+	a.active = !a.active
+	a.since = time.Time{}
+	return alarmState{Reason: "error_spike", Active: a.active, ErrorRatio: ratio}, true
+}
+
+// runAlarm evaluates the error alarm every interval and broadcasts its
+// transitions until stop is closed.
+func (s *sonifierExtension) runAlarm(stop <-chan struct{}) {
+	ticker := time.NewTicker(alarmInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-stop:
+			return
+		case now := <-ticker.C:
+			if state, changed := s.alarm.evaluate(now); changed {
+				s.broadcastJSON("alarm", state)
+			}
+		}
+	}
+}
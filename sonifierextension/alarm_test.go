@@ -0,0 +1,48 @@
+package sonifierextension
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestErrorAlarm(t *testing.T) {
+	a := newErrorAlarm(AlarmConfig{Enabled: true, Window: 3 * time.Second, OnThreshold: 0.5, OffThreshold: 0.1, Sustain: 2 * time.Second})
+	start := time.Unix(1000, 0)
+
+	a.observe(testSpans(5, 5), start)
+	_, changed := a.evaluate(start)
+	assert.False(t, changed, "not sustained yet")
+	state, changed := a.evaluate(start.Add(2 * time.Second))
+	assert.True(t, changed)
+	assert.Equal(t, alarmState{Reason: "error_spike", Active: true, ErrorRatio: 0.5}, state)
+
+	// The spans drop out of the window after 3s, and it clears 2s later
+	_, changed = a.evaluate(start.Add(3 * time.Second))
+	assert.False(t, changed)
+	state, changed = a.evaluate(start.Add(5 * time.Second))
+	assert.True(t, changed)
+	assert.False(t, state.Active)
+}
+
+func TestErrorAlarmCounts(t *testing.T) {
+	a := newErrorAlarmSlices(AlarmConfig{Window: time.Second}, 10)
+	start := time.Unix(1000, 0)
+
+	a.observe(testSpans(2, 3), start)
+	a.observe(testSpans(1, 0), start.Add(500*time.Millisecond))
+	spans, errors := a.counts(start.Add(900 * time.Millisecond))
+	assert.Equal(t, 6, spans)
+	assert.Equal(t, 3, errors)
+
+	// Slices drop out one at a time
+	spans, errors = a.counts(start.Add(time.Second))
+	assert.Equal(t, 1, spans)
+	assert.Equal(t, 1, errors)
+
+	a.observe(&decodedTelemetry{dataType: "logs", parsed: true}, start)
+	a.reset()
+	spans, _ = a.counts(start.Add(900 * time.Millisecond))
+	assert.Zero(t, spans)
+}
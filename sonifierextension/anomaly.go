@@ -0,0 +1,230 @@
+package sonifierextension
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/collector/pdata/pcommon"
+)
+
+// errorBurstBuckets is how many buckets the error burst window is split
+// into.
+const errorBurstBuckets = 10
+
+// Alert kinds.
+const (
+	alertMetricSpike = "metric_spike"
+	alertErrorBurst  = "error_burst"
+)
+
+// alert is the payload of an {"type":"alert"} message.
+type alert struct {
+	Kind string `json:"kind"`
+	// Series is the metric name and data point attributes for a metric
+	// spike, or error_spans for an error burst.
+	Series  string `json:"series"`
+	Service string `json:"service,omitempty"`
+	// Severity is critical when the deviation or rate is at least twice
+	// its threshold, and warning otherwise.
+	Severity string  `json:"severity"`
+	Value    float64 `json:"value"`
+	// Expected is the series' moving average for a metric spike, or the
+	// configured rate for an error burst.
+	Expected float64 `json:"expected"`
+	ZScore   float64 `json:"z_score,omitempty"`
+}
+
+// seriesBaseline is the exponentially weighted mean and variance of one
+// metric series.
+type seriesBaseline struct {
+	counterRate
+	count          int
+	mean, variance float64
+	// deviating is set from an alert until the series comes back within
+	// the threshold, so a sustained deviation alerts once.
+	deviating bool
+	lastAlert time.Time
+}
+
+// anomalyDetector raises alerts for metric values that deviate from their
+// series' moving average by more than a z-score, and for bursts of error
+// spans. Each alert source stays quiet until it recovers, and for at least
+// the cooldown after it fired. A nil detector raises nothing.
+type anomalyDetector struct {
+	cfg AnomalyConfig
+
+	mu     sync.Mutex
+	series *seriesCache[seriesBaseline]
+	// errors counts the error spans over the error window
+	errors    *errorAlarm
+	bursting  bool
+	lastBurst time.Time
+}
+
+func newAnomalyDetector(cfg AnomalyConfig) (*anomalyDetector, error) {
+	if !cfg.Enabled {
+		return nil, nil
+	}
+	var errs []error
+	check := func(ok bool, format string, args ...any) {
+		if !ok {
+			errs = append(errs, fmt.Errorf(format, args...))
+		}
+	}
+	check(cfg.ZScore > 0, "anomaly.z_score must be positive")
+	check(cfg.Alpha > 0 && cfg.Alpha <= 1, "anomaly.alpha must be greater than 0 and at most 1")
+	check(cfg.Warmup >= 2, "anomaly.warmup must be at least 2")
+	check(cfg.MaxSeries > 0, "anomaly.max_series must be positive")
+	check(cfg.ErrorWindow >= errorBurstBuckets*time.Millisecond, "anomaly.error_window must be at least %v", errorBurstBuckets*time.Millisecond)
+	check(cfg.ErrorRate >= 0, "anomaly.error_rate must not be negative")
+	check(cfg.Cooldown >= 0, "anomaly.cooldown must not be negative")
+	if len(errs) > 0 {
+		return nil, errors.Join(errs...)
+	}
+	return &anomalyDetector{
+		cfg:    cfg,
+		series: newSeriesCache[seriesBaseline](cfg.MaxSeries),
+		errors: newErrorAlarmSlices(AlarmConfig{Window: cfg.ErrorWindow}, errorBurstBuckets),
+	}, nil
+}
+
+// observe feeds a decoded payload received at now through the detector and
+// returns the alerts it raised.
+func (a *anomalyDetector) observe(d *decodedTelemetry, now time.Time) []alert {
+	if a == nil || !d.parsed {
+		return nil
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	var alerts []alert
+	switch d.dataType {
+	case "metrics":
+		numberPoints(d.metrics, func(p numberPoint) {
+			if al, ok := a.observePoint(p, now); ok {
+				alerts = append(alerts, al)
+			}
+		})
+	case "traces":
+		if al, ok := a.observeSpans(d, now); ok {
+			alerts = append(alerts, al)
+		}
+	}
+	return alerts
+}
+
+// observePoint compares a data point with its series' moving average
+// before folding it in.
+func (a *anomalyDetector) observePoint(p numberPoint, now time.Time) (alert, bool) {
+	s := a.series.get(p.key)
+	value, ok := s.value(p)
+	if !ok {
+		return alert{}, false
+	}
+
+	var raised alert
+	var fired bool
+	if s.count >= a.cfg.Warmup && s.variance > 0 {
+		z := (value - s.mean) / math.Sqrt(s.variance)
+		if math.Abs(z) < a.cfg.ZScore {
+			s.deviating = false
+		} else {
+			if !s.deviating && now.Sub(s.lastAlert) >= a.cfg.Cooldown {
+				raised = alert{
+					Kind:     alertMetricSpike,
+					Series:   seriesName(p),
+					Severity: severity(math.Abs(z), a.cfg.ZScore),
+					Value:    value,
+					Expected: s.mean,
+					ZScore:   z,
+				}
+				if v, ok := p.resource.Get(serviceNameKey); ok {
+					raised.Service = v.AsString()
+				}
+				s.lastAlert, fired = now, true
+			}
+			s.deviating = true
+		}
+	}
+
+	if s.count == 0 {
+		s.mean = value
+	} else {
+		diff := value - s.mean
+		incr := a.cfg.Alpha * diff
+		s.mean += incr
+		s.variance = (1 - a.cfg.Alpha) * (s.variance + diff*incr)
+	}
+	s.count++
+	return raised, fired
+}
+
+// observeSpans counts the error spans in a traces payload and checks the
+// error rate over the window.
+func (a *anomalyDetector) observeSpans(d *decodedTelemetry, now time.Time) (alert, bool) {
+	if a.cfg.ErrorRate == 0 {
+		return alert{}, false
+	}
+
+	a.errors.observe(d, now)
+	_, errorCount := a.errors.counts(now)
+	rate := float64(errorCount) / a.cfg.ErrorWindow.Seconds()
+	if rate < a.cfg.ErrorRate {
+		a.bursting = false
+		return alert{}, false
+	}
+	wasBursting := a.bursting
+	a.bursting = true
+	if wasBursting || now.Sub(a.lastBurst) < a.cfg.Cooldown {
+		return alert{}, false
+	}
+	a.lastBurst = now
+	return alert{
+		Kind:     alertErrorBurst,
+		Series:   "error_spans",
+		Severity: severity(rate, a.cfg.ErrorRate),
+		Value:    rate,
+		Expected: a.cfg.ErrorRate,
+	}, true
+}
+
+// reset forgets every series and the error burst window.
+func (a *anomalyDetector) reset() {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	a.series.reset()
+	a.errors.reset()
+	a.bursting = false
+	a.lastBurst = time.Time{}
+}
+
+// severity grades how far value is past threshold.
+func severity(value, threshold float64) string {
+	if value >= 2*threshold {
+		return "critical"
+	}
+	return "warning"
+}
+
+// seriesName formats a data point's series as name{key=value,...}, with
+// the attributes sorted by key.
+func seriesName(p numberPoint) string {
+	attrs := p.dp.Attributes()
+	if attrs.Len() == 0 {
+		return p.metric
+	}
+	pairs := make([]string, 0, attrs.Len())
+	attrs.Range(func(k string, v pcommon.Value) bool {
+		pairs = append(pairs, k+"="+v.AsString())
+		return true
+	})
+	slices.Sort(pairs)
+	return p.metric + "{" + strings.Join(pairs, ",") + "}"
+}
@@ -0,0 +1,148 @@
+package sonifierextension
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/collector/pdata/pmetric"
+	"go.opentelemetry.io/collector/pdata/ptrace"
+)
+
+// testSpans returns a decoded traces payload with errors error spans and
+// ok other spans.
+func testSpans(errors, ok int) *decodedTelemetry {
+	td := ptrace.NewTraces()
+	spans := td.ResourceSpans().AppendEmpty().ScopeSpans().AppendEmpty().Spans()
+	for i := range errors + ok {
+		span := spans.AppendEmpty()
+		span.SetName("GET /cart")
+		if i < errors {
+			span.Status().SetCode(ptrace.StatusCodeError)
+		}
+	}
+	return &decodedTelemetry{dataType: "traces", parsed: true, traces: td}
+}
+
+// testCPU returns a decoded metrics payload with one point of checkout's
+// system.cpu.utilization for cpu 0.
+func testCPU(value float64) *decodedTelemetry {
+	md := pmetric.NewMetrics()
+	rm := md.ResourceMetrics().AppendEmpty()
+	rm.Resource().Attributes().PutStr(serviceNameKey, "checkout")
+	m := rm.ScopeMetrics().AppendEmpty().Metrics().AppendEmpty()
+	m.SetName("system.cpu.utilization")
+	dp := m.SetEmptyGauge().DataPoints().AppendEmpty()
+	dp.Attributes().PutStr("cpu", "0")
+	dp.SetDoubleValue(value)
+	return &decodedTelemetry{dataType: "metrics", parsed: true, metrics: md}
+}
+
+func newTestAnomalyDetector(t *testing.T, modify func(*AnomalyConfig)) *anomalyDetector {
+	t.Helper()
+	cfg := AnomalyConfig{
+		Enabled:     true,
+		ZScore:      3,
+		Alpha:       0.1,
+		Warmup:      5,
+		MaxSeries:   10,
+		ErrorWindow: 10 * time.Second,
+		ErrorRate:   1,
+		Cooldown:    30 * time.Second,
+	}
+	if modify != nil {
+		modify(&cfg)
+	}
+	a, err := newAnomalyDetector(cfg)
+	require.NoError(t, err)
+	return a
+}
+
+func TestAnomalyWarmup(t *testing.T) {
+	a := newTestAnomalyDetector(t, nil)
+	now := time.Unix(1000, 0)
+	// Nothing before the warmup, however far off
+	for _, v := range []float64{10, 11, 10, 11, 1000} {
+		assert.Empty(t, a.observe(testCPU(v), now))
+	}
+}
+
+func TestAnomalyMetricSpike(t *testing.T) {
+	a := newTestAnomalyDetector(t, nil)
+	now := time.Unix(1000, 0)
+	feed := func(value float64) []alert {
+		now = now.Add(time.Second)
+		return a.observe(testCPU(value), now)
+	}
+
+	for _, v := range []float64{10, 11, 10, 11, 10, 11} {
+		assert.Empty(t, feed(v))
+	}
+
+	alerts := feed(100)
+	require.Len(t, alerts, 1)
+	assert.Equal(t, alertMetricSpike, alerts[0].Kind)
+	assert.Equal(t, "system.cpu.utilization{cpu=0}", alerts[0].Series)
+	assert.Equal(t, "checkout", alerts[0].Service)
+	assert.Equal(t, "critical", alerts[0].Severity)
+	assert.Equal(t, 100.0, alerts[0].Value)
+	assert.Greater(t, alerts[0].ZScore, 6.0)
+
+	// A sustained deviation alerts once
+	assert.Empty(t, feed(100))
+
+	// Back to normal and spiking again within the cooldown stays quiet,
+	// and spiking after it alerts again
+	for range 50 {
+		assert.Empty(t, feed(10))
+	}
+	require.Len(t, feed(1000), 1)
+	for range 5 {
+		feed(10)
+	}
+	assert.Empty(t, feed(100000), "within the cooldown")
+	now = now.Add(time.Minute)
+	for range 50 {
+		feed(10)
+	}
+	assert.Len(t, feed(1e9), 1)
+}
+
+func TestAnomalyErrorBurst(t *testing.T) {
+	a := newTestAnomalyDetector(t, nil)
+	start := time.Unix(1000, 0)
+
+	// One error a second over 10s is the threshold
+	assert.Empty(t, a.observe(testSpans(9, 100), start))
+	alerts := a.observe(testSpans(1, 0), start.Add(5*time.Second))
+	require.Len(t, alerts, 1)
+	assert.Equal(t, alert{Kind: alertErrorBurst, Series: "error_spans", Severity: "warning", Value: 1, Expected: 1}, alerts[0])
+	assert.Empty(t, a.observe(testSpans(10, 0), start.Add(6*time.Second)), "still bursting")
+
+	// The first errors drop out of the window, then the rest
+	assert.Empty(t, a.observe(testSpans(0, 1), start.Add(10*time.Second)))
+	assert.Empty(t, a.observe(testSpans(0, 1), start.Add(20*time.Second)))
+
+	// Bursting again within the cooldown stays quiet
+	assert.Empty(t, a.observe(testSpans(20, 0), start.Add(25*time.Second)))
+	assert.Empty(t, a.observe(testSpans(0, 1), start.Add(40*time.Second)))
+	alerts = a.observe(testSpans(20, 0), start.Add(41*time.Second))
+	require.Len(t, alerts, 1)
+	assert.Equal(t, "critical", alerts[0].Severity)
+	assert.Equal(t, 2.0, alerts[0].Value)
+}
+
+func TestAnomalyErrorBurstDisabled(t *testing.T) {
+	a := newTestAnomalyDetector(t, func(cfg *AnomalyConfig) { cfg.ErrorRate = 0 })
+	assert.Empty(t, a.observe(testSpans(1000, 0), time.Unix(1000, 0)))
+}
+
+func TestAnomalyReset(t *testing.T) {
+	a := newTestAnomalyDetector(t, nil)
+	now := time.Unix(1000, 0)
+	a.observe(testSpans(9, 0), now)
+	a.reset()
+	assert.Empty(t, a.observe(testSpans(9, 0), now), "the first errors were forgotten")
+	assert.Len(t, a.observe(testSpans(1, 0), now), 1)
+}
@@ -0,0 +1,122 @@
+package sonifierextension
+
+import (
+	"crypto/subtle"
+	"fmt"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+
+	"go.opentelemetry.io/collector/config/configopaque"
+	"go.uber.org/zap"
+)
+
+// requireToken wraps next so it only runs for requests bearing token in an
+// "Authorization: Bearer" header. An unset token disables the endpoint.
+func requireToken(token configopaque.String, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if token == "" {
+			http.Error(w, "Forbidden: endpoint disabled, no token configured", http.StatusForbidden)
+			return
+		}
+		if !validBearer(r, string(token)) {
+			w.Header().Set("WWW-Authenticate", "Bearer")
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+		next(w, r)
+	}
+}
+
+func validBearer(r *http.Request, token string) bool {
+	auth := r.Header.Get("Authorization")
+	presented, found := strings.CutPrefix(auth, "Bearer ")
+	if !found {
+		return false
+	}
+	return subtle.ConstantTimeCompare([]byte(presented), []byte(token)) == 1
+}
+
+// validQueryToken reports whether the token query parameter matches token.
+// Browsers can't set headers on WebSocket and EventSource connections, so
+// listener endpoints accept it there too.
+func validQueryToken(r *http.Request, token string) bool {
+	presented := r.URL.Query().Get("token")
+	return presented != "" && subtle.ConstantTimeCompare([]byte(presented), []byte(token)) == 1
+}
+
+// optionalToken wraps next so it only runs for requests bearing token when
+// one is configured, as a bearer token or, with allowQuery, the token query
+// parameter. An unset token leaves the endpoint open.
+func (s *sonifierExtension) optionalToken(token configopaque.String, allowQuery bool, next http.HandlerFunc) http.HandlerFunc {
+	if token == "" {
+		return next
+	}
+	return func(w http.ResponseWriter, r *http.Request) {
+		if validBearer(r, string(token)) || (allowQuery && validQueryToken(r, string(token))) {
+			next(w, r)
+			return
+		}
+		s.stats.unauthorized.Add(1)
+		s.logger.Debug("Rejected unauthorized request", zap.String("path", r.URL.Path), zap.String("remote", r.RemoteAddr))
+		w.Header().Set("WWW-Authenticate", "Bearer")
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+	}
+}
+
+// originChecker decides which browser origins may open streaming
+// connections.
+type originChecker struct {
+	allowAll bool
+	patterns []*regexp.Regexp
+}
+
+// newOriginChecker compiles auth.allowed_origins. Entries are exact
+// origins such as https://dashboard.example.com, or patterns where *
+// matches any run of characters; a lone * allows every origin.
+func newOriginChecker(origins []string) (*originChecker, error) {
+	c := &originChecker{}
+	for _, origin := range origins {
+		switch {
+		case origin == "*":
+			c.allowAll = true
+		case !strings.Contains(origin, "://"):
+			return nil, fmt.Errorf("auth.allowed_origins: %q must be an origin such as https://example.com, or *", origin)
+		default:
+			expr := strings.ReplaceAll(regexp.QuoteMeta(origin), `\*`, ".*")
+			c.patterns = append(c.patterns, regexp.MustCompile("(?i)^"+expr+"$"))
+		}
+	}
+	return c, nil
+}
+
+// allowed reports whether r may connect. Requests without an Origin header
+// don't come from browsers and are always allowed, as are same-origin ones
+// such as the built-in web UI.
+func (c *originChecker) allowed(r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	if origin == "" || c.allowAll {
+		return true
+	}
+	if u, err := url.Parse(origin); err == nil && strings.EqualFold(u.Host, r.Host) {
+		return true
+	}
+	for _, re := range c.patterns {
+		if re.MatchString(origin) {
+			return true
+		}
+	}
+	return false
+}
+
+// checkOrigin is the WebSocket upgrader's origin check. The upgrader
+// responds 403 to rejected origins.
+func (s *sonifierExtension) checkOrigin(r *http.Request) bool {
+	if s.origins.allowed(r) {
+		return true
+	}
+	s.stats.unauthorized.Add(1)
+	s.logger.Debug("Rejected WebSocket origin", zap.String("origin", r.Header.Get("Origin")))
+	return false
+}
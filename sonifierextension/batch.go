@@ -0,0 +1,94 @@
+package sonifierextension
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"mime"
+	"net/http"
+
+	"go.uber.org/zap"
+)
+
+// batchItem is one signal in a /v1/batch upload. Payload is an OTLP JSON
+// export request.
+type batchItem struct {
+	Type    string          `json:"type"`
+	Payload json.RawMessage `json:"payload"`
+}
+
+// batchResult reports how one batch item was handled.
+type batchResult struct {
+	Index  int    `json:"index"`
+	Type   string `json:"type"`
+	Status string `json:"status"`
+	Error  string `json:"error,omitempty"`
+}
+
+// handleBatch accepts a JSON array of {"type","payload"} items and ingests
+// each one as if it had been posted to its own endpoint. Items fail
+// individually, so the response lists a status per item.
+func (s *sonifierExtension) handleBatch(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if ct := r.Header.Get("Content-Type"); ct != "" {
+		if mediaType, _, err := mime.ParseMediaType(ct); err != nil || mediaType != "application/json" {
+			s.countRejected(r.Context(), "unsupported_media_type")
+			http.Error(w, "Unsupported media type, expected JSON", http.StatusUnsupportedMediaType)
+			return
+		}
+	}
+
+	body, ok := s.readBody(w, r)
+	if !ok {
+		return
+	}
+
+	var items []batchItem
+	if err := json.Unmarshal(body, &items); err != nil {
+		s.countRejected(r.Context(), "invalid_batch")
+		http.Error(w, "Invalid batch, expected a JSON array of {type, payload} objects", http.StatusBadRequest)
+		return
+	}
+
+	results := make([]batchResult, len(items))
+	for i, item := range items {
+		results[i] = s.ingestBatchItem(i, item)
+	}
+	s.logger.Info("Received telemetry batch", zap.Int("items", len(items)))
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(results); err != nil {
+		s.logger.Error("Failed to write batch response", zap.Error(err))
+	}
+}
+
+func (s *sonifierExtension) ingestBatchItem(index int, item batchItem) batchResult {
+	result := batchResult{Index: index, Type: item.Type}
+	switch item.Type {
+	case "traces", "metrics", "logs":
+	default:
+		result.Status, result.Error = "error", fmt.Sprintf("unknown type %q, expected traces, metrics or logs", item.Type)
+		s.countRejected(context.Background(), "invalid_batch_item")
+		return result
+	}
+	// Classify before ingesting so a mislabeled item isn't buffered
+	decoded := decodeTelemetry(item.Payload, item.Type)
+	if decoded.dataType != item.Type || !decoded.parsed {
+		result.Status, result.Error = "error", fmt.Sprintf("payload is not an OTLP JSON %s request", item.Type)
+		s.countRejected(context.Background(), "invalid_batch_item")
+		return result
+	}
+	if err := s.checkDecodedLimit(context.Background(), decoded, len(item.Payload)); err != nil {
+		result.Status, result.Error = "error", err.Error()
+		return result
+	}
+	if _, err := s.ingest(item.Payload, decoded); err != nil {
+		result.Status, result.Error = "error", err.Error()
+		return result
+	}
+	result.Status = "ok"
+	return result
+}
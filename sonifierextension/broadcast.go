@@ -0,0 +1,434 @@
+package sonifierextension
+
+import (
+	"context"
+	"encoding/json"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// envelope is the message sent to streaming clients and returned by
+// /telemetry-data.
+type envelope struct {
+	Type string `json:"type"`
+	// Seq is shared by all message types and increases by one with every
+	// broadcast, so clients can detect gaps and reordering.
+	Seq uint64 `json:"seq"`
+	// Timestamp is when the server broadcast the message.
+	Timestamp time.Time `json:"ts"`
+	// TTLMs is how many milliseconds after Timestamp the message is still
+	// delivered, when broadcast.message_ttl is set.
+	TTLMs int64 `json:"ttl_ms,omitempty"`
+	// Payload is replaced by its decoded form for MessagePack clients.
+	Payload json.RawMessage `json:"payload" msgpack:"-"`
+	// Service and Environment are the service.name and
+	// deployment.environment of the payload's resource. Payloads with
+	// several resources are split into one message per resource.
+	Service     string `json:"service,omitempty"`
+	Environment string `json:"environment,omitempty"`
+	// Channel is the service's stable index in first-seen order, as
+	// listed by /services.
+	Channel *int `json:"channel,omitempty"`
+	// Dropped counts messages of this type discarded by rate limiting
+	// since the previous one was sent.
+	Dropped int `json:"dropped,omitempty"`
+	// Pan is the stereo position from -1 (left) to 1 (right) derived from
+	// the configured pan attribute, when the payload has it.
+	Pan *float64 `json:"pan,omitempty"`
+	// Seen is the number of payloads of each type broadcast since startup
+	// or the last reset_counts, including this one.
+	Seen *seenCounts `json:"seen,omitempty"`
+	// Normalized has the metric payload's data points scaled against the
+	// rolling range of their series.
+	Normalized []normalizedValue `json:"normalized,omitempty"`
+	// Chord has a chord for each histogram data point in the metric
+	// payload.
+	Chord []chord `json:"chord,omitempty"`
+	// Voice maps the span names or log severity levels in the payload to
+	// their configured voices. MessagePack clients get it in name order.
+	Voice map[string]voice `json:"voice,omitempty" msgpack:"-"`
+	// Routes are the named channels the message was routed to. Clients
+	// that joined channels only receive routed messages in one of them.
+	Routes []string `json:"-"`
+}
+
+// broadcastMessage is an encoded envelope ready for delivery. Its id is the
+// envelope's seq, which SSE clients also use to resume.
+type broadcastMessage struct {
+	id       uint64
+	dataType string
+	payload  []byte
+	data     []byte
+	env      envelope
+
+	servicesOnce sync.Once
+	services     []string
+
+	packOnce sync.Once
+	packed   []byte
+	packErr  error
+}
+
+// serviceNames returns the message's service, or parses the payload's
+// service names on first use.
+func (m *broadcastMessage) serviceNames() []string {
+	m.servicesOnce.Do(func() {
+		if m.env.Service != "" {
+			m.services = []string{m.env.Service}
+			return
+		}
+		m.services = serviceNames(m.dataType, m.payload)
+	})
+	return m.services
+}
+
+// msgpack encodes the message as MessagePack on first use, so it is only
+// encoded when a MessagePack client receives it.
+func (m *broadcastMessage) msgpack() ([]byte, error) {
+	m.packOnce.Do(func() {
+		m.packed, m.packErr = encodeMsgpack(&m.env)
+	})
+	return m.packed, m.packErr
+}
+
+// subscriber is a streaming client, such as a WebSocket or SSE connection.
+type subscriber interface {
+	// wants reports whether msg matches the subscriber's filters.
+	wants(msg *broadcastMessage) bool
+	// enqueue hands msg to the subscriber's writer without blocking. It
+	// returns false when the subscriber's queue is full.
+	enqueue(msg *broadcastMessage) bool
+	// kind names the transport, such as "websocket" or "sse".
+	kind() string
+	// queued returns the number of messages waiting to be written.
+	queued() int
+	// droppedCount returns the number of messages dropped for the
+	// subscriber because its queue was full.
+	droppedCount() uint64
+	// tooSlow reports whether the subscriber just reached its limit of
+	// consecutive drops and should be disconnected.
+	tooSlow() bool
+	// warnDrop reports whether a drop that just happened should be logged.
+	warnDrop(now time.Time) bool
+	// remoteAddr is the address of the subscriber's peer.
+	remoteAddr() string
+	// disconnect tells the subscriber's handler to end the stream for
+	// reason. It may block while it tells the peer, so it must not be
+	// called with locks held on the publish path.
+	disconnect(reason closeReason)
+	// connection describes the subscriber for /connections.
+	connection() connectionInfo
+}
+
+// broadcaster fans messages out to subscribers and keeps a bounded history
+// so reconnecting clients can catch up.
+type broadcaster struct {
+	mu sync.Mutex
+	// subscribers maps each subscriber to the seq of the first message
+	// published after it joined.
+	subscribers  map[subscriber]uint64
+	history      []*broadcastMessage
+	historySize  int
+	historyBytes int64
+	maxBytes     int64
+	lastID       uint64
+}
+
+// newBroadcaster returns a broadcaster keeping up to historySize messages
+// and, when maxBytes is positive, at most maxBytes of encoded messages.
+func newBroadcaster(historySize int, maxBytes int64) *broadcaster {
+	return &broadcaster{
+		subscribers: make(map[subscriber]uint64),
+		historySize: historySize,
+		maxBytes:    maxBytes,
+	}
+}
+
+// subscribe registers sub and returns the history messages after lastID
+// that it wants, so the caller can replay them before live delivery.
+func (b *broadcaster) subscribe(sub subscriber, lastID uint64) []*broadcastMessage {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.subscribers[sub] = b.lastID + 1
+	if lastID == 0 {
+		return nil
+	}
+	var backlog []*broadcastMessage
+	for _, msg := range b.history {
+		if msg.id > lastID && sub.wants(msg) {
+			backlog = append(backlog, msg)
+		}
+	}
+	return backlog
+}
+
+// resumer is a subscriber whose stream can start with older messages.
+type resumer interface {
+	subscriber
+	// rewind hands msgs to the writer, to be sent before anything queued
+	// later.
+	rewind(msgs []*broadcastMessage)
+}
+
+// subscribeFrom registers sub with its stream starting at fromSeq. Under
+// the lock, so nothing is published in between, it rewinds sub with the
+// history messages from fromSeq on that it wants before registering it, so
+// live messages are only queued behind them. It returns the number of
+// history messages replayed, or false and the oldest seq it still has when
+// the history no longer reaches back to fromSeq, in which case sub is
+// registered for live messages only.
+func (b *broadcaster) subscribeFrom(sub resumer, fromSeq uint64) (int, uint64, bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.subscribers[sub] = b.lastID + 1
+	oldest := b.lastID + 1
+	if len(b.history) > 0 {
+		oldest = b.history[0].id
+	}
+	if fromSeq < oldest {
+		return 0, oldest, false
+	}
+	var backlog []*broadcastMessage
+	for _, msg := range b.history {
+		if msg.id >= fromSeq && sub.wants(msg) {
+			backlog = append(backlog, msg)
+		}
+	}
+	sub.rewind(backlog)
+	return len(backlog), 0, true
+}
+
+func (b *broadcaster) unsubscribe(sub subscriber) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	delete(b.subscribers, sub)
+}
+
+// disconnectAll ends every subscriber's stream. The subscribers are told
+// concurrently and without the lock, so stalled peers neither add up nor
+// hold up publishing.
+func (b *broadcaster) disconnectAll() {
+	b.mu.Lock()
+	subs := make([]subscriber, 0, len(b.subscribers))
+	for sub := range b.subscribers {
+		subs = append(subs, sub)
+	}
+	b.mu.Unlock()
+
+	var wg sync.WaitGroup
+	for _, sub := range subs {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			sub.disconnect(closeShutdown)
+		}()
+	}
+	wg.Wait()
+}
+
+// broadcasterSnapshot is a point-in-time copy of the broadcaster's state.
+type broadcasterSnapshot struct {
+	subscribers map[string]int
+	clients     []clientState
+	queued      int
+	history     int
+	lastID      uint64
+}
+
+// clientState is the /debug/state entry of one streaming client.
+type clientState struct {
+	Kind    string `json:"kind"`
+	Remote  string `json:"remote"`
+	Queued  int    `json:"queued"`
+	Dropped uint64 `json:"dropped"`
+}
+
+func (b *broadcaster) snapshot() broadcasterSnapshot {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	snap := broadcasterSnapshot{
+		subscribers: make(map[string]int),
+		history:     len(b.history),
+		lastID:      b.lastID,
+	}
+	for sub := range b.subscribers {
+		snap.subscribers[sub.kind()]++
+		snap.queued += sub.queued()
+		snap.clients = append(snap.clients, clientState{
+			Kind:    sub.kind(),
+			Remote:  sub.remoteAddr(),
+			Queued:  sub.queued(),
+			Dropped: sub.droppedCount(),
+		})
+	}
+	return snap
+}
+
+// clearHistory empties the history and returns how many messages it held.
+// Sequence numbers carry on, so clients never see one reused.
+func (b *broadcaster) clearHistory() int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	n := len(b.history)
+	b.history, b.historyBytes = nil, 0
+	return n
+}
+
+// historyLen returns the number of messages in the history.
+func (b *broadcaster) historyLen() int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return len(b.history)
+}
+
+// currentID returns the id of the most recent message.
+func (b *broadcaster) currentID() uint64 {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.lastID
+}
+
+// publish stamps env with the next sequence number and the current time,
+// encodes it, records it in the history and queues it for every matching
+// subscriber.
+func (b *broadcaster) publish(env *envelope) (publishResult, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	env.Seq = b.lastID + 1
+	env.Timestamp = time.Now()
+	data, err := json.Marshal(env)
+	if err != nil {
+		return publishResult{}, err
+	}
+	b.lastID++
+	msg := &broadcastMessage{
+		id:       b.lastID,
+		dataType: env.Type,
+		payload:  env.Payload,
+		data:     data,
+		env:      *env,
+	}
+	if b.historySize > 0 {
+		b.history = append(b.history, msg)
+		b.historyBytes += int64(len(data))
+		for len(b.history) > b.historySize || (b.maxBytes > 0 && b.historyBytes > b.maxBytes && len(b.history) > 1) {
+			b.historyBytes -= int64(len(b.history[0].data))
+			b.history = append(b.history[:0], b.history[1:]...)
+		}
+	}
+
+	res := publishResult{bytes: len(data)}
+	now := time.Now()
+	for sub := range b.subscribers {
+		if !sub.wants(msg) {
+			continue
+		}
+		if sub.enqueue(msg) {
+			res.served++
+			continue
+		}
+		res.dropped++
+		if sub.warnDrop(now) {
+			res.warn = append(res.warn, sub)
+		}
+		if sub.tooSlow() {
+			res.slow = append(res.slow, sub)
+		}
+	}
+	return res, nil
+}
+
+// publishResult is what happened to a published message.
+type publishResult struct {
+	// bytes is the size of the message as JSON.
+	bytes int
+	// served is the number of subscribers the message was queued for.
+	served int
+	// dropped is the number of subscribers whose queue was full.
+	dropped int
+	// warn are the subscribers whose drop should be logged.
+	warn []subscriber
+	// slow are the subscribers that just reached their limit of
+	// consecutive drops.
+	slow []subscriber
+}
+
+// forward broadcasts env, or hands it to its signal's rate limiter when one
+// is configured. The caller must hold configMu.
+func (s *sonifierExtension) forward(env *envelope) {
+	if limiter, ok := s.limiters[signalType(env.Type)]; ok {
+		limiter.offer(env)
+		return
+	}
+	s.broadcast(env)
+}
+
+// broadcastJSON encodes v as the payload of a server-generated message and
+// broadcasts it, bypassing rate limits. Webhooks receive it even while
+// muted.
+func (s *sonifierExtension) broadcastJSON(dataType string, v any) {
+	s.broadcastJSONTo(dataType, v, nil)
+}
+
+// broadcastJSONTo is broadcastJSON for a message routed to the named
+// channels in routes.
+func (s *sonifierExtension) broadcastJSONTo(dataType string, v any, routes []string) {
+	payload, err := json.Marshal(v)
+	if err != nil {
+		s.logger.Error("Failed to encode broadcast payload", zap.String("type", dataType), zap.Error(err))
+		return
+	}
+	s.webhooks.notify(dataType, v, payload)
+	s.broadcast(&envelope{Type: dataType, Payload: payload, Routes: routes})
+}
+
+// broadcast publishes env to all streaming clients unless broadcasting is
+// muted.
+func (s *sonifierExtension) broadcast(env *envelope) {
+	if s.mute.hold(env) {
+		return
+	}
+	s.publish(env)
+}
+
+// publish sends env to all streaming clients and records how long the
+// fan-out took.
+func (s *sonifierExtension) publish(env *envelope) {
+	start := time.Now()
+	env.TTLMs = s.config.Broadcast.MessageTTL.Milliseconds()
+	res, err := s.broadcaster.publish(env)
+	if err != nil {
+		s.logger.Error("Failed to encode broadcast message", zap.Error(err))
+		return
+	}
+	s.telemetry.recordBroadcast(context.Background(), env.Type, time.Since(start), res.served, res.dropped)
+	s.stats.broadcast.Add(1)
+	s.stats.messageSize(res.bytes)
+	s.stats.dropped.Add(uint64(res.dropped))
+	for _, sub := range res.warn {
+		s.logger.Warn("Dropping messages for slow client, consider raising client_queue_size",
+			zap.String("transport", sub.kind()),
+			zap.String("remote", sub.remoteAddr()),
+			zap.Uint64("dropped", sub.droppedCount()),
+			zap.Int("client_queue_size", s.config.ClientQueueSize))
+	}
+	for _, sub := range res.slow {
+		s.logger.Warn("Disconnecting slow client",
+			zap.String("transport", sub.kind()),
+			zap.String("remote", sub.remoteAddr()),
+			zap.Int("consecutive_drops", s.config.MaxClientDrops),
+			zap.Uint64("dropped", sub.droppedCount()))
+		s.stats.slowDisconnects.Add(1)
+		s.telemetry.recordSlowDisconnect(context.Background(), sub.kind())
+		// Sending the close frame can take up to shutdownCloseTimeout, and
+		// the ingest path calls publish with the extension's locks held.
+		go sub.disconnect(closeTooSlow)
+	}
+}
@@ -0,0 +1,220 @@
+package sonifierextension
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// testSubscriber is a subscriber with an unbounded queue that wants every
+// message.
+type testSubscriber struct {
+	mu           sync.Mutex
+	msgs         []*broadcastMessage
+	disconnected chan struct{}
+	once         sync.Once
+	// reason is the reason disconnect was first called with.
+	reason closeReason
+	// rewound are the messages rewind was called with.
+	rewound []*broadcastMessage
+}
+
+func (s *testSubscriber) wants(*broadcastMessage) bool { return true }
+
+func (s *testSubscriber) enqueue(msg *broadcastMessage) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.msgs = append(s.msgs, msg)
+	return true
+}
+
+func (s *testSubscriber) kind() string               { return "test" }
+func (s *testSubscriber) queued() int                { return 0 }
+func (s *testSubscriber) droppedCount() uint64       { return 0 }
+func (s *testSubscriber) tooSlow() bool              { return false }
+func (s *testSubscriber) warnDrop(time.Time) bool    { return false }
+func (s *testSubscriber) remoteAddr() string         { return "test" }
+func (s *testSubscriber) connection() connectionInfo { return connectionInfo{Transport: "test"} }
+
+func (s *testSubscriber) disconnect(reason closeReason) {
+	s.once.Do(func() {
+		s.reason = reason
+		if s.disconnected != nil {
+			close(s.disconnected)
+		}
+	})
+}
+
+func (s *testSubscriber) rewind(msgs []*broadcastMessage) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.rewound = append(s.rewound, msgs...)
+}
+
+// ids returns the ids of the messages queued so far.
+func (s *testSubscriber) ids() []uint64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	ids := make([]uint64, len(s.msgs))
+	for i, msg := range s.msgs {
+		ids[i] = msg.id
+	}
+	return ids
+}
+
+// history returns a copy of the messages in b's history.
+func history(b *broadcaster) []*broadcastMessage {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return append([]*broadcastMessage(nil), b.history...)
+}
+
+// historyTypes returns the types of the messages in b's history.
+func historyTypes(b *broadcaster) []string {
+	var types []string
+	for _, msg := range history(b) {
+		types = append(types, msg.dataType)
+	}
+	return types
+}
+
+// slowSubscriber is a subscriber whose queue is always full and whose
+// disconnect blocks until release is closed, like a WebSocket client whose
+// peer doesn't read the close frame.
+type slowSubscriber struct {
+	testSubscriber
+	release chan struct{}
+}
+
+func (s *slowSubscriber) enqueue(*broadcastMessage) bool { return false }
+func (s *slowSubscriber) tooSlow() bool                  { return true }
+
+func (s *slowSubscriber) disconnect(reason closeReason) {
+	s.testSubscriber.disconnect(reason)
+	<-s.release
+}
+
+func TestPublishDoesNotWaitForSlowDisconnect(t *testing.T) {
+	s := newTestExtension(t)
+	sub := &slowSubscriber{
+		testSubscriber: testSubscriber{disconnected: make(chan struct{})},
+		release:        make(chan struct{}),
+	}
+	defer close(sub.release)
+	s.broadcaster.subscribe(sub, 0)
+
+	published := make(chan struct{})
+	go func() {
+		defer close(published)
+		// As on the ingest path
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		s.publish(&envelope{Type: "logs", Payload: []byte(testLogs)})
+	}()
+	select {
+	case <-published:
+	case <-time.After(5 * time.Second):
+		t.Fatal("publish blocked on the slow client's disconnect")
+	}
+
+	select {
+	case <-sub.disconnected:
+	case <-time.After(5 * time.Second):
+		t.Fatal("slow client wasn't disconnected")
+	}
+	assert.Equal(t, closeTooSlow, sub.reason)
+	assert.Equal(t, uint64(1), s.stats.slowDisconnects.Load())
+}
+
+func TestDisconnectAllReason(t *testing.T) {
+	b := newBroadcaster(0, 0)
+	sub := &testSubscriber{disconnected: make(chan struct{})}
+	b.subscribe(sub, 0)
+	b.disconnectAll()
+	require.Equal(t, closeShutdown, sub.reason)
+}
+
+func TestSubscribeFrom(t *testing.T) {
+	b := newBroadcaster(3, 0)
+	for range 4 {
+		_, err := b.publish(&envelope{Type: "logs", Payload: []byte(testLogs)})
+		require.NoError(t, err)
+	}
+
+	sub := &testSubscriber{}
+	replayed, _, ok := b.subscribeFrom(sub, 3)
+	require.True(t, ok)
+	assert.Equal(t, 2, replayed)
+	_, err := b.publish(&envelope{Type: "logs", Payload: []byte(testLogs)})
+	require.NoError(t, err)
+	require.Len(t, sub.rewound, 2)
+	assert.Equal(t, []uint64{3, 4}, []uint64{sub.rewound[0].id, sub.rewound[1].id})
+	assert.Equal(t, []uint64{5}, sub.ids(), "live messages are only queued after the backlog")
+
+	// Message 1 has been evicted
+	gap := &testSubscriber{}
+	_, oldest, ok := b.subscribeFrom(gap, 1)
+	assert.False(t, ok)
+	assert.Equal(t, uint64(3), oldest)
+	assert.Empty(t, gap.rewound)
+	_, err = b.publish(&envelope{Type: "logs", Payload: []byte(testLogs)})
+	require.NoError(t, err)
+	assert.Equal(t, []uint64{6}, gap.ids())
+}
+
+func TestDisconnectAllConcurrently(t *testing.T) {
+	b := newBroadcaster(0, 0)
+	release := make(chan struct{})
+	var subs []*slowSubscriber
+	for range 3 {
+		sub := &slowSubscriber{
+			testSubscriber: testSubscriber{disconnected: make(chan struct{})},
+			release:        release,
+		}
+		b.subscribe(sub, 0)
+		subs = append(subs, sub)
+	}
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		b.disconnectAll()
+	}()
+	// Every subscriber is told while the others are still blocked
+	for i, sub := range subs {
+		select {
+		case <-sub.disconnected:
+		case <-time.After(5 * time.Second):
+			t.Fatalf("subscriber %d wasn't disconnected while the others blocked", i)
+		}
+		assert.Equal(t, closeShutdown, sub.reason)
+	}
+
+	// and publishing doesn't wait for them
+	published := make(chan struct{})
+	go func() {
+		defer close(published)
+		_, err := b.publish(&envelope{Type: "logs", Payload: []byte(testLogs)})
+		assert.NoError(t, err)
+	}()
+	select {
+	case <-published:
+	case <-time.After(5 * time.Second):
+		t.Fatal("publish blocked on disconnectAll")
+	}
+
+	select {
+	case <-done:
+		t.Fatal("disconnectAll returned before the subscribers were disconnected")
+	default:
+	}
+	close(release)
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("disconnectAll didn't return")
+	}
+}
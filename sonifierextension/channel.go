@@ -0,0 +1,241 @@
+package sonifierextension
+
+import (
+	"encoding/json"
+	"net/http"
+	"slices"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/collector/pdata/pcommon"
+	"go.opentelemetry.io/collector/pdata/plog"
+	"go.opentelemetry.io/collector/pdata/pmetric"
+	"go.opentelemetry.io/collector/pdata/ptrace"
+	"go.uber.org/zap"
+)
+
+// Resource attributes identifying a payload's environment. Older semantic
+// conventions use the first, newer ones the second.
+const (
+	deploymentEnvironmentKey     = "deployment.environment"
+	deploymentEnvironmentNameKey = "deployment.environment.name"
+)
+
+// serviceChannels assigns every service a channel number in the order it
+// is first seen. Numbers are never reused or reassigned while the process
+// runs, so clients can map them to instruments deterministically.
+type serviceChannels struct {
+	mu       sync.Mutex
+	channels map[string]int
+	services []serviceChannel
+}
+
+// serviceChannel is the /services entry of one service.
+type serviceChannel struct {
+	Service      string    `json:"service"`
+	Channel      int       `json:"channel"`
+	Environments []string  `json:"environments,omitempty"`
+	FirstSeen    time.Time `json:"first_seen"`
+}
+
+func newServiceChannels() *serviceChannels {
+	return &serviceChannels{channels: make(map[string]int)}
+}
+
+// assign returns the channel of service, assigning the next one on first
+// sight, and notes the environment it was seen in.
+func (c *serviceChannels) assign(service, environment string) int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	channel, ok := c.channels[service]
+	if !ok {
+		channel = len(c.services)
+		c.channels[service] = channel
+		c.services = append(c.services, serviceChannel{
+			Service:   service,
+			Channel:   channel,
+			FirstSeen: time.Now(),
+		})
+	}
+	entry := &c.services[channel]
+	if environment != "" && !slices.Contains(entry.Environments, environment) {
+		entry.Environments = append(entry.Environments, environment)
+	}
+	return channel
+}
+
+// list returns a copy of the known services in channel order.
+func (c *serviceChannels) list() []serviceChannel {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	services := make([]serviceChannel, len(c.services))
+	for i, entry := range c.services {
+		entry.Environments = slices.Clone(entry.Environments)
+		services[i] = entry
+	}
+	return services
+}
+
+// handleServices lists the known services and their channels.
+func (s *sonifierExtension) handleServices(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(map[string]any{"services": s.channels.list()}); err != nil {
+		s.logger.Error("Failed to write services response", zap.Error(err))
+	}
+}
+
+// resourceEnvelopes builds the broadcast messages for a decoded payload:
+// one per resource, each tagged with its service, environment and channel,
+// so clients can route messages without parsing the payload. A payload
+// with a single resource, or one that couldn't be parsed, keeps payload as
+// its body. With notes enabled, traces are sent as notes instead, and as
+// well when notes.forward_raw is set. Resources over
+// broadcast.max_message_bytes are split further or summarized, according
+// to broadcast.oversize.
+func (s *sonifierExtension) resourceEnvelopes(decoded *decodedTelemetry, payload json.RawMessage) []*envelope {
+	parts := s.splitOversize(splitResources(decoded), payload)
+	envs := make([]*envelope, 0, len(parts))
+	var depths map[pcommon.SpanID]int
+	if s.noter.applies(decoded) {
+		// Parents may belong to another resource, so look at them all
+		depths = spanDepths(decoded.traces)
+	}
+	for _, part := range parts {
+		env := &envelope{Type: decoded.dataType, Payload: payload}
+		if service, environment := resourceIdentity(part); service != "" {
+			channel := s.channels.assign(service, environment)
+			env.Service, env.Environment, env.Channel = service, environment, &channel
+		}
+		env.Routes = s.router.routeDecoded(part)
+		if s.panner != nil {
+			if p, ok := s.panner.pan(part); ok {
+				env.Pan = &p
+			}
+		}
+		if s.normalizer.applies(part) {
+			env.Normalized = s.normalizer.observe(part.metrics, time.Now())
+		}
+		if s.chords.applies(part) {
+			env.Chord = s.chords.chords(part.metrics)
+		}
+		if s.voicer.applies(part) {
+			env.Voice = s.voicer.voices(part)
+		}
+		if s.noter.applies(part) {
+			notes := *env
+			notes.Type = "notes"
+			var err error
+			if notes.Payload, err = s.noter.encode(part.traces, depths); err != nil {
+				s.logger.Warn("Failed to encode notes", zap.Error(err))
+			} else {
+				envs = append(envs, s.fitEnvelope(&notes, part))
+			}
+			if !s.noter.forwardRaw {
+				continue
+			}
+		}
+		if len(parts) > 1 {
+			if part.json == nil {
+				if err := part.encodeJSON(); err != nil {
+					s.logger.Warn("Failed to encode resource payload", zap.String("type", decoded.dataType), zap.Error(err))
+					continue
+				}
+			}
+			env.Payload = part.json
+		}
+		envs = append(envs, s.fitEnvelope(env, part))
+	}
+	return envs
+}
+
+// splitResources returns a parsed payload with several resources as one
+// payload per resource, and any other payload as is.
+func splitResources(d *decodedTelemetry) []*decodedTelemetry {
+	if !d.parsed {
+		return []*decodedTelemetry{d}
+	}
+	var parts []*decodedTelemetry
+	switch d.dataType {
+	case "traces":
+		rs := d.traces.ResourceSpans()
+		if rs.Len() <= 1 {
+			return []*decodedTelemetry{d}
+		}
+		for i := 0; i < rs.Len(); i++ {
+			traces := ptrace.NewTraces()
+			rs.At(i).CopyTo(traces.ResourceSpans().AppendEmpty())
+			parts = append(parts, &decodedTelemetry{dataType: d.dataType, parsed: true, traces: traces})
+		}
+	case "metrics":
+		rm := d.metrics.ResourceMetrics()
+		if rm.Len() <= 1 {
+			return []*decodedTelemetry{d}
+		}
+		for i := 0; i < rm.Len(); i++ {
+			metrics := pmetric.NewMetrics()
+			rm.At(i).CopyTo(metrics.ResourceMetrics().AppendEmpty())
+			parts = append(parts, &decodedTelemetry{dataType: d.dataType, parsed: true, metrics: metrics})
+		}
+	case "logs":
+		rl := d.logs.ResourceLogs()
+		if rl.Len() <= 1 {
+			return []*decodedTelemetry{d}
+		}
+		for i := 0; i < rl.Len(); i++ {
+			logs := plog.NewLogs()
+			rl.At(i).CopyTo(logs.ResourceLogs().AppendEmpty())
+			parts = append(parts, &decodedTelemetry{dataType: d.dataType, parsed: true, logs: logs})
+		}
+	default:
+		return []*decodedTelemetry{d}
+	}
+	return parts
+}
+
+// resourceIdentity returns the service.name and deployment environment of
+// the first resource in a parsed payload.
+func resourceIdentity(d *decodedTelemetry) (service, environment string) {
+	res, ok := firstResource(d)
+	if !ok {
+		return "", ""
+	}
+	attrs := res.Attributes()
+	if v, ok := attrs.Get(serviceNameKey); ok {
+		service = v.AsString()
+	}
+	if v, ok := attrs.Get(deploymentEnvironmentNameKey); ok {
+		environment = v.AsString()
+	} else if v, ok := attrs.Get(deploymentEnvironmentKey); ok {
+		environment = v.AsString()
+	}
+	return service, environment
+}
+
+// firstResource returns the resource of a parsed payload's first resource
+// entry, which is its only one once split by resource.
+func firstResource(d *decodedTelemetry) (pcommon.Resource, bool) {
+	if !d.parsed {
+		return pcommon.Resource{}, false
+	}
+	switch d.dataType {
+	case "traces":
+		if d.traces.ResourceSpans().Len() > 0 {
+			return d.traces.ResourceSpans().At(0).Resource(), true
+		}
+	case "metrics":
+		if d.metrics.ResourceMetrics().Len() > 0 {
+			return d.metrics.ResourceMetrics().At(0).Resource(), true
+		}
+	case "logs":
+		if d.logs.ResourceLogs().Len() > 0 {
+			return d.logs.ResourceLogs().At(0).Resource(), true
+		}
+	}
+	return pcommon.Resource{}, false
+}
@@ -0,0 +1,111 @@
+package sonifierextension
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// testMultiResourceLogs has a log record from each of three resources:
+// auth in prod, named by both environment attributes, billing in dev and
+// one without a service.
+const testMultiResourceLogs = `{"resourceLogs":[
+  {"resource":{"attributes":[{"key":"service.name","value":{"stringValue":"auth"}},{"key":"deployment.environment","value":{"stringValue":"staging"}},{"key":"deployment.environment.name","value":{"stringValue":"prod"}}]},"scopeLogs":[{"logRecords":[{"severityNumber":17,"body":{"stringValue":"denied"}}]}]},
+  {"resource":{"attributes":[{"key":"service.name","value":{"stringValue":"billing"}},{"key":"deployment.environment","value":{"stringValue":"dev"}}]},"scopeLogs":[{"logRecords":[{"severityNumber":9,"body":{"stringValue":"invoiced"}}]}]},
+  {"resource":{"attributes":[]},"scopeLogs":[{"logRecords":[{"severityNumber":9,"body":{"stringValue":"anonymous"}}]}]}
+]}`
+
+func intPtr(n int) *int { return &n }
+
+func TestServiceChannels(t *testing.T) {
+	c := newServiceChannels()
+	assert.Equal(t, 0, c.assign("checkout", ""))
+	assert.Equal(t, 1, c.assign("auth", "prod"))
+	assert.Equal(t, 0, c.assign("checkout", "dev"))
+	assert.Equal(t, 1, c.assign("auth", "prod"))
+	assert.Equal(t, 1, c.assign("auth", "staging"))
+
+	services := c.list()
+	require.Len(t, services, 2)
+	assert.Equal(t, "checkout", services[0].Service)
+	assert.Equal(t, 0, services[0].Channel)
+	assert.Equal(t, []string{"dev"}, services[0].Environments)
+	assert.Equal(t, "auth", services[1].Service)
+	assert.Equal(t, 1, services[1].Channel)
+	assert.Equal(t, []string{"prod", "staging"}, services[1].Environments)
+	assert.False(t, services[1].FirstSeen.IsZero())
+
+	// The list is a copy
+	services[1].Environments[0] = "changed"
+	assert.Equal(t, []string{"prod", "staging"}, c.list()[1].Environments)
+}
+
+func TestResourceEnvelopes(t *testing.T) {
+	s := newTestExtension(t)
+	payload := []byte(testMultiResourceLogs)
+	envs := s.resourceEnvelopes(decodeTelemetry(payload, "logs"), payload)
+	require.Len(t, envs, 3)
+
+	for i, want := range []struct {
+		service, environment, body string
+		channel                    *int
+	}{
+		{"auth", "prod", "denied", intPtr(0)},
+		{"billing", "dev", "invoiced", intPtr(1)},
+		{"", "", "anonymous", nil},
+	} {
+		env := envs[i]
+		assert.Equal(t, "logs", env.Type, "envelope %d", i)
+		assert.Equal(t, want.service, env.Service, "envelope %d", i)
+		assert.Equal(t, want.environment, env.Environment, "envelope %d", i)
+		assert.Equal(t, want.channel, env.Channel, "envelope %d", i)
+
+		// Each envelope carries only its own resource
+		part := decodeTelemetry(env.Payload, "logs")
+		require.True(t, part.parsed, "envelope %d", i)
+		require.Equal(t, 1, part.logs.ResourceLogs().Len(), "envelope %d", i)
+		record := part.logs.ResourceLogs().At(0).ScopeLogs().At(0).LogRecords().At(0)
+		assert.Equal(t, want.body, record.Body().AsString(), "envelope %d", i)
+	}
+
+	// A payload with one resource is sent as it came, on the service's
+	// existing channel
+	envs = s.resourceEnvelopes(decodeTelemetry([]byte(testTraces), "traces"), []byte(testTraces))
+	require.Len(t, envs, 1)
+	assert.Equal(t, testTraces, string(envs[0].Payload))
+	assert.Equal(t, intPtr(2), envs[0].Channel)
+	envs = s.resourceEnvelopes(decodeTelemetry([]byte(testLogs), "logs"), []byte(testLogs))
+	assert.Equal(t, intPtr(0), envs[0].Channel, "auth keeps its channel")
+
+	// Unparsed payloads have no service
+	envs = s.resourceEnvelopes(decodeTelemetry([]byte("hello"), ""), []byte("hello"))
+	require.Len(t, envs, 1)
+	assert.Empty(t, envs[0].Service)
+	assert.Nil(t, envs[0].Channel)
+}
+
+func TestServicesEndpoint(t *testing.T) {
+	_, url := startTestExtension(t)
+	status, _ := postTelemetry(t, url, "/v1/logs", testMultiResourceLogs)
+	require.Equal(t, http.StatusOK, status)
+
+	resp, err := http.Get(url + "/services")
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	var body struct {
+		Services []serviceChannel `json:"services"`
+	}
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&body))
+	require.Len(t, body.Services, 2)
+	assert.Equal(t, serviceChannel{Service: "auth", Channel: 0, Environments: []string{"prod"}, FirstSeen: body.Services[0].FirstSeen}, body.Services[0])
+	assert.Equal(t, serviceChannel{Service: "billing", Channel: 1, Environments: []string{"dev"}, FirstSeen: body.Services[1].FirstSeen}, body.Services[1])
+
+	resp, err = http.Post(url+"/services", "application/json", nil)
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusMethodNotAllowed, resp.StatusCode)
+}
@@ -0,0 +1,173 @@
+package sonifierextension
+
+import (
+	"fmt"
+	"maps"
+	"math"
+	"slices"
+	"strconv"
+	"strings"
+
+	"go.opentelemetry.io/collector/pdata/pmetric"
+)
+
+// chordScales are the semitone offsets from the root of each scale's
+// degrees within one octave.
+var chordScales = map[string][]int{
+	"major":            {0, 2, 4, 5, 7, 9, 11},
+	"minor":            {0, 2, 3, 5, 7, 8, 10},
+	"pentatonic":       {0, 2, 4, 7, 9},
+	"minor_pentatonic": {0, 3, 5, 7, 10},
+	"whole_tone":       {0, 2, 4, 6, 8, 10},
+	"chromatic":        {0, 1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11},
+}
+
+// pitchClasses are the semitones of the natural note names above C.
+var pitchClasses = map[byte]int{'C': 0, 'D': 2, 'E': 4, 'F': 5, 'G': 7, 'A': 9, 'B': 11}
+
+// chord is one histogram data point played as a chord.
+type chord struct {
+	Metric     string         `json:"metric"`
+	Attributes map[string]any `json:"attributes,omitempty"`
+	Notes      []chordNote    `json:"notes"`
+}
+
+// chordNote is one occupied histogram bucket. Bucket i is the scale's i-th
+// degree above the root, so higher buckets sound higher.
+type chordNote struct {
+	Bucket int `json:"bucket"`
+	// UpperBound is the bucket's upper bound, or absent for the overflow
+	// bucket.
+	UpperBound *float64 `json:"upper_bound,omitempty"`
+	Count      uint64   `json:"count"`
+	// Note is the MIDI note number and Pitch its frequency in Hz.
+	Note  int     `json:"note"`
+	Pitch float64 `json:"pitch"`
+	// Velocity is the bucket's count relative to the fullest bucket, from
+	// 0 to 1.
+	Velocity float64 `json:"velocity"`
+}
+
+// chordMapper turns histogram bucket counts into chords. A nil mapper adds
+// nothing.
+type chordMapper struct {
+	root  int
+	scale []int
+}
+
+func newChordMapper(cfg ChordConfig) (*chordMapper, error) {
+	if !cfg.Enabled {
+		return nil, nil
+	}
+	scale, ok := chordScales[strings.ToLower(cfg.Scale)]
+	if !ok {
+		return nil, fmt.Errorf("unknown chord.scale %q, expected one of %s",
+			cfg.Scale, strings.Join(slices.Sorted(maps.Keys(chordScales)), ", "))
+	}
+	root, err := parseNoteName(cfg.Root)
+	if err != nil {
+		return nil, fmt.Errorf("chord.root: %w", err)
+	}
+	return &chordMapper{root: root, scale: scale}, nil
+}
+
+// parseNoteName returns the MIDI note number of a name such as C4, F#3 or
+// Bb2, where C4 is middle C (60).
+func parseNoteName(name string) (int, error) {
+	if name == "" {
+		return 0, fmt.Errorf("empty note name")
+	}
+	pc, ok := pitchClasses[strings.ToUpper(name[:1])[0]]
+	if !ok {
+		return 0, fmt.Errorf("invalid note %q, expected a name such as C4, F#3 or Bb2", name)
+	}
+	rest := name[1:]
+	if s, ok := strings.CutPrefix(rest, "#"); ok {
+		pc, rest = pc+1, s
+	} else if s, ok := strings.CutPrefix(rest, "b"); ok {
+		pc, rest = pc-1, s
+	}
+	octave, err := strconv.Atoi(rest)
+	if err != nil {
+		return 0, fmt.Errorf("invalid note %q, expected a name such as C4, F#3 or Bb2", name)
+	}
+	note := 12*(octave+1) + pc
+	if note < 0 || note > 127 {
+		return 0, fmt.Errorf("note %q is outside the MIDI range", name)
+	}
+	return note, nil
+}
+
+// applies reports whether d is a metrics payload to look for histograms in.
+func (c *chordMapper) applies(d *decodedTelemetry) bool {
+	return c != nil && d.parsed && d.dataType == "metrics"
+}
+
+// chords returns a chord for every histogram data point in md with at
+// least one occupied bucket. Exponential histograms are skipped.
+func (c *chordMapper) chords(md pmetric.Metrics) []chord {
+	var chords []chord
+	rm := md.ResourceMetrics()
+	for i := 0; i < rm.Len(); i++ {
+		sm := rm.At(i).ScopeMetrics()
+		for j := 0; j < sm.Len(); j++ {
+			metrics := sm.At(j).Metrics()
+			for k := 0; k < metrics.Len(); k++ {
+				m := metrics.At(k)
+				if m.Type() != pmetric.MetricTypeHistogram {
+					continue
+				}
+				points := m.Histogram().DataPoints()
+				for l := 0; l < points.Len(); l++ {
+					if notes := c.notes(points.At(l)); len(notes) > 0 {
+						chords = append(chords, chord{
+							Metric:     m.Name(),
+							Attributes: points.At(l).Attributes().AsRaw(),
+							Notes:      notes,
+						})
+					}
+				}
+			}
+		}
+	}
+	return chords
+}
+
+func (c *chordMapper) notes(dp pmetric.HistogramDataPoint) []chordNote {
+	counts, bounds := dp.BucketCounts(), dp.ExplicitBounds()
+	var fullest uint64
+	for i := 0; i < counts.Len(); i++ {
+		fullest = max(fullest, counts.At(i))
+	}
+	if fullest == 0 {
+		return nil
+	}
+	var notes []chordNote
+	for i := 0; i < counts.Len(); i++ {
+		count := counts.At(i)
+		if count == 0 {
+			continue
+		}
+		note := c.degree(i)
+		n := chordNote{
+			Bucket:   i,
+			Count:    count,
+			Note:     note,
+			Pitch:    440 * math.Pow(2, float64(note-69)/12),
+			Velocity: float64(count) / float64(fullest),
+		}
+		if i < bounds.Len() {
+			bound := bounds.At(i)
+			n.UpperBound = &bound
+		}
+		notes = append(notes, n)
+	}
+	return notes
+}
+
+// degree returns the MIDI note of the scale's i-th degree above the root,
+// capped at the top of the MIDI range.
+func (c *chordMapper) degree(i int) int {
+	octave, step := i/len(c.scale), i%len(c.scale)
+	return min(c.root+12*octave+c.scale[step], 127)
+}
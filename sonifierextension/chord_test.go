@@ -0,0 +1,120 @@
+package sonifierextension
+
+import (
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/collector/pdata/pmetric"
+)
+
+// testHistogram returns a payload with one point of the histogram name
+// per counts, all sharing bounds.
+func testHistogram(name string, bounds []float64, counts ...[]uint64) pmetric.Metrics {
+	md := pmetric.NewMetrics()
+	m := md.ResourceMetrics().AppendEmpty().ScopeMetrics().AppendEmpty().Metrics().AppendEmpty()
+	m.SetName(name)
+	points := m.SetEmptyHistogram().DataPoints()
+	for i, c := range counts {
+		dp := points.AppendEmpty()
+		dp.Attributes().PutInt("point", int64(i))
+		dp.ExplicitBounds().FromRaw(bounds)
+		dp.BucketCounts().FromRaw(c)
+	}
+	return md
+}
+
+// midiPitch is the frequency in Hz of a MIDI note.
+func midiPitch(note int) float64 {
+	return 440 * math.Pow(2, float64(note-69)/12)
+}
+
+func TestParseNoteName(t *testing.T) {
+	for name, want := range map[string]int{
+		"C4":  60,
+		"c4":  60,
+		"A4":  69,
+		"F#3": 54,
+		"Bb2": 46,
+		"C-1": 0,
+		"G9":  127,
+	} {
+		got, err := parseNoteName(name)
+		require.NoError(t, err, name)
+		assert.Equal(t, want, got, name)
+	}
+
+	for name, wantErr := range map[string]string{
+		"":     "empty note name",
+		"H4":   `invalid note "H4", expected a name such as C4, F#3 or Bb2`,
+		"C":    `invalid note "C", expected a name such as C4, F#3 or Bb2`,
+		"Cx4":  `invalid note "Cx4", expected a name such as C4, F#3 or Bb2`,
+		"G#9":  `note "G#9" is outside the MIDI range`,
+		"Cb-1": `note "Cb-1" is outside the MIDI range`,
+	} {
+		_, err := parseNoteName(name)
+		assert.EqualError(t, err, wantErr, name)
+	}
+}
+
+func TestNewChordMapper(t *testing.T) {
+	c, err := newChordMapper(ChordConfig{Root: "C3", Scale: "pentatonic"})
+	require.NoError(t, err)
+	assert.Nil(t, c, "disabled")
+	assert.False(t, c.applies(loadFixture(t, "metrics")))
+
+	_, err = newChordMapper(ChordConfig{Enabled: true, Root: "C3", Scale: "blues"})
+	assert.EqualError(t, err, `unknown chord.scale "blues", expected one of chromatic, major, minor, minor_pentatonic, pentatonic, whole_tone`)
+	_, err = newChordMapper(ChordConfig{Enabled: true, Root: "X3", Scale: "major"})
+	assert.ErrorContains(t, err, `chord.root: invalid note "X3"`)
+
+	c, err = newChordMapper(ChordConfig{Enabled: true, Root: "C3", Scale: "Major"})
+	require.NoError(t, err, "scales are case-insensitive")
+	assert.True(t, c.applies(loadFixture(t, "metrics")))
+	assert.False(t, c.applies(loadFixture(t, "traces")))
+}
+
+func TestChords(t *testing.T) {
+	c, err := newChordMapper(ChordConfig{Enabled: true, Root: "C3", Scale: "pentatonic"})
+	require.NoError(t, err)
+
+	// The second point is empty and plays nothing; of the first, buckets
+	// 1, 2 and 4 are the 2nd, 3rd and 5th degrees of C3 pentatonic, and the
+	// overflow bucket 5 starts the next octave
+	bounds := []float64{0.005, 0.01, 0.025, 0.05, 0.1}
+	md := testHistogram("latency", bounds, []uint64{0, 4, 6, 0, 1, 3}, []uint64{0, 0, 0, 0, 0, 0})
+	gauge := md.ResourceMetrics().At(0).ScopeMetrics().At(0).Metrics().AppendEmpty()
+	gauge.SetName("cpu")
+	gauge.SetEmptyGauge().DataPoints().AppendEmpty().SetDoubleValue(1)
+
+	chords := c.chords(md)
+	require.Len(t, chords, 1)
+	assert.Equal(t, "latency", chords[0].Metric)
+	assert.Equal(t, map[string]any{"point": int64(0)}, chords[0].Attributes)
+
+	want := []chordNote{
+		{Bucket: 1, UpperBound: &bounds[1], Count: 4, Note: 50, Velocity: 4.0 / 6},
+		{Bucket: 2, UpperBound: &bounds[2], Count: 6, Note: 52, Velocity: 1},
+		{Bucket: 4, UpperBound: &bounds[4], Count: 1, Note: 57, Velocity: 1.0 / 6},
+		{Bucket: 5, Count: 3, Note: 60, Velocity: 0.5},
+	}
+	got := chords[0].Notes
+	require.Len(t, got, len(want))
+	for i := range want {
+		want[i].Pitch = midiPitch(want[i].Note)
+		assert.InDelta(t, want[i].Pitch, got[i].Pitch, 1e-9, "note %d", i)
+		assert.InDelta(t, want[i].Velocity, got[i].Velocity, 1e-9, "note %d", i)
+		want[i].Pitch, want[i].Velocity = got[i].Pitch, got[i].Velocity
+	}
+	assert.Equal(t, want, got)
+	assert.InDelta(t, 261.63, got[3].Pitch, 0.01, "middle C")
+}
+
+func TestChordDegreeCappedAtMIDIRange(t *testing.T) {
+	c, err := newChordMapper(ChordConfig{Enabled: true, Root: "G8", Scale: "chromatic"})
+	require.NoError(t, err)
+	assert.Equal(t, 115, c.degree(0))
+	assert.Equal(t, 127, c.degree(12))
+	assert.Equal(t, 127, c.degree(20))
+}
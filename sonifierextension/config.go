@@ -1,18 +1,764 @@
 package sonifierextension
 
 import (
-	"go.opentelemetry.io/collector/config/confighttp"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
 	"go.opentelemetry.io/collector/component"
+	"go.opentelemetry.io/collector/config/confighttp"
+	"go.opentelemetry.io/collector/config/configopaque"
 )
 
 // Config has the configuration for the sonifier extension.
 type Config struct {
 	confighttp.ServerConfig `mapstructure:",squash"`
+
+	// AdminToken is the bearer token required by administrative endpoints
+	// such as /debug/state, /control/reset and the record, replay and demo
+	// controls. Those endpoints are disabled when it is unset.
+	AdminToken configopaque.String `mapstructure:"admin_token"`
+
+	// Auth restricts who may stream telemetry and who may send it.
+	Auth AuthConfig `mapstructure:"auth"`
+
+	// MaxRequestBodyBytes is the largest telemetry request body accepted.
+	// Larger requests are rejected with 413 before they are read in full.
+	MaxRequestBodyBytes int64 `mapstructure:"max_request_body_bytes"`
+
+	// AcceptUnknown ingests bodies posted to the legacy /telemetry endpoint
+	// that aren't OTLP, passing them through to clients as-is. Otherwise
+	// they are rejected with 400 like on the /v1 endpoints. It can't be
+	// combined with Redact, as such bodies can't be redacted.
+	AcceptUnknown bool `mapstructure:"accept_unknown"`
+
+	// IngestLimits caps how fast each signal type is accepted. Payloads
+	// over a limit are rejected with 429 before they are decoded.
+	IngestLimits IngestLimitsConfig `mapstructure:"ingest_limits"`
+
+	// ClientQueueSize is how many messages may wait for a slow WebSocket
+	// or SSE client before further messages to it are dropped.
+	ClientQueueSize int `mapstructure:"client_queue_size"`
+
+	// MaxClientDrops disconnects a client once this many consecutive
+	// messages have been dropped for it, as it is too slow to catch up.
+	// Zero keeps slow clients connected.
+	MaxClientDrops int `mapstructure:"max_client_drops"`
+
+	// WebSocket configures the /ws streaming endpoint.
+	WebSocket WebSocketConfig `mapstructure:"websocket"`
+
+	// SSE configures the /events streaming endpoint.
+	SSE SSEConfig `mapstructure:"sse"`
+
+	// Buffer configures the history of broadcast messages kept for
+	// reconnecting clients.
+	Buffer BufferConfig `mapstructure:"buffer"`
+
+	// Broadcast configures how telemetry is fanned out to clients.
+	Broadcast BroadcastConfig `mapstructure:"broadcast"`
+
+	// Channels route telemetry to named channels by resource attribute,
+	// for clients that connect with ?channel=name.
+	Channels map[string]ChannelConfig `mapstructure:"channels"`
+
+	// Aggregation configures periodic summary messages.
+	Aggregation AggregationConfig `mapstructure:"aggregation"`
+
+	// Record configures recording received telemetry to disk.
+	Record RecordConfig `mapstructure:"record"`
+
+	// Replay configures replaying recordings through /replay.
+	Replay ReplayConfig `mapstructure:"replay"`
+
+	// DemoOnStart starts the built-in demo stream, as POST /demo/start
+	// does, when the extension starts.
+	DemoOnStart bool `mapstructure:"demo_on_start"`
+
+	// Control configures the /control mute switch.
+	Control ControlConfig `mapstructure:"control"`
+
+	// Alarm configures the trace error-rate alarm.
+	Alarm AlarmConfig `mapstructure:"alarm"`
+
+	// Anomaly configures alerts for metric spikes and error bursts.
+	Anomaly AnomalyConfig `mapstructure:"anomaly"`
+
+	// Webhooks POST alerts, alarms and summaries to external endpoints.
+	Webhooks []WebhookConfig `mapstructure:"webhooks"`
+
+	// Topology configures the service call graph built from traces.
+	Topology TopologyConfig `mapstructure:"topology"`
+
+	// Traces has shorthand settings for trace filtering.
+	Traces TracesConfig `mapstructure:"traces"`
+
+	// Logs has shorthand settings for log filtering.
+	Logs LogsConfig `mapstructure:"logs"`
+
+	// Redact masks sensitive attribute values before broadcasting.
+	Redact RedactConfig `mapstructure:"redact"`
+
+	// Pan adds a stereo position to broadcast telemetry.
+	Pan PanConfig `mapstructure:"pan"`
+
+	// Filters selects which telemetry is sonified.
+	Filters FiltersConfig `mapstructure:"filters"`
+
+	// Mappings configures server-side rules that turn telemetry into
+	// sound events.
+	Mappings MappingsConfig `mapstructure:"mappings"`
+
+	// Notes configures broadcasting spans as ready-to-play notes.
+	Notes NotesConfig `mapstructure:"notes"`
+
+	// Normalize adds values scaled to 0-1 to metric messages.
+	Normalize NormalizeConfig `mapstructure:"normalize"`
+
+	// Chord adds histogram bucket counts played as chords to metric
+	// messages.
+	Chord ChordConfig `mapstructure:"chord"`
+
+	// MIDI sends the mapping rules' sound events as MIDI messages too.
+	MIDI MIDIConfig `mapstructure:"midi"`
+
+	// OSC sends the mapping rules' sound events as OSC messages over UDP.
+	OSC OSCConfig `mapstructure:"osc"`
+}
+
+// AuthConfig has the access settings for listeners and producers.
+type AuthConfig struct {
+	// AllowedOrigins are the browser origins, besides the server's own,
+	// that may open /ws and /events. Entries are exact origins or patterns
+	// where * matches anything; "*" allows every origin.
+	AllowedOrigins []string `mapstructure:"allowed_origins"`
+	// ListenerToken is required by /ws, /events, /telemetry-data and the
+	// control, mute, record and replay endpoints when set, as a bearer
+	// token or the token query parameter.
+	ListenerToken configopaque.String `mapstructure:"listener_token"`
+	// IngestToken is required as a bearer token to post telemetry when set,
+	// so producers and listeners use different credentials.
+	IngestToken configopaque.String `mapstructure:"ingest_token"`
+	// SigningKey enables POST /ws-token, which issues tokens signed with
+	// it to holders of the listener token. /ws and /events accept them in
+	// the token query parameter until they expire after TokenTTL.
+	SigningKey configopaque.String `mapstructure:"signing_key"`
+	TokenTTL   time.Duration       `mapstructure:"token_ttl"`
+}
+
+// IngestLimitsConfig has the ingest limits of each signal type. Zero rates
+// are unlimited.
+type IngestLimitsConfig struct {
+	// Burst is how many seconds' worth of each rate may arrive at once
+	// after a quiet spell.
+	Burst   time.Duration `mapstructure:"burst"`
+	Traces  IngestLimit   `mapstructure:"traces"`
+	Metrics IngestLimit   `mapstructure:"metrics"`
+	Logs    IngestLimit   `mapstructure:"logs"`
+}
+
+// IngestLimit is the limit of one signal type, and optionally of each
+// service.name sending it.
+type IngestLimit struct {
+	IngestRate `mapstructure:",squash"`
+	PerService IngestRate `mapstructure:"per_service"`
+}
+
+// IngestRate is a number of payloads and of bytes accepted per second.
+type IngestRate struct {
+	PayloadsPerSec float64 `mapstructure:"payloads_per_sec"`
+	BytesPerSec    int64   `mapstructure:"bytes_per_sec"`
+}
+
+// WebSocketConfig has the settings for WebSocket clients.
+type WebSocketConfig struct {
+	// WriteTimeout bounds each write to a client. A client that doesn't
+	// accept a message within this time is disconnected. Zero disables it.
+	WriteTimeout time.Duration `mapstructure:"write_timeout"`
+	// Subprotocols are accepted during the handshake in addition to json
+	// and msgpack, for client libraries that require the server to echo a
+	// protocol of their own. The first one the client offers is chosen.
+	Subprotocols []string `mapstructure:"subprotocols"`
+	// Compression offers permessage-deflate during the handshake. Messages
+	// to clients that accept it are compressed.
+	Compression bool `mapstructure:"compression"`
+	// MaxClients caps concurrent WebSocket clients. Further upgrades are
+	// refused with a 503. Zero means no limit.
+	MaxClients int `mapstructure:"max_clients"`
+	// IdleTimeout disconnects a client that has neither sent a message nor
+	// answered a ping for this long, such as a tab whose machine went to
+	// sleep. Clients are pinged a few times per timeout. Zero disables it.
+	IdleTimeout time.Duration `mapstructure:"idle_timeout"`
+	// HeartbeatInterval is how often every client is sent a
+	// {"type":"heartbeat"} message, whatever else is being broadcast, so
+	// it can tell a quiet stream from a dead connection. Zero disables
+	// heartbeats.
+	HeartbeatInterval time.Duration `mapstructure:"heartbeat_interval"`
+}
+
+// SSEConfig has the settings for Server-Sent Events clients.
+type SSEConfig struct {
+	// HeartbeatInterval is how often a comment line is sent to keep
+	// proxies from closing idle streams. Zero disables heartbeats.
+	HeartbeatInterval time.Duration `mapstructure:"heartbeat_interval"`
+}
+
+// BufferConfig has the settings for the broadcast history.
+type BufferConfig struct {
+	// MaxEntries is how many recent messages are kept for replay.
+	MaxEntries int `mapstructure:"max_entries"`
+	// MaxBytes caps the total size of the kept messages, so a burst of
+	// large payloads can't hold on to unbounded memory. The newest message
+	// is always kept. Zero means no size cap.
+	MaxBytes int64 `mapstructure:"max_bytes"`
+}
+
+// ChannelConfig selects the resources whose messages go to a named
+// channel.
+type ChannelConfig struct {
+	// Match maps resource attribute keys, such as service.name, to regular
+	// expressions their values must match. A resource is in the channel
+	// when all of them match.
+	Match map[string]string `mapstructure:"match"`
+}
+
+// BroadcastConfig has the settings for fanning telemetry out to clients.
+type BroadcastConfig struct {
+	// MaxMessagesPerSec caps broadcasts per signal type. Excess traces are
+	// sampled, metrics keep the latest payload per interval and logs keep
+	// the highest-severity payload. Zero means no limit.
+	MaxMessagesPerSec SignalRates `mapstructure:"max_messages_per_sec"`
+	// Format is the default WebSocket encoding: json for text frames or
+	// msgpack for binary MessagePack frames. Clients can pick either with
+	// the format query parameter or the json and msgpack subprotocols.
+	Format string `mapstructure:"format"`
+	// MessageTTL is how long a message stays worth sending. A client whose
+	// queue holds messages older than this skips them rather than playing
+	// a delayed echo of live traffic. Zero keeps every message.
+	MessageTTL time.Duration `mapstructure:"message_ttl"`
+	// MaxMessageBytes caps the size of a telemetry message, as JSON and as
+	// MessagePack. Zero means no limit.
+	MaxMessageBytes int `mapstructure:"max_message_bytes"`
+	// Oversize is what happens to a message over MaxMessageBytes: split
+	// sends its spans, data points or log records in several messages, and
+	// summarize replaces the payload with its size and item count. A
+	// message still too large after splitting is summarized.
+	Oversize string `mapstructure:"oversize"`
+}
+
+// SignalRates holds a per-second rate for each signal type.
+type SignalRates struct {
+	Traces  int `mapstructure:"traces"`
+	Metrics int `mapstructure:"metrics"`
+	Logs    int `mapstructure:"logs"`
+}
+
+// byType returns the rates keyed by signal type.
+func (r SignalRates) byType() map[string]int {
+	return map[string]int{
+		"traces":  r.Traces,
+		"metrics": r.Metrics,
+		"logs":    r.Logs,
+	}
+}
+
+// AggregationConfig has the settings for summary messages. When enabled,
+// a {"type":"summary"} message with span, error, latency, log severity and
+// metric totals is broadcast once per window.
+type AggregationConfig struct {
+	Enabled bool `mapstructure:"enabled"`
+	// Window is the length of each summary window.
+	Window time.Duration `mapstructure:"window"`
+	// ForwardRaw keeps broadcasting raw payloads alongside summaries.
+	ForwardRaw bool `mapstructure:"forward_raw"`
+	// Metrics lists the metric names whose latest values are included.
+	// Empty includes every gauge and sum.
+	Metrics []string `mapstructure:"metrics"`
+}
+
+// RecordConfig has the settings for recording. Each accepted payload is
+// appended to Path as a {"ts","type","payload"} JSON line.
+type RecordConfig struct {
+	// Path is the recording file. Recording is unavailable when unset.
+	Path string `mapstructure:"path"`
+	// Enabled starts recording with the extension rather than waiting for
+	// POST /record/start.
+	Enabled bool `mapstructure:"enabled"`
+	// MaxSize is the size in bytes at which the file is rotated. Zero
+	// never rotates.
+	MaxSize int64 `mapstructure:"max_size"`
+	// MaxBackups is how many rotated files are kept as path.1, path.2 and
+	// so on.
+	MaxBackups int `mapstructure:"max_backups"`
+}
+
+// RedactConfig has the settings for masking attribute values before they
+// reach streaming clients, for dashboards shown in public. Matching
+// resource, scope, span, metric data point and log record attributes of
+// parsed OTLP payloads are replaced with "[REDACTED]".
+type RedactConfig struct {
+	// Keys lists attribute keys to redact, e.g. user.id.
+	Keys []string `mapstructure:"keys"`
+	// Patterns lists regular expressions matched against attribute keys.
+	Patterns []string `mapstructure:"patterns"`
+	// KeepInTelemetryData keeps the original values in /telemetry-data.
+	KeepInTelemetryData bool `mapstructure:"keep_in_telemetry_data"`
+}
+
+// PanConfig has the settings for the "pan" field of broadcast telemetry.
+// The value of Attribute on the payload's first span, data point or log
+// record, or else its resource, picks a position between -1 (left) and 1
+// (right), so related messages cluster in the stereo field.
+type PanConfig struct {
+	// Attribute is the attribute to pan by, e.g. http.route or host.name.
+	// Panning is off when unset.
+	Attribute string `mapstructure:"attribute"`
+	// Mapping is hash to spread values evenly across the field, or fixed
+	// to place them at Positions.
+	Mapping string `mapstructure:"mapping"`
+	// Positions maps attribute values to pan positions for the fixed
+	// mapping. Other values are centered.
+	Positions map[string]float64 `mapstructure:"positions"`
+}
+
+// ReplayConfig has the settings for replaying recordings to streaming
+// clients.
+type ReplayConfig struct {
+	// Path is the recording POST /replay plays when the request has no
+	// body. It defaults to record.path.
+	Path string `mapstructure:"path"`
+	// SuppressLive stops broadcasting live telemetry while a replay runs,
+	// instead of mixing it with the replayed messages. Live telemetry is
+	// still received, buffered and recorded.
+	SuppressLive bool `mapstructure:"suppress_live"`
+}
+
+// ControlConfig has the settings for muting broadcasts.
+type ControlConfig struct {
+	// ResumeBacklog is how many of the most recent messages are held while
+	// muted, to be broadcast on a resume with flush. Zero holds none.
+	ResumeBacklog int `mapstructure:"resume_backlog"`
+}
+
+// AlarmConfig has the settings for the error-rate alarm. When enabled, an
+// {"type":"alarm"} message is broadcast when the ratio of error spans over
+// the window stays at or above OnThreshold for Sustain, and again when it
+// stays at or below OffThreshold for Sustain.
+type AlarmConfig struct {
+	Enabled bool `mapstructure:"enabled"`
+	// Window is the rolling window the error ratio is computed over.
+	Window time.Duration `mapstructure:"window"`
+	// OnThreshold is the error ratio, from 0 to 1, that raises the alarm.
+	OnThreshold float64 `mapstructure:"on_threshold"`
+	// OffThreshold is the error ratio that clears it. It must be lower
+	// than OnThreshold.
+	OffThreshold float64 `mapstructure:"off_threshold"`
+	// Sustain is how long a threshold must be crossed before the alarm
+	// changes state.
+	Sustain time.Duration `mapstructure:"sustain"`
+}
+
+// AnomalyConfig has the settings for anomaly alerts. When enabled, an
+// {"type":"alert"} message is broadcast when a metric series' value is more
+// than ZScore standard deviations from its moving average, or when error
+// spans arrive faster than ErrorRate over ErrorWindow. An alert source
+// doesn't fire again until it has recovered and Cooldown has passed.
+type AnomalyConfig struct {
+	Enabled bool `mapstructure:"enabled"`
+	// ZScore is how many standard deviations from the moving average a
+	// metric value must be to raise a metric_spike alert.
+	ZScore float64 `mapstructure:"z_score"`
+	// Alpha is the weight, from 0 to 1, of each new value in a series'
+	// moving average and variance. Higher values adapt faster.
+	Alpha float64 `mapstructure:"alpha"`
+	// Warmup is how many values a series needs before it can alert.
+	Warmup int `mapstructure:"warmup"`
+	// MaxSeries bounds the number of series tracked. The least recently
+	// updated series is forgotten first.
+	MaxSeries int `mapstructure:"max_series"`
+	// ErrorWindow is the rolling window the error span rate is taken over.
+	ErrorWindow time.Duration `mapstructure:"error_window"`
+	// ErrorRate is the error spans per second over ErrorWindow that raise
+	// an error_burst alert. Zero disables error bursts.
+	ErrorRate float64 `mapstructure:"error_rate"`
+	// Cooldown is the least time between two alerts from the same source.
+	Cooldown time.Duration `mapstructure:"cooldown"`
+}
+
+// WebhookConfig is an endpoint that alerts, alarms and summaries are
+// POSTed to as JSON. Failed deliveries are retried with exponential
+// backoff, and an endpoint that keeps failing is left alone for a while.
+type WebhookConfig struct {
+	URL string `mapstructure:"url"`
+	// Events are the message types to send: alert, alarm and summary. Empty
+	// sends all of them.
+	Events []string `mapstructure:"events"`
+	// Secret signs each request body with HMAC-SHA256, sent in the
+	// X-Sonifier-Signature header as sha256=<hex>.
+	Secret configopaque.String `mapstructure:"secret"`
+	// Timeout bounds each delivery attempt. It defaults to 5s.
+	Timeout time.Duration `mapstructure:"timeout"`
+}
+
+// TopologyConfig has the settings for the service call graph. When
+// enabled, caller to callee edges are extracted from trace payloads, the
+// edges that gained calls are broadcast as {"type":"topology"} messages
+// every second, and the whole graph is served at /topology.
+type TopologyConfig struct {
+	Enabled bool `mapstructure:"enabled"`
+	// Window is how fast call and error counts decay: an edge without
+	// calls keeps 1/e of its counts after each window.
+	Window time.Duration `mapstructure:"window"`
+	// MaxEdges bounds the number of edges tracked. The least recently
+	// called edge is evicted first.
+	MaxEdges int `mapstructure:"max_edges"`
+	// JoinWindow is how long spans are held to match parents and children
+	// exported in separate requests. Zero only matches within a request.
+	JoinWindow time.Duration `mapstructure:"join_window"`
+	// MaxPendingSpans bounds the spans held for joining. The oldest are
+	// dropped first.
+	MaxPendingSpans int `mapstructure:"max_pending_spans"`
+}
+
+// NotesConfig has the settings for note messages. When enabled, trace
+// payloads are broadcast as {"type":"notes"} messages with one compact note
+// per span instead of the raw OTLP payload.
+type NotesConfig struct {
+	Enabled bool `mapstructure:"enabled"`
+	// LengthBuckets are the upper bounds of the note lengths, in increasing
+	// order. A note's length is the index of the first bucket its span's
+	// duration fits in, or the number of buckets for longer spans.
+	LengthBuckets []time.Duration `mapstructure:"length_buckets"`
+	// ForwardRaw keeps broadcasting raw trace payloads alongside notes.
+	ForwardRaw bool `mapstructure:"forward_raw"`
+}
+
+// NormalizeConfig has the settings for normalized metric values. When
+// enabled, metric messages carry each gauge and sum data point scaled to
+// 0-1 against the rolling min and max of its series, the metric name plus
+// its resource and data point attributes. Monotonic sums are scaled as
+// per-second rates.
+type NormalizeConfig struct {
+	Enabled bool `mapstructure:"enabled"`
+	// Window is how long observed values count towards a series' range.
+	Window time.Duration `mapstructure:"window"`
+	// MaxSeries bounds the number of series tracked. The least recently
+	// updated series is forgotten first.
+	MaxSeries int `mapstructure:"max_series"`
+}
+
+// ChordConfig has the settings for histogram chords. When enabled, metric
+// messages carry a chord for each histogram data point, with a note per
+// occupied bucket whose velocity follows the bucket's count.
+type ChordConfig struct {
+	Enabled bool `mapstructure:"enabled"`
+	// Root is the note of the first bucket, such as C3 or F#2.
+	Root string `mapstructure:"root"`
+	// Scale is the scale higher buckets climb: major, minor, pentatonic,
+	// minor_pentatonic, whole_tone or chromatic.
+	Scale string `mapstructure:"scale"`
+}
+
+// MIDIConfig has the settings for MIDI output. When enabled, every sound
+// event the mapping rules produce is also sent as a note on, and a note
+// off once its duration is up, to WebSocket clients that connect to /ws
+// with the midi subprotocol. Each binary frame holds one raw MIDI
+// message, for a bridge to write to a MIDI port.
+type MIDIConfig struct {
+	Enabled bool `mapstructure:"enabled"`
+	// Channels pins services to MIDI channels 1 to 16. Other services are
+	// given the remaining channels in first-seen order, leaving out 10,
+	// the General MIDI percussion channel.
+	Channels map[string]int `mapstructure:"channels"`
+	// Rules override the channel or note of the events of named mapping
+	// rules, such as playing errors as a drum on channel 10.
+	Rules map[string]MIDINote `mapstructure:"rules"`
+}
+
+// MIDINote fixes where a mapping rule's events play.
+type MIDINote struct {
+	// Channel is 1 to 16. Zero keeps the service's channel.
+	Channel int `mapstructure:"channel"`
+	// Note is a note name such as D2, the General MIDI snare on channel
+	// 10. Empty keeps the note nearest the event's pitch.
+	Note string `mapstructure:"note"`
+}
+
+// OSCConfig has the settings for OSC output. When enabled, every sound
+// event the mapping rules produce is also sent over UDP as an OSC message
+// with four arguments: pitch in Hz (float), velocity from 0 to 1 (float),
+// duration in milliseconds (int) and the instrument (string).
+type OSCConfig struct {
+	Enabled bool `mapstructure:"enabled"`
+	// Target is the host:port of the OSC server, such as SuperCollider's
+	// language on localhost:57120.
+	Target string `mapstructure:"target"`
+	// Address is the OSC address of each message. {signal}, {service},
+	// {rule} and {instrument} are replaced with the event's fields.
+	Address string `mapstructure:"address"`
+	// Bundle sends the events of each payload together as OSC bundles
+	// instead of one datagram per event.
+	Bundle bool `mapstructure:"bundle"`
+	// MaxMessagesPerSec caps the events sent each second. Events over the
+	// cap are dropped. Zero means no limit.
+	MaxMessagesPerSec int `mapstructure:"max_messages_per_sec"`
+}
+
+// TracesConfig has the settings for traces.
+type TracesConfig struct {
+	// ErrorsOnly forwards only spans with an error status, the same as
+	// filters.traces.span_status: error.
+	ErrorsOnly bool `mapstructure:"errors_only"`
+	// SampleRatio is the share of traces, from 0 to 1, that is forwarded.
+	// Traces are picked by hashing their trace ID, so all spans of a trace
+	// are kept or dropped together.
+	SampleRatio float64 `mapstructure:"sample_ratio"`
+	// HashSeed seeds the trace ID hash. Samplers with the same seed and
+	// ratio keep the same traces.
+	HashSeed uint32 `mapstructure:"hash_seed"`
+	// AlwaysKeepErrors keeps a trace that isn't sampled when the first
+	// export request it arrives in has an error span of it. The decision is
+	// remembered for its later spans, so a trace is never partly kept.
+	AlwaysKeepErrors bool `mapstructure:"always_keep_errors"`
+}
+
+// LogsConfig has the settings for logs.
+type LogsConfig struct {
+	// MinSeverity drops log records below a level such as WARN, the same
+	// as filters.logs.min_severity.
+	MinSeverity string `mapstructure:"min_severity"`
+}
+
+// FiltersConfig has a filter per signal type. Filtered-out data is
+// counted but neither stored nor broadcast.
+type FiltersConfig struct {
+	Traces  FilterConfig `mapstructure:"traces"`
+	Metrics FilterConfig `mapstructure:"metrics"`
+	Logs    FilterConfig `mapstructure:"logs"`
+}
+
+// byType returns the filters keyed by signal type.
+func (f FiltersConfig) byType() map[string]FilterConfig {
+	return map[string]FilterConfig{
+		"traces":  f.Traces,
+		"metrics": f.Metrics,
+		"logs":    f.Logs,
+	}
+}
+
+// FilterConfig selects the data of one signal type to keep.
+type FilterConfig struct {
+	// IncludeServices keeps only resources whose service.name matches one
+	// of these regular expressions. Empty keeps every service.
+	IncludeServices []string `mapstructure:"include_services"`
+	// ExcludeServices drops resources whose service.name matches one of
+	// these regular expressions, even if they are included.
+	ExcludeServices []string `mapstructure:"exclude_services"`
+	// MinSeverity drops log records below a level such as WARN or ERROR.
+	MinSeverity string `mapstructure:"min_severity"`
+	// SpanStatus keeps only spans with status unset, ok or error.
+	SpanStatus string `mapstructure:"span_status"`
+}
+
+// MappingsConfig has the sonification rules. When rules are configured,
+// matching spans, metrics and log records are broadcast as
+// {"type":"sound_event"} messages instead of raw payloads.
+type MappingsConfig struct {
+	Rules []MappingRule `mapstructure:"rules"`
+	// ForwardUnmatched keeps broadcasting raw payloads that no rule matched.
+	ForwardUnmatched bool `mapstructure:"forward_unmatched"`
+	// Voices give operations and log severities a fixed instrument and
+	// color, so they sound the same on every client.
+	Voices VoicesConfig `mapstructure:"voices"`
+}
+
+// VoicesConfig assigns voices to operations and log severities. Trace and
+// log messages carry the voices of the span names and severity levels in
+// their payload.
+type VoicesConfig struct {
+	// Operations are the voices of span names.
+	Operations map[string]VoiceHint `mapstructure:"operations"`
+	// Severities are the voices of log levels: TRACE, DEBUG, INFO, WARN,
+	// ERROR or FATAL.
+	Severities map[string]VoiceHint `mapstructure:"severities"`
+	// Palette are the voices other span names are given, each picked by
+	// hashing the name so an operation always gets the same one.
+	Palette []VoiceHint `mapstructure:"palette"`
+}
+
+// VoiceHint is how clients should play an operation or severity.
+type VoiceHint struct {
+	Instrument string `mapstructure:"instrument"`
+	// Color is a hex color such as #e91e63.
+	Color string `mapstructure:"color"`
+}
+
+// MappingRule matches data of one signal type and describes the sound
+// event it produces. Rules are tried in order and the first match wins.
+type MappingRule struct {
+	// Name is included in the events the rule produces.
+	Name string `mapstructure:"name"`
+	// Signal is traces, metrics or logs.
+	Signal string `mapstructure:"signal"`
+	// Metric restricts a metrics rule to one metric name.
+	Metric string `mapstructure:"metric"`
+	// MinSeverity restricts a logs rule to records at or above a level
+	// such as WARN or ERROR.
+	MinSeverity string `mapstructure:"min_severity"`
+	// Status restricts a traces rule to spans with status unset, ok or
+	// error.
+	Status string `mapstructure:"status"`
+	// Event describes the sound to produce.
+	Event EventTemplate `mapstructure:"event"`
+}
+
+// EventTemplate describes a sound event. The matched value is the metric
+// value, the span duration in milliseconds or the log severity number.
+type EventTemplate struct {
+	Instrument string `mapstructure:"instrument"`
+	// Pitch is a fixed register: low, mid or high. It defaults to mid.
+	Pitch string `mapstructure:"pitch"`
+	// PitchRange scales the matched value across [min, max] Hz instead of
+	// using a fixed pitch.
+	PitchRange []float64 `mapstructure:"pitch_range"`
+	// ValueRange is the [min, max] of the matched value, defaulting to
+	// [0, 1]. Values outside it are clamped.
+	ValueRange []float64 `mapstructure:"value_range"`
+	// Velocity is "value" to scale loudness with the matched value or
+	// "severity" to scale it with log severity. It is fixed when unset.
+	Velocity   string `mapstructure:"velocity"`
+	DurationMs int    `mapstructure:"duration_ms"`
 }
 
 var _ component.Config = (*Config)(nil)
 
-// Validate checks if the extension configuration is valid
+// Validate checks if the extension configuration is valid. It reports
+// every problem it finds rather than only the first.
 func (cfg *Config) Validate() error {
-	return nil
+	var errs []error
+	check := func(ok bool, msg string, args ...any) {
+		if !ok {
+			errs = append(errs, fmt.Errorf(msg, args...))
+		}
+	}
+
+	check(cfg.Endpoint != "", "endpoint must not be empty")
+	check(cfg.MaxRequestBodyBytes > 0, "max_request_body_bytes must be positive")
+	check(cfg.ClientQueueSize > 0, "client_queue_size must be positive")
+	check(cfg.MaxClientDrops >= 0, "max_client_drops must not be negative")
+	check(cfg.WebSocket.WriteTimeout >= 0, "websocket.write_timeout must not be negative")
+	check(cfg.WebSocket.MaxClients >= 0, "websocket.max_clients must not be negative")
+	check(cfg.WebSocket.IdleTimeout >= 0, "websocket.idle_timeout must not be negative")
+	check(cfg.WebSocket.HeartbeatInterval >= 0, "websocket.heartbeat_interval must not be negative")
+	for _, protocol := range cfg.WebSocket.Subprotocols {
+		check(validSubprotocol(protocol), "websocket.subprotocols: %q is not a valid protocol token", protocol)
+	}
+	check(cfg.SSE.HeartbeatInterval >= 0, "sse.heartbeat_interval must not be negative")
+	check(cfg.Buffer.MaxEntries >= 0, "buffer.max_entries must not be negative")
+	check(cfg.Buffer.MaxBytes >= 0, "buffer.max_bytes must not be negative")
+	check(!cfg.Aggregation.Enabled || cfg.Aggregation.Window > 0, "aggregation.window must be positive when aggregation is enabled")
+	check(!cfg.Record.Enabled || cfg.Record.Path != "", "record.path is required when record.enabled is set")
+	check(cfg.Record.MaxSize >= 0, "record.max_size must not be negative")
+	check(cfg.Record.MaxBackups >= 0, "record.max_backups must not be negative")
+	check(cfg.Control.ResumeBacklog >= 0, "control.resume_backlog must not be negative")
+	// Unknown payloads are passed through as they came, unredacted
+	check(!cfg.AcceptUnknown || (len(cfg.Redact.Keys) == 0 && len(cfg.Redact.Patterns) == 0),
+		"accept_unknown can't be combined with redact, as payloads that aren't OTLP can't be redacted")
+	if cfg.Alarm.Enabled {
+		check(cfg.Alarm.Window >= alarmInterval, "alarm.window must be at least %v", alarmInterval)
+		check(cfg.Alarm.OnThreshold > 0 && cfg.Alarm.OnThreshold <= 1, "alarm.on_threshold must be greater than 0 and at most 1")
+		check(cfg.Alarm.OffThreshold >= 0 && cfg.Alarm.OffThreshold < cfg.Alarm.OnThreshold, "alarm.off_threshold must be at least 0 and lower than alarm.on_threshold")
+		check(cfg.Alarm.Sustain >= 0, "alarm.sustain must not be negative")
+	}
+	check(validBroadcastFormat(cfg.Broadcast.Format), "broadcast.format must be json or msgpack, got %q", cfg.Broadcast.Format)
+	check(cfg.Broadcast.MessageTTL >= 0, "broadcast.message_ttl must not be negative")
+	check(cfg.Broadcast.MaxMessageBytes == 0 || cfg.Broadcast.MaxMessageBytes > envelopeOverhead,
+		"broadcast.max_message_bytes must be 0 or more than %d", envelopeOverhead)
+	check(cfg.Broadcast.Oversize == oversizeSplit || cfg.Broadcast.Oversize == oversizeSummarize,
+		"broadcast.oversize must be split or summarize, got %q", cfg.Broadcast.Oversize)
+	for _, dataType := range []string{"traces", "metrics", "logs"} {
+		rate := cfg.Broadcast.MaxMessagesPerSec.byType()[dataType]
+		check(rate >= 0, "broadcast.max_messages_per_sec.%s must not be negative", dataType)
+	}
+
+	if filters, err := cfg.effectiveFilters(); err != nil {
+		errs = append(errs, err)
+	} else if _, err := newFilters(filters); err != nil {
+		errs = append(errs, err)
+	}
+	if _, err := newMapper(cfg.Mappings.Rules); err != nil {
+		errs = append(errs, err)
+	}
+	if _, err := newVoicer(cfg.Mappings.Voices); err != nil {
+		errs = append(errs, err)
+	}
+	if _, err := newRedactor(cfg.Redact); err != nil {
+		errs = append(errs, err)
+	}
+	if _, err := newPanner(cfg.Pan); err != nil {
+		errs = append(errs, err)
+	}
+	if _, err := newChannelRouter(cfg.Channels); err != nil {
+		errs = append(errs, err)
+	}
+	if _, err := newNoter(cfg.Notes); err != nil {
+		errs = append(errs, err)
+	}
+	if _, err := newNormalizer(cfg.Normalize); err != nil {
+		errs = append(errs, err)
+	}
+	if _, err := newChordMapper(cfg.Chord); err != nil {
+		errs = append(errs, err)
+	}
+	if _, err := newMIDIOutput(cfg.MIDI); err != nil {
+		errs = append(errs, err)
+	}
+	if _, err := newOSCOutput(cfg.OSC); err != nil {
+		errs = append(errs, err)
+	}
+	if _, err := newAnomalyDetector(cfg.Anomaly); err != nil {
+		errs = append(errs, err)
+	}
+	if _, err := newWebhooks(cfg.Webhooks); err != nil {
+		errs = append(errs, err)
+	}
+	if _, err := newTopology(cfg.Topology); err != nil {
+		errs = append(errs, err)
+	}
+	if _, err := newTraceSampler(cfg.Traces); err != nil {
+		errs = append(errs, err)
+	}
+	if _, err := newOriginChecker(cfg.Auth.AllowedOrigins); err != nil {
+		errs = append(errs, err)
+	}
+	if _, err := newTokenSigner(cfg.Auth); err != nil {
+		errs = append(errs, err)
+	}
+	if _, err := newIngestLimiter(cfg.IngestLimits); err != nil {
+		errs = append(errs, err)
+	}
+	return errors.Join(errs...)
+}
+
+// effectiveFilters folds the logs.min_severity and traces.errors_only
+// shorthands into the filters.
+func (cfg *Config) effectiveFilters() (FiltersConfig, error) {
+	filters := cfg.Filters
+	if sev := cfg.Logs.MinSeverity; sev != "" {
+		if _, ok := severityNumbers[strings.ToUpper(sev)]; !ok {
+			return filters, fmt.Errorf("logs.min_severity: unknown severity %q, expected TRACE, DEBUG, INFO, WARN, ERROR or FATAL", sev)
+		}
+		if filters.Logs.MinSeverity != "" && !strings.EqualFold(filters.Logs.MinSeverity, sev) {
+			return filters, errors.New("logs.min_severity conflicts with filters.logs.min_severity")
+		}
+		filters.Logs.MinSeverity = sev
+	}
+	if cfg.Traces.ErrorsOnly {
+		if filters.Traces.SpanStatus != "" && !strings.EqualFold(filters.Traces.SpanStatus, "error") {
+			return filters, errors.New("traces.errors_only conflicts with filters.traces.span_status")
+		}
+		filters.Traces.SpanStatus = "error"
+	}
+	return filters, nil
 }
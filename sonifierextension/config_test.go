@@ -0,0 +1,216 @@
+package sonifierextension
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/collector/component"
+	"go.opentelemetry.io/collector/confmap/confmaptest"
+)
+
+func loadConfig(t *testing.T, name string) *Config {
+	t.Helper()
+	cm, err := confmaptest.LoadConf(filepath.Join("testdata", "config.yaml"))
+	require.NoError(t, err)
+	sub, err := cm.Sub(component.NewIDWithName(component.MustNewType(typeStr), name).String())
+	require.NoError(t, err)
+	cfg := createDefaultConfig().(*Config)
+	require.NoError(t, sub.Unmarshal(cfg))
+	return cfg
+}
+
+func TestLoadConfig(t *testing.T) {
+	t.Run("defaults", func(t *testing.T) {
+		cfg := loadConfig(t, "")
+		assert.Equal(t, createDefaultConfig(), cfg)
+		assert.NoError(t, cfg.Validate())
+	})
+
+	t.Run("custom", func(t *testing.T) {
+		cfg := loadConfig(t, "custom")
+		require.NoError(t, cfg.Validate())
+		assert.Equal(t, "0.0.0.0:55555", cfg.Endpoint)
+		assert.Equal(t, int64(1<<20), cfg.MaxRequestBodyBytes)
+		assert.Equal(t, 16, cfg.ClientQueueSize)
+		assert.Equal(t, 10, cfg.WebSocket.MaxClients)
+		assert.Equal(t, 2*time.Second, cfg.WebSocket.WriteTimeout)
+		assert.Equal(t, 50, cfg.Buffer.MaxEntries)
+		assert.Equal(t, int64(4096), cfg.Buffer.MaxBytes)
+		assert.Equal(t, 20, cfg.Broadcast.MaxMessagesPerSec.Traces)
+		assert.Equal(t, 5, cfg.Broadcast.MaxMessagesPerSec.Logs)
+		// Settings the file leaves out keep their defaults
+		assert.True(t, cfg.WebSocket.Compression)
+		assert.Equal(t, 15*time.Second, cfg.SSE.HeartbeatInterval)
+	})
+
+	t.Run("invalid", func(t *testing.T) {
+		err := loadConfig(t, "invalid").Validate()
+		require.Error(t, err)
+		for _, msg := range []string{
+			"endpoint must not be empty",
+			"max_request_body_bytes must be positive",
+			"client_queue_size must be positive",
+			"buffer.max_entries must not be negative",
+		} {
+			assert.ErrorContains(t, err, msg)
+		}
+	})
+}
+
+func TestConfigValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		modify  func(*Config)
+		wantErr []string
+	}{
+		{
+			name:   "default",
+			modify: func(*Config) {},
+		},
+		{
+			name:    "empty endpoint",
+			modify:  func(cfg *Config) { cfg.Endpoint = "" },
+			wantErr: []string{"endpoint must not be empty"},
+		},
+		{
+			name:    "zero body limit",
+			modify:  func(cfg *Config) { cfg.MaxRequestBodyBytes = 0 },
+			wantErr: []string{"max_request_body_bytes must be positive"},
+		},
+		{
+			name:    "zero client queue",
+			modify:  func(cfg *Config) { cfg.ClientQueueSize = 0 },
+			wantErr: []string{"client_queue_size must be positive"},
+		},
+		{
+			name:    "negative max clients",
+			modify:  func(cfg *Config) { cfg.WebSocket.MaxClients = -1 },
+			wantErr: []string{"websocket.max_clients must not be negative"},
+		},
+		{
+			name:    "negative write timeout",
+			modify:  func(cfg *Config) { cfg.WebSocket.WriteTimeout = -time.Second },
+			wantErr: []string{"websocket.write_timeout must not be negative"},
+		},
+		{
+			name:    "invalid subprotocol",
+			modify:  func(cfg *Config) { cfg.WebSocket.Subprotocols = []string{"two words"} },
+			wantErr: []string{`websocket.subprotocols: "two words" is not a valid protocol token`},
+		},
+		{
+			name:    "negative buffer entries",
+			modify:  func(cfg *Config) { cfg.Buffer.MaxEntries = -1 },
+			wantErr: []string{"buffer.max_entries must not be negative"},
+		},
+		{
+			name:    "negative buffer bytes",
+			modify:  func(cfg *Config) { cfg.Buffer.MaxBytes = -1 },
+			wantErr: []string{"buffer.max_bytes must not be negative"},
+		},
+		{
+			name:    "negative broadcast rate",
+			modify:  func(cfg *Config) { cfg.Broadcast.MaxMessagesPerSec.Metrics = -1 },
+			wantErr: []string{"broadcast.max_messages_per_sec.metrics must not be negative"},
+		},
+		{
+			name:    "unknown broadcast format",
+			modify:  func(cfg *Config) { cfg.Broadcast.Format = "xml" },
+			wantErr: []string{`broadcast.format must be json or msgpack, got "xml"`},
+		},
+		{
+			name:    "aggregation without window",
+			modify:  func(cfg *Config) { cfg.Aggregation.Enabled, cfg.Aggregation.Window = true, 0 },
+			wantErr: []string{"aggregation.window must be positive when aggregation is enabled"},
+		},
+		{
+			name:    "recording without path",
+			modify:  func(cfg *Config) { cfg.Record.Enabled = true },
+			wantErr: []string{"record.path is required when record.enabled is set"},
+		},
+		{
+			name: "contradictory alarm thresholds",
+			modify: func(cfg *Config) {
+				cfg.Alarm.Enabled = true
+				cfg.Alarm.OnThreshold, cfg.Alarm.OffThreshold = 0.2, 0.5
+			},
+			wantErr: []string{"alarm.off_threshold must be at least 0 and lower than alarm.on_threshold"},
+		},
+		{
+			name: "conflicting severity shorthand",
+			modify: func(cfg *Config) {
+				cfg.Logs.MinSeverity = "ERROR"
+				cfg.Filters.Logs.MinSeverity = "WARN"
+			},
+			wantErr: []string{"logs.min_severity conflicts with filters.logs.min_severity"},
+		},
+		{
+			name: "several errors",
+			modify: func(cfg *Config) {
+				cfg.Endpoint = ""
+				cfg.ClientQueueSize = 0
+				cfg.Buffer.MaxBytes = -1
+			},
+			wantErr: []string{
+				"endpoint must not be empty",
+				"client_queue_size must be positive",
+				"buffer.max_bytes must not be negative",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := createDefaultConfig().(*Config)
+			tt.modify(cfg)
+			err := cfg.Validate()
+			if len(tt.wantErr) == 0 {
+				assert.NoError(t, err)
+				return
+			}
+			require.Error(t, err)
+			for _, msg := range tt.wantErr {
+				assert.ErrorContains(t, err, msg)
+			}
+			// Every problem is reported on its own rather than the first
+			// one only
+			var joined interface{ Unwrap() []error }
+			require.True(t, errors.As(err, &joined))
+			assert.Len(t, joined.Unwrap(), len(tt.wantErr))
+		})
+	}
+}
+
+func TestBufferMaxBytes(t *testing.T) {
+	payload := []byte(`{"resourceLogs":[]}`)
+	size := func(b *broadcaster) int64 {
+		b.mu.Lock()
+		defer b.mu.Unlock()
+		return b.historyBytes
+	}
+
+	probe := newBroadcaster(100, 0)
+	_, err := probe.publish(&envelope{Type: "logs", Payload: payload})
+	require.NoError(t, err)
+	one := size(probe)
+
+	// Room for three messages and a bit, though max_entries allows more
+	b := newBroadcaster(100, 3*one+one/2)
+	for range 10 {
+		_, err := b.publish(&envelope{Type: "logs", Payload: payload})
+		require.NoError(t, err)
+	}
+	assert.Equal(t, 3, b.historyLen())
+	assert.LessOrEqual(t, size(b), b.maxBytes)
+	backlog := b.subscribe(&testSubscriber{}, 1)
+	require.Len(t, backlog, 3)
+	assert.Equal(t, uint64(8), backlog[0].id, "oldest messages are evicted first")
+
+	// A message larger than max_bytes is still kept on its own
+	b = newBroadcaster(100, one/2)
+	_, err = b.publish(&envelope{Type: "logs", Payload: payload})
+	require.NoError(t, err)
+	assert.Equal(t, 1, b.historyLen())
+}
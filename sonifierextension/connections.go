@@ -0,0 +1,83 @@
+package sonifierextension
+
+import (
+	"encoding/json"
+	"net/http"
+	"slices"
+	"sort"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// connectionInfo is the /connections entry of one streaming client.
+type connectionInfo struct {
+	Transport   string    `json:"transport"`
+	Remote      string    `json:"remote"`
+	ConnectedAt time.Time `json:"connected_at"`
+	// Format is the WebSocket encoding, json or msgpack.
+	Format string `json:"format,omitempty"`
+	// Types and Services are the subscription's filters, null when the
+	// client receives every type or service.
+	Types    []string `json:"types"`
+	Services []string `json:"services"`
+	// Channels are the named channels the client joined.
+	Channels  []string `json:"channels,omitempty"`
+	Paused    bool     `json:"paused"`
+	BytesSent uint64   `json:"bytes_sent"`
+	Messages  uint64   `json:"messages_sent"`
+	// LastActive is when a WebSocket client last sent a message or
+	// answered a ping, absent until it has.
+	LastActive *time.Time `json:"last_active,omitempty"`
+	Queued     int        `json:"queued"`
+	Dropped    uint64     `json:"dropped"`
+}
+
+// connections returns the connected clients, oldest first.
+func (b *broadcaster) connections() []connectionInfo {
+	b.mu.Lock()
+	conns := make([]connectionInfo, 0, len(b.subscribers))
+	for sub := range b.subscribers {
+		info := sub.connection()
+		info.Transport = sub.kind()
+		conns = append(conns, info)
+	}
+	b.mu.Unlock()
+
+	sort.Slice(conns, func(i, j int) bool { return conns[i].ConnectedAt.Before(conns[j].ConnectedAt) })
+	return conns
+}
+
+// filters returns the subscription's type and service filters as sorted
+// lists, and whether it is paused.
+func (c *subscription) filters() (types, services []string, paused bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return setList(c.types), setList(c.services), c.paused
+}
+
+func setList(set map[string]bool) []string {
+	if set == nil {
+		return nil
+	}
+	values := make([]string, 0, len(set))
+	for v := range set {
+		values = append(values, v)
+	}
+	slices.Sort(values)
+	return values
+}
+
+// handleConnections lists the connected WebSocket and SSE clients with
+// their subscriptions and delivery counts. It serves /clients as well.
+func (s *sonifierExtension) handleConnections(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(s.broadcaster.connections()); err != nil {
+		s.logger.Error("Failed to write connections response", zap.Error(err))
+	}
+}
@@ -0,0 +1,218 @@
+package sonifierextension
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// muteState is the server-side mute switch. While muted, broadcasts are
+// suppressed and the most recent ones are held for an optional flush on
+// resume.
+type muteState struct {
+	mu      sync.Mutex
+	muted   bool
+	held    []*envelope
+	maxHeld int
+}
+
+// hold keeps env back if broadcasting is muted and reports whether it did.
+func (m *muteState) hold(env *envelope) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if !m.muted {
+		return false
+	}
+	if m.maxHeld > 0 {
+		if len(m.held) >= m.maxHeld {
+			m.held = append(m.held[:0], m.held[1:]...)
+		}
+		m.held = append(m.held, env)
+	}
+	return true
+}
+
+// set changes the mute switch and returns the envelopes held while muted
+// when unmuting. It reports whether the state changed.
+func (m *muteState) set(muted bool) ([]*envelope, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.muted == muted {
+		return nil, false
+	}
+	m.muted = muted
+	held := m.held
+	m.held = nil
+	return held, true
+}
+
+// discard drops the held envelopes without changing the mute switch and
+// returns how many there were.
+func (m *muteState) discard() int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	n := len(m.held)
+	m.held = nil
+	return n
+}
+
+// controlState is returned by the control endpoints and pushed to clients
+// as the payload of a {"type":"control"} message when it changes.
+type controlState struct {
+	Muted bool `json:"muted"`
+	Held  int  `json:"held"`
+}
+
+func (m *muteState) state() controlState {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return controlState{Muted: m.muted, Held: len(m.held)}
+}
+
+// controlRequest is the body of POST /control. Flush broadcasts the held
+// messages when unmuting instead of discarding them.
+type controlRequest struct {
+	Muted bool `json:"muted"`
+	Flush bool `json:"flush,omitempty"`
+}
+
+// handleControl reports the mute state on GET and changes it on POST.
+func (s *sonifierExtension) handleControl(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		s.writeControlState(w)
+	case http.MethodPost:
+		var req controlRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, `Invalid request, expected {"muted": true}`, http.StatusBadRequest)
+			return
+		}
+		s.setMuted(req.Muted, req.Flush)
+		s.writeControlState(w)
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// handleMute mutes broadcasting.
+func (s *sonifierExtension) handleMute(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	s.setMuted(true, false)
+	s.writeControlState(w)
+}
+
+// handleResume unmutes broadcasting, flushing held messages when the flush
+// query parameter is true.
+func (s *sonifierExtension) handleResume(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	flush, _ := strconv.ParseBool(r.URL.Query().Get("flush"))
+	s.setMuted(false, flush)
+	s.writeControlState(w)
+}
+
+// setMuted changes the mute state and tells streaming clients about it.
+func (s *sonifierExtension) setMuted(muted, flush bool) {
+	held, changed := s.mute.set(muted)
+	if !changed {
+		return
+	}
+	s.logger.Info("Changed broadcast mute state", zap.Bool("muted", muted), zap.Int("held", len(held)))
+
+	payload, _ := json.Marshal(controlState{Muted: muted})
+	s.publish(&envelope{Type: "control", Payload: payload})
+	if flush {
+		for _, env := range held {
+			s.publish(env)
+		}
+	}
+}
+
+func (s *sonifierExtension) writeControlState(w http.ResponseWriter) {
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(s.mute.state()); err != nil {
+		s.logger.Error("Failed to write control state response", zap.Error(err))
+	}
+}
+
+// resetResult is the response to POST /control/reset and the payload of
+// the {"type":"reset"} message, counting what was cleared.
+type resetResult struct {
+	History int `json:"history"`
+	Held    int `json:"held"`
+}
+
+// handleReset clears the accumulated state so a new session starts clean.
+func (s *sonifierExtension) handleReset(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	result := s.resetState()
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(result); err != nil {
+		s.logger.Error("Failed to write reset response", zap.Error(err))
+	}
+}
+
+// resetState clears the history buffer, the last payload, the per-type
+// receive and seen counts, aggregation, alarm and anomaly windows, metric
+// ranges, the service graph, and messages held by rate limits or the mute
+// switch, then tells streaming clients to clear their own state, including
+// any alarm they show. Lifetime counters such as /stats and self-metrics,
+// service channels and the mute switch itself are kept.
+func (s *sonifierExtension) resetState() resetResult {
+	s.mu.Lock()
+	s.telemetryData.Reset()
+	s.telemetryType = ""
+	s.telemetrySeq = 0
+	s.telemetryTime = time.Time{}
+	clear(s.received)
+	clear(s.lastReceived)
+	clear(s.filterStats)
+	s.mu.Unlock()
+	s.stats.resetSeen()
+
+	s.configMu.RLock()
+	for _, limiter := range s.limiters {
+		limiter.reset()
+	}
+	s.configMu.RUnlock()
+	if s.aggregator != nil {
+		s.aggregator.reset()
+	}
+	if s.alarm != nil {
+		s.alarm.reset()
+	}
+	if s.normalizer != nil {
+		s.normalizer.reset()
+	}
+	if s.anomalies != nil {
+		s.anomalies.reset()
+	}
+	if s.topology != nil {
+		s.topology.reset()
+	}
+	result := resetResult{
+		Held:    s.mute.discard(),
+		History: s.broadcaster.clearHistory(),
+	}
+	s.logger.Info("Reset accumulated state", zap.Int("history", result.History), zap.Int("held", result.Held))
+
+	// Published directly so muted clients clear their state too
+	payload, _ := json.Marshal(result)
+	s.publish(&envelope{Type: "reset", Payload: payload})
+	return result
+}
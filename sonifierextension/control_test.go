@@ -0,0 +1,47 @@
+package sonifierextension
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// do sends a request with an optional bearer token and returns the
+// response status.
+func do(t *testing.T, method, url, token, body string) int {
+	t.Helper()
+	req, err := http.NewRequest(method, url, strings.NewReader(body))
+	require.NoError(t, err)
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	resp.Body.Close()
+	return resp.StatusCode
+}
+
+func TestResetNeedsAdminToken(t *testing.T) {
+	t.Run("disabled without admin token", func(t *testing.T) {
+		_, url := startTestExtension(t)
+		assert.Equal(t, http.StatusForbidden, do(t, http.MethodPost, url+"/control/reset", "", ""))
+	})
+
+	t.Run("admin token", func(t *testing.T) {
+		s, url := startTestExtension(t, func(cfg *Config) {
+			cfg.AdminToken = "admin"
+			cfg.Auth.ListenerToken = "listener"
+		})
+		require.Equal(t, http.StatusOK, do(t, http.MethodPost, url+"/v1/logs", "", testLogs))
+		require.Equal(t, 1, s.broadcaster.historyLen())
+
+		assert.Equal(t, http.StatusUnauthorized, do(t, http.MethodPost, url+"/control/reset", "", ""))
+		assert.Equal(t, http.StatusUnauthorized, do(t, http.MethodPost, url+"/control/reset", "listener", ""))
+		assert.Equal(t, 1, s.broadcaster.historyLen(), "rejected resets keep the history")
+		assert.Equal(t, http.StatusOK, do(t, http.MethodPost, url+"/control/reset", "admin", ""))
+		assert.Equal(t, []string{"reset"}, historyTypes(s.broadcaster), "only the reset message is left")
+	})
+}
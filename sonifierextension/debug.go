@@ -0,0 +1,76 @@
+package sonifierextension
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// debugState is the snapshot returned by /debug/state.
+type debugState struct {
+	Time           time.Time                 `json:"time"`
+	Subscribers    map[string]int            `json:"subscribers"`
+	Clients        []clientState             `json:"clients"`
+	QueuedMessages int                       `json:"queued_messages"`
+	History        int                       `json:"history_entries"`
+	LastMessageID  uint64                    `json:"last_message_id"`
+	BufferBytes    int                       `json:"buffer_bytes"`
+	BufferType     string                    `json:"buffer_type"`
+	Received       map[string]uint64         `json:"received"`
+	LastReceived   map[string]time.Time      `json:"last_received"`
+	Filters        map[string]filterStats    `json:"filters,omitempty"`
+	RateLimits     map[string]rateLimitStats `json:"rate_limits,omitempty"`
+	Config         *Config                   `json:"config"`
+}
+
+// handleDebugState dumps the extension's internals for troubleshooting.
+// Everything is copied under the owning locks before encoding.
+func (s *sonifierExtension) handleDebugState(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	state := debugState{
+		Time:   time.Now(),
+		Config: s.config,
+	}
+
+	s.mu.Lock()
+	state.BufferBytes = s.telemetryData.Len()
+	state.BufferType = s.telemetryType
+	state.Received = make(map[string]uint64, len(s.received))
+	for k, v := range s.received {
+		state.Received[k] = v
+	}
+	state.LastReceived = make(map[string]time.Time, len(s.lastReceived))
+	for k, v := range s.lastReceived {
+		state.LastReceived[k] = v
+	}
+	state.Filters = make(map[string]filterStats, len(s.filterStats))
+	for k, v := range s.filterStats {
+		state.Filters[k] = v
+	}
+	s.mu.Unlock()
+
+	s.configMu.RLock()
+	state.RateLimits = make(map[string]rateLimitStats, len(s.limiters))
+	for dataType := range s.limiters {
+		state.RateLimits[dataType] = s.stats.signals[dataType].throttle.stats()
+	}
+	s.configMu.RUnlock()
+
+	b := s.broadcaster.snapshot()
+	state.Subscribers = b.subscribers
+	state.Clients = b.clients
+	state.QueuedMessages = b.queued
+	state.History = b.history
+	state.LastMessageID = b.lastID
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(state); err != nil {
+		s.logger.Error("Failed to write debug state response", zap.Error(err))
+	}
+}
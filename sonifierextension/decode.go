@@ -0,0 +1,172 @@
+package sonifierextension
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"go.opentelemetry.io/collector/pdata/plog"
+	"go.opentelemetry.io/collector/pdata/plog/plogotlp"
+	"go.opentelemetry.io/collector/pdata/pmetric"
+	"go.opentelemetry.io/collector/pdata/pmetric/pmetricotlp"
+	"go.opentelemetry.io/collector/pdata/ptrace"
+	"go.opentelemetry.io/collector/pdata/ptrace/ptraceotlp"
+)
+
+// decodedTelemetry is an ingest body classified by signal type. When parsed
+// is true, the pdata field matching dataType holds the decoded request.
+// Otherwise err holds the first parse error, and format what the body was
+// tried as.
+type decodedTelemetry struct {
+	dataType string
+	json     []byte
+	parsed   bool
+	format   string
+	err      error
+	traces   ptrace.Traces
+	metrics  pmetric.Metrics
+	logs     plog.Logs
+}
+
+// signalTypes are the signals tried, in order, when a protobuf body's type
+// isn't known from its endpoint.
+var signalTypes = []string{"traces", "metrics", "logs"}
+
+// decodeTelemetry classifies an OTLP JSON or protobuf body and converts it
+// to JSON for broadcasting. A protobuf body is decoded as expected, or
+// sniffed when expected is empty. Unrecognized bodies are passed through
+// as-is with parsed false.
+func decodeTelemetry(body []byte, expected string) *decodedTelemetry {
+	d := &decodedTelemetry{}
+
+	// Check if it's already JSON by looking for known OTLP JSON structures
+	if json.Valid(body) {
+		d.dataType = "unknown"
+		d.json = body
+		d.format = "json"
+		var jsonObj map[string]interface{}
+		if err := json.Unmarshal(body, &jsonObj); err != nil {
+			d.err = fmt.Errorf("not a JSON object: %w", err)
+			return d
+		}
+		if _, hasResourceSpans := jsonObj["resourceSpans"]; hasResourceSpans {
+			d.dataType = "traces"
+			req := ptraceotlp.NewExportRequest()
+			if d.err = req.UnmarshalJSON(body); d.err == nil {
+				d.traces, d.parsed = req.Traces(), true
+			}
+		} else if _, hasResourceMetrics := jsonObj["resourceMetrics"]; hasResourceMetrics {
+			d.dataType = "metrics"
+			req := pmetricotlp.NewExportRequest()
+			if d.err = req.UnmarshalJSON(body); d.err == nil {
+				d.metrics, d.parsed = req.Metrics(), true
+			}
+		} else if _, hasResourceLogs := jsonObj["resourceLogs"]; hasResourceLogs {
+			d.dataType = "logs"
+			req := plogotlp.NewExportRequest()
+			if d.err = req.UnmarshalJSON(body); d.err == nil {
+				d.logs, d.parsed = req.Logs(), true
+			}
+		} else {
+			d.err = errors.New("no resourceSpans, resourceMetrics or resourceLogs field")
+		}
+		return d
+	}
+
+	// Try to parse as protobuf
+	d.format = "protobuf"
+	candidates := signalTypes
+	if expected != "" {
+		candidates = []string{expected}
+	}
+	for _, signal := range candidates {
+		if err := d.unmarshalProto(signal, body); err != nil {
+			if d.err == nil {
+				d.err = fmt.Errorf("not an OTLP %s request: %w", signal, err)
+			}
+			continue
+		}
+		d.dataType, d.parsed, d.err = signal, true, nil
+		return d
+	}
+	d.dataType = "unknown"
+	d.json = body // fallback to raw data
+	return d
+}
+
+// unmarshalProto decodes body as a protobuf export request of signal.
+func (d *decodedTelemetry) unmarshalProto(signal string, body []byte) error {
+	var (
+		jsonBytes []byte
+		err       error
+	)
+	switch signal {
+	case "traces":
+		req := ptraceotlp.NewExportRequest()
+		if err = req.UnmarshalProto(body); err != nil {
+			return err
+		}
+		d.traces = req.Traces()
+		jsonBytes, err = req.MarshalJSON()
+	case "metrics":
+		req := pmetricotlp.NewExportRequest()
+		if err = req.UnmarshalProto(body); err != nil {
+			return err
+		}
+		d.metrics = req.Metrics()
+		jsonBytes, err = req.MarshalJSON()
+	case "logs":
+		req := plogotlp.NewExportRequest()
+		if err = req.UnmarshalProto(body); err != nil {
+			return err
+		}
+		d.logs = req.Logs()
+		jsonBytes, err = req.MarshalJSON()
+	default:
+		return fmt.Errorf("unknown signal %q", signal)
+	}
+	if err == nil {
+		d.json = jsonBytes
+	}
+	return nil
+}
+
+// count returns the number of spans, metrics or log records in a parsed
+// payload.
+func (d *decodedTelemetry) count() int {
+	if !d.parsed {
+		return 0
+	}
+	switch d.dataType {
+	case "traces":
+		return d.traces.SpanCount()
+	case "metrics":
+		return d.metrics.MetricCount()
+	case "logs":
+		return d.logs.LogRecordCount()
+	}
+	return 0
+}
+
+// encodeJSON re-encodes the parsed data after it has been modified.
+func (d *decodedTelemetry) encodeJSON() error {
+	var (
+		b   []byte
+		err error
+	)
+	switch d.dataType {
+	case "traces":
+		b, err = ptraceotlp.NewExportRequestFromTraces(d.traces).MarshalJSON()
+	case "metrics":
+		b, err = pmetricotlp.NewExportRequestFromMetrics(d.metrics).MarshalJSON()
+	case "logs":
+		b, err = plogotlp.NewExportRequestFromLogs(d.logs).MarshalJSON()
+	default:
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	d.json = b
+	return nil
+}
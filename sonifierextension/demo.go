@@ -0,0 +1,333 @@
+package sonifierextension
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"math/rand"
+	"net/http"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/collector/pdata/pcommon"
+	"go.opentelemetry.io/collector/pdata/plog"
+	"go.opentelemetry.io/collector/pdata/pmetric"
+	"go.opentelemetry.io/collector/pdata/ptrace"
+	"go.uber.org/zap"
+)
+
+// How often the demo emits each signal. Traces come a few a second, with
+// jitter so they don't tick like a metronome.
+const (
+	demoTraceInterval  = 400 * time.Millisecond
+	demoMetricInterval = time.Second
+	demoLogInterval    = 2 * time.Second
+	// demoErrorRatio is the share of demo traces that fail.
+	demoErrorRatio = 0.1
+)
+
+// demoServices are the services the demo's traces pass through, from the
+// one receiving the request to the one calling the database.
+var demoServices = []string{"demo-frontend", "demo-checkout", "demo-payments"}
+
+var demoOperations = []string{"GET /cart", "POST /checkout", "GET /products/{id}", "POST /payments"}
+
+// demoStatus is returned by the /demo endpoints.
+type demoStatus struct {
+	Running bool       `json:"running"`
+	Since   *time.Time `json:"since,omitempty"`
+	// Traces, Metrics and Logs count the payloads ingested by the current
+	// or last demo.
+	Traces  int `json:"traces"`
+	Metrics int `json:"metrics"`
+	Logs    int `json:"logs"`
+}
+
+// demoGenerator tracks the demo stream. At most one runs at a time.
+type demoGenerator struct {
+	mu     sync.Mutex
+	status demoStatus
+	cancel chan struct{}
+	done   chan struct{}
+}
+
+func (g *demoGenerator) snapshot() demoStatus {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return g.status
+}
+
+func (g *demoGenerator) count(dataType string) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	switch dataType {
+	case "traces":
+		g.status.Traces++
+	case "metrics":
+		g.status.Metrics++
+	case "logs":
+		g.status.Logs++
+	}
+}
+
+// stop cancels the running demo, if any, and waits for it to finish.
+func (g *demoGenerator) stop() {
+	g.mu.Lock()
+	cancel, done := g.cancel, g.done
+	// Only the first caller cancels, later ones wait along with it
+	g.cancel = nil
+	g.mu.Unlock()
+
+	if done == nil {
+		return
+	}
+	if cancel != nil {
+		close(cancel)
+	}
+	<-done
+}
+
+// startDemo runs the demo stream in the background unless it is already
+// running.
+func (s *sonifierExtension) startDemo() bool {
+	s.demo.mu.Lock()
+	defer s.demo.mu.Unlock()
+
+	if s.demo.done != nil {
+		return false
+	}
+	cancel, done := make(chan struct{}), make(chan struct{})
+	now := time.Now()
+	s.demo.cancel, s.demo.done = cancel, done
+	s.demo.status = demoStatus{Running: true, Since: &now}
+
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		s.runDemo(cancel)
+
+		s.demo.mu.Lock()
+		defer s.demo.mu.Unlock()
+		s.demo.status.Running = false
+		s.demo.cancel, s.demo.done = nil, nil
+		close(done)
+	}()
+	s.logger.Info("Started demo stream")
+	return true
+}
+
+// runDemo synthesizes traces, metrics and logs until cancelled, ingesting
+// each payload as if it had been posted, so filters, sampling, mapping
+// rules, rate limits and every other stage apply to it.
+func (s *sonifierExtension) runDemo(cancel <-chan struct{}) {
+	d := newDemoSource()
+	traces := time.NewTimer(0)
+	defer traces.Stop()
+	metrics := time.NewTicker(demoMetricInterval)
+	defer metrics.Stop()
+	logs := time.NewTicker(demoLogInterval)
+	defer logs.Stop()
+
+	for {
+		var body []byte
+		var err error
+		select {
+		case <-cancel:
+			return
+		case <-s.stop:
+			return
+		case now := <-traces.C:
+			traces.Reset(demoTraceInterval/2 + time.Duration(rand.Int63n(int64(demoTraceInterval))))
+			body, err = (&ptrace.JSONMarshaler{}).MarshalTraces(d.trace(now))
+		case now := <-metrics.C:
+			body, err = (&pmetric.JSONMarshaler{}).MarshalMetrics(d.metrics(now))
+		case now := <-logs.C:
+			body, err = (&plog.JSONMarshaler{}).MarshalLogs(d.log(now))
+		}
+		if err != nil {
+			s.logger.Error("Failed to encode demo telemetry", zap.Error(err))
+			continue
+		}
+		if dataType, err := s.ingest(body, decodeTelemetry(body, "")); err == nil {
+			s.demo.count(dataType)
+		}
+	}
+}
+
+// demoSource builds the demo's payloads. Its metrics wander from one
+// reading to the next rather than jumping around.
+type demoSource struct {
+	cpu   float64
+	queue float64
+	// failing is the service the last failed trace broke in, which the
+	// next error log blames.
+	failing string
+}
+
+func newDemoSource() *demoSource {
+	return &demoSource{cpu: 0.3, queue: 5}
+}
+
+// trace returns one request passing through the demo services, ending in
+// a database call. A failed request has error status on the span where
+// it failed and on every span above it.
+func (d *demoSource) trace(now time.Time) ptrace.Traces {
+	td := ptrace.NewTraces()
+	var traceID pcommon.TraceID
+	binary.BigEndian.PutUint64(traceID[:8], rand.Uint64())
+	binary.BigEndian.PutUint64(traceID[8:], rand.Uint64())
+	operation := demoOperations[rand.Intn(len(demoOperations))]
+	failAt := -1
+	if rand.Float64() < demoErrorRatio {
+		failAt = rand.Intn(len(demoServices))
+		d.failing = demoServices[failAt]
+	}
+
+	// Each hop starts a little after its caller and ends a little before
+	// it, with the database call at the bottom
+	depth := len(demoServices)
+	total := time.Duration(20+rand.Intn(180)) * time.Millisecond
+	var parent pcommon.SpanID
+	for i, service := range demoServices {
+		rs := td.ResourceSpans().AppendEmpty()
+		rs.Resource().Attributes().PutStr("service.name", service)
+		spans := rs.ScopeSpans().AppendEmpty().Spans()
+
+		offset := total * time.Duration(i) / time.Duration(2*depth)
+		server := spans.AppendEmpty()
+		server.SetTraceID(traceID)
+		server.SetSpanID(demoSpanID())
+		server.SetParentSpanID(parent)
+		server.SetName(operation)
+		server.SetKind(ptrace.SpanKindServer)
+		server.SetStartTimestamp(pcommon.NewTimestampFromTime(now.Add(offset)))
+		server.SetEndTimestamp(pcommon.NewTimestampFromTime(now.Add(total - offset)))
+		if failAt >= i {
+			server.Status().SetCode(ptrace.StatusCodeError)
+		} else {
+			server.Status().SetCode(ptrace.StatusCodeOk)
+		}
+
+		client := spans.AppendEmpty()
+		client.SetTraceID(traceID)
+		client.SetSpanID(demoSpanID())
+		client.SetParentSpanID(server.SpanID())
+		client.SetKind(ptrace.SpanKindClient)
+		inner := total * time.Duration(2*i+1) / time.Duration(4*depth)
+		client.SetStartTimestamp(pcommon.NewTimestampFromTime(now.Add(inner)))
+		client.SetEndTimestamp(pcommon.NewTimestampFromTime(now.Add(total - inner)))
+		if i+1 < depth {
+			client.SetName(operation)
+			client.Attributes().PutStr("peer.service", demoServices[i+1])
+		} else {
+			client.SetName("SELECT orders")
+			client.Attributes().PutStr("db.system", "postgresql")
+			client.Attributes().PutStr("peer.service", "postgres")
+		}
+		// Calls into a failed service fail too, and the last service
+		// fails on its database call
+		if failAt > i || failAt == depth-1 {
+			client.Status().SetCode(ptrace.StatusCodeError)
+		}
+		parent = client.SpanID()
+	}
+	return td
+}
+
+func demoSpanID() pcommon.SpanID {
+	var id pcommon.SpanID
+	binary.BigEndian.PutUint64(id[:], rand.Uint64())
+	return id
+}
+
+// metrics returns the frontend's CPU utilization and the checkout queue
+// depth, each a step of a random walk.
+func (d *demoSource) metrics(now time.Time) pmetric.Metrics {
+	d.cpu = min(max(d.cpu+(rand.Float64()-0.5)*0.1, 0.05), 0.95)
+	d.queue = min(max(d.queue+(rand.Float64()-0.5)*4, 0), 50)
+
+	md := pmetric.NewMetrics()
+	for _, m := range []struct {
+		service, name, unit string
+		value               float64
+	}{
+		{"demo-frontend", "system.cpu.utilization", "1", d.cpu},
+		{"demo-checkout", "queue.depth", "{message}", d.queue},
+	} {
+		rm := md.ResourceMetrics().AppendEmpty()
+		rm.Resource().Attributes().PutStr("service.name", m.service)
+		metric := rm.ScopeMetrics().AppendEmpty().Metrics().AppendEmpty()
+		metric.SetName(m.name)
+		metric.SetUnit(m.unit)
+		dp := metric.SetEmptyGauge().DataPoints().AppendEmpty()
+		dp.SetTimestamp(pcommon.NewTimestampFromTime(now))
+		dp.SetDoubleValue(m.value)
+	}
+	return md
+}
+
+// log returns a routine log record, a warning when the queue backs up, or
+// an error from the service the last failed trace broke in.
+func (d *demoSource) log(now time.Time) plog.Logs {
+	service, severity, text, body := "demo-checkout", plog.SeverityNumberInfo, "INFO", "Order processed"
+	switch {
+	case d.failing != "":
+		service, severity, text, body = d.failing, plog.SeverityNumberError, "ERROR", "Request failed"
+		d.failing = ""
+	case d.queue > 30:
+		severity, text, body = plog.SeverityNumberWarn, "WARN", "Queue is backing up"
+	}
+
+	ld := plog.NewLogs()
+	rl := ld.ResourceLogs().AppendEmpty()
+	rl.Resource().Attributes().PutStr("service.name", service)
+	record := rl.ScopeLogs().AppendEmpty().LogRecords().AppendEmpty()
+	record.SetTimestamp(pcommon.NewTimestampFromTime(now))
+	record.SetSeverityNumber(severity)
+	record.SetSeverityText(text)
+	record.Body().SetStr(body)
+	return ld
+}
+
+// handleDemo reports the demo stream's status on GET.
+func (s *sonifierExtension) handleDemo(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	s.writeDemoStatus(w, http.StatusOK)
+}
+
+// handleDemoStart starts the demo stream.
+func (s *sonifierExtension) handleDemoStart(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if !s.startDemo() {
+		http.Error(w, "The demo is already running", http.StatusConflict)
+		return
+	}
+	s.writeDemoStatus(w, http.StatusAccepted)
+}
+
+// handleDemoStop stops the demo stream, if it is running.
+func (s *sonifierExtension) handleDemoStop(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if status := s.demo.snapshot(); status.Running {
+		s.demo.stop()
+		s.logger.Info("Stopped demo stream", zap.Int("traces", status.Traces),
+			zap.Int("metrics", status.Metrics), zap.Int("logs", status.Logs))
+	}
+	s.writeDemoStatus(w, http.StatusOK)
+}
+
+func (s *sonifierExtension) writeDemoStatus(w http.ResponseWriter, code int) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	if err := json.NewEncoder(w).Encode(s.demo.snapshot()); err != nil {
+		s.logger.Error("Failed to write demo status response", zap.Error(err))
+	}
+}
@@ -0,0 +1,53 @@
+package sonifierextension
+
+import (
+	"context"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDemoStopWhileStopping(t *testing.T) {
+	cancel, done := make(chan struct{}), make(chan struct{})
+	g := &demoGenerator{cancel: cancel, done: done}
+
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		g.stop()
+	}()
+	<-cancel
+	// A second stop while the demo winds down waits instead of cancelling
+	// it again
+	go func() {
+		defer wg.Done()
+		g.stop()
+	}()
+	close(done)
+	wg.Wait()
+}
+
+func TestDemoConcurrentStop(t *testing.T) {
+	s, _ := startTestExtension(t)
+	require.True(t, s.startDemo())
+	assert.False(t, s.startDemo(), "only one demo runs at a time")
+
+	var wg sync.WaitGroup
+	for range 8 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			s.demo.stop()
+		}()
+	}
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		assert.NoError(t, s.Shutdown(context.Background()))
+	}()
+	wg.Wait()
+	assert.False(t, s.demo.snapshot().Running)
+}
@@ -5,16 +5,22 @@ import (
 	"context"
 	"embed"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"io"
 	"io/fs"
+	"math"
+	"mime"
+	"net"
 	"net/http"
+	"slices"
+	"strconv"
 	"sync"
+	"sync/atomic"
+	"time"
 
 	"github.com/gorilla/websocket"
 	"go.opentelemetry.io/collector/component"
-	"go.opentelemetry.io/collector/pdata/plog/plogotlp"
-	"go.opentelemetry.io/collector/pdata/pmetric/pmetricotlp"
-	"go.opentelemetry.io/collector/pdata/ptrace/ptraceotlp"
 	"go.uber.org/zap"
 )
 
@@ -22,45 +28,221 @@ import (
 var webFiles embed.FS
 
 type sonifierExtension struct {
-	config        *Config
-	logger        *zap.Logger
-	server        *http.Server
-	wg            sync.WaitGroup
+	config    *Config
+	settings  component.TelemetrySettings
+	logger    *zap.Logger
+	telemetry *extensionTelemetry
+	server    *http.Server
+	addr      net.Addr
+	wg        sync.WaitGroup
+	connWG    sync.WaitGroup
+	// wsClients counts the WebSocket clients holding one of the
+	// websocket.max_clients slots.
+	wsClients     atomic.Int64
 	telemetryData *bytes.Buffer
 	telemetryType string
+	telemetrySeq  uint64
+	telemetryTime time.Time
+	received      map[string]uint64
+	lastReceived  map[string]time.Time
+	filterStats   map[string]filterStats
+	stats         *ingestStats
 	mu            sync.Mutex
 	wsUpgrader    websocket.Upgrader
-	wsConnections map[*websocket.Conn]bool
-	wsConnMutex   sync.Mutex
+	broadcaster   *broadcaster
+	// configMu guards the settings PUT /config can replace: runtime and
+	// the filters, mapper and limiters compiled from it. Ingest holds it
+	// for reading, so a change applies between payloads.
+	configMu     sync.RWMutex
+	runtime      runtimeSettings
+	limiters     map[string]*rateLimiter
+	aggregator   *aggregator
+	recorder     *recorder
+	replay       replayer
+	demo         demoGenerator
+	alarm        *errorAlarm
+	filters      map[string]*signalFilter
+	redactor     *redactor
+	panner       *panner
+	noter        *noter
+	normalizer   *normalizer
+	chords       *chordMapper
+	midi         *midiOutput
+	osc          *oscOutput
+	anomalies    *anomalyDetector
+	webhooks     *webhooks
+	topology     *topology
+	sampler      *traceSampler
+	channels     *serviceChannels
+	router       *channelRouter
+	origins      *originChecker
+	signer       *tokenSigner
+	ingestLimits *ingestLimiter
+	mapper       *mapper
+	voicer       *voicer
+	mute         muteState
+	stop         chan struct{}
+	stopOnce     sync.Once
+	// ready is closed once Start has finished setting up. Streaming
+	// clients wait for it, so none connects to half-started machinery.
+	ready chan struct{}
 }
 
-func newSonifierExtension(config *Config, logger *zap.Logger) *sonifierExtension {
-	return &sonifierExtension{
+func newSonifierExtension(config *Config, settings component.TelemetrySettings) (*sonifierExtension, error) {
+	telemetry, err := newExtensionTelemetry(settings.MeterProvider.Meter(scopeName))
+	if err != nil {
+		return nil, err
+	}
+	s := &sonifierExtension{
 		config:        config,
-		logger:        logger,
+		settings:      settings,
+		logger:        settings.Logger,
+		telemetry:     telemetry,
 		telemetryData: &bytes.Buffer{},
+		received:      make(map[string]uint64),
+		lastReceived:  make(map[string]time.Time),
+		filterStats:   make(map[string]filterStats),
+		stats:         newIngestStats(),
 		wsUpgrader: websocket.Upgrader{
-			CheckOrigin: func(r *http.Request) bool {
-				return true // Allow all origins for development
-			},
+			Subprotocols:      append(slices.Clone(config.WebSocket.Subprotocols), formatJSON, formatMsgpack),
+			EnableCompression: config.WebSocket.Compression,
 		},
-		wsConnections: make(map[*websocket.Conn]bool),
+		broadcaster: newBroadcaster(config.Buffer.MaxEntries, config.Buffer.MaxBytes),
+		channels:    newServiceChannels(),
+		mute:        muteState{maxHeld: config.Control.ResumeBacklog},
+		stop:        make(chan struct{}),
+		ready:       make(chan struct{}),
+	}
+	if config.Aggregation.Enabled {
+		s.aggregator = newAggregator(config.Aggregation.Metrics)
+	}
+	if config.Record.Path != "" {
+		s.recorder = newRecorder(config.Record, s.logger)
+	}
+	if config.Alarm.Enabled {
+		s.alarm = newErrorAlarm(config.Alarm)
+	}
+	filterConfig, err := config.effectiveFilters()
+	if err != nil {
+		return nil, err
+	}
+	s.runtime = runtimeSettings{
+		Mappings:          config.Mappings,
+		Filters:           filterConfig,
+		MaxMessagesPerSec: config.Broadcast.MaxMessagesPerSec,
+	}
+	state, err := s.compile(s.runtime, nil)
+	if err != nil {
+		return nil, err
+	}
+	s.filters, s.mapper, s.voicer, s.limiters = state.filters, state.mapper, state.voicer, state.limiters
+	if s.redactor, err = newRedactor(config.Redact); err != nil {
+		return nil, err
+	}
+	if s.panner, err = newPanner(config.Pan); err != nil {
+		return nil, err
+	}
+	if s.router, err = newChannelRouter(config.Channels); err != nil {
+		return nil, err
+	}
+	if s.noter, err = newNoter(config.Notes); err != nil {
+		return nil, err
+	}
+	if s.normalizer, err = newNormalizer(config.Normalize); err != nil {
+		return nil, err
+	}
+	if s.chords, err = newChordMapper(config.Chord); err != nil {
+		return nil, err
+	}
+	if s.midi, err = newMIDIOutput(config.MIDI); err != nil {
+		return nil, err
+	}
+	if s.midi != nil {
+		s.wsUpgrader.Subprotocols = append(s.wsUpgrader.Subprotocols, midiSubprotocol)
+	}
+	if s.osc, err = newOSCOutput(config.OSC); err != nil {
+		return nil, err
+	}
+	if s.anomalies, err = newAnomalyDetector(config.Anomaly); err != nil {
+		return nil, err
+	}
+	if s.webhooks, err = newWebhooks(config.Webhooks); err != nil {
+		return nil, err
 	}
+	if s.topology, err = newTopology(config.Topology); err != nil {
+		return nil, err
+	}
+	if s.sampler, err = newTraceSampler(config.Traces); err != nil {
+		return nil, err
+	}
+	if s.origins, err = newOriginChecker(config.Auth.AllowedOrigins); err != nil {
+		return nil, err
+	}
+	if s.signer, err = newTokenSigner(config.Auth); err != nil {
+		return nil, err
+	}
+	if s.ingestLimits, err = newIngestLimiter(config.IngestLimits); err != nil {
+		return nil, err
+	}
+	s.wsUpgrader.CheckOrigin = s.checkOrigin
+	return s, nil
 }
 
-func (s *sonifierExtension) Start(_ context.Context, host component.Host) error {
+func (s *sonifierExtension) Start(ctx context.Context, host component.Host) error {
 	s.logger.Info("Starting sonifier extension server", zap.String("endpoint", s.config.Endpoint))
 
 	mux := http.NewServeMux()
-	mux.HandleFunc("/v1/traces", s.handleTelemetry)
-	mux.HandleFunc("/v1/metrics", s.handleTelemetry) 
-	mux.HandleFunc("/v1/logs", s.handleTelemetry)
-	mux.HandleFunc("/telemetry", s.handleTelemetry) // Legacy endpoint
-	mux.HandleFunc("/telemetry-data", s.handleGetTelemetryData)
-	
+	// Producers and listeners can be given separate tokens
+	ingest := func(h http.HandlerFunc) http.HandlerFunc { return s.optionalToken(s.config.Auth.IngestToken, false, h) }
+	listener := func(h http.HandlerFunc) http.HandlerFunc {
+		return s.optionalToken(s.config.Auth.ListenerToken, true, h)
+	}
+	// Endpoints that destroy or rewrite state, silence every listener,
+	// write files or inject telemetry always need the admin token
+	admin := func(h http.HandlerFunc) http.HandlerFunc { return requireToken(s.config.AdminToken, h) }
+	// and ones that read it as well as change it only for the changes
+	adminWrites := func(h http.HandlerFunc) http.HandlerFunc {
+		read, write := listener(h), admin(h)
+		return func(w http.ResponseWriter, r *http.Request) {
+			if r.Method == http.MethodGet || r.Method == http.MethodHead {
+				read(w, r)
+				return
+			}
+			write(w, r)
+		}
+	}
+	// Streams also take the short-lived tokens issued by /ws-token
+	stream := func(h http.HandlerFunc) http.HandlerFunc { return s.streamToken(h, listener(h)) }
+
+	mux.HandleFunc("/v1/traces", ingest(s.handleTelemetry))
+	mux.HandleFunc("/v1/metrics", ingest(s.handleTelemetry))
+	mux.HandleFunc("/v1/logs", ingest(s.handleTelemetry))
+	mux.HandleFunc("/v1/batch", ingest(s.handleBatch))
+	mux.HandleFunc("/telemetry", ingest(s.handleTelemetry)) // Legacy endpoint
+	mux.HandleFunc("/telemetry-data", listener(s.handleGetTelemetryData))
+	mux.HandleFunc("/stats", listener(s.handleStats))
+	mux.HandleFunc("/metrics", listener(s.handleMetrics))
+	mux.HandleFunc("/services", listener(s.handleServices))
+	mux.HandleFunc("/connections", listener(s.handleConnections))
+	mux.HandleFunc("/clients", listener(s.handleConnections))
+	mux.HandleFunc("/topology", listener(s.handleTopology))
+	mux.HandleFunc("/record/start", admin(s.handleRecordStart))
+	mux.HandleFunc("/record/stop", admin(s.handleRecordStop))
+	mux.HandleFunc("/replay", adminWrites(s.handleReplay))
+	mux.HandleFunc("/demo", listener(s.handleDemo))
+	mux.HandleFunc("/demo/start", admin(s.handleDemoStart))
+	mux.HandleFunc("/demo/stop", admin(s.handleDemoStop))
+	mux.HandleFunc("/control", adminWrites(s.handleControl))
+	mux.HandleFunc("/mute", admin(s.handleMute))
+	mux.HandleFunc("/resume", admin(s.handleResume))
+	mux.HandleFunc("/control/reset", admin(s.handleReset))
+	mux.HandleFunc("/config", adminWrites(s.handleConfig))
+	mux.HandleFunc("/debug/state", admin(s.handleDebugState))
+	mux.HandleFunc("/ws-token", requireToken(s.config.Auth.ListenerToken, s.handleStreamToken))
+
 	// Serve embedded web files
 	s.logger.Info("Setting up embedded web files")
-	
+
 	// List files in the embedded filesystem for debugging
 	fs.WalkDir(webFiles, ".", func(path string, d fs.DirEntry, err error) error {
 		if err == nil {
@@ -68,63 +250,189 @@ func (s *sonifierExtension) Start(_ context.Context, host component.Host) error
 		}
 		return nil
 	})
-	
+
 	webFS, fsErr := fs.Sub(webFiles, "web")
 	if fsErr != nil {
 		s.logger.Error("Failed to create web filesystem", zap.Error(fsErr))
 		return fsErr
 	}
 	s.logger.Info("Web filesystem created successfully")
-	
 
-	
-	// Set up WebSocket route
-	mux.HandleFunc("/ws", s.handleWebSocket)
-	
-	// Main visualization
-	mux.Handle("/", http.FileServer(http.FS(webFS)))
+	// Set up streaming routes
+	mux.HandleFunc("/ws", stream(s.handleWebSocket))
+	mux.HandleFunc("/events", stream(s.handleEvents))
+
+	// Main visualization, or a bare console when the UI wasn't embedded
+	if hasWebUI(webFS) {
+		mux.Handle("/", http.FileServer(http.FS(webFS)))
+	} else {
+		s.logger.Warn("Web UI files are missing from this build, serving a fallback console at /")
+		mux.HandleFunc("/", handleFallback)
+	}
 
 	s.logger.Info("Setting up HTTP listener", zap.String("endpoint", s.config.Endpoint))
-	
+
 	// Create listener first
-	ln, err := s.config.ServerConfig.ToListener(context.Background())
+	ln, err := s.config.ServerConfig.ToListener(ctx)
 	if err != nil {
 		s.logger.Error("Failed to create listener", zap.Error(err))
 		return err
 	}
-	
+
 	// Create server
-	s.server, err = s.config.ServerConfig.ToServer(context.Background(), host, component.TelemetrySettings{Logger: s.logger}, nil)
+	server, err := s.config.ServerConfig.ToServer(ctx, host, s.settings, nil)
 	if err != nil {
 		s.logger.Error("Failed to create HTTP server", zap.Error(err))
+		ln.Close()
 		return err
 	}
-	
+
+	if s.osc != nil {
+		if err := s.osc.open(); err != nil {
+			ln.Close()
+			return fmt.Errorf("failed to open OSC target %s: %w", s.config.OSC.Target, err)
+		}
+	}
+
+	// Open the recording before starting anything, so a failure leaves
+	// nothing running for Shutdown to clean up
+	var records *recordFile
+	if s.recorder != nil {
+		records, err = openRecordFile(s.config.Record.Path)
+		if err != nil {
+			ln.Close()
+			if s.osc != nil {
+				s.osc.close()
+			}
+			return fmt.Errorf("failed to open recording: %w", err)
+		}
+	}
+
 	// Set the handler
-	s.server.Handler = mux
+	server.Handler = mux
+	s.mu.Lock()
+	s.server = server
+	s.mu.Unlock()
+	s.addr = ln.Addr()
 	s.logger.Info("HTTP server created successfully", zap.String("address", ln.Addr().String()))
 
+	if s.aggregator != nil {
+		s.wg.Add(1)
+		go func() {
+			defer s.wg.Done()
+			s.runAggregation(s.stop)
+		}()
+	}
+	if s.recorder != nil {
+		s.wg.Add(1)
+		go func() {
+			defer s.wg.Done()
+			s.recorder.run(records, s.stop)
+		}()
+	}
+	if s.alarm != nil {
+		s.wg.Add(1)
+		go func() {
+			defer s.wg.Done()
+			s.runAlarm(s.stop)
+		}()
+	}
+	if s.topology != nil {
+		s.wg.Add(1)
+		go func() {
+			defer s.wg.Done()
+			s.runTopology(s.stop)
+		}()
+	}
+	if s.webhooks != nil {
+		s.wg.Add(1)
+		go func() {
+			defer s.wg.Done()
+			s.webhooks.run(s.stop, s.logger)
+		}()
+	}
+
+	listenerTLS := s.config.ServerConfig.TLS.HasValue()
 	s.wg.Add(1)
 	go func() {
 		defer s.wg.Done()
-		s.logger.Info("Starting HTTP server", zap.String("address", ln.Addr().String()))
-		if err := s.server.Serve(ln); err != http.ErrServerClosed {
+		s.logger.Info("Starting HTTP server", zap.String("address", ln.Addr().String()),
+			zap.Bool("tls", listenerTLS || server.TLSConfig != nil))
+		if err := serveHTTP(server, ln, listenerTLS); err != http.ErrServerClosed {
 			s.logger.Error("Server error", zap.Error(err))
 		} else {
 			s.logger.Info("HTTP server stopped gracefully")
 		}
 	}()
 
+	close(s.ready)
+	if s.config.DemoOnStart {
+		s.startDemo()
+	}
 	return nil
 }
 
+// serveHTTP serves on ln, terminating TLS in exactly one place. confighttp
+// wraps the listener from ToListener in TLS when tls is configured and
+// leaves the server's TLSConfig unset, so the server must then serve plain
+// HTTP over it, as ServeTLS would wrap the connection a second time. Only a
+// server that brings its own TLS config to a plain listener serves TLS
+// itself.
+func serveHTTP(server *http.Server, ln net.Listener, listenerTLS bool) error {
+	if server.TLSConfig != nil && !listenerTLS {
+		return server.ServeTLS(ln, "", "")
+	}
+	return server.Serve(ln)
+}
+
+// Shutdown stops the server and every background goroutine. It is safe to
+// call when Start failed or never ran, and more than once. When the server
+// doesn't stop in time it still disconnects every client and stops the
+// outputs, and returns the errors joined.
 func (s *sonifierExtension) Shutdown(ctx context.Context) error {
-	s.logger.Info("Shutting down sonifier extension server")
-	if err := s.server.Shutdown(ctx); err != nil {
-		return err
+	s.stopOnce.Do(func() {
+		s.logger.Info("Shutting down sonifier extension server")
+		close(s.stop)
+	})
+
+	s.configMu.RLock()
+	for _, limiter := range s.limiters {
+		limiter.stop()
 	}
-	s.wg.Wait()
-	return nil
+	s.configMu.RUnlock()
+	s.mu.Lock()
+	server := s.server
+	s.mu.Unlock()
+	var errs []error
+	if server != nil {
+		if err := server.Shutdown(ctx); err != nil {
+			errs = append(errs, fmt.Errorf("failed to shut down HTTP server: %w", err))
+		}
+	}
+
+	// Hijacked WebSocket connections outlive server.Shutdown, so close
+	// them explicitly and wait for their handlers to return.
+	s.broadcaster.disconnectAll()
+	if s.midi != nil {
+		s.midi.close()
+	}
+	if s.osc != nil {
+		if dropped := s.osc.close(); dropped > 0 {
+			s.logger.Info("OSC output closed", zap.Uint64("dropped", dropped))
+		}
+	}
+	drained := make(chan struct{})
+	go func() {
+		s.connWG.Wait()
+		s.wg.Wait()
+		close(drained)
+	}()
+	select {
+	case <-drained:
+	case <-ctx.Done():
+		errs = append(errs, fmt.Errorf("failed to wait for clients and background tasks: %w", ctx.Err()))
+	}
+	return errors.Join(errs...)
 }
 
 func (s *sonifierExtension) handleTelemetry(w http.ResponseWriter, r *http.Request) {
@@ -132,60 +440,168 @@ func (s *sonifierExtension) handleTelemetry(w http.ResponseWriter, r *http.Reque
 		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
 		return
 	}
+	if !supportedContentType(r.Header.Get("Content-Type")) {
+		s.countRejected(r.Context(), "unsupported_media_type")
+		http.Error(w, "Unsupported media type, expected JSON or protobuf", http.StatusUnsupportedMediaType)
+		return
+	}
 
-	body, err := io.ReadAll(r.Body)
-	if err != nil {
-		http.Error(w, "Error reading request body", http.StatusInternalServerError)
+	body, ok := s.readBody(w, r)
+	if !ok {
 		return
 	}
-	defer r.Body.Close()
 
-	var dataType string
-	var jsonData []byte
-	
-	// Check if it's already JSON by looking for known OTLP JSON structures
-	if json.Valid(body) {
-		var jsonObj map[string]interface{}
-		if json.Unmarshal(body, &jsonObj) == nil {
-			if _, hasResourceSpans := jsonObj["resourceSpans"]; hasResourceSpans {
-				dataType = "traces"
-				jsonData = body
-			} else if _, hasResourceMetrics := jsonObj["resourceMetrics"]; hasResourceMetrics {
-				dataType = "metrics"
-				jsonData = body
-			} else if _, hasResourceLogs := jsonObj["resourceLogs"]; hasResourceLogs {
-				dataType = "logs"
-				jsonData = body
-			} else {
-				dataType = "unknown"
-				jsonData = body
-			}
-		} else {
-			dataType = "unknown"
-			jsonData = body
+	err := s.Ingest(r.Context(), endpointSignal(r.URL.Path), body)
+	var rejected *RejectedError
+	var limited *RateLimitedError
+	switch {
+	case errors.As(err, &limited):
+		w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(limited.RetryAfter.Seconds()))))
+		http.Error(w, "Too many requests, over the ingest limit", http.StatusTooManyRequests)
+	case errors.As(err, &rejected):
+		rejection := rejected.rejection
+		rejection.ContentType = r.Header.Get("Content-Type")
+		s.logger.Warn("Rejected unparseable telemetry", zap.String("path", r.URL.Path),
+			zap.String("content_type", rejection.ContentType), zap.String("parse_error", rejection.ParseError))
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(rejection)
+	case errors.Is(err, errRedactionFailed):
+		http.Error(w, "Failed to redact telemetry", http.StatusInternalServerError)
+	case errors.Is(err, ErrNotRunning):
+		w.Header().Set("Retry-After", "1")
+		http.Error(w, "Server is not running", http.StatusServiceUnavailable)
+	case err != nil:
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	default:
+		w.WriteHeader(http.StatusOK)
+	}
+}
+
+// parseRejection is the 400 response to a body that isn't OTLP telemetry.
+type parseRejection struct {
+	Error       string `json:"error"`
+	ContentType string `json:"content_type"`
+	Attempted   string `json:"attempted"`
+	ParseError  string `json:"parse_error,omitempty"`
+}
+
+// endpointSignal returns the signal type an ingest path accepts, or "" for
+// the legacy /telemetry endpoint, which accepts any.
+func endpointSignal(path string) string {
+	switch path {
+	case "/v1/traces":
+		return "traces"
+	case "/v1/metrics":
+		return "metrics"
+	case "/v1/logs":
+		return "logs"
+	}
+	return ""
+}
+
+// checkDecoded returns why a decoded body must be rejected, or nil if it
+// may be ingested. Bodies that aren't OTLP for the endpoint's signal are
+// rejected, except on /telemetry when accept_unknown is set.
+func (s *sonifierExtension) checkDecoded(decoded *decodedTelemetry, expected string) *parseRejection {
+	if expected == "" && s.config.AcceptUnknown {
+		return nil
+	}
+	attempted := "OTLP " + decoded.format
+	if expected != "" {
+		attempted += " " + expected
+	}
+	attempted += " export request"
+	switch {
+	case !decoded.parsed:
+		rejection := &parseRejection{Error: "payload is not valid OTLP telemetry", Attempted: attempted}
+		if decoded.err != nil {
+			rejection.ParseError = decoded.err.Error()
 		}
-	} else {
-		// Try to parse as protobuf
-		if tracesReq := ptraceotlp.NewExportRequest(); tracesReq.UnmarshalProto(body) == nil {
-			dataType = "traces"
-			if jsonBytes, err := tracesReq.MarshalJSON(); err == nil {
-				jsonData = jsonBytes
-			}
-		} else if metricsReq := pmetricotlp.NewExportRequest(); metricsReq.UnmarshalProto(body) == nil {
-			dataType = "metrics"
-			if jsonBytes, err := metricsReq.MarshalJSON(); err == nil {
-				jsonData = jsonBytes
-			}
-		} else if logsReq := plogotlp.NewExportRequest(); logsReq.UnmarshalProto(body) == nil {
-			dataType = "logs"
-			if jsonBytes, err := logsReq.MarshalJSON(); err == nil {
-				jsonData = jsonBytes
-			}
-		} else {
-			dataType = "unknown"
-			jsonData = body // fallback to raw data
+		return rejection
+	case expected != "" && decoded.dataType != expected:
+		return &parseRejection{
+			Error:     fmt.Sprintf("payload is OTLP %s, expected %s", decoded.dataType, expected),
+			Attempted: attempted,
+		}
+	}
+	return nil
+}
+
+// readBody reads a telemetry request body of at most
+// max_request_body_bytes. On failure it writes the error response, counts
+// the rejection and returns false.
+func (s *sonifierExtension) readBody(w http.ResponseWriter, r *http.Request) ([]byte, bool) {
+	defer r.Body.Close()
+
+	limit := s.config.MaxRequestBodyBytes
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, limit))
+	if err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			s.countRejected(r.Context(), "body_too_large")
+			s.logger.Warn("Rejected oversized request body", zap.String("path", r.URL.Path),
+				zap.Int64("content_length", r.ContentLength), zap.Int64("limit", limit))
+			writeJSONError(w, http.StatusRequestEntityTooLarge, fmt.Sprintf("request body exceeds %d bytes", limit))
+			return nil, false
 		}
+		s.countRejected(r.Context(), "read_error")
+		http.Error(w, "Error reading request body", http.StatusInternalServerError)
+		return nil, false
+	}
+	return body, true
+}
+
+// writeJSONError responds with code and a {"error": msg} body.
+func writeJSONError(w http.ResponseWriter, code int, msg string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(map[string]string{"error": msg})
+}
+
+// ingest filters, buffers and broadcasts a decoded OTLP body, and returns
+// its signal type. It fails, dropping the body, when redaction does.
+func (s *sonifierExtension) ingest(body []byte, decoded *decodedTelemetry) (string, error) {
+	dataType := decoded.dataType
+	s.telemetry.recordReceived(context.Background(), dataType, len(body))
+	s.configMu.RLock()
+	defer s.configMu.RUnlock()
+	filtered := s.applyFilter(decoded)
+	sampled := s.applySampling(decoded)
+	passed := decoded.count()
+	if filtered+sampled > 0 && passed == 0 {
+		s.mu.Lock()
+		s.received[dataType]++
+		s.lastReceived[dataType] = time.Now()
+		s.stats.received(dataType, s.lastReceived[dataType])
+		s.countFiltered(dataType, 0, filtered)
+		s.mu.Unlock()
+
+		s.logger.Debug("Filtered out telemetry data", zap.String("type", dataType), zap.Int("items", filtered+sampled))
+		return dataType, nil
+	}
+	original, err := s.applyRedaction(decoded)
+	if err != nil {
+		s.countRejected(context.Background(), "redaction_failed")
+		s.logger.Error("Dropped telemetry that couldn't be redacted", zap.String("type", dataType), zap.Error(err))
+		return dataType, err
+	}
+	jsonData := decoded.json
+	if s.aggregator != nil {
+		s.aggregator.observe(decoded)
+	}
+	if s.alarm != nil {
+		s.alarm.observe(decoded, time.Now())
 	}
+	for _, a := range s.anomalies.observe(decoded, time.Now()) {
+		s.broadcastJSON("alert", a)
+	}
+	s.topology.observe(decoded, time.Now())
+	var events []soundEvent
+	if s.mapper != nil {
+		events = s.mapper.evaluate(decoded)
+	}
+	live := !s.replayHidesLive()
 
 	s.mu.Lock()
 	s.telemetryData.Reset()
@@ -195,35 +611,89 @@ func (s *sonifierExtension) handleTelemetry(w http.ResponseWriter, r *http.Reque
 		s.telemetryData.Write(body)
 	}
 	s.telemetryType = dataType
-	
+	s.telemetryTime = time.Now()
+	s.received[dataType]++
+	s.lastReceived[dataType] = s.telemetryTime
+	s.stats.received(dataType, s.telemetryTime)
+	s.countFiltered(dataType, passed, filtered)
+	seen := s.stats.countSeen(dataType)
+
 	// Prepare message for WebSocket broadcast
+	// Copy the buffered data since the history outlives the buffer contents
 	var payload json.RawMessage
-	data := s.telemetryData.Bytes()
+	data := bytes.Clone(s.telemetryData.Bytes())
 	if json.Valid(data) {
 		payload = json.RawMessage(data)
 	} else {
 		jsonStr, _ := json.Marshal(string(data))
 		payload = json.RawMessage(jsonStr)
 	}
-
-	response := struct {
-		Type    string          `json:"type"`
-		Payload json.RawMessage `json:"payload"`
-	}{
-		Type:    dataType,
-		Payload: payload,
+	if original != nil {
+		// /telemetry-data keeps the values redacted from broadcasts
+		s.telemetryData.Reset()
+		s.telemetryData.Write(original)
 	}
 
-	messageBytes, err := json.Marshal(response)
-	if err == nil {
-		// Broadcast immediately to all WebSocket connections
-		s.broadcastToWebSockets(messageBytes)
+	// Broadcast immediately to all streaming clients, subject to rate limits
+	if s.recorder != nil {
+		s.recorder.record(dataType, payload)
 	}
-	
+	if live && s.forwardsRaw(len(events) > 0) {
+		for _, env := range s.resourceEnvelopes(decoded, payload) {
+			env.Seen = seen
+			s.forward(env)
+		}
+	}
+	// This is the payload's own seq unless it was held back or not broadcast
+	s.telemetrySeq = s.broadcaster.currentID()
+
 	s.mu.Unlock()
 
-	s.logger.Info("Received telemetry data", zap.String("type", dataType))
-	w.WriteHeader(http.StatusOK)
+	if !live {
+		return dataType, nil
+	}
+	// Events held while muted would play late, so MIDI and OSC skip them
+	muted := s.mute.state().Muted
+	for _, event := range events {
+		s.broadcastJSONTo("sound_event", event, s.router.route(event.resource))
+		if s.midi != nil && !muted {
+			s.midi.play(event)
+		}
+	}
+	if s.osc != nil && !muted && len(events) > 0 {
+		s.osc.send(events, s.logger)
+	}
+	return dataType, nil
+}
+
+// supportedContentType reports whether an ingest request's Content-Type is
+// OTLP JSON or protobuf. Requests without a Content-Type are sniffed.
+func supportedContentType(contentType string) bool {
+	if contentType == "" {
+		return true
+	}
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false
+	}
+	switch mediaType {
+	case "application/json", "application/x-protobuf", "application/protobuf":
+		return true
+	}
+	return false
+}
+
+// forwardsRaw reports whether a received payload is broadcast as-is, given
+// whether any mapping rule turned it into sound events. The caller must
+// hold configMu.
+func (s *sonifierExtension) forwardsRaw(mapped bool) bool {
+	if s.aggregator != nil && !s.config.Aggregation.ForwardRaw {
+		return false
+	}
+	if s.mapper != nil {
+		return !mapped && s.runtime.Mappings.ForwardUnmatched
+	}
+	return true
 }
 
 func (s *sonifierExtension) handleGetTelemetryData(w http.ResponseWriter, r *http.Request) {
@@ -238,7 +708,7 @@ func (s *sonifierExtension) handleGetTelemetryData(w http.ResponseWriter, r *htt
 	// Validate that the payload is valid JSON
 	var payload json.RawMessage
 	data := s.telemetryData.Bytes()
-	
+
 	// Check if data is valid JSON
 	if json.Valid(data) {
 		payload = json.RawMessage(data)
@@ -248,12 +718,12 @@ func (s *sonifierExtension) handleGetTelemetryData(w http.ResponseWriter, r *htt
 		payload = json.RawMessage(jsonStr)
 	}
 
-	response := struct {
-		Type    string          `json:"type"`
-		Payload json.RawMessage `json:"payload"`
-	}{
-		Type:    s.telemetryType,
-		Payload: payload,
+	// Seq is the latest broadcast when the payload was received
+	response := envelope{
+		Type:      s.telemetryType,
+		Seq:       s.telemetrySeq,
+		Timestamp: s.telemetryTime,
+		Payload:   payload,
 	}
 
 	w.Header().Set("Content-Type", "application/json")
@@ -262,54 +732,3 @@ func (s *sonifierExtension) handleGetTelemetryData(w http.ResponseWriter, r *htt
 		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
 	}
 }
-
-
-
-func (s *sonifierExtension) handleWebSocket(w http.ResponseWriter, r *http.Request) {
-	conn, err := s.wsUpgrader.Upgrade(w, r, nil)
-	if err != nil {
-		s.logger.Error("Failed to upgrade WebSocket connection", zap.Error(err))
-		return
-	}
-
-	// Add connection to the map
-	s.wsConnMutex.Lock()
-	s.wsConnections[conn] = true
-	s.wsConnMutex.Unlock()
-
-	s.logger.Info("WebSocket connection established")
-
-	// Handle connection cleanup
-	defer func() {
-		s.wsConnMutex.Lock()
-		delete(s.wsConnections, conn)
-		s.wsConnMutex.Unlock()
-		conn.Close()
-		s.logger.Info("WebSocket connection closed")
-	}()
-
-	// Keep connection alive and handle messages
-	for {
-		_, _, err := conn.ReadMessage()
-		if err != nil {
-			if websocket.IsUnexpectedCloseError(err, websocket.CloseGoingAway, websocket.CloseAbnormalClosure) {
-				s.logger.Error("WebSocket error", zap.Error(err))
-			}
-			break
-		}
-	}
-}
-
-func (s *sonifierExtension) broadcastToWebSockets(message []byte) {
-	s.wsConnMutex.Lock()
-	defer s.wsConnMutex.Unlock()
-
-	for conn := range s.wsConnections {
-		err := conn.WriteMessage(websocket.TextMessage, message)
-		if err != nil {
-			s.logger.Error("Failed to write to WebSocket", zap.Error(err))
-			conn.Close()
-			delete(s.wsConnections, conn)
-		}
-	}
-}
@@ -0,0 +1,324 @@
+package sonifierextension
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/collector/component/componenttest"
+)
+
+// Payloads for tests, one resource each with a service name.
+const (
+	testTraces  = `{"resourceSpans":[{"resource":{"attributes":[{"key":"service.name","value":{"stringValue":"checkout"}}]},"scopeSpans":[{"spans":[{"traceId":"0102030405060708090a0b0c0d0e0f10","spanId":"0102030405060708","name":"GET /cart","startTimeUnixNano":"1","endTimeUnixNano":"2000000","status":{"code":2}}]}]}]}`
+	testMetrics = `{"resourceMetrics":[{"resource":{"attributes":[{"key":"service.name","value":{"stringValue":"checkout"}}]},"scopeMetrics":[{"metrics":[{"name":"system.cpu.utilization","gauge":{"dataPoints":[{"asDouble":0.5}]}}]}]}]}`
+	testLogs    = `{"resourceLogs":[{"resource":{"attributes":[{"key":"service.name","value":{"stringValue":"auth"}}]},"scopeLogs":[{"logRecords":[{"severityNumber":17,"severityText":"ERROR","body":{"stringValue":"boom"}}]}]}]}`
+)
+
+// newTestExtension returns an extension built from the default config
+// changed by modify, without starting it.
+func newTestExtension(t *testing.T, modify ...func(*Config)) *sonifierExtension {
+	t.Helper()
+	cfg := createDefaultConfig().(*Config)
+	cfg.Endpoint = "127.0.0.1:0"
+	for _, m := range modify {
+		m(cfg)
+	}
+	require.NoError(t, cfg.Validate())
+	s, err := newSonifierExtension(cfg, componenttest.NewNopTelemetrySettings())
+	require.NoError(t, err)
+	return s
+}
+
+// startTestExtension is newTestExtension for a started extension, which is
+// shut down when the test ends. It returns the extension's base URL.
+func startTestExtension(t *testing.T, modify ...func(*Config)) (*sonifierExtension, string) {
+	t.Helper()
+	s := newTestExtension(t, modify...)
+	require.NoError(t, s.Start(context.Background(), componenttest.NewNopHost()))
+	t.Cleanup(func() {
+		require.NoError(t, s.Shutdown(context.Background()))
+	})
+	scheme := "http://"
+	if s.config.TLS.HasValue() {
+		scheme = "https://"
+	}
+	return s, scheme + s.addr.String()
+}
+
+func TestAdminEndpoints(t *testing.T) {
+	endpoints := []struct {
+		method, path, body string
+	}{
+		{http.MethodPost, "/control", `{"muted":false}`},
+		{http.MethodPost, "/mute", ""},
+		{http.MethodPost, "/resume?flush=true", ""},
+		{http.MethodPost, "/control/reset", ""},
+		{http.MethodPut, "/config", `{"muted":false}`},
+		{http.MethodPost, "/record/start", ""},
+		{http.MethodPost, "/record/stop", ""},
+		{http.MethodPost, "/replay", `{"ts":"2025-01-01T00:00:00Z","type":"logs","payload":{"resourceLogs":[]}}`},
+		{http.MethodDelete, "/replay", ""},
+		{http.MethodPost, "/demo/start", ""},
+		{http.MethodPost, "/demo/stop", ""},
+		{http.MethodGet, "/debug/state", ""},
+	}
+
+	_, open := startTestExtension(t)
+	_, guarded := startTestExtension(t, func(cfg *Config) {
+		cfg.AdminToken = "admin"
+		cfg.Auth.ListenerToken = "listener"
+	})
+	for _, e := range endpoints {
+		t.Run(e.method+" "+e.path, func(t *testing.T) {
+			// The listener token being unset doesn't open them up
+			assert.Equal(t, http.StatusForbidden, do(t, e.method, open+e.path, "", e.body))
+			assert.Equal(t, http.StatusUnauthorized, do(t, e.method, guarded+e.path, "", e.body))
+			assert.Equal(t, http.StatusUnauthorized, do(t, e.method, guarded+e.path, "listener", e.body))
+			status := do(t, e.method, guarded+e.path, "admin", e.body)
+			assert.NotContains(t, []int{http.StatusUnauthorized, http.StatusForbidden}, status)
+		})
+	}
+
+	// Reading the state they change still only takes the listener token
+	for _, path := range []string{"/control", "/config", "/replay", "/demo"} {
+		assert.Equal(t, http.StatusOK, do(t, http.MethodGet, open+path, "", ""), path)
+		assert.Equal(t, http.StatusOK, do(t, http.MethodGet, guarded+path, "listener", ""), path)
+	}
+}
+
+func TestListenerEndpoints(t *testing.T) {
+	paths := []string{"/stats", "/metrics", "/services", "/connections", "/clients"}
+
+	_, open := startTestExtension(t)
+	_, guarded := startTestExtension(t, func(cfg *Config) { cfg.Auth.ListenerToken = "listener" })
+	for _, path := range paths {
+		t.Run(path, func(t *testing.T) {
+			assert.Equal(t, http.StatusOK, do(t, http.MethodGet, open+path, "", ""))
+			assert.Equal(t, http.StatusUnauthorized, do(t, http.MethodGet, guarded+path, "", ""))
+			assert.Equal(t, http.StatusUnauthorized, do(t, http.MethodGet, guarded+path, "ingest", ""))
+			assert.Equal(t, http.StatusOK, do(t, http.MethodGet, guarded+path, "listener", ""))
+			// As passed on by the web UI
+			assert.Equal(t, http.StatusOK, do(t, http.MethodGet, guarded+path+"?token=listener", "", ""))
+		})
+	}
+}
+
+func TestShutdownFinishesAfterServerTimeout(t *testing.T) {
+	s, base := startTestExtension(t)
+	addr := strings.TrimPrefix(base, "http://")
+
+	ws, _, err := websocket.DefaultDialer.Dial("ws://"+addr+"/ws", nil)
+	require.NoError(t, err)
+	defer ws.Close()
+
+	// A request whose body never arrives keeps server.Shutdown waiting
+	conn, err := net.Dial("tcp", addr)
+	require.NoError(t, err)
+	defer conn.Close()
+	_, err = fmt.Fprint(conn, "POST /v1/logs HTTP/1.1\r\nHost: sonifier\r\nContent-Type: application/json\r\nContent-Length: 1000\r\n\r\n{")
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	err = s.Shutdown(ctx)
+	require.Error(t, err)
+	assert.True(t, errors.Is(err, context.Canceled))
+
+	// The WebSocket client was still told the server is going away
+	require.NoError(t, ws.SetReadDeadline(time.Now().Add(5*time.Second)))
+	for {
+		if _, _, err = ws.ReadMessage(); err != nil {
+			break
+		}
+	}
+	assert.True(t, websocket.IsCloseError(err, websocket.CloseGoingAway), "got %v", err)
+}
+
+func TestStartFailure(t *testing.T) {
+	t.Run("occupied port", func(t *testing.T) {
+		taken, err := net.Listen("tcp", "127.0.0.1:0")
+		require.NoError(t, err)
+		addr := taken.Addr().String()
+
+		s := newTestExtension(t, func(cfg *Config) { cfg.Endpoint = addr })
+		require.Error(t, s.Start(context.Background(), componenttest.NewNopHost()))
+		assert.Nil(t, s.addr)
+		assert.NoError(t, s.Shutdown(context.Background()))
+
+		// Nothing is left holding the port once it's free again
+		require.NoError(t, taken.Close())
+		ln, err := net.Listen("tcp", addr)
+		require.NoError(t, err)
+		ln.Close()
+	})
+
+	t.Run("after listening", func(t *testing.T) {
+		probe, err := net.Listen("tcp", "127.0.0.1:0")
+		require.NoError(t, err)
+		addr := probe.Addr().String()
+		require.NoError(t, probe.Close())
+
+		// The recording can't be opened once the listener is up
+		s := newTestExtension(t, func(cfg *Config) {
+			cfg.Endpoint = addr
+			cfg.Record.Path = filepath.Join(t.TempDir(), "missing", "record.jsonl")
+		})
+		require.ErrorContains(t, s.Start(context.Background(), componenttest.NewNopHost()), "failed to open recording")
+		assert.NoError(t, s.Shutdown(context.Background()))
+
+		// and the port was let go
+		ln, err := net.Listen("tcp", addr)
+		require.NoError(t, err)
+		ln.Close()
+	})
+}
+
+func TestShutdownWithoutStart(t *testing.T) {
+	s := newTestExtension(t)
+	assert.NoError(t, s.Shutdown(context.Background()))
+}
+
+func TestShutdownTwice(t *testing.T) {
+	s := newTestExtension(t)
+	require.NoError(t, s.Start(context.Background(), componenttest.NewNopHost()))
+	assert.NotPanics(t, func() {
+		assert.NoError(t, s.Shutdown(context.Background()))
+		assert.NoError(t, s.Shutdown(context.Background()))
+	})
+}
+
+// postTelemetry posts body as JSON to path and returns the status and
+// response body.
+func postTelemetry(t *testing.T, url, path, body string) (int, string) {
+	t.Helper()
+	resp, err := http.Post(url+path, "application/json", strings.NewReader(body))
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	data, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	return resp.StatusCode, string(data)
+}
+
+func TestCheckDecoded(t *testing.T) {
+	for _, acceptUnknown := range []bool{false, true} {
+		t.Run(fmt.Sprintf("accept_unknown %v", acceptUnknown), func(t *testing.T) {
+			s, url := startTestExtension(t, func(cfg *Config) { cfg.AcceptUnknown = acceptUnknown })
+
+			// OTLP is accepted either way
+			status, _ := postTelemetry(t, url, "/telemetry", testLogs)
+			assert.Equal(t, http.StatusOK, status)
+
+			status, body := postTelemetry(t, url, "/telemetry", `{"hello":"world"}`)
+			if acceptUnknown {
+				assert.Equal(t, http.StatusOK, status)
+				assert.Equal(t, []string{"logs", "unknown"}, historyTypes(s.broadcaster))
+			} else {
+				assert.Equal(t, http.StatusBadRequest, status)
+				var rejection parseRejection
+				require.NoError(t, json.Unmarshal([]byte(body), &rejection))
+				assert.Equal(t, "payload is not valid OTLP telemetry", rejection.Error)
+				assert.Equal(t, "OTLP json export request", rejection.Attempted)
+				assert.Equal(t, "no resourceSpans, resourceMetrics or resourceLogs field", rejection.ParseError)
+				assert.Equal(t, []string{"logs"}, historyTypes(s.broadcaster))
+			}
+
+			// The signal endpoints only take OTLP of their own signal
+			status, body = postTelemetry(t, url, "/v1/traces", `{"hello":"world"}`)
+			assert.Equal(t, http.StatusBadRequest, status)
+			assert.Contains(t, body, `"attempted":"OTLP json traces export request"`)
+			status, body = postTelemetry(t, url, "/v1/traces", testLogs)
+			assert.Equal(t, http.StatusBadRequest, status)
+			assert.Contains(t, body, "payload is OTLP logs, expected traces")
+		})
+	}
+}
+
+func TestBodyTooLarge(t *testing.T) {
+	s, url := startTestExtension(t, func(cfg *Config) { cfg.MaxRequestBodyBytes = 64 })
+
+	for i, tc := range []struct{ path, body string }{
+		{"/v1/traces", testTraces},
+		{"/v1/batch", `[{"type":"traces","payload":` + testTraces + `}]`},
+	} {
+		status, body := postTelemetry(t, url, tc.path, tc.body)
+		assert.Equal(t, http.StatusRequestEntityTooLarge, status, tc.path)
+		assert.JSONEq(t, `{"error":"request body exceeds 64 bytes"}`, body, tc.path)
+		assert.Equal(t, uint64(i+1), s.stats.rejected.Load(), tc.path)
+	}
+	assert.Empty(t, historyTypes(s.broadcaster))
+
+	// A body within the limit still gets through
+	status, _ := postTelemetry(t, url, "/v1/logs", `{"resourceLogs":[]}`)
+	assert.Equal(t, http.StatusOK, status)
+	assert.Equal(t, uint64(2), s.stats.rejected.Load())
+}
+
+func TestServeHTTP(t *testing.T) {
+	// httptest's certificate is valid for 127.0.0.1, and its client
+	// trusts it
+	certServer := httptest.NewUnstartedServer(nil)
+	certServer.StartTLS()
+	t.Cleanup(certServer.Close)
+	certConfig := certServer.TLS.Clone()
+	httpsClient := certServer.Client()
+
+	for _, tc := range []struct {
+		name string
+		// listenerTLS wraps the listener in TLS, as confighttp does
+		listenerTLS bool
+		// serverTLS gives the server a TLS config of its own
+		serverTLS bool
+		https     bool
+	}{
+		{name: "plain"},
+		{name: "server TLS", serverTLS: true, https: true},
+		{name: "listener TLS", listenerTLS: true, https: true},
+		{name: "listener and server TLS", listenerTLS: true, serverTLS: true, https: true},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			ln, err := net.Listen("tcp", "127.0.0.1:0")
+			require.NoError(t, err)
+			if tc.listenerTLS {
+				ln = tls.NewListener(ln, certConfig)
+			}
+			server := &http.Server{Handler: http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+				fmt.Fprint(w, "ok")
+			})}
+			if tc.serverTLS {
+				server.TLSConfig = certConfig.Clone()
+			}
+			served := make(chan error, 1)
+			go func() { served <- serveHTTP(server, ln, tc.listenerTLS) }()
+			t.Cleanup(func() {
+				server.Close()
+				assert.ErrorIs(t, <-served, http.ErrServerClosed)
+			})
+
+			scheme, client := "http://", http.DefaultClient
+			if tc.https {
+				scheme, client = "https://", httpsClient
+			}
+			resp, err := client.Get(scheme + ln.Addr().String())
+			require.NoError(t, err)
+			body, err := io.ReadAll(resp.Body)
+			resp.Body.Close()
+			require.NoError(t, err)
+			assert.Equal(t, "ok", string(body))
+			assert.Equal(t, tc.https, resp.TLS != nil)
+		})
+	}
+}
@@ -2,6 +2,7 @@ package sonifierextension
 
 import (
 	"context"
+	"time"
 
 	"go.opentelemetry.io/collector/component"
 	"go.opentelemetry.io/collector/config/confighttp"
@@ -27,6 +28,89 @@ func createDefaultConfig() component.Config {
 	return &Config{
 		ServerConfig: confighttp.ServerConfig{
 			Endpoint: "localhost:44444",
+			// Bound how long a slow client can hold an ingest connection.
+			// Streaming connections clear these once established.
+			ReadHeaderTimeout: 10 * time.Second,
+			ReadTimeout:       30 * time.Second,
+			WriteTimeout:      30 * time.Second,
+			IdleTimeout:       2 * time.Minute,
+		},
+		MaxRequestBodyBytes: 8 << 20,
+		ClientQueueSize:     64,
+		Auth: AuthConfig{
+			TokenTTL: time.Minute,
+		},
+		IngestLimits: IngestLimitsConfig{
+			Burst: time.Second,
+		},
+		WebSocket: WebSocketConfig{
+			WriteTimeout:      5 * time.Second,
+			Compression:       true,
+			HeartbeatInterval: 10 * time.Second,
+		},
+		SSE: SSEConfig{
+			HeartbeatInterval: 15 * time.Second,
+		},
+		Buffer: BufferConfig{
+			MaxEntries: 100,
+			MaxBytes:   32 << 20,
+		},
+		Broadcast: BroadcastConfig{
+			Format:          formatJSON,
+			MaxMessageBytes: 1 << 20,
+			Oversize:        oversizeSplit,
+		},
+		Aggregation: AggregationConfig{
+			Window:     time.Second,
+			ForwardRaw: true,
+		},
+		Record: RecordConfig{
+			MaxBackups: 5,
+		},
+		Control: ControlConfig{
+			ResumeBacklog: 20,
+		},
+		Alarm: AlarmConfig{
+			Window:       30 * time.Second,
+			OnThreshold:  0.3,
+			OffThreshold: 0.1,
+			Sustain:      10 * time.Second,
+		},
+		Anomaly: AnomalyConfig{
+			ZScore:      3,
+			Alpha:       0.1,
+			Warmup:      10,
+			MaxSeries:   10000,
+			ErrorWindow: 10 * time.Second,
+			ErrorRate:   5,
+			Cooldown:    time.Minute,
+		},
+		Traces: TracesConfig{
+			SampleRatio: 1,
+		},
+		Topology: TopologyConfig{
+			Window:          time.Minute,
+			MaxEdges:        1000,
+			JoinWindow:      10 * time.Second,
+			MaxPendingSpans: 10000,
+		},
+		Mappings: MappingsConfig{
+			ForwardUnmatched: true,
+		},
+		Notes: NotesConfig{
+			LengthBuckets: []time.Duration{10 * time.Millisecond, 50 * time.Millisecond, 250 * time.Millisecond, time.Second},
+		},
+		Normalize: NormalizeConfig{
+			Window:    5 * time.Minute,
+			MaxSeries: 10000,
+		},
+		Chord: ChordConfig{
+			Root:  "C3",
+			Scale: "pentatonic",
+		},
+		OSC: OSCConfig{
+			Target:  "localhost:57120",
+			Address: "/otel/{signal}/{service}",
 		},
 	}
 }
@@ -36,5 +120,5 @@ func createExtension(
 	set extension.Settings,
 	cfg component.Config,
 ) (extension.Extension, error) {
-	return newSonifierExtension(cfg.(*Config), set.Logger), nil
+	return newSonifierExtension(cfg.(*Config), set.TelemetrySettings)
 }
@@ -0,0 +1,79 @@
+package sonifierextension
+
+import (
+	"io/fs"
+	"net/http"
+)
+
+// fallbackPage is served at / when the binary was built without the web
+// UI. Its console streams /ws and sends control messages, so the stream
+// can still be checked from a browser.
+const fallbackPage = `<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<title>OpenTelemetry Sonifier</title>
+<style>
+  body { font-family: system-ui, sans-serif; margin: 2rem; max-width: 60rem; }
+  #log { background: #111; color: #ddd; font: 12px monospace; height: 24rem; overflow-y: auto; padding: .5rem; white-space: pre-wrap; }
+  #control { font-family: monospace; width: 40rem; }
+</style>
+</head>
+<body>
+<h1>OpenTelemetry Sonifier</h1>
+<p>The web UI isn't included in this build, so only this console is available.
+Rebuild with the <code>sonifierextension/web</code> directory in place to get the sonification UI back.
+The streaming endpoints, <code>/ws</code> and <code>/events</code>, work as usual.</p>
+<p>Status: <span id="status">connecting…</span></p>
+<div id="log"></div>
+<p>
+  <input id="control" value='{"action":"subscribe","types":["traces","logs"]}'>
+  <button id="send">Send control message</button>
+</p>
+<script>
+  const log = document.getElementById("log");
+  const status = document.getElementById("status");
+  const token = new URLSearchParams(location.search).get("token");
+  const scheme = location.protocol === "https:" ? "wss:" : "ws:";
+  const url = scheme + "//" + location.host + "/ws" + (token ? "?token=" + encodeURIComponent(token) : "");
+
+  function append(line) {
+    log.textContent += line + "\n";
+    if (log.textContent.length > 200000) log.textContent = log.textContent.slice(-100000);
+    log.scrollTop = log.scrollHeight;
+  }
+
+  const ws = new WebSocket(url);
+  ws.onopen = () => { status.textContent = "connected to " + url; };
+  ws.onclose = () => { status.textContent = "disconnected, reload to reconnect"; };
+  ws.onmessage = (event) => {
+    try {
+      const msg = JSON.parse(event.data);
+      append("#" + msg.seq + " " + msg.type + (msg.service ? " [" + msg.service + "]" : "") + " " + JSON.stringify(msg.payload).slice(0, 200));
+    } catch (err) {
+      append(String(event.data).slice(0, 200));
+    }
+  };
+  document.getElementById("send").onclick = () => {
+    ws.send(document.getElementById("control").value);
+  };
+</script>
+</body>
+</html>
+`
+
+// hasWebUI reports whether webFS holds the web UI's entry page.
+func hasWebUI(webFS fs.FS) bool {
+	_, err := fs.Stat(webFS, "index.html")
+	return err == nil
+}
+
+// handleFallback serves the built-in console at / and 404s elsewhere.
+func handleFallback(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path != "/" && r.URL.Path != "/index.html" {
+		http.NotFound(w, r)
+		return
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Write([]byte(fallbackPage))
+}
@@ -0,0 +1,198 @@
+package sonifierextension
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"go.opentelemetry.io/collector/pdata/plog"
+	"go.opentelemetry.io/collector/pdata/pmetric"
+	"go.opentelemetry.io/collector/pdata/ptrace"
+	"go.uber.org/zap"
+)
+
+// signalFilter is a compiled FilterConfig for one signal type.
+type signalFilter struct {
+	include     []*regexp.Regexp
+	exclude     []*regexp.Regexp
+	minSeverity plog.SeverityNumber
+	status      ptrace.StatusCode
+	anyStatus   bool
+}
+
+// newFilters compiles the configured filters, returning only the signal
+// types that have one.
+func newFilters(cfg FiltersConfig) (map[string]*signalFilter, error) {
+	filters := make(map[string]*signalFilter)
+	for dataType, fc := range cfg.byType() {
+		f, err := compileFilter(dataType, fc)
+		if err != nil {
+			return nil, fmt.Errorf("filters.%s.%w", dataType, err)
+		}
+		if f != nil {
+			filters[dataType] = f
+		}
+	}
+	return filters, nil
+}
+
+func compileFilter(dataType string, fc FilterConfig) (*signalFilter, error) {
+	if len(fc.IncludeServices) == 0 && len(fc.ExcludeServices) == 0 && fc.MinSeverity == "" && fc.SpanStatus == "" {
+		return nil, nil
+	}
+
+	f := &signalFilter{anyStatus: true}
+	var err error
+	if f.include, err = compilePatterns("include_services", fc.IncludeServices); err != nil {
+		return nil, err
+	}
+	if f.exclude, err = compilePatterns("exclude_services", fc.ExcludeServices); err != nil {
+		return nil, err
+	}
+	if fc.MinSeverity != "" {
+		if dataType != "logs" {
+			return nil, fmt.Errorf("min_severity: only applies to logs")
+		}
+		sev, ok := severityNumbers[strings.ToUpper(fc.MinSeverity)]
+		if !ok {
+			return nil, fmt.Errorf("min_severity: unknown severity %q, expected TRACE, DEBUG, INFO, WARN, ERROR or FATAL", fc.MinSeverity)
+		}
+		f.minSeverity = sev
+	}
+	if fc.SpanStatus != "" {
+		if dataType != "traces" {
+			return nil, fmt.Errorf("span_status: only applies to traces")
+		}
+		status, ok := spanStatuses[strings.ToLower(fc.SpanStatus)]
+		if !ok {
+			return nil, fmt.Errorf("span_status: unknown status %q, expected unset, ok or error", fc.SpanStatus)
+		}
+		f.status, f.anyStatus = status, false
+	}
+	return f, nil
+}
+
+func compilePatterns(key string, patterns []string) ([]*regexp.Regexp, error) {
+	var res []*regexp.Regexp
+	for i, p := range patterns {
+		re, err := regexp.Compile(p)
+		if err != nil {
+			return nil, fmt.Errorf("%s[%d]: invalid regular expression: %w", key, i, err)
+		}
+		res = append(res, re)
+	}
+	return res, nil
+}
+
+// keepService reports whether data from service passes the include and
+// exclude lists.
+func (f *signalFilter) keepService(service string) bool {
+	for _, re := range f.exclude {
+		if re.MatchString(service) {
+			return false
+		}
+	}
+	if len(f.include) == 0 {
+		return true
+	}
+	for _, re := range f.include {
+		if re.MatchString(service) {
+			return true
+		}
+	}
+	return false
+}
+
+// apply removes the data f filters out of d, dropping resources and scopes
+// left empty. It returns the number of spans, metrics or log records
+// removed.
+func (f *signalFilter) apply(d *decodedTelemetry) int {
+	before := d.count()
+	switch d.dataType {
+	case "traces":
+		f.filterTraces(d.traces)
+	case "metrics":
+		f.filterMetrics(d.metrics)
+	case "logs":
+		f.filterLogs(d.logs)
+	}
+	return before - d.count()
+}
+
+func (f *signalFilter) filterTraces(td ptrace.Traces) {
+	td.ResourceSpans().RemoveIf(func(rs ptrace.ResourceSpans) bool {
+		if !f.keepService(resourceService(rs.Resource())) {
+			return true
+		}
+		if f.anyStatus {
+			return false
+		}
+		rs.ScopeSpans().RemoveIf(func(ss ptrace.ScopeSpans) bool {
+			ss.Spans().RemoveIf(func(span ptrace.Span) bool {
+				return span.Status().Code() != f.status
+			})
+			return ss.Spans().Len() == 0
+		})
+		return rs.ScopeSpans().Len() == 0
+	})
+}
+
+func (f *signalFilter) filterMetrics(md pmetric.Metrics) {
+	md.ResourceMetrics().RemoveIf(func(rm pmetric.ResourceMetrics) bool {
+		return !f.keepService(resourceService(rm.Resource()))
+	})
+}
+
+func (f *signalFilter) filterLogs(ld plog.Logs) {
+	ld.ResourceLogs().RemoveIf(func(rl plog.ResourceLogs) bool {
+		if !f.keepService(resourceService(rl.Resource())) {
+			return true
+		}
+		if f.minSeverity == 0 {
+			return false
+		}
+		rl.ScopeLogs().RemoveIf(func(sl plog.ScopeLogs) bool {
+			sl.LogRecords().RemoveIf(func(record plog.LogRecord) bool {
+				return record.SeverityNumber() < f.minSeverity
+			})
+			return sl.LogRecords().Len() == 0
+		})
+		return rl.ScopeLogs().Len() == 0
+	})
+}
+
+// filterStats count the spans, metrics or log records of one signal type
+// that passed or were dropped by its filter.
+type filterStats struct {
+	Passed  uint64 `json:"passed"`
+	Dropped uint64 `json:"dropped"`
+}
+
+// countFiltered records a filtered payload's counts. The caller must hold
+// s.mu.
+func (s *sonifierExtension) countFiltered(dataType string, passed, dropped int) {
+	if _, ok := s.filters[dataType]; !ok || passed+dropped == 0 {
+		return
+	}
+	stats := s.filterStats[dataType]
+	stats.Passed += uint64(passed)
+	stats.Dropped += uint64(dropped)
+	s.filterStats[dataType] = stats
+	s.stats.filtered(dataType, passed, dropped)
+}
+
+// applyFilter removes filtered-out data from d and returns the number of
+// spans, metrics or log records removed. Unparsed payloads pass through.
+func (s *sonifierExtension) applyFilter(d *decodedTelemetry) int {
+	f, ok := s.filters[d.dataType]
+	if !ok || !d.parsed {
+		return 0
+	}
+	removed := f.apply(d)
+	if removed > 0 && d.count() > 0 {
+		if err := d.encodeJSON(); err != nil {
+			s.logger.Error("Failed to encode filtered telemetry", zap.Error(err))
+		}
+	}
+	return removed
+}
@@ -0,0 +1,188 @@
+package sonifierextension
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"go.opentelemetry.io/collector/pdata/pcommon"
+	"go.uber.org/zap"
+)
+
+// Ingester is implemented by the sonifier extension, so other collector
+// components and tests can feed it telemetry without going through HTTP.
+// Find it among the host's extensions:
+//
+//	for _, ext := range host.GetExtensions() {
+//		if sonifier, ok := ext.(sonifierextension.Ingester); ok {
+//			err := sonifier.Ingest(ctx, "traces", body)
+//		}
+//	}
+type Ingester interface {
+	// Ingest handles payload, an OTLP JSON or protobuf export request, as
+	// if it had been posted to the signal's endpoint.
+	Ingest(ctx context.Context, signalType string, payload []byte) error
+}
+
+var _ Ingester = (*sonifierExtension)(nil)
+
+// ErrNotRunning is returned by Ingest before Start or after Shutdown.
+var ErrNotRunning = errors.New("sonifier extension is not running")
+
+// RejectedError is returned by Ingest for a payload that isn't OTLP
+// telemetry of the expected signal.
+type RejectedError struct {
+	rejection parseRejection
+}
+
+// RateLimitedError is returned by Ingest for a payload over one of the
+// ingest_limits. Service is set when the service's own limit was hit, and
+// RetryAfter is how long until the limit has room again.
+type RateLimitedError struct {
+	Signal     string
+	Service    string
+	RetryAfter time.Duration
+}
+
+func (e *RateLimitedError) Error() string {
+	if e.Service != "" {
+		return fmt.Sprintf("%s from service %s over their ingest limit, retry after %v", e.Signal, e.Service, e.RetryAfter.Round(time.Millisecond))
+	}
+	return fmt.Sprintf("%s over their ingest limit, retry after %v", e.Signal, e.RetryAfter.Round(time.Millisecond))
+}
+
+func (e *RejectedError) Error() string {
+	if e.rejection.ParseError != "" {
+		return e.rejection.Error + ": " + e.rejection.ParseError
+	}
+	return e.rejection.Error
+}
+
+// Ingest runs payload through the same path as a request to /v1/traces,
+// /v1/metrics or /v1/logs, or to /telemetry when signalType is empty: it
+// is checked, filtered, stored as the latest payload and broadcast. It
+// returns a *RejectedError when the payload isn't OTLP telemetry of that
+// signal, a *RateLimitedError when it is over the ingest_limits, and an
+// error when it exceeds max_request_body_bytes.
+func (s *sonifierExtension) Ingest(ctx context.Context, signalType string, payload []byte) error {
+	switch signalType {
+	case "", "traces", "metrics", "logs":
+	default:
+		return fmt.Errorf("unknown signal type %q, expected traces, metrics, logs or empty to detect it", signalType)
+	}
+	if !s.running() {
+		return ErrNotRunning
+	}
+	if limit := s.config.MaxRequestBodyBytes; int64(len(payload)) > limit {
+		s.countRejected(ctx, "body_too_large")
+		return fmt.Errorf("payload exceeds %d bytes", limit)
+	}
+
+	// Limits are checked before decoding when the signal is known and the
+	// service doesn't matter, so a flood is turned away before it costs
+	// anything
+	early := signalType != "" && !s.ingestLimits.perService(signalType)
+	if early {
+		if err := s.checkIngestLimit(ctx, signalType, nil, len(payload)); err != nil {
+			return err
+		}
+	}
+	decoded := decodeTelemetry(payload, signalType)
+	if rejection := s.checkDecoded(decoded, signalType); rejection != nil {
+		s.countRejected(ctx, "unparseable")
+		return &RejectedError{rejection: *rejection}
+	}
+	switch {
+	case early:
+	case decoded.parsed:
+		if err := s.checkDecodedLimit(ctx, decoded, len(payload)); err != nil {
+			return err
+		}
+	case signalType != "":
+		if err := s.checkIngestLimit(ctx, signalType, nil, len(payload)); err != nil {
+			return err
+		}
+	}
+	dataType, err := s.ingest(payload, decoded)
+	if err != nil {
+		return err
+	}
+	s.logger.Info("Received telemetry data", zap.String("type", dataType))
+	return nil
+}
+
+// checkIngestLimit takes a payload of n bytes from the ingest limit of
+// signal and from those of the services sending it, counting it as rate
+// limited when any limit is reached. services maps each service to its
+// share of the bytes.
+func (s *sonifierExtension) checkIngestLimit(ctx context.Context, signal string, services map[string]int, n int) error {
+	err := s.ingestLimits.allow(signal, services, n, time.Now(), s.logger)
+	if err != nil {
+		s.stats.rateLimited(signal)
+		s.countRejected(ctx, "rate_limited")
+	}
+	return err
+}
+
+// checkDecodedLimit checks a decoded payload of n bytes against the limit
+// of its signal and, when the signal has per-service limits, of every
+// service with a resource in it. Each service is charged one payload and
+// the share of the bytes its resources make up of all the payload's.
+func (s *sonifierExtension) checkDecodedLimit(ctx context.Context, decoded *decodedTelemetry, n int) error {
+	var services map[string]int
+	if s.ingestLimits.perService(decoded.dataType) {
+		services = serviceShares(decoded, n)
+	}
+	return s.checkIngestLimit(ctx, decoded.dataType, services, n)
+}
+
+// serviceShares splits n bytes of a parsed payload between the services
+// of its resources, by how many of the resources each has. Resources
+// without a service.name are only counted in the total.
+func serviceShares(d *decodedTelemetry, n int) map[string]int {
+	var resources []pcommon.Resource
+	switch d.dataType {
+	case "traces":
+		rs := d.traces.ResourceSpans()
+		for i := 0; i < rs.Len(); i++ {
+			resources = append(resources, rs.At(i).Resource())
+		}
+	case "metrics":
+		rm := d.metrics.ResourceMetrics()
+		for i := 0; i < rm.Len(); i++ {
+			resources = append(resources, rm.At(i).Resource())
+		}
+	case "logs":
+		rl := d.logs.ResourceLogs()
+		for i := 0; i < rl.Len(); i++ {
+			resources = append(resources, rl.At(i).Resource())
+		}
+	}
+	counts := make(map[string]int)
+	for _, res := range resources {
+		if service := resourceService(res); service != "" {
+			counts[service]++
+		}
+	}
+	shares := make(map[string]int, len(counts))
+	for service, count := range counts {
+		shares[service] = n * count / len(resources)
+	}
+	return shares
+}
+
+// running reports whether Start has finished and Shutdown hasn't begun.
+func (s *sonifierExtension) running() bool {
+	select {
+	case <-s.stop:
+		return false
+	default:
+	}
+	select {
+	case <-s.ready:
+		return true
+	default:
+		return false
+	}
+}
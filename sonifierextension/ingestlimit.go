@@ -0,0 +1,255 @@
+package sonifierextension
+
+import (
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// maxLimitedServices bounds how many services' buckets are kept. Past it,
+// services without a bucket are only held to their signal's limits.
+const maxLimitedServices = 1024
+
+// tokenBucket allows rate units per second, with up to capacity saved up.
+// A take may drive it below zero, so a payload larger than the capacity
+// still gets through while the bucket isn't empty, and the ones after it
+// wait for the debt to be paid off.
+type tokenBucket struct {
+	rate     float64
+	capacity float64
+	tokens   float64
+}
+
+func newTokenBucket(rate, burst float64) tokenBucket {
+	capacity := max(rate*burst, 1)
+	return tokenBucket{rate: rate, capacity: capacity, tokens: capacity}
+}
+
+// wait returns how long until the bucket can give anything, zero if it
+// can now. An unlimited bucket never waits.
+func (b *tokenBucket) wait(elapsed time.Duration) time.Duration {
+	if b.rate == 0 {
+		return 0
+	}
+	b.tokens = min(b.capacity, b.tokens+elapsed.Seconds()*b.rate)
+	if b.tokens >= 1 {
+		return 0
+	}
+	return time.Duration((1 - b.tokens) / b.rate * float64(time.Second))
+}
+
+// fullAfter reports whether the bucket is back to its capacity after
+// elapsed.
+func (b *tokenBucket) fullAfter(elapsed time.Duration) bool {
+	return b.rate == 0 || b.tokens+elapsed.Seconds()*b.rate >= b.capacity
+}
+
+func (b *tokenBucket) take(n float64) {
+	if b.rate != 0 {
+		b.tokens -= n
+	}
+}
+
+// ingestBucket holds the payload and byte buckets of one signal type or
+// service.
+type ingestBucket struct {
+	payloads tokenBucket
+	bytes    tokenBucket
+	last     time.Time
+	// limited is set while payloads are being rejected, so only the first
+	// rejection is logged.
+	limited bool
+}
+
+func newIngestBucket(rate IngestRate, burst time.Duration, now time.Time) *ingestBucket {
+	return &ingestBucket{
+		payloads: newTokenBucket(rate.PayloadsPerSec, burst.Seconds()),
+		bytes:    newTokenBucket(float64(rate.BytesPerSec), burst.Seconds()),
+		last:     now,
+	}
+}
+
+// wait refills the bucket up to now and returns how long until it
+// accepts a payload, zero if it does now.
+func (b *ingestBucket) wait(now time.Time) time.Duration {
+	elapsed := now.Sub(b.last)
+	b.last = now
+	return max(b.payloads.wait(elapsed), b.bytes.wait(elapsed))
+}
+
+// take takes a payload of n bytes.
+func (b *ingestBucket) take(n int) {
+	b.payloads.take(1)
+	b.bytes.take(float64(n))
+}
+
+// full reports whether the bucket has refilled completely by now, when it
+// is as good as a new one.
+func (b *ingestBucket) full(now time.Time) bool {
+	elapsed := now.Sub(b.last)
+	return b.payloads.fullAfter(elapsed) && b.bytes.fullAfter(elapsed)
+}
+
+type serviceBucketKey struct {
+	signal  string
+	service string
+}
+
+// ingestLimiter enforces ingest_limits with token buckets. A nil limiter
+// accepts everything.
+type ingestLimiter struct {
+	burst   time.Duration
+	signals map[string]IngestLimit
+
+	mu       sync.Mutex
+	buckets  map[string]*ingestBucket
+	services map[serviceBucketKey]*ingestBucket
+}
+
+func newIngestLimiter(cfg IngestLimitsConfig) (*ingestLimiter, error) {
+	l := &ingestLimiter{
+		burst:    cfg.Burst,
+		signals:  make(map[string]IngestLimit),
+		buckets:  make(map[string]*ingestBucket),
+		services: make(map[serviceBucketKey]*ingestBucket),
+	}
+	var errs []error
+	for _, signal := range []struct {
+		name  string
+		limit IngestLimit
+	}{{"traces", cfg.Traces}, {"metrics", cfg.Metrics}, {"logs", cfg.Logs}} {
+		limit := signal.limit
+		if negativeRate(limit.IngestRate) {
+			errs = append(errs, fmt.Errorf("ingest_limits.%s: rates must not be negative", signal.name))
+		}
+		if negativeRate(limit.PerService) {
+			errs = append(errs, fmt.Errorf("ingest_limits.%s.per_service: rates must not be negative", signal.name))
+		}
+		if limit != (IngestLimit{}) {
+			l.signals[signal.name] = limit
+		}
+	}
+	if cfg.Burst < 0 {
+		errs = append(errs, errors.New("ingest_limits.burst must not be negative"))
+	}
+	if len(errs) > 0 {
+		return nil, errors.Join(errs...)
+	}
+	if len(l.signals) == 0 {
+		return nil, nil
+	}
+	return l, nil
+}
+
+func negativeRate(rate IngestRate) bool {
+	return rate.PayloadsPerSec < 0 || rate.BytesPerSec < 0
+}
+
+// allow takes a payload of n bytes of signal from the signal's bucket
+// and, when signal has per-service limits, a payload from the bucket of
+// each service in services, of the bytes it maps to. It takes from none
+// unless all have room, and returns a *RateLimitedError for the first one
+// that doesn't, checking services in name order. The buckets refill up to
+// now.
+func (l *ingestLimiter) allow(signal string, services map[string]int, n int, now time.Time, logger *zap.Logger) error {
+	limit, ok := l.limit(signal)
+	if !ok {
+		return nil
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	type charge struct {
+		bucket  *ingestBucket
+		service string
+		n       int
+	}
+	var charges []charge
+	if limit.IngestRate != (IngestRate{}) {
+		b, ok := l.buckets[signal]
+		if !ok {
+			b = newIngestBucket(limit.IngestRate, l.burst, now)
+			l.buckets[signal] = b
+		}
+		charges = append(charges, charge{bucket: b, n: n})
+	}
+	if limit.PerService != (IngestRate{}) {
+		for _, service := range slices.Sorted(maps.Keys(services)) {
+			if b := l.serviceBucket(signal, service, limit.PerService, now); b != nil {
+				charges = append(charges, charge{bucket: b, service: service, n: services[service]})
+			}
+		}
+	}
+	for _, c := range charges {
+		if wait := c.bucket.wait(now); wait > 0 {
+			setLimited(c.bucket, true, signal, c.service, logger)
+			return &RateLimitedError{Signal: signal, Service: c.service, RetryAfter: wait}
+		}
+	}
+	for _, c := range charges {
+		c.bucket.take(c.n)
+		setLimited(c.bucket, false, signal, c.service, logger)
+	}
+	return nil
+}
+
+// serviceBucket returns the bucket of service sending signal, creating it
+// with rate, or nil when there are too many services to keep another.
+// The caller holds l.mu.
+func (l *ingestLimiter) serviceBucket(signal, service string, rate IngestRate, now time.Time) *ingestBucket {
+	key := serviceBucketKey{signal: signal, service: service}
+	if b, ok := l.services[key]; ok {
+		return b
+	}
+	if len(l.services) >= maxLimitedServices {
+		for k, other := range l.services {
+			if other.full(now) {
+				delete(l.services, k)
+			}
+		}
+		if len(l.services) >= maxLimitedServices {
+			return nil
+		}
+	}
+	b := newIngestBucket(rate, l.burst, now)
+	l.services[key] = b
+	return b
+}
+
+// perService reports whether services sending signal have limits of their
+// own.
+func (l *ingestLimiter) perService(signal string) bool {
+	limit, ok := l.limit(signal)
+	return ok && limit.PerService != (IngestRate{})
+}
+
+func (l *ingestLimiter) limit(signal string) (IngestLimit, bool) {
+	if l == nil {
+		return IngestLimit{}, false
+	}
+	limit, ok := l.signals[signal]
+	return limit, ok
+}
+
+// setLimited records whether b is rejecting payloads, logging when it
+// starts and stops.
+func setLimited(b *ingestBucket, limited bool, signal, service string, logger *zap.Logger) {
+	if limited == b.limited {
+		return
+	}
+	b.limited = limited
+	fields := []zap.Field{zap.String("type", signal)}
+	if service != "" {
+		fields = append(fields, zap.String("service", service))
+	}
+	if limited {
+		logger.Warn("Ingest limit reached, rejecting payloads with 429", fields...)
+	} else {
+		logger.Info("Ingest back under its limit", fields...)
+	}
+}
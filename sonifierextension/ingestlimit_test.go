@@ -0,0 +1,115 @@
+package sonifierextension
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func TestIngestLimiterChecksBothBuckets(t *testing.T) {
+	l, err := newIngestLimiter(IngestLimitsConfig{
+		Burst: time.Second,
+		Logs: IngestLimit{
+			IngestRate: IngestRate{PayloadsPerSec: 2},
+			PerService: IngestRate{PayloadsPerSec: 1},
+		},
+	})
+	require.NoError(t, err)
+	logger := zap.NewNop()
+	now := time.Unix(1000, 0)
+	allow := func(service string) error {
+		return l.allow("logs", map[string]int{service: 10}, 10, now, logger)
+	}
+	var limited *RateLimitedError
+
+	require.NoError(t, allow("auth"))
+
+	// Over its service's limit, the payload doesn't use up the signal's
+	// room, which is left for another service
+	require.ErrorAs(t, allow("auth"), &limited)
+	assert.Equal(t, &RateLimitedError{Signal: "logs", Service: "auth", RetryAfter: time.Second}, limited)
+	require.NoError(t, allow("checkout"))
+
+	// Over the signal's limit, the payload doesn't use up its service's
+	// room either
+	require.ErrorAs(t, allow("billing"), &limited)
+	assert.Equal(t, &RateLimitedError{Signal: "logs", RetryAfter: 500 * time.Millisecond}, limited)
+
+	// Once the signal has refilled, auth still waits out the rest of its
+	// own limit and billing gets through
+	now = now.Add(500 * time.Millisecond)
+	require.ErrorAs(t, allow("auth"), &limited)
+	assert.Equal(t, &RateLimitedError{Signal: "logs", Service: "auth", RetryAfter: 500 * time.Millisecond}, limited)
+	require.NoError(t, allow("billing"))
+	now = now.Add(500 * time.Millisecond)
+	require.NoError(t, allow("auth"))
+}
+
+func TestIngestLimiterUnlimited(t *testing.T) {
+	l, err := newIngestLimiter(IngestLimitsConfig{})
+	require.NoError(t, err)
+	assert.Nil(t, l)
+	assert.NoError(t, l.allow("traces", map[string]int{"checkout": 10}, 10, time.Now(), zap.NewNop()))
+}
+
+func TestIngestLimitRetryAfter(t *testing.T) {
+	_, url := startTestExtension(t, func(cfg *Config) {
+		cfg.IngestLimits = IngestLimitsConfig{Burst: time.Second, Logs: IngestLimit{IngestRate: IngestRate{PayloadsPerSec: 0.5}}}
+	})
+	post := func() *http.Response {
+		resp, err := http.Post(url+"/v1/logs", "application/json", strings.NewReader(testLogs))
+		require.NoError(t, err)
+		resp.Body.Close()
+		return resp
+	}
+
+	assert.Equal(t, http.StatusOK, post().StatusCode)
+	resp := post()
+	assert.Equal(t, http.StatusTooManyRequests, resp.StatusCode)
+	assert.Equal(t, "2", resp.Header.Get("Retry-After"))
+	assert.Equal(t, http.StatusTooManyRequests, post().StatusCode)
+	assert.Equal(t, uint64(2), getStats(t, url).Signals["logs"].RateLimited, "rejections are counted")
+
+	// Other signals have no limit
+	resp, err := http.Post(url+"/v1/traces", "application/json", strings.NewReader(testTraces))
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Zero(t, getStats(t, url).Signals["traces"].RateLimited)
+}
+
+func TestServiceShares(t *testing.T) {
+	// auth, billing and a resource without a service each make up a third
+	d := decodeTelemetry([]byte(testMultiResourceLogs), "logs")
+	assert.Equal(t, map[string]int{"auth": 100, "billing": 100}, serviceShares(d, 300))
+	assert.Equal(t, map[string]int{"checkout": 300}, serviceShares(decodeTelemetry([]byte(testTraces), "traces"), 300))
+}
+
+func TestIngestLimitChargesEveryService(t *testing.T) {
+	s, _ := startTestExtension(t, func(cfg *Config) {
+		cfg.IngestLimits = IngestLimitsConfig{Burst: time.Second, Logs: IngestLimit{PerService: IngestRate{PayloadsPerSec: 0.1}}}
+	})
+	ctx := context.Background()
+	billing := strings.Replace(testLogs, `"auth"`, `"billing"`, 1)
+
+	// A payload with resources of auth and billing uses up both services'
+	// limits, not only the first's
+	require.NoError(t, s.Ingest(ctx, "logs", []byte(testMultiResourceLogs)))
+	for service, payload := range map[string]string{"auth": testLogs, "billing": billing} {
+		var limited *RateLimitedError
+		require.ErrorAs(t, s.Ingest(ctx, "logs", []byte(payload)), &limited, service)
+		assert.Equal(t, service, limited.Service)
+	}
+	require.NoError(t, s.Ingest(ctx, "logs", []byte(strings.Replace(testLogs, `"auth"`, `"checkout"`, 1))))
+
+	// Both services over their limit turn away the payload
+	var limited *RateLimitedError
+	require.ErrorAs(t, s.Ingest(ctx, "logs", []byte(testMultiResourceLogs)), &limited)
+	assert.Equal(t, "auth", limited.Service, "services are checked in name order")
+}
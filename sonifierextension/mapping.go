@@ -0,0 +1,302 @@
+package sonifierextension
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"go.opentelemetry.io/collector/pdata/pcommon"
+	"go.opentelemetry.io/collector/pdata/plog"
+	"go.opentelemetry.io/collector/pdata/pmetric"
+	"go.opentelemetry.io/collector/pdata/ptrace"
+)
+
+const (
+	defaultEventVelocity   = 0.8
+	defaultEventDurationMs = 200
+)
+
+// pitchRegisters are the frequencies in Hz of the named pitches.
+var pitchRegisters = map[string]float64{
+	"low":  220,
+	"mid":  440,
+	"high": 880,
+}
+
+// severityNumbers maps the severity names accepted in rules to the lowest
+// severity number of each level.
+var severityNumbers = map[string]plog.SeverityNumber{
+	"TRACE": plog.SeverityNumberTrace,
+	"DEBUG": plog.SeverityNumberDebug,
+	"INFO":  plog.SeverityNumberInfo,
+	"WARN":  plog.SeverityNumberWarn,
+	"ERROR": plog.SeverityNumberError,
+	"FATAL": plog.SeverityNumberFatal,
+}
+
+var spanStatuses = map[string]ptrace.StatusCode{
+	"unset": ptrace.StatusCodeUnset,
+	"ok":    ptrace.StatusCodeOk,
+	"error": ptrace.StatusCodeError,
+}
+
+// soundEvent is the payload of a {"type":"sound_event"} message.
+type soundEvent struct {
+	Rule       string  `json:"rule,omitempty"`
+	Signal     string  `json:"signal"`
+	Service    string  `json:"service,omitempty"`
+	Instrument string  `json:"instrument"`
+	Pitch      float64 `json:"pitch"`
+	Velocity   float64 `json:"velocity"`
+	DurationMs int     `json:"duration_ms"`
+
+	// resource is the resource of the matched datum, for routing.
+	resource pcommon.Resource
+}
+
+// compiledRule is a validated MappingRule with its names resolved.
+type compiledRule struct {
+	MappingRule
+	minSeverity plog.SeverityNumber
+	status      ptrace.StatusCode
+	anyStatus   bool
+	pitch       float64
+	valueMin    float64
+	valueMax    float64
+}
+
+func compileRule(r MappingRule) (*compiledRule, error) {
+	c := &compiledRule{MappingRule: r, anyStatus: true, valueMax: 1}
+
+	switch r.Signal {
+	case "traces", "metrics", "logs":
+	case "":
+		return nil, errors.New("signal is required (traces, metrics or logs)")
+	default:
+		return nil, fmt.Errorf("unknown signal %q, expected traces, metrics or logs", r.Signal)
+	}
+	if r.Metric != "" && r.Signal != "metrics" {
+		return nil, fmt.Errorf("metric only applies to metrics rules, not %s", r.Signal)
+	}
+	if r.MinSeverity != "" {
+		if r.Signal != "logs" {
+			return nil, fmt.Errorf("min_severity only applies to logs rules, not %s", r.Signal)
+		}
+		sev, ok := severityNumbers[strings.ToUpper(r.MinSeverity)]
+		if !ok {
+			return nil, fmt.Errorf("unknown min_severity %q, expected TRACE, DEBUG, INFO, WARN, ERROR or FATAL", r.MinSeverity)
+		}
+		c.minSeverity = sev
+	}
+	if r.Status != "" {
+		if r.Signal != "traces" {
+			return nil, fmt.Errorf("status only applies to traces rules, not %s", r.Signal)
+		}
+		status, ok := spanStatuses[strings.ToLower(r.Status)]
+		if !ok {
+			return nil, fmt.Errorf("unknown status %q, expected unset, ok or error", r.Status)
+		}
+		c.status, c.anyStatus = status, false
+	}
+
+	ev := r.Event
+	if ev.Instrument == "" {
+		return nil, errors.New("event.instrument is required")
+	}
+	switch {
+	case ev.Pitch != "" && len(ev.PitchRange) > 0:
+		return nil, errors.New("event.pitch and event.pitch_range are mutually exclusive")
+	case len(ev.PitchRange) > 0:
+		if len(ev.PitchRange) != 2 || ev.PitchRange[0] <= 0 || ev.PitchRange[1] <= 0 {
+			return nil, fmt.Errorf("event.pitch_range must be two positive frequencies in Hz, got %v", ev.PitchRange)
+		}
+	default:
+		name := ev.Pitch
+		if name == "" {
+			name = "mid"
+		}
+		pitch, ok := pitchRegisters[strings.ToLower(name)]
+		if !ok {
+			return nil, fmt.Errorf("unknown event.pitch %q, expected low, mid or high", ev.Pitch)
+		}
+		c.pitch = pitch
+	}
+	if len(ev.ValueRange) > 0 {
+		if len(ev.ValueRange) != 2 || ev.ValueRange[0] >= ev.ValueRange[1] {
+			return nil, fmt.Errorf("event.value_range must be [min, max] with min < max, got %v", ev.ValueRange)
+		}
+		c.valueMin, c.valueMax = ev.ValueRange[0], ev.ValueRange[1]
+	}
+	switch ev.Velocity {
+	case "", "value":
+	case "severity":
+		if r.Signal != "logs" {
+			return nil, fmt.Errorf("event.velocity severity only applies to logs rules, not %s", r.Signal)
+		}
+	default:
+		return nil, fmt.Errorf("unknown event.velocity %q, expected severity or value", ev.Velocity)
+	}
+	if ev.DurationMs < 0 {
+		return nil, errors.New("event.duration_ms must not be negative")
+	}
+	return c, nil
+}
+
+// event builds the sound event for a matched datum of resource res. value
+// is the metric value, span duration in milliseconds or log severity
+// number.
+func (c *compiledRule) event(res pcommon.Resource, value float64, severity plog.SeverityNumber) soundEvent {
+	norm := (value - c.valueMin) / (c.valueMax - c.valueMin)
+	norm = max(0, min(1, norm))
+
+	ev := soundEvent{
+		Rule:       c.Name,
+		Signal:     c.Signal,
+		Service:    resourceService(res),
+		Instrument: c.Event.Instrument,
+		Pitch:      c.pitch,
+		Velocity:   defaultEventVelocity,
+		DurationMs: c.Event.DurationMs,
+		resource:   res,
+	}
+	if len(c.Event.PitchRange) == 2 {
+		lo, hi := c.Event.PitchRange[0], c.Event.PitchRange[1]
+		ev.Pitch = lo + norm*(hi-lo)
+	}
+	switch c.Event.Velocity {
+	case "value":
+		ev.Velocity = norm
+	case "severity":
+		ev.Velocity = min(1, float64(severity)/float64(plog.SeverityNumberFatal4))
+	}
+	if ev.DurationMs == 0 {
+		ev.DurationMs = defaultEventDurationMs
+	}
+	return ev
+}
+
+// mapper evaluates mapping rules against parsed telemetry. Each span, log
+// record or metric is matched against the rules in order and produces an
+// event from the first rule it matches.
+type mapper struct {
+	rules map[string][]*compiledRule
+}
+
+func newMapper(rules []MappingRule) (*mapper, error) {
+	m := &mapper{rules: make(map[string][]*compiledRule)}
+	for i, r := range rules {
+		c, err := compileRule(r)
+		if err != nil {
+			return nil, fmt.Errorf("mappings.rules[%d]: %w", i, err)
+		}
+		m.rules[c.Signal] = append(m.rules[c.Signal], c)
+	}
+	return m, nil
+}
+
+// evaluate returns the sound events for a decoded payload.
+func (m *mapper) evaluate(d *decodedTelemetry) []soundEvent {
+	if !d.parsed || len(m.rules[d.dataType]) == 0 {
+		return nil
+	}
+	switch d.dataType {
+	case "traces":
+		return m.evaluateTraces(d.traces)
+	case "metrics":
+		return m.evaluateMetrics(d.metrics)
+	case "logs":
+		return m.evaluateLogs(d.logs)
+	}
+	return nil
+}
+
+func (m *mapper) evaluateTraces(td ptrace.Traces) []soundEvent {
+	var events []soundEvent
+	rs := td.ResourceSpans()
+	for i := 0; i < rs.Len(); i++ {
+		res := rs.At(i).Resource()
+		ss := rs.At(i).ScopeSpans()
+		for j := 0; j < ss.Len(); j++ {
+			spans := ss.At(j).Spans()
+			for k := 0; k < spans.Len(); k++ {
+				span := spans.At(k)
+				for _, rule := range m.rules["traces"] {
+					if !rule.anyStatus && span.Status().Code() != rule.status {
+						continue
+					}
+					ms := float64(span.EndTimestamp().AsTime().Sub(span.StartTimestamp().AsTime())) / float64(time.Millisecond)
+					events = append(events, rule.event(res, ms, 0))
+					break
+				}
+			}
+		}
+	}
+	return events
+}
+
+func (m *mapper) evaluateMetrics(md pmetric.Metrics) []soundEvent {
+	var events []soundEvent
+	rm := md.ResourceMetrics()
+	for i := 0; i < rm.Len(); i++ {
+		res := rm.At(i).Resource()
+		sm := rm.At(i).ScopeMetrics()
+		for j := 0; j < sm.Len(); j++ {
+			metrics := sm.At(j).Metrics()
+			for k := 0; k < metrics.Len(); k++ {
+				metric := metrics.At(k)
+				var points pmetric.NumberDataPointSlice
+				switch metric.Type() {
+				case pmetric.MetricTypeGauge:
+					points = metric.Gauge().DataPoints()
+				case pmetric.MetricTypeSum:
+					points = metric.Sum().DataPoints()
+				default:
+					continue
+				}
+				if points.Len() == 0 {
+					continue
+				}
+				for _, rule := range m.rules["metrics"] {
+					if rule.Metric != "" && rule.Metric != metric.Name() {
+						continue
+					}
+					events = append(events, rule.event(res, numberValue(points.At(points.Len()-1)), 0))
+					break
+				}
+			}
+		}
+	}
+	return events
+}
+
+func (m *mapper) evaluateLogs(ld plog.Logs) []soundEvent {
+	var events []soundEvent
+	rl := ld.ResourceLogs()
+	for i := 0; i < rl.Len(); i++ {
+		res := rl.At(i).Resource()
+		sl := rl.At(i).ScopeLogs()
+		for j := 0; j < sl.Len(); j++ {
+			records := sl.At(j).LogRecords()
+			for k := 0; k < records.Len(); k++ {
+				sev := records.At(k).SeverityNumber()
+				for _, rule := range m.rules["logs"] {
+					if sev < rule.minSeverity {
+						continue
+					}
+					events = append(events, rule.event(res, float64(sev), sev))
+					break
+				}
+			}
+		}
+	}
+	return events
+}
+
+// resourceService returns the service.name attribute of res, if any.
+func resourceService(res pcommon.Resource) string {
+	if v, ok := res.Attributes().Get(serviceNameKey); ok {
+		return v.AsString()
+	}
+	return ""
+}
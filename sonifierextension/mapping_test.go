@@ -0,0 +1,244 @@
+package sonifierextension
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// loadFixture decodes the OTLP JSON fixture testdata/mapping/name.json.
+func loadFixture(t *testing.T, name string) *decodedTelemetry {
+	t.Helper()
+	body, err := os.ReadFile(filepath.Join("testdata", "mapping", name+".json"))
+	require.NoError(t, err)
+	d := decodeTelemetry(body, "")
+	require.NoError(t, d.err)
+	require.True(t, d.parsed)
+	return d
+}
+
+func TestMapperEvaluate(t *testing.T) {
+	tests := []struct {
+		name    string
+		fixture string
+		rules   []MappingRule
+		want    []soundEvent
+	}{
+		{
+			// The error span matches the first rule, the others fall
+			// through to the catch-all, whose pitch scales with the
+			// duration: 500ms, 100ms and 2000ms clamped to 1000ms
+			name:    "traces",
+			fixture: "traces",
+			rules: []MappingRule{
+				{Name: "failures", Signal: "traces", Status: "error", Event: EventTemplate{Instrument: "gong", Pitch: "low"}},
+				{Name: "spans", Signal: "traces", Event: EventTemplate{
+					Instrument: "tick", PitchRange: []float64{200, 400}, ValueRange: []float64{0, 1000}, Velocity: "value",
+				}},
+			},
+			want: []soundEvent{
+				{Rule: "failures", Signal: "traces", Service: "checkout", Instrument: "gong", Pitch: 220, Velocity: 0.8, DurationMs: 200},
+				{Rule: "spans", Signal: "traces", Service: "checkout", Instrument: "tick", Pitch: 220, Velocity: 0.1, DurationMs: 200},
+				{Rule: "spans", Signal: "traces", Service: "checkout", Instrument: "tick", Pitch: 400, Velocity: 1, DurationMs: 200},
+			},
+		},
+		{
+			name:    "traces without a catch-all",
+			fixture: "traces",
+			rules: []MappingRule{
+				{Signal: "traces", Status: "ok", Event: EventTemplate{Instrument: "bell", Pitch: "high", DurationMs: 80}},
+			},
+			want: []soundEvent{
+				{Signal: "traces", Service: "checkout", Instrument: "bell", Pitch: 880, Velocity: 0.8, DurationMs: 80},
+			},
+		},
+		{
+			// The gauge plays its last data point, the sum its int value
+			// and the histogram nothing
+			name:    "metrics",
+			fixture: "metrics",
+			rules: []MappingRule{
+				{Name: "cpu", Signal: "metrics", Metric: "system.cpu.utilization", Event: EventTemplate{
+					Instrument: "pad", Pitch: "high", Velocity: "value", DurationMs: 50,
+				}},
+				{Name: "other", Signal: "metrics", Event: EventTemplate{
+					Instrument: "blip", PitchRange: []float64{100, 200}, ValueRange: []float64{0, 300},
+				}},
+			},
+			want: []soundEvent{
+				{Rule: "cpu", Signal: "metrics", Service: "checkout", Instrument: "pad", Pitch: 880, Velocity: 0.5, DurationMs: 50},
+				{Rule: "other", Signal: "metrics", Service: "checkout", Instrument: "blip", Pitch: 150, Velocity: 0.8, DurationMs: 200},
+			},
+		},
+		{
+			name:    "metrics rule for another metric",
+			fixture: "metrics",
+			rules: []MappingRule{
+				{Signal: "metrics", Metric: "queue.depth", Event: EventTemplate{Instrument: "pad"}},
+			},
+		},
+		{
+			// INFO is below both rules, WARN only reaches the second and
+			// ERROR and FATAL stop at the first
+			name:    "logs",
+			fixture: "logs",
+			rules: []MappingRule{
+				{Name: "errors", Signal: "logs", MinSeverity: "error", Event: EventTemplate{Instrument: "alarm", Pitch: "high", Velocity: "severity"}},
+				{Name: "warnings", Signal: "logs", MinSeverity: "WARN", Event: EventTemplate{Instrument: "chime"}},
+			},
+			want: []soundEvent{
+				{Rule: "warnings", Signal: "logs", Service: "auth", Instrument: "chime", Pitch: 440, Velocity: 0.8, DurationMs: 200},
+				{Rule: "errors", Signal: "logs", Service: "auth", Instrument: "alarm", Pitch: 880, Velocity: 17.0 / 24, DurationMs: 200},
+				{Rule: "errors", Signal: "logs", Service: "auth", Instrument: "alarm", Pitch: 880, Velocity: 21.0 / 24, DurationMs: 200},
+			},
+		},
+		{
+			// With the broader rule first, nothing reaches the narrower one
+			name:    "logs in the other order",
+			fixture: "logs",
+			rules: []MappingRule{
+				{Name: "warnings", Signal: "logs", MinSeverity: "WARN", Event: EventTemplate{Instrument: "chime"}},
+				{Name: "errors", Signal: "logs", MinSeverity: "ERROR", Event: EventTemplate{Instrument: "alarm", Velocity: "severity"}},
+			},
+			want: []soundEvent{
+				{Rule: "warnings", Signal: "logs", Service: "auth", Instrument: "chime", Pitch: 440, Velocity: 0.8, DurationMs: 200},
+				{Rule: "warnings", Signal: "logs", Service: "auth", Instrument: "chime", Pitch: 440, Velocity: 0.8, DurationMs: 200},
+				{Rule: "warnings", Signal: "logs", Service: "auth", Instrument: "chime", Pitch: 440, Velocity: 0.8, DurationMs: 200},
+			},
+		},
+		{
+			name:    "rules for another signal",
+			fixture: "logs",
+			rules: []MappingRule{
+				{Signal: "traces", Event: EventTemplate{Instrument: "tick"}},
+				{Signal: "metrics", Event: EventTemplate{Instrument: "pad"}},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m, err := newMapper(tt.rules)
+			require.NoError(t, err)
+			got := m.evaluate(loadFixture(t, tt.fixture))
+			require.Len(t, got, len(tt.want))
+			for i, want := range tt.want {
+				ev := got[i]
+				assert.Equal(t, want.Rule, ev.Rule, "event %d", i)
+				assert.Equal(t, want.Signal, ev.Signal, "event %d", i)
+				assert.Equal(t, want.Service, ev.Service, "event %d", i)
+				assert.Equal(t, want.Instrument, ev.Instrument, "event %d", i)
+				assert.InDelta(t, want.Pitch, ev.Pitch, 1e-9, "event %d", i)
+				assert.InDelta(t, want.Velocity, ev.Velocity, 1e-9, "event %d", i)
+				assert.Equal(t, want.DurationMs, ev.DurationMs, "event %d", i)
+				assert.Equal(t, want.Service, resourceService(ev.resource), "event %d", i)
+			}
+		})
+	}
+}
+
+func TestMapperUnparsed(t *testing.T) {
+	m, err := newMapper([]MappingRule{{Signal: "logs", Event: EventTemplate{Instrument: "chime"}}})
+	require.NoError(t, err)
+	assert.Empty(t, m.evaluate(decodeTelemetry([]byte(`{"hello":"world"}`), "")))
+}
+
+func TestNewMapperErrors(t *testing.T) {
+	valid := MappingRule{Signal: "logs", Event: EventTemplate{Instrument: "chime"}}
+	tests := []struct {
+		name    string
+		rule    MappingRule
+		wantErr string
+	}{
+		{
+			name:    "no signal",
+			rule:    MappingRule{Event: EventTemplate{Instrument: "chime"}},
+			wantErr: "signal is required (traces, metrics or logs)",
+		},
+		{
+			name:    "unknown signal",
+			rule:    MappingRule{Signal: "profiles", Event: EventTemplate{Instrument: "chime"}},
+			wantErr: `unknown signal "profiles", expected traces, metrics or logs`,
+		},
+		{
+			name:    "metric on a logs rule",
+			rule:    MappingRule{Signal: "logs", Metric: "cpu", Event: EventTemplate{Instrument: "chime"}},
+			wantErr: "metric only applies to metrics rules, not logs",
+		},
+		{
+			name:    "min_severity on a traces rule",
+			rule:    MappingRule{Signal: "traces", MinSeverity: "WARN", Event: EventTemplate{Instrument: "chime"}},
+			wantErr: "min_severity only applies to logs rules, not traces",
+		},
+		{
+			name:    "unknown min_severity",
+			rule:    MappingRule{Signal: "logs", MinSeverity: "LOUD", Event: EventTemplate{Instrument: "chime"}},
+			wantErr: `unknown min_severity "LOUD"`,
+		},
+		{
+			name:    "status on a metrics rule",
+			rule:    MappingRule{Signal: "metrics", Status: "error", Event: EventTemplate{Instrument: "chime"}},
+			wantErr: "status only applies to traces rules, not metrics",
+		},
+		{
+			name:    "unknown status",
+			rule:    MappingRule{Signal: "traces", Status: "failed", Event: EventTemplate{Instrument: "chime"}},
+			wantErr: `unknown status "failed", expected unset, ok or error`,
+		},
+		{
+			name:    "no instrument",
+			rule:    MappingRule{Signal: "logs"},
+			wantErr: "event.instrument is required",
+		},
+		{
+			name:    "pitch and pitch_range",
+			rule:    MappingRule{Signal: "logs", Event: EventTemplate{Instrument: "chime", Pitch: "low", PitchRange: []float64{100, 200}}},
+			wantErr: "event.pitch and event.pitch_range are mutually exclusive",
+		},
+		{
+			name:    "one-sided pitch_range",
+			rule:    MappingRule{Signal: "logs", Event: EventTemplate{Instrument: "chime", PitchRange: []float64{100}}},
+			wantErr: "event.pitch_range must be two positive frequencies in Hz, got [100]",
+		},
+		{
+			name:    "negative pitch_range",
+			rule:    MappingRule{Signal: "logs", Event: EventTemplate{Instrument: "chime", PitchRange: []float64{-100, 200}}},
+			wantErr: "event.pitch_range must be two positive frequencies in Hz, got [-100 200]",
+		},
+		{
+			name:    "unknown pitch",
+			rule:    MappingRule{Signal: "logs", Event: EventTemplate{Instrument: "chime", Pitch: "soprano"}},
+			wantErr: `unknown event.pitch "soprano", expected low, mid or high`,
+		},
+		{
+			name:    "empty value_range",
+			rule:    MappingRule{Signal: "metrics", Event: EventTemplate{Instrument: "pad", ValueRange: []float64{5, 5}}},
+			wantErr: "event.value_range must be [min, max] with min < max, got [5 5]",
+		},
+		{
+			name:    "severity velocity on a metrics rule",
+			rule:    MappingRule{Signal: "metrics", Event: EventTemplate{Instrument: "pad", Velocity: "severity"}},
+			wantErr: "event.velocity severity only applies to logs rules, not metrics",
+		},
+		{
+			name:    "unknown velocity",
+			rule:    MappingRule{Signal: "logs", Event: EventTemplate{Instrument: "chime", Velocity: "loud"}},
+			wantErr: `unknown event.velocity "loud", expected severity or value`,
+		},
+		{
+			name:    "negative duration",
+			rule:    MappingRule{Signal: "logs", Event: EventTemplate{Instrument: "chime", DurationMs: -1}},
+			wantErr: "event.duration_ms must not be negative",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// The error names the index of the bad rule
+			_, err := newMapper([]MappingRule{valid, tt.rule})
+			require.Error(t, err)
+			assert.ErrorContains(t, err, "mappings.rules[1]: "+tt.wantErr)
+		})
+	}
+}
@@ -0,0 +1,273 @@
+package sonifierextension
+
+import (
+	"errors"
+	"fmt"
+	"maps"
+	"math"
+	"net/http"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"go.uber.org/zap"
+)
+
+// midiSubprotocol is the WebSocket subprotocol of MIDI clients.
+const midiSubprotocol = "midi"
+
+// MIDI status bytes, with the channel in the low nibble.
+const (
+	midiNoteOff = 0x80
+	midiNoteOn  = 0x90
+)
+
+const (
+	// midiPercussionChannel is the General MIDI drum channel, which
+	// services are only given when pinned to it.
+	midiPercussionChannel = 10
+	// midiClientQueueSize is how many MIDI messages wait for a slow
+	// client before further ones are dropped.
+	midiClientQueueSize = 256
+)
+
+// midiRule is a MIDINote resolved to numbers.
+type midiRule struct {
+	channel int
+	note    int
+	hasNote bool
+}
+
+// midiOutput turns sound events into MIDI messages for the connected MIDI
+// clients. A nil output sends nothing.
+type midiOutput struct {
+	pinned map[string]int
+	rules  map[string]midiRule
+	// free are the channels services that aren't pinned take turns on.
+	free []int
+
+	mu       sync.Mutex
+	assigned map[string]int
+	clients  map[*midiClient]struct{}
+	closed   bool
+}
+
+// midiClient is a WebSocket connection receiving MIDI messages, written
+// by its own goroutine so a slow bridge never blocks ingest.
+type midiClient struct {
+	conn   *websocket.Conn
+	remote string
+	queue  chan []byte
+	done   chan struct{}
+	// dropped counts messages discarded while the queue was full. It is
+	// guarded by the output's mutex.
+	dropped uint64
+}
+
+func newMIDIOutput(cfg MIDIConfig) (*midiOutput, error) {
+	if !cfg.Enabled {
+		return nil, nil
+	}
+	o := &midiOutput{
+		pinned:   make(map[string]int),
+		rules:    make(map[string]midiRule),
+		assigned: make(map[string]int),
+		clients:  make(map[*midiClient]struct{}),
+	}
+	var errs []error
+	taken := map[int]bool{midiPercussionChannel: true}
+	for _, service := range slices.Sorted(maps.Keys(cfg.Channels)) {
+		channel := cfg.Channels[service]
+		if channel < 1 || channel > 16 {
+			errs = append(errs, fmt.Errorf("midi.channels.%s must be between 1 and 16, got %d", service, channel))
+			continue
+		}
+		o.pinned[service] = channel
+		taken[channel] = true
+	}
+	for channel := 1; channel <= 16; channel++ {
+		if !taken[channel] {
+			o.free = append(o.free, channel)
+		}
+	}
+	if len(o.free) == 0 {
+		// Every melodic channel is pinned, so the rest share them
+		for channel := 1; channel <= 16; channel++ {
+			if channel != midiPercussionChannel {
+				o.free = append(o.free, channel)
+			}
+		}
+	}
+	for _, name := range slices.Sorted(maps.Keys(cfg.Rules)) {
+		r := cfg.Rules[name]
+		rule := midiRule{channel: r.Channel}
+		if r.Channel < 0 || r.Channel > 16 {
+			errs = append(errs, fmt.Errorf("midi.rules.%s.channel must be between 1 and 16, or 0 to keep the service's channel", name))
+		}
+		if r.Note != "" {
+			note, err := parseNoteName(r.Note)
+			if err != nil {
+				errs = append(errs, fmt.Errorf("midi.rules.%s.note: %w", name, err))
+			}
+			rule.note, rule.hasNote = note, true
+		}
+		o.rules[name] = rule
+	}
+	if len(errs) > 0 {
+		return nil, errors.Join(errs...)
+	}
+	return o, nil
+}
+
+// channel returns the channel of service, assigning the next free one on
+// first sight.
+func (o *midiOutput) channel(service string) int {
+	if channel, ok := o.pinned[service]; ok {
+		return channel
+	}
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	channel, ok := o.assigned[service]
+	if !ok {
+		channel = o.free[len(o.assigned)%len(o.free)]
+		o.assigned[service] = channel
+	}
+	return channel
+}
+
+// play sends ev as a note on, and as a note off once its duration is up.
+// The note is the one nearest the event's pitch and its velocity follows
+// the event's, unless the event's rule fixes them.
+func (o *midiOutput) play(ev soundEvent) {
+	channel, note := o.channel(ev.Service), pitchNote(ev.Pitch)
+	if rule, ok := o.rules[ev.Rule]; ok {
+		if rule.channel > 0 {
+			channel = rule.channel
+		}
+		if rule.hasNote {
+			note = rule.note
+		}
+	}
+	// A note on with velocity 0 would be read as a note off
+	velocity := max(1, min(127, int(math.Round(ev.Velocity*127))))
+	status := byte(channel - 1)
+	o.send([]byte{midiNoteOn | status, byte(note), byte(velocity)})
+	time.AfterFunc(time.Duration(ev.DurationMs)*time.Millisecond, func() {
+		o.send([]byte{midiNoteOff | status, byte(note), 0})
+	})
+}
+
+// pitchNote returns the MIDI note nearest to a pitch in Hz, where A4 (69)
+// is 440 Hz.
+func pitchNote(hz float64) int {
+	if hz <= 0 {
+		return 60
+	}
+	return max(0, min(127, int(math.Round(69+12*math.Log2(hz/440)))))
+}
+
+// send queues msg for every MIDI client, dropping it for clients whose
+// queue is full.
+func (o *midiOutput) send(msg []byte) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	if o.closed {
+		return
+	}
+	for client := range o.clients {
+		select {
+		case client.queue <- msg:
+		default:
+			client.dropped++
+		}
+	}
+}
+
+// add registers client, and reports false once the output is closed.
+func (o *midiOutput) add(client *midiClient) bool {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	if o.closed {
+		return false
+	}
+	o.clients[client] = struct{}{}
+	return true
+}
+
+// remove unregisters client, stops its writer and returns how many
+// messages it dropped.
+func (o *midiOutput) remove(client *midiClient) uint64 {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	delete(o.clients, client)
+	close(client.done)
+	return client.dropped
+}
+
+// close disconnects every MIDI client and stops sending.
+func (o *midiOutput) close() {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.closed = true
+	msg := websocket.FormatCloseMessage(closeShutdown.code, closeShutdown.text)
+	for client := range o.clients {
+		client.conn.WriteControl(websocket.CloseMessage, msg, time.Now().Add(shutdownCloseTimeout))
+		client.conn.Close()
+	}
+}
+
+// serveMIDI streams MIDI messages to a WebSocket client that negotiated
+// the midi subprotocol, one raw message per binary frame, until it leaves.
+// Anything the client sends is ignored.
+func (s *sonifierExtension) serveMIDI(conn *websocket.Conn, r *http.Request) {
+	client := &midiClient{
+		conn:   conn,
+		remote: r.RemoteAddr,
+		queue:  make(chan []byte, midiClientQueueSize),
+		done:   make(chan struct{}),
+	}
+	if !s.midi.add(client) {
+		conn.Close()
+		return
+	}
+	s.logger.Info("MIDI client connected", zap.String("remote", client.remote))
+
+	writerDone := make(chan struct{})
+	go func() {
+		defer close(writerDone)
+		s.writeMIDI(client)
+	}()
+	defer func() {
+		dropped := s.midi.remove(client)
+		<-writerDone
+		conn.Close()
+		s.logger.Info("MIDI client disconnected", zap.String("remote", client.remote), zap.Uint64("dropped", dropped))
+	}()
+
+	for {
+		if _, _, err := conn.ReadMessage(); err != nil {
+			return
+		}
+	}
+}
+
+// writeMIDI delivers queued MIDI messages until the client leaves. A
+// failed write closes the connection, which ends the read loop.
+func (s *sonifierExtension) writeMIDI(client *midiClient) {
+	for {
+		select {
+		case <-client.done:
+			return
+		case msg := <-client.queue:
+			if timeout := s.config.WebSocket.WriteTimeout; timeout > 0 {
+				client.conn.SetWriteDeadline(time.Now().Add(timeout))
+			}
+			if err := client.conn.WriteMessage(websocket.BinaryMessage, msg); err != nil {
+				s.logger.Warn("Failed to write to MIDI client, dropping it", zap.String("remote", client.remote), zap.Error(err))
+				client.conn.Close()
+				return
+			}
+		}
+	}
+}
@@ -0,0 +1,164 @@
+package sonifierextension
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newTestMIDIClient registers a MIDI client without a connection on o, to
+// read what it is sent from its queue.
+func newTestMIDIClient(t *testing.T, o *midiOutput) *midiClient {
+	t.Helper()
+	client := &midiClient{queue: make(chan []byte, midiClientQueueSize), done: make(chan struct{})}
+	require.True(t, o.add(client))
+	return client
+}
+
+// nextMIDI returns the next message queued for client.
+func nextMIDI(t *testing.T, client *midiClient) []byte {
+	t.Helper()
+	select {
+	case msg := <-client.queue:
+		return msg
+	case <-time.After(5 * time.Second):
+		require.FailNow(t, "no MIDI message")
+		return nil
+	}
+}
+
+func TestNewMIDIOutput(t *testing.T) {
+	o, err := newMIDIOutput(MIDIConfig{Channels: map[string]int{"checkout": 1}})
+	require.NoError(t, err)
+	assert.Nil(t, o, "disabled")
+
+	_, err = newMIDIOutput(MIDIConfig{
+		Enabled:  true,
+		Channels: map[string]int{"checkout": 0, "auth": 17, "payments": 3},
+		Rules: map[string]MIDINote{
+			"errors": {Channel: 17},
+			"slow":   {Note: "H2"},
+			"fine":   {Channel: 10, Note: "D2"},
+		},
+	})
+	require.Error(t, err)
+	for _, want := range []string{
+		"midi.channels.auth must be between 1 and 16, got 17",
+		"midi.channels.checkout must be between 1 and 16, got 0",
+		"midi.rules.errors.channel must be between 1 and 16, or 0 to keep the service's channel",
+		`midi.rules.slow.note: invalid note "H2"`,
+	} {
+		assert.ErrorContains(t, err, want)
+	}
+	assert.NotContains(t, err.Error(), "payments")
+	assert.NotContains(t, err.Error(), "fine")
+}
+
+func TestMIDIChannels(t *testing.T) {
+	o, err := newMIDIOutput(MIDIConfig{Enabled: true, Channels: map[string]int{"checkout": 2, "drums": 10}})
+	require.NoError(t, err)
+
+	// Services that aren't pinned take the free channels in first-seen
+	// order, skipping pinned ones and the percussion channel
+	assert.Equal(t, 2, o.channel("checkout"))
+	assert.Equal(t, 10, o.channel("drums"))
+	var got []int
+	for _, service := range []string{"a", "b", "c", "d", "e", "f", "g", "h", "i", "j", "k", "l", "m", "n", "o"} {
+		got = append(got, o.channel(service))
+	}
+	assert.Equal(t, []int{1, 3, 4, 5, 6, 7, 8, 9, 11, 12, 13, 14, 15, 16, 1}, got)
+	assert.Equal(t, 3, o.channel("b"), "a service keeps its channel")
+
+	// With every melodic channel pinned, the rest share them
+	pinned := make(map[string]int)
+	for channel := 1; channel <= 16; channel++ {
+		pinned[strings.Repeat("s", channel)] = channel
+	}
+	o, err = newMIDIOutput(MIDIConfig{Enabled: true, Channels: pinned})
+	require.NoError(t, err)
+	assert.Equal(t, 1, o.channel("other"))
+	assert.Equal(t, 2, o.channel("another"))
+}
+
+func TestPitchNote(t *testing.T) {
+	for hz, want := range map[float64]int{
+		440:    69,
+		261.63: 60,
+		450:    69,
+		466.16: 70,
+		0:      60,
+		-1:     60,
+		1:      0,
+		1e6:    127,
+	} {
+		assert.Equal(t, want, pitchNote(hz), hz)
+	}
+}
+
+func TestMIDIPlay(t *testing.T) {
+	o, err := newMIDIOutput(MIDIConfig{
+		Enabled:  true,
+		Channels: map[string]int{"checkout": 3},
+		Rules:    map[string]MIDINote{"errors": {Channel: 10, Note: "D2"}, "warnings": {Note: "C4"}},
+	})
+	require.NoError(t, err)
+	client := newTestMIDIClient(t, o)
+
+	for _, tt := range []struct {
+		name string
+		ev   soundEvent
+		on   []byte
+	}{
+		{"nearest note", soundEvent{Service: "checkout", Pitch: 440, Velocity: 0.5, DurationMs: 1}, []byte{0x92, 69, 64}},
+		{"rule note and channel", soundEvent{Rule: "errors", Service: "checkout", Pitch: 440, Velocity: 1, DurationMs: 1}, []byte{0x99, 38, 127}},
+		{"rule note on the service's channel", soundEvent{Rule: "warnings", Service: "checkout", Pitch: 880, Velocity: 2, DurationMs: 1}, []byte{0x92, 60, 127}},
+		{"silent event", soundEvent{Service: "checkout", Pitch: 440, Velocity: 0, DurationMs: 1}, []byte{0x92, 69, 1}},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			o.play(tt.ev)
+			assert.Equal(t, tt.on, nextMIDI(t, client))
+			assert.Equal(t, []byte{tt.on[0]&0x0f | midiNoteOff, tt.on[1], 0}, nextMIDI(t, client))
+		})
+	}
+
+	// A full queue drops messages for that client only
+	for range midiClientQueueSize {
+		o.send([]byte{0x90, 60, 1})
+	}
+	other := newTestMIDIClient(t, o)
+	o.send([]byte{0x90, 61, 1})
+	assert.Equal(t, []byte{0x90, 61, 1}, nextMIDI(t, other))
+	assert.Equal(t, uint64(1), o.remove(client))
+	assert.Zero(t, o.remove(other))
+}
+
+func TestMIDIWebSocket(t *testing.T) {
+	s, base := startTestExtension(t, func(cfg *Config) {
+		cfg.Mappings.Rules = []MappingRule{{Name: "errors", Signal: "logs", Event: EventTemplate{Instrument: "alarm", DurationMs: 10}}}
+		cfg.MIDI = MIDIConfig{Enabled: true, Rules: map[string]MIDINote{"errors": {Channel: 10, Note: "D2"}}}
+	})
+	dialer := websocket.Dialer{Subprotocols: []string{midiSubprotocol}}
+	conn, _, err := dialer.Dial("ws"+strings.TrimPrefix(base, "http")+"/ws", nil)
+	require.NoError(t, err)
+	defer conn.Close()
+	require.Equal(t, midiSubprotocol, conn.Subprotocol())
+	require.NoError(t, conn.SetReadDeadline(time.Now().Add(10*time.Second)))
+	require.Eventually(t, func() bool {
+		s.midi.mu.Lock()
+		defer s.midi.mu.Unlock()
+		return len(s.midi.clients) == 1
+	}, 5*time.Second, 10*time.Millisecond)
+
+	require.NoError(t, s.Ingest(context.Background(), "logs", []byte(testLogs)))
+	for _, want := range [][]byte{{0x99, 38, 102}, {0x89, 38, 0}} {
+		kind, msg, err := conn.ReadMessage()
+		require.NoError(t, err)
+		assert.Equal(t, websocket.BinaryMessage, kind)
+		assert.Equal(t, want, msg)
+	}
+}
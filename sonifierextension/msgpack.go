@@ -0,0 +1,107 @@
+package sonifierextension
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"maps"
+	"slices"
+	"strconv"
+
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+// packedEnvelope is an envelope as sent to MessagePack clients. Its payload
+// is re-encoded value by value rather than sent as JSON bytes.
+type packedEnvelope struct {
+	envelope
+	Voice   sortedVoices   `json:"voice,omitempty"`
+	Payload msgpackPayload `json:"payload"`
+}
+
+// encodeMsgpack encodes env as a MessagePack map with the same keys as its
+// JSON form. ts uses the MessagePack timestamp extension.
+func encodeMsgpack(env *envelope) ([]byte, error) {
+	var buf bytes.Buffer
+	buf.Grow(len(env.Payload))
+	enc := msgpack.NewEncoder(&buf)
+	enc.SetCustomStructTag("json")
+	// Equal payloads encode alike
+	enc.SetSortMapKeys(true)
+	enc.UseCompactInts(true)
+	packed := &packedEnvelope{envelope: *env, Voice: sortedVoices(env.Voice), Payload: msgpackPayload(env.Payload)}
+	if err := enc.Encode(packed); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// sortedVoices is a voice map encoded in key order, which SetSortMapKeys
+// only does for maps of basic types.
+type sortedVoices map[string]voice
+
+func (v sortedVoices) EncodeMsgpack(enc *msgpack.Encoder) error {
+	if err := enc.EncodeMapLen(len(v)); err != nil {
+		return err
+	}
+	for _, name := range slices.Sorted(maps.Keys(v)) {
+		if err := enc.EncodeString(name); err != nil {
+			return err
+		}
+		if err := enc.Encode(v[name]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// msgpackPayload is a JSON payload that encodes to MessagePack as the
+// maps, arrays and numbers it holds.
+type msgpackPayload json.RawMessage
+
+func (p msgpackPayload) EncodeMsgpack(enc *msgpack.Encoder) error {
+	dec := json.NewDecoder(bytes.NewReader(p))
+	dec.UseNumber()
+	var v any
+	if err := dec.Decode(&v); err != nil {
+		return fmt.Errorf("failed to decode payload: %w", err)
+	}
+	v, err := convertNumbers(v)
+	if err != nil {
+		return err
+	}
+	return enc.Encode(v)
+}
+
+// convertNumbers replaces the json.Numbers in a value decoded with
+// UseNumber by integers where they fit and floats otherwise.
+func convertNumbers(v any) (any, error) {
+	var err error
+	switch v := v.(type) {
+	case json.Number:
+		if i, err := v.Int64(); err == nil {
+			return i, nil
+		}
+		if u, err := strconv.ParseUint(v.String(), 10, 64); err == nil {
+			return u, nil
+		}
+		f, err := v.Float64()
+		if err != nil {
+			return nil, fmt.Errorf("invalid number %q", v)
+		}
+		return f, nil
+	case []any:
+		for i := range v {
+			if v[i], err = convertNumbers(v[i]); err != nil {
+				return nil, err
+			}
+		}
+	case map[string]any:
+		for k := range v {
+			if v[k], err = convertNumbers(v[k]); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return v, nil
+}
@@ -0,0 +1,111 @@
+package sonifierextension
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+// fullEnvelope returns an envelope with every field set.
+func fullEnvelope() *envelope {
+	channel, pan, bound := 3, -0.25, 0.5
+	return &envelope{
+		Type:        "metrics",
+		Seq:         1 << 40,
+		Timestamp:   time.Date(2025, 3, 1, 12, 30, 0, 123456789, time.UTC),
+		TTLMs:       1500,
+		Payload:     json.RawMessage(`{"resourceMetrics":[{"count":17,"big":18446744073709551615,"ratio":0.5,"neg":-40,"ok":true,"none":null,"tags":["a","b"]}]}`),
+		Service:     "checkout",
+		Environment: "prod",
+		Channel:     &channel,
+		Dropped:     4,
+		Pan:         &pan,
+		Seen:        &seenCounts{Traces: 1, Metrics: 2, Logs: 300},
+		Normalized: []normalizedValue{
+			{Metric: "cpu", Attributes: map[string]any{"core": int64(0)}, Value: 0.75, Rate: true, Normalized: 0.5, Min: 0.25, Max: 1.25},
+			{Metric: "mem", Value: 10, Normalized: 1, Min: 0, Max: 10},
+		},
+		Chord: []chord{{
+			Metric:     "latency",
+			Attributes: map[string]any{"route": "/cart"},
+			Notes: []chordNote{
+				{Bucket: 0, UpperBound: &bound, Count: 7, Note: 60, Pitch: 261.63, Velocity: 1},
+				{Bucket: 1, Count: 2, Note: 62, Pitch: 293.66, Velocity: 0.3},
+			},
+		}},
+		Voice: map[string]voice{
+			"GET /cart": {Instrument: "piano", Color: "#e91e63"},
+			"ERROR":     {Instrument: "gong"},
+		},
+		Routes: []string{"alerts"},
+	}
+}
+
+// unpack decodes a MessagePack message into generic values.
+func unpack(t *testing.T, packed []byte) map[string]any {
+	t.Helper()
+	var decoded map[string]any
+	require.NoError(t, msgpack.Unmarshal(packed, &decoded))
+	return decoded
+}
+
+func TestMsgpackRoundTrip(t *testing.T) {
+	for name, env := range map[string]*envelope{
+		"every field":     fullEnvelope(),
+		"required fields": {Type: "logs", Seq: 1, Timestamp: time.Unix(1, 0).UTC(), Payload: json.RawMessage(`{"resourceLogs":[]}`)},
+	} {
+		t.Run(name, func(t *testing.T) {
+			packed, err := encodeMsgpack(env)
+			require.NoError(t, err)
+			decoded := unpack(t, packed)
+
+			// The keys and values are those of the JSON form, and ts is a
+			// native timestamp
+			ts, ok := decoded["ts"].(time.Time)
+			require.True(t, ok, "ts decoded as %T", decoded["ts"])
+			assert.True(t, env.Timestamp.Equal(ts))
+			want, err := json.Marshal(env)
+			require.NoError(t, err)
+			got, err := json.Marshal(decoded)
+			require.NoError(t, err)
+			assert.JSONEq(t, string(want), string(got))
+			assert.NotContains(t, decoded, "Routes")
+		})
+	}
+}
+
+func TestMsgpackTypes(t *testing.T) {
+	packed, err := encodeMsgpack(fullEnvelope())
+	require.NoError(t, err)
+	decoded := unpack(t, packed)
+
+	assert.EqualValues(t, uint64(1<<40), decoded["seq"])
+	assert.EqualValues(t, 1500, decoded["ttl_ms"])
+	assert.EqualValues(t, 3, decoded["channel"])
+	assert.Equal(t, -0.25, decoded["pan"])
+
+	// Payload numbers keep their integer or float type
+	payload := decoded["payload"].(map[string]any)["resourceMetrics"].([]any)[0].(map[string]any)
+	assert.IsType(t, int8(0), payload["count"])
+	assert.EqualValues(t, 17, payload["count"])
+	assert.Equal(t, uint64(18446744073709551615), payload["big"])
+	assert.Equal(t, 0.5, payload["ratio"])
+	assert.EqualValues(t, -40, payload["neg"])
+	assert.Equal(t, true, payload["ok"])
+	assert.Nil(t, payload["none"])
+	assert.Equal(t, []any{"a", "b"}, payload["tags"])
+
+	// Equal envelopes encode alike, whatever the map order
+	again, err := encodeMsgpack(fullEnvelope())
+	require.NoError(t, err)
+	assert.Equal(t, packed, again)
+}
+
+func TestMsgpackInvalidPayload(t *testing.T) {
+	_, err := encodeMsgpack(&envelope{Type: "unknown", Payload: json.RawMessage(`{"a":`)})
+	assert.ErrorContains(t, err, "failed to decode payload")
+}
@@ -0,0 +1,148 @@
+package sonifierextension
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/collector/pdata/pmetric"
+)
+
+// normalizeBuckets is how many buckets a series' rolling window is split
+// into. Values older than the window drop out one bucket at a time.
+const normalizeBuckets = 10
+
+// normalizedValue is one number data point scaled against the rolling
+// range of its series.
+type normalizedValue struct {
+	Metric     string         `json:"metric"`
+	Attributes map[string]any `json:"attributes,omitempty"`
+	// Value is the data point's value, or its per-second rate for
+	// monotonic sums.
+	Value float64 `json:"value"`
+	Rate  bool    `json:"rate,omitempty"`
+	// Normalized is Value scaled from Min to Max into 0-1, or 0.5 while
+	// the series has seen a single distinct value.
+	Normalized float64 `json:"normalized"`
+	Min        float64 `json:"min"`
+	Max        float64 `json:"max"`
+}
+
+// rangeBucket holds the extremes observed during one slice of the window.
+// epoch is the slice's index since the Unix epoch, plus one so that the
+// zero bucket is empty.
+type rangeBucket struct {
+	epoch    int64
+	min, max float64
+}
+
+// valueRange is the rolling range of one series.
+type valueRange struct {
+	counterRate
+	buckets [normalizeBuckets]rangeBucket
+}
+
+// normalizer tracks the rolling min and max of each metric series and
+// scales data point values into 0-1. A nil normalizer adds nothing.
+type normalizer struct {
+	slice time.Duration
+
+	mu     sync.Mutex
+	series *seriesCache[valueRange]
+}
+
+func newNormalizer(cfg NormalizeConfig) (*normalizer, error) {
+	if !cfg.Enabled {
+		return nil, nil
+	}
+	if cfg.Window < normalizeBuckets*time.Millisecond {
+		return nil, fmt.Errorf("normalize.window must be at least %v, got %v", normalizeBuckets*time.Millisecond, cfg.Window)
+	}
+	if cfg.MaxSeries <= 0 {
+		return nil, errors.New("normalize.max_series must be positive")
+	}
+	return &normalizer{
+		slice:  cfg.Window / normalizeBuckets,
+		series: newSeriesCache[valueRange](cfg.MaxSeries),
+	}, nil
+}
+
+// applies reports whether d is a metrics payload to normalize.
+func (n *normalizer) applies(d *decodedTelemetry) bool {
+	return n != nil && d.parsed && d.dataType == "metrics"
+}
+
+// observe records the gauge and sum data points in md at now and returns
+// their normalized values. Counter points without a rate are skipped, and
+// one after a restart also starts its series' range over. NaN and infinite
+// values are skipped without touching their series, so they can't spoil
+// its range.
+func (n *normalizer) observe(md pmetric.Metrics, now time.Time) []normalizedValue {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	var values []normalizedValue
+	numberPoints(md, func(p numberPoint) {
+		if !finite(numberValue(p.dp)) {
+			return
+		}
+		s := n.series.get(p.key)
+		value, ok := s.value(p)
+		if !ok {
+			clear(s.buckets[:])
+			return
+		}
+		if !finite(value) {
+			return
+		}
+		lo, hi := s.record(value, now, n.slice)
+		normalized := 0.5
+		if hi > lo {
+			normalized = (value - lo) / (hi - lo)
+		}
+		values = append(values, normalizedValue{
+			Metric:     p.metric,
+			Attributes: p.dp.Attributes().AsRaw(),
+			Value:      value,
+			Rate:       p.counter,
+			Normalized: normalized,
+			Min:        lo,
+			Max:        hi,
+		})
+	})
+	return values
+}
+
+// reset forgets every series.
+func (n *normalizer) reset() {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	n.series.reset()
+}
+
+// record adds value to the bucket for now and returns the min and max over
+// the buckets still in the window.
+func (r *valueRange) record(value float64, now time.Time, slice time.Duration) (lo, hi float64) {
+	epoch := now.UnixNano()/int64(slice) + 1
+	b := &r.buckets[epoch%normalizeBuckets]
+	if b.epoch != epoch {
+		*b = rangeBucket{epoch: epoch, min: value, max: value}
+	}
+	b.min, b.max = min(b.min, value), max(b.max, value)
+
+	lo, hi = value, value
+	for _, b := range r.buckets {
+		if b.epoch > epoch-normalizeBuckets && b.epoch <= epoch {
+			lo, hi = min(lo, b.min), max(hi, b.max)
+		}
+	}
+	return lo, hi
+}
+
+// finite reports whether v is neither NaN nor infinite.
+func finite(v float64) bool {
+	return !math.IsNaN(v) && !math.IsInf(v, 0)
+}
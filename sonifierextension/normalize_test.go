@@ -0,0 +1,136 @@
+package sonifierextension
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/collector/pdata/pcommon"
+	"go.opentelemetry.io/collector/pdata/pmetric"
+)
+
+// testGauge returns a payload with one point of the gauge name.
+func testGauge(name string, value float64) pmetric.Metrics {
+	md := pmetric.NewMetrics()
+	m := md.ResourceMetrics().AppendEmpty().ScopeMetrics().AppendEmpty().Metrics().AppendEmpty()
+	m.SetName(name)
+	m.SetEmptyGauge().DataPoints().AppendEmpty().SetDoubleValue(value)
+	return md
+}
+
+// testCounter returns a payload with one point of the monotonic sum name,
+// taken at ts since start, or without a start time when start is zero.
+func testCounter(name string, temporality pmetric.AggregationTemporality, value float64, start, ts time.Time) pmetric.Metrics {
+	md := pmetric.NewMetrics()
+	m := md.ResourceMetrics().AppendEmpty().ScopeMetrics().AppendEmpty().Metrics().AppendEmpty()
+	m.SetName(name)
+	sum := m.SetEmptySum()
+	sum.SetIsMonotonic(true)
+	sum.SetAggregationTemporality(temporality)
+	dp := sum.DataPoints().AppendEmpty()
+	dp.SetDoubleValue(value)
+	if !start.IsZero() {
+		dp.SetStartTimestamp(pcommon.NewTimestampFromTime(start))
+	}
+	dp.SetTimestamp(pcommon.NewTimestampFromTime(ts))
+	return md
+}
+
+func newTestNormalizer(t *testing.T, maxSeries int) *normalizer {
+	t.Helper()
+	n, err := newNormalizer(NormalizeConfig{Enabled: true, Window: 10 * time.Second, MaxSeries: maxSeries})
+	require.NoError(t, err)
+	return n
+}
+
+// observeOne observes md at now and returns its single normalized value.
+func observeOne(t *testing.T, n *normalizer, md pmetric.Metrics, now time.Time) normalizedValue {
+	t.Helper()
+	values := n.observe(md, now)
+	require.Len(t, values, 1)
+	return values[0]
+}
+
+func TestNormalizerRollingWindow(t *testing.T) {
+	n := newTestNormalizer(t, 10)
+	start := time.Unix(1000, 0)
+
+	v := observeOne(t, n, testGauge("load", 10), start)
+	assert.Equal(t, 0.5, v.Normalized, "a single value is in the middle")
+	v = observeOne(t, n, testGauge("load", 20), start.Add(time.Second))
+	assert.Equal(t, normalizedValue{Metric: "load", Attributes: map[string]any{}, Value: 20, Normalized: 1, Min: 10, Max: 20}, v)
+
+	// The first second's bucket drops out of the 10s window
+	v = observeOne(t, n, testGauge("load", 15), start.Add(10*time.Second))
+	assert.Equal(t, 15.0, v.Min)
+	assert.Equal(t, 20.0, v.Max)
+	assert.Equal(t, 0.0, v.Normalized)
+
+	// Then the second's
+	v = observeOne(t, n, testGauge("load", 17), start.Add(11*time.Second))
+	assert.Equal(t, 15.0, v.Min)
+	assert.Equal(t, 17.0, v.Max)
+	assert.Equal(t, 1.0, v.Normalized)
+}
+
+func TestNormalizerCounterRates(t *testing.T) {
+	n := newTestNormalizer(t, 10)
+	start := time.Unix(1000, 0)
+	cumulative := pmetric.AggregationTemporalityCumulative
+
+	// A cumulative counter's first point has no rate
+	assert.Empty(t, n.observe(testCounter("requests", cumulative, 100, start, start.Add(time.Second)), start))
+	v := observeOne(t, n, testCounter("requests", cumulative, 160, start, start.Add(3*time.Second)), start)
+	assert.True(t, v.Rate)
+	assert.Equal(t, 30.0, v.Value)
+	v = observeOne(t, n, testCounter("requests", cumulative, 200, start, start.Add(4*time.Second)), start)
+	assert.Equal(t, 40.0, v.Value)
+	assert.Equal(t, 30.0, v.Min)
+
+	// A restart starts the rate and range over
+	assert.Empty(t, n.observe(testCounter("requests", cumulative, 5, start, start.Add(5*time.Second)), start))
+	v = observeOne(t, n, testCounter("requests", cumulative, 15, start, start.Add(6*time.Second)), start)
+	assert.Equal(t, normalizedValue{Metric: "requests", Attributes: map[string]any{}, Value: 10, Rate: true, Normalized: 0.5, Min: 10, Max: 10}, v)
+
+	// A delta point is its own rate, if it has a start time
+	v = observeOne(t, n, testCounter("bytes", pmetric.AggregationTemporalityDelta, 50, start, start.Add(5*time.Second)), start)
+	assert.Equal(t, 10.0, v.Value)
+	assert.Empty(t, n.observe(testCounter("bytes", pmetric.AggregationTemporalityDelta, 50, time.Time{}, start), start))
+}
+
+func TestNormalizerEvictsLeastRecentlyUsed(t *testing.T) {
+	n := newTestNormalizer(t, 2)
+	now := time.Unix(1000, 0)
+
+	observeOne(t, n, testGauge("a", 1), now)
+	observeOne(t, n, testGauge("b", 1), now)
+	observeOne(t, n, testGauge("a", 3), now)
+	// c takes b's place, which was used least recently
+	observeOne(t, n, testGauge("c", 1), now)
+
+	v := observeOne(t, n, testGauge("a", 1.5), now)
+	assert.Equal(t, 0.25, v.Normalized, "a kept its range")
+	v = observeOne(t, n, testGauge("b", 5), now)
+	assert.Equal(t, normalizedValue{Metric: "b", Attributes: map[string]any{}, Value: 5, Normalized: 0.5, Min: 5, Max: 5}, v, "b starts over")
+}
+
+func TestNormalizerSkipsNonFinite(t *testing.T) {
+	n := newTestNormalizer(t, 10)
+	now := time.Unix(1000, 0)
+
+	observeOne(t, n, testGauge("load", 1), now)
+	for _, bad := range []float64{math.NaN(), math.Inf(1), math.Inf(-1)} {
+		assert.Empty(t, n.observe(testGauge("load", bad), now))
+	}
+	v := observeOne(t, n, testGauge("load", 3), now)
+	assert.Equal(t, normalizedValue{Metric: "load", Attributes: map[string]any{}, Value: 3, Normalized: 1, Min: 1, Max: 3}, v)
+
+	// Nor do they reset a counter's rate
+	cumulative := pmetric.AggregationTemporalityCumulative
+	assert.Empty(t, n.observe(testCounter("requests", cumulative, 10, now, now.Add(time.Second)), now))
+	assert.Empty(t, n.observe(testCounter("requests", cumulative, math.NaN(), now, now.Add(2*time.Second)), now))
+	v = observeOne(t, n, testCounter("requests", cumulative, 20, now, now.Add(3*time.Second)), now)
+	assert.Equal(t, 5.0, v.Value)
+}
@@ -0,0 +1,162 @@
+package sonifierextension
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+	"time"
+
+	"go.opentelemetry.io/collector/pdata/pcommon"
+	"go.opentelemetry.io/collector/pdata/ptrace"
+)
+
+// note is one span translated for playback: how long it lasts, where it
+// sits in its trace and whether it failed, so clients can play it without
+// parsing OTLP.
+type note struct {
+	TraceID    string  `json:"trace_id"`
+	Service    string  `json:"service,omitempty"`
+	Name       string  `json:"name"`
+	Kind       string  `json:"kind"`
+	DurationMs float64 `json:"duration_ms"`
+	// Length is the index of the note length bucket the duration falls in.
+	Length int  `json:"length"`
+	Error  bool `json:"error,omitempty"`
+	// Depth is the number of the span's ancestors in the same payload.
+	Depth int `json:"depth"`
+}
+
+// notesPayload is the payload of a {"type":"notes"} message.
+type notesPayload struct {
+	Notes []note `json:"notes"`
+}
+
+// signalType returns the signal a message type carries: traces for notes,
+// and the type itself otherwise. Notes share the traces rate limit and
+// subscriptions to traces.
+func signalType(dataType string) string {
+	if dataType == "notes" {
+		return "traces"
+	}
+	return dataType
+}
+
+// noter translates trace payloads into notes. A nil noter leaves traces as
+// they are.
+type noter struct {
+	buckets    []time.Duration
+	forwardRaw bool
+}
+
+func newNoter(cfg NotesConfig) (*noter, error) {
+	if !cfg.Enabled {
+		return nil, nil
+	}
+	if len(cfg.LengthBuckets) == 0 {
+		return nil, errors.New("notes.length_buckets must not be empty when notes are enabled")
+	}
+	for i, b := range cfg.LengthBuckets {
+		if b <= 0 {
+			return nil, fmt.Errorf("notes.length_buckets must be positive, got %v", b)
+		}
+		if i > 0 && b <= cfg.LengthBuckets[i-1] {
+			return nil, fmt.Errorf("notes.length_buckets must be increasing, got %v after %v", b, cfg.LengthBuckets[i-1])
+		}
+	}
+	return &noter{buckets: cfg.LengthBuckets, forwardRaw: cfg.ForwardRaw}, nil
+}
+
+// applies reports whether d is a trace payload to broadcast as notes.
+func (n *noter) applies(d *decodedTelemetry) bool {
+	return n != nil && d.parsed && d.dataType == "traces"
+}
+
+// length returns the bucket index of a span duration.
+func (n *noter) length(d time.Duration) int {
+	for i, b := range n.buckets {
+		if d <= b {
+			return i
+		}
+	}
+	return len(n.buckets)
+}
+
+// encode returns the notes payload for the spans in td, using depths from
+// spanDepths of the whole payload td was split from.
+func (n *noter) encode(td ptrace.Traces, depths map[pcommon.SpanID]int) (json.RawMessage, error) {
+	payload := notesPayload{Notes: make([]note, 0, td.SpanCount())}
+	rs := td.ResourceSpans()
+	for i := 0; i < rs.Len(); i++ {
+		var service string
+		if v, ok := rs.At(i).Resource().Attributes().Get(serviceNameKey); ok {
+			service = v.AsString()
+		}
+		ss := rs.At(i).ScopeSpans()
+		for j := 0; j < ss.Len(); j++ {
+			spans := ss.At(j).Spans()
+			for k := 0; k < spans.Len(); k++ {
+				span := spans.At(k)
+				duration := span.EndTimestamp().AsTime().Sub(span.StartTimestamp().AsTime())
+				duration = max(duration, 0)
+				payload.Notes = append(payload.Notes, note{
+					TraceID:    span.TraceID().String(),
+					Service:    service,
+					Name:       span.Name(),
+					Kind:       strings.ToLower(span.Kind().String()),
+					DurationMs: math.Round(float64(duration)/float64(time.Microsecond)) / 1000,
+					Length:     n.length(duration),
+					Error:      span.Status().Code() == ptrace.StatusCodeError,
+					Depth:      depths[span.SpanID()],
+				})
+			}
+		}
+	}
+	return json.Marshal(payload)
+}
+
+// spanDepths returns how many ancestors each span in td has within td. A
+// span whose parent isn't in the payload counts as a child of an unseen
+// root, at depth 1.
+func spanDepths(td ptrace.Traces) map[pcommon.SpanID]int {
+	parents := make(map[pcommon.SpanID]pcommon.SpanID, td.SpanCount())
+	rs := td.ResourceSpans()
+	for i := 0; i < rs.Len(); i++ {
+		ss := rs.At(i).ScopeSpans()
+		for j := 0; j < ss.Len(); j++ {
+			spans := ss.At(j).Spans()
+			for k := 0; k < spans.Len(); k++ {
+				parents[spans.At(k).SpanID()] = spans.At(k).ParentSpanID()
+			}
+		}
+	}
+
+	depths := make(map[pcommon.SpanID]int, len(parents))
+	var depth func(id pcommon.SpanID, hops int) int
+	depth = func(id pcommon.SpanID, hops int) int {
+		if d, ok := depths[id]; ok {
+			return d
+		}
+		parent, ok := parents[id]
+		var d int
+		switch {
+		case !ok:
+			// An unseen span is the root of the ones pointing at it
+			return -1
+		case parent.IsEmpty():
+			d = 0
+		case hops > len(parents):
+			// Parent links loop; stop rather than recurse forever
+			d = 0
+		default:
+			d = max(depth(parent, hops+1), 0) + 1
+		}
+		depths[id] = d
+		return d
+	}
+	for id := range parents {
+		depth(id, 0)
+	}
+	return depths
+}
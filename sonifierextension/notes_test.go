@@ -0,0 +1,98 @@
+package sonifierextension
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/collector/pdata/pcommon"
+	"go.opentelemetry.io/collector/pdata/ptrace"
+)
+
+var testLengthBuckets = []time.Duration{10 * time.Millisecond, 50 * time.Millisecond, 250 * time.Millisecond, time.Second}
+
+func TestNewNoter(t *testing.T) {
+	n, err := newNoter(NotesConfig{LengthBuckets: testLengthBuckets})
+	require.NoError(t, err)
+	assert.Nil(t, n, "disabled")
+	assert.False(t, n.applies(loadFixture(t, "traces")))
+
+	tests := []struct {
+		name    string
+		buckets []time.Duration
+		wantErr string
+	}{
+		{"no buckets", nil, "notes.length_buckets must not be empty when notes are enabled"},
+		{"zero bucket", []time.Duration{0, time.Second}, "notes.length_buckets must be positive, got 0s"},
+		{"decreasing buckets", []time.Duration{time.Second, time.Millisecond}, "notes.length_buckets must be increasing, got 1ms after 1s"},
+		{"repeated bucket", []time.Duration{time.Second, time.Second}, "notes.length_buckets must be increasing, got 1s after 1s"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := newNoter(NotesConfig{Enabled: true, LengthBuckets: tt.buckets})
+			assert.EqualError(t, err, tt.wantErr)
+		})
+	}
+}
+
+func TestNoterLength(t *testing.T) {
+	n, err := newNoter(NotesConfig{Enabled: true, LengthBuckets: testLengthBuckets})
+	require.NoError(t, err)
+	for d, want := range map[time.Duration]int{
+		0:                      0,
+		10 * time.Millisecond:  0,
+		11 * time.Millisecond:  1,
+		250 * time.Millisecond: 2,
+		time.Second:            3,
+		time.Minute:            4,
+	} {
+		assert.Equal(t, want, n.length(d), d)
+	}
+}
+
+func TestNoterEncode(t *testing.T) {
+	n, err := newNoter(NotesConfig{Enabled: true, LengthBuckets: testLengthBuckets})
+	require.NoError(t, err)
+	d := loadFixture(t, "traces")
+	require.True(t, n.applies(d))
+	assert.False(t, n.applies(loadFixture(t, "metrics")))
+
+	raw, err := n.encode(d.traces, spanDepths(d.traces))
+	require.NoError(t, err)
+	var payload notesPayload
+	require.NoError(t, json.Unmarshal(raw, &payload))
+	const traceID = "0102030405060708090a0b0c0d0e0f10"
+	assert.Equal(t, []note{
+		{TraceID: traceID, Service: "checkout", Name: "POST /pay", Kind: "unspecified", DurationMs: 500, Length: 3, Error: true},
+		{TraceID: traceID, Service: "checkout", Name: "GET /cart", Kind: "unspecified", DurationMs: 100, Length: 2},
+		{TraceID: traceID, Service: "checkout", Name: "GET /catalog", Kind: "unspecified", DurationMs: 2000, Length: 4},
+	}, payload.Notes)
+}
+
+func TestSpanDepths(t *testing.T) {
+	td := ptrace.NewTraces()
+	spans := td.ResourceSpans().AppendEmpty().ScopeSpans().AppendEmpty().Spans()
+	id := func(b byte) pcommon.SpanID { return pcommon.SpanID{b} }
+	addSpan := func(span, parent pcommon.SpanID) {
+		s := spans.AppendEmpty()
+		s.SetSpanID(span)
+		s.SetParentSpanID(parent)
+	}
+	// Children come before their parents, and 4's parent 9 is missing
+	addSpan(id(3), id(2))
+	addSpan(id(2), id(1))
+	addSpan(id(1), pcommon.SpanID{})
+	addSpan(id(5), id(4))
+	addSpan(id(4), id(9))
+
+	assert.Equal(t, map[pcommon.SpanID]int{id(1): 0, id(2): 1, id(3): 2, id(4): 1, id(5): 2}, spanDepths(td))
+
+	// Looping parent links end rather than recursing forever
+	loop := ptrace.NewTraces()
+	spans = loop.ResourceSpans().AppendEmpty().ScopeSpans().AppendEmpty().Spans()
+	addSpan(id(1), id(2))
+	addSpan(id(2), id(1))
+	assert.Len(t, spanDepths(loop), 2)
+}
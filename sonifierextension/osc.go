@@ -0,0 +1,215 @@
+package sonifierextension
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"net"
+	"regexp"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// oscPlaceholders are the fields an osc.address template can use.
+var oscPlaceholders = []string{"signal", "service", "rule", "instrument"}
+
+var oscPlaceholder = regexp.MustCompile(`\{([^{}]*)\}`)
+
+const (
+	// maxOSCPacket keeps bundles well below the size of a UDP datagram, so
+	// they aren't dropped or fragmented on the way. Events that don't fit
+	// in one bundle are sent in several.
+	maxOSCPacket = 8192
+	// oscImmediately is the OSC time tag meaning "as soon as received".
+	oscImmediately = 1
+)
+
+// oscArgs is the type tag of every event message: pitch in Hz, velocity
+// from 0 to 1, duration in milliseconds and the instrument.
+const oscArgs = ",ffis"
+
+// oscOutput sends sound events as OSC messages over UDP. A nil output
+// sends nothing.
+type oscOutput struct {
+	cfg  OSCConfig
+	conn net.Conn
+
+	mu sync.Mutex
+	// window and sent count the messages sent in the current one-second
+	// window, for max_messages_per_sec.
+	window  time.Time
+	sent    int
+	dropped uint64
+	// failing is set while writes fail, so only the first failure is
+	// logged.
+	failing bool
+}
+
+func newOSCOutput(cfg OSCConfig) (*oscOutput, error) {
+	if !cfg.Enabled {
+		return nil, nil
+	}
+	var errs []error
+	if _, _, err := net.SplitHostPort(cfg.Target); err != nil {
+		errs = append(errs, fmt.Errorf("osc.target must be host:port: %w", err))
+	}
+	if !strings.HasPrefix(cfg.Address, "/") {
+		errs = append(errs, fmt.Errorf("osc.address must start with /, got %q", cfg.Address))
+	}
+	for _, match := range oscPlaceholder.FindAllStringSubmatch(cfg.Address, -1) {
+		if !slices.Contains(oscPlaceholders, match[1]) {
+			errs = append(errs, fmt.Errorf("osc.address: unknown placeholder %s, expected one of {%s}",
+				match[0], strings.Join(oscPlaceholders, "}, {")))
+		}
+	}
+	if cfg.MaxMessagesPerSec < 0 {
+		errs = append(errs, errors.New("osc.max_messages_per_sec must not be negative"))
+	}
+	if len(errs) > 0 {
+		return nil, errors.Join(errs...)
+	}
+	return &oscOutput{cfg: cfg}, nil
+}
+
+// open resolves the target and opens the UDP socket events are sent from.
+func (o *oscOutput) open() error {
+	conn, err := net.Dial("udp", o.cfg.Target)
+	if err != nil {
+		return err
+	}
+	o.conn = conn
+	return nil
+}
+
+// close closes the socket and returns how many events the rate limit
+// dropped.
+func (o *oscOutput) close() uint64 {
+	if o.conn != nil {
+		o.conn.Close()
+	}
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	return o.dropped
+}
+
+// address fills the address template in with ev's fields. Characters OSC
+// reserves for patterns are replaced, so a service name can't change
+// which methods the message matches.
+func (o *oscOutput) address(ev soundEvent) string {
+	return oscPlaceholder.ReplaceAllStringFunc(o.cfg.Address, func(placeholder string) string {
+		var value string
+		switch strings.Trim(placeholder, "{}") {
+		case "signal":
+			value = ev.Signal
+		case "service":
+			value = ev.Service
+		case "rule":
+			value = ev.Rule
+		case "instrument":
+			value = ev.Instrument
+		}
+		if value == "" {
+			return "unknown"
+		}
+		return strings.Map(func(r rune) rune {
+			if r <= ' ' || r >= 0x7f || strings.ContainsRune("#*,/?[]{}!", r) {
+				return '_'
+			}
+			return r
+		}, value)
+	})
+}
+
+// allow takes n messages out of the current second's budget and returns
+// how many of them may be sent.
+func (o *oscOutput) allow(n int, now time.Time) int {
+	limit := o.cfg.MaxMessagesPerSec
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	if limit == 0 {
+		return n
+	}
+	if now.Sub(o.window) >= time.Second {
+		o.window, o.sent = now, 0
+	}
+	allowed := max(0, min(n, limit-o.sent))
+	o.sent += allowed
+	o.dropped += uint64(n - allowed)
+	return allowed
+}
+
+// send encodes events as OSC messages and writes them to the target, one
+// datagram per message, or as bundles when osc.bundle is set so the
+// events of a payload land together. Events over the rate limit are
+// dropped.
+func (o *oscOutput) send(events []soundEvent, logger *zap.Logger) {
+	events = events[:o.allow(len(events), time.Now())]
+	if len(events) == 0 {
+		return
+	}
+	var packets [][]byte
+	if o.cfg.Bundle {
+		var elements [][]byte
+		size := 16
+		for _, ev := range events {
+			msg := o.message(ev)
+			if len(elements) > 0 && size+4+len(msg) > maxOSCPacket {
+				packets = append(packets, encodeOSCBundle(elements))
+				elements, size = nil, 16
+			}
+			elements = append(elements, msg)
+			size += 4 + len(msg)
+		}
+		packets = append(packets, encodeOSCBundle(elements))
+	} else {
+		for _, ev := range events {
+			packets = append(packets, o.message(ev))
+		}
+	}
+
+	for _, packet := range packets {
+		_, err := o.conn.Write(packet)
+		o.mu.Lock()
+		changed := (err != nil) != o.failing
+		o.failing = err != nil
+		o.mu.Unlock()
+		if changed && err != nil {
+			logger.Warn("Failed to send OSC, is anything listening?", zap.String("target", o.cfg.Target), zap.Error(err))
+		} else if changed {
+			logger.Info("Sending OSC again", zap.String("target", o.cfg.Target))
+		}
+	}
+}
+
+// message encodes ev as an OSC message.
+func (o *oscOutput) message(ev soundEvent) []byte {
+	b := appendOSCString(nil, o.address(ev))
+	b = appendOSCString(b, oscArgs)
+	b = binary.BigEndian.AppendUint32(b, math.Float32bits(float32(ev.Pitch)))
+	b = binary.BigEndian.AppendUint32(b, math.Float32bits(float32(ev.Velocity)))
+	b = binary.BigEndian.AppendUint32(b, uint32(int32(ev.DurationMs)))
+	return appendOSCString(b, ev.Instrument)
+}
+
+// encodeOSCBundle wraps messages in a bundle to be dispatched at once.
+func encodeOSCBundle(messages [][]byte) []byte {
+	b := appendOSCString(nil, "#bundle")
+	b = binary.BigEndian.AppendUint64(b, oscImmediately)
+	for _, msg := range messages {
+		b = binary.BigEndian.AppendUint32(b, uint32(len(msg)))
+		b = append(b, msg...)
+	}
+	return b
+}
+
+// appendOSCString appends s null-terminated and padded to a multiple of
+// four bytes.
+func appendOSCString(b []byte, s string) []byte {
+	b = append(b, s...)
+	return append(b, make([]byte, 4-len(s)%4)...)
+}
@@ -0,0 +1,159 @@
+package sonifierextension
+
+import (
+	"bytes"
+	"encoding/binary"
+	"math"
+	"net"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// oscTestTraces has two spans of a service whose name has a slash, one
+// of them failed.
+const oscTestTraces = `{"resourceSpans":[{"resource":{"attributes":[{"key":"service.name","value":{"stringValue":"check/out"}}]},"scopeSpans":[{"spans":[` +
+	`{"traceId":"0102030405060708090a0b0c0d0e0f10","spanId":"0102030405060708","name":"GET /cart","startTimeUnixNano":"1","endTimeUnixNano":"2000000","status":{"code":2}},` +
+	`{"traceId":"0102030405060708090a0b0c0d0e0f10","spanId":"0102030405060709","parentSpanId":"0102030405060708","name":"SELECT","startTimeUnixNano":"1","endTimeUnixNano":"1000000"}]}]}]}`
+
+// oscMessage is a decoded OSC event message.
+type oscMessage struct {
+	address    string
+	typeTag    string
+	pitch      float32
+	velocity   float32
+	durationMs int32
+	instrument string
+}
+
+// readOSCString reads a null-terminated, four-byte aligned string.
+func readOSCString(t *testing.T, b []byte) (string, []byte) {
+	t.Helper()
+	end := bytes.IndexByte(b, 0)
+	require.GreaterOrEqual(t, end, 0, "unterminated OSC string")
+	padded := (end/4 + 1) * 4
+	require.LessOrEqual(t, padded, len(b))
+	return string(b[:end]), b[padded:]
+}
+
+func decodeOSCMessage(t *testing.T, b []byte) oscMessage {
+	t.Helper()
+	var msg oscMessage
+	msg.address, b = readOSCString(t, b)
+	msg.typeTag, b = readOSCString(t, b)
+	require.GreaterOrEqual(t, len(b), 12)
+	msg.pitch = math.Float32frombits(binary.BigEndian.Uint32(b))
+	msg.velocity = math.Float32frombits(binary.BigEndian.Uint32(b[4:]))
+	msg.durationMs = int32(binary.BigEndian.Uint32(b[8:]))
+	msg.instrument, b = readOSCString(t, b[12:])
+	assert.Empty(t, b, "trailing bytes")
+	return msg
+}
+
+// decodeOSCBundle returns the messages of an immediate bundle.
+func decodeOSCBundle(t *testing.T, b []byte) []oscMessage {
+	t.Helper()
+	tag, b := readOSCString(t, b)
+	require.Equal(t, "#bundle", tag)
+	require.GreaterOrEqual(t, len(b), 8)
+	assert.Equal(t, uint64(oscImmediately), binary.BigEndian.Uint64(b))
+	b = b[8:]
+	var msgs []oscMessage
+	for len(b) > 0 {
+		require.GreaterOrEqual(t, len(b), 4)
+		size := int(binary.BigEndian.Uint32(b))
+		require.LessOrEqual(t, 4+size, len(b))
+		msgs = append(msgs, decodeOSCMessage(t, b[4:4+size]))
+		b = b[4+size:]
+	}
+	return msgs
+}
+
+// startOSCExtension starts an extension sending OSC to a UDP socket it
+// returns, with a rule playing every span.
+func startOSCExtension(t *testing.T, bundle bool) (*net.UDPConn, string) {
+	t.Helper()
+	conn, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	require.NoError(t, err)
+	t.Cleanup(func() { conn.Close() })
+
+	_, url := startTestExtension(t, func(cfg *Config) {
+		cfg.OSC = OSCConfig{Enabled: true, Target: conn.LocalAddr().String(), Address: "/otel/{signal}/{service}/{rule}", Bundle: bundle}
+		cfg.Mappings.Rules = []MappingRule{{
+			Name:   "spans",
+			Signal: "traces",
+			Event:  EventTemplate{Instrument: "bell", Pitch: "high", DurationMs: 250},
+		}}
+	})
+	return conn, url
+}
+
+func readOSCPacket(t *testing.T, conn *net.UDPConn) []byte {
+	t.Helper()
+	require.NoError(t, conn.SetReadDeadline(time.Now().Add(5*time.Second)))
+	buf := make([]byte, maxOSCPacket)
+	n, err := conn.Read(buf)
+	require.NoError(t, err)
+	return buf[:n]
+}
+
+func postOSCTraces(t *testing.T, url string) {
+	t.Helper()
+	resp, err := http.Post(url+"/v1/traces", "application/json", strings.NewReader(oscTestTraces))
+	require.NoError(t, err)
+	resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+}
+
+func TestOSCMessages(t *testing.T) {
+	conn, url := startOSCExtension(t, false)
+	postOSCTraces(t, url)
+
+	want := oscMessage{
+		// The service's slash can't add an address level
+		address:    "/otel/traces/check_out/spans",
+		typeTag:    oscArgs,
+		pitch:      880,
+		velocity:   float32(defaultEventVelocity),
+		durationMs: 250,
+		instrument: "bell",
+	}
+	for range 2 {
+		assert.Equal(t, want, decodeOSCMessage(t, readOSCPacket(t, conn)))
+	}
+}
+
+func TestOSCBundle(t *testing.T) {
+	conn, url := startOSCExtension(t, true)
+	postOSCTraces(t, url)
+
+	msgs := decodeOSCBundle(t, readOSCPacket(t, conn))
+	require.Len(t, msgs, 2)
+	for _, msg := range msgs {
+		assert.Equal(t, "/otel/traces/check_out/spans", msg.address)
+		assert.Equal(t, "bell", msg.instrument)
+	}
+}
+
+func TestOSCAddressPlaceholders(t *testing.T) {
+	o, err := newOSCOutput(OSCConfig{Enabled: true, Target: "127.0.0.1:57120", Address: "/{signal}/{service}/{instrument}"})
+	require.NoError(t, err)
+	assert.Equal(t, "/logs/unknown/p_a_n_o", o.address(soundEvent{Signal: "logs", Instrument: "p*a?n o"}))
+
+	_, err = newOSCOutput(OSCConfig{Enabled: true, Target: "127.0.0.1:57120", Address: "/{host}"})
+	assert.ErrorContains(t, err, "unknown placeholder {host}")
+}
+
+func TestOSCRateLimit(t *testing.T) {
+	o, err := newOSCOutput(OSCConfig{Enabled: true, Target: "127.0.0.1:57120", Address: "/x", MaxMessagesPerSec: 3})
+	require.NoError(t, err)
+	now := time.Now()
+	assert.Equal(t, 2, o.allow(2, now))
+	assert.Equal(t, 1, o.allow(2, now.Add(500*time.Millisecond)))
+	assert.Equal(t, 2, o.allow(2, now.Add(time.Second)))
+	assert.Equal(t, uint64(1), o.close())
+}
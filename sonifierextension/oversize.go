@@ -0,0 +1,223 @@
+package sonifierextension
+
+import (
+	"encoding/json"
+
+	"go.opentelemetry.io/collector/pdata/plog"
+	"go.opentelemetry.io/collector/pdata/pmetric"
+	"go.opentelemetry.io/collector/pdata/ptrace"
+	"go.uber.org/zap"
+)
+
+// Ways of handling messages over broadcast.max_message_bytes.
+const (
+	oversizeSplit     = "split"
+	oversizeSummarize = "summarize"
+)
+
+// envelopeOverhead bounds the size of an envelope's fields other than its
+// payload, normalized values and chords, so that most messages can be
+// checked without encoding them.
+const envelopeOverhead = 1024
+
+// msgpackGrowth bounds how many times larger a message is as MessagePack
+// than as JSON. It is usually smaller, but a float takes 9 bytes however
+// short it is in JSON.
+const msgpackGrowth = 3
+
+// oversizePayload is the payload of a message that was too large to
+// send, in place of the telemetry.
+type oversizePayload struct {
+	Oversize oversizeSummary `json:"oversize"`
+}
+
+type oversizeSummary struct {
+	// Bytes is the encoded size of the message that was replaced, in the
+	// larger of JSON and MessagePack.
+	Bytes int `json:"bytes"`
+	Limit int `json:"limit"`
+	// Items is the number of spans, data points or log records it held.
+	Items int `json:"items,omitempty"`
+}
+
+// splitOversize splits the parts whose JSON is over the size limit. payload
+// is the body of a single part.
+func (s *sonifierExtension) splitOversize(parts []*decodedTelemetry, payload json.RawMessage) []*decodedTelemetry {
+	limit := s.config.Broadcast.MaxMessageBytes
+	if limit == 0 || s.config.Broadcast.Oversize != oversizeSplit {
+		return parts
+	}
+	if len(parts) == 1 && len(payload) <= s.splitBudget(parts[0]) {
+		return parts
+	}
+	var fitted []*decodedTelemetry
+	for _, part := range parts {
+		if len(parts) == 1 {
+			part.json = payload
+		} else if part.json == nil && part.parsed {
+			if err := part.encodeJSON(); err != nil {
+				s.logger.Warn("Failed to encode resource payload", zap.String("type", part.dataType), zap.Error(err))
+				continue
+			}
+		}
+		pieces := splitPart(part, s.splitBudget(part))
+		if len(pieces) > 1 {
+			s.stats.oversizeSplit.Add(1)
+		}
+		fitted = append(fitted, pieces...)
+	}
+	return fitted
+}
+
+// splitBudget returns the payload size a message for d can have and stay
+// under the size limit. Normalized values and chords repeat the attributes
+// of each data point, so they are left as much room as the payload.
+func (s *sonifierExtension) splitBudget(d *decodedTelemetry) int {
+	budget := s.config.Broadcast.MaxMessageBytes - envelopeOverhead
+	if s.normalizer.applies(d) || s.chords.applies(d) {
+		budget /= 2
+	}
+	return budget
+}
+
+// splitPart halves d along its spans, data points or log records until the
+// JSON of each piece is within limit. A single item that is still too
+// large is left as is.
+func splitPart(d *decodedTelemetry, limit int) []*decodedTelemetry {
+	n := splitItems(d)
+	if len(d.json) <= limit || n <= 1 {
+		return []*decodedTelemetry{d}
+	}
+	var pieces []*decodedTelemetry
+	for _, half := range [][2]int{{0, n / 2}, {n / 2, n}} {
+		piece := itemRange(d, half[0], half[1])
+		if err := piece.encodeJSON(); err != nil {
+			continue
+		}
+		pieces = append(pieces, splitPart(piece, limit)...)
+	}
+	return pieces
+}
+
+// splitItems returns the number of units d can be split into: spans, data
+// points or log records.
+func splitItems(d *decodedTelemetry) int {
+	if !d.parsed {
+		return 0
+	}
+	switch d.dataType {
+	case "traces":
+		return d.traces.SpanCount()
+	case "metrics":
+		return d.metrics.DataPointCount()
+	case "logs":
+		return d.logs.LogRecordCount()
+	}
+	return 0
+}
+
+// itemRange returns a copy of d with only its items from lo up to hi, in
+// the order splitItems counts them, dropping resources, scopes and
+// metrics left empty.
+func itemRange(d *decodedTelemetry, lo, hi int) *decodedTelemetry {
+	i := 0
+	drop := func() bool {
+		keep := i >= lo && i < hi
+		i++
+		return !keep
+	}
+	piece := &decodedTelemetry{dataType: d.dataType, parsed: true, format: d.format}
+	switch d.dataType {
+	case "traces":
+		piece.traces = ptrace.NewTraces()
+		d.traces.CopyTo(piece.traces)
+		piece.traces.ResourceSpans().RemoveIf(func(rs ptrace.ResourceSpans) bool {
+			rs.ScopeSpans().RemoveIf(func(ss ptrace.ScopeSpans) bool {
+				ss.Spans().RemoveIf(func(ptrace.Span) bool { return drop() })
+				return ss.Spans().Len() == 0
+			})
+			return rs.ScopeSpans().Len() == 0
+		})
+	case "metrics":
+		piece.metrics = pmetric.NewMetrics()
+		d.metrics.CopyTo(piece.metrics)
+		piece.metrics.ResourceMetrics().RemoveIf(func(rm pmetric.ResourceMetrics) bool {
+			rm.ScopeMetrics().RemoveIf(func(sm pmetric.ScopeMetrics) bool {
+				sm.Metrics().RemoveIf(func(m pmetric.Metric) bool {
+					return removeDataPoints(m, drop) == 0
+				})
+				return sm.Metrics().Len() == 0
+			})
+			return rm.ScopeMetrics().Len() == 0
+		})
+	case "logs":
+		piece.logs = plog.NewLogs()
+		d.logs.CopyTo(piece.logs)
+		piece.logs.ResourceLogs().RemoveIf(func(rl plog.ResourceLogs) bool {
+			rl.ScopeLogs().RemoveIf(func(sl plog.ScopeLogs) bool {
+				sl.LogRecords().RemoveIf(func(plog.LogRecord) bool { return drop() })
+				return sl.LogRecords().Len() == 0
+			})
+			return rl.ScopeLogs().Len() == 0
+		})
+	}
+	return piece
+}
+
+// removeDataPoints removes the data points of m for which drop returns
+// true and returns how many are left.
+func removeDataPoints(m pmetric.Metric, drop func() bool) int {
+	switch m.Type() {
+	case pmetric.MetricTypeGauge:
+		m.Gauge().DataPoints().RemoveIf(func(pmetric.NumberDataPoint) bool { return drop() })
+		return m.Gauge().DataPoints().Len()
+	case pmetric.MetricTypeSum:
+		m.Sum().DataPoints().RemoveIf(func(pmetric.NumberDataPoint) bool { return drop() })
+		return m.Sum().DataPoints().Len()
+	case pmetric.MetricTypeHistogram:
+		m.Histogram().DataPoints().RemoveIf(func(pmetric.HistogramDataPoint) bool { return drop() })
+		return m.Histogram().DataPoints().Len()
+	case pmetric.MetricTypeExponentialHistogram:
+		m.ExponentialHistogram().DataPoints().RemoveIf(func(pmetric.ExponentialHistogramDataPoint) bool { return drop() })
+		return m.ExponentialHistogram().DataPoints().Len()
+	case pmetric.MetricTypeSummary:
+		m.Summary().DataPoints().RemoveIf(func(pmetric.SummaryDataPoint) bool { return drop() })
+		return m.Summary().DataPoints().Len()
+	}
+	return 0
+}
+
+// fitEnvelope returns env, or a summary in its place when it is over the
+// size limit as JSON or as MessagePack. part is the telemetry env was
+// built from.
+func (s *sonifierExtension) fitEnvelope(env *envelope, part *decodedTelemetry) *envelope {
+	limit := s.config.Broadcast.MaxMessageBytes
+	if limit == 0 {
+		return env
+	}
+	size := len(env.Payload) + envelopeOverhead
+	if len(env.Normalized) > 0 || len(env.Chord) > 0 || len(env.Voice) > 0 || size > limit {
+		data, err := json.Marshal(env)
+		if err != nil {
+			return env
+		}
+		size = len(data)
+	}
+	if size <= limit && size*msgpackGrowth > limit {
+		if packed, err := encodeMsgpack(env); err == nil {
+			size = max(size, len(packed))
+		}
+	}
+	if size <= limit {
+		return env
+	}
+
+	summary := oversizeSummary{Bytes: size, Limit: limit, Items: splitItems(part)}
+	payload, _ := json.Marshal(oversizePayload{Oversize: summary})
+	s.stats.oversizeSummarized.Add(1)
+	s.logger.Debug("Summarized oversized message", zap.String("type", env.Type), zap.Int("bytes", size), zap.Int("limit", limit))
+	fitted := *env
+	fitted.Payload = payload
+	fitted.Normalized, fitted.Chord, fitted.Voice = nil, nil, nil
+	return &fitted
+}
@@ -0,0 +1,144 @@
+package sonifierextension
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/collector/pdata/plog"
+	"go.opentelemetry.io/collector/pdata/ptrace"
+)
+
+const testMessageLimit = 8192
+
+// newOversizeExtension returns an extension capping messages at
+// testMessageLimit bytes, handling larger ones with oversize, that sends
+// traces both as notes and raw.
+func newOversizeExtension(t *testing.T, oversize string) *sonifierExtension {
+	return newTestExtension(t, func(cfg *Config) {
+		cfg.Broadcast.MaxMessageBytes = testMessageLimit
+		cfg.Broadcast.Oversize = oversize
+		cfg.Notes.Enabled = true
+		cfg.Notes.ForwardRaw = true
+	})
+}
+
+// largeTraces returns a payload of checkout with n spans of about 200
+// bytes each.
+func largeTraces(t *testing.T, n int) (*decodedTelemetry, []byte) {
+	t.Helper()
+	td := ptrace.NewTraces()
+	rs := td.ResourceSpans().AppendEmpty()
+	rs.Resource().Attributes().PutStr(serviceNameKey, "checkout")
+	spans := rs.ScopeSpans().AppendEmpty().Spans()
+	for i := range n {
+		span := spans.AppendEmpty()
+		span.SetName(strings.Repeat("x", 100))
+		span.SetTraceID([16]byte{1})
+		span.SetSpanID([8]byte{byte(i), byte(i >> 8), 1})
+	}
+	payload, err := (&ptrace.JSONMarshaler{}).MarshalTraces(td)
+	require.NoError(t, err)
+	return decodeTelemetry(payload, "traces"), payload
+}
+
+// assertFits checks that every envelope is within testMessageLimit as JSON
+// and as MessagePack.
+func assertFits(t *testing.T, envs []*envelope) {
+	t.Helper()
+	for i, env := range envs {
+		data, err := json.Marshal(env)
+		require.NoError(t, err)
+		assert.LessOrEqual(t, len(data), testMessageLimit, "envelope %d as JSON", i)
+		packed, err := encodeMsgpack(env)
+		require.NoError(t, err)
+		assert.LessOrEqual(t, len(packed), testMessageLimit, "envelope %d as MessagePack", i)
+	}
+}
+
+func TestOversizeSplit(t *testing.T) {
+	s := newOversizeExtension(t, oversizeSplit)
+	decoded, payload := largeTraces(t, 200)
+	require.Greater(t, len(payload), 4*testMessageLimit)
+
+	envs := s.resourceEnvelopes(decoded, payload)
+	require.Greater(t, len(envs), 4)
+	assertFits(t, envs)
+
+	spans, notes := 0, 0
+	for _, env := range envs {
+		assert.Equal(t, "checkout", env.Service)
+		if env.Type == "notes" {
+			var payload struct {
+				Notes []json.RawMessage `json:"notes"`
+			}
+			require.NoError(t, json.Unmarshal(env.Payload, &payload))
+			notes += len(payload.Notes)
+			continue
+		}
+		piece := decodeTelemetry(env.Payload, "traces")
+		require.True(t, piece.parsed)
+		spans += piece.traces.SpanCount()
+	}
+	assert.Equal(t, 200, spans, "every span is sent once")
+	assert.Equal(t, 200, notes, "every span is played once")
+	assert.Equal(t, uint64(1), s.stats.oversizeSplit.Load())
+	assert.Zero(t, s.stats.oversizeSummarized.Load())
+}
+
+func TestOversizeSummarize(t *testing.T) {
+	s := newOversizeExtension(t, oversizeSummarize)
+	decoded, payload := largeTraces(t, 200)
+
+	// The notes and the raw payload are each summarized
+	envs := s.resourceEnvelopes(decoded, payload)
+	require.Len(t, envs, 2)
+	assertFits(t, envs)
+
+	for _, env := range envs {
+		var summary oversizePayload
+		require.NoError(t, json.Unmarshal(env.Payload, &summary))
+		assert.Greater(t, summary.Oversize.Bytes, testMessageLimit, env.Type)
+		assert.Equal(t, testMessageLimit, summary.Oversize.Limit)
+		assert.Equal(t, 200, summary.Oversize.Items)
+	}
+	assert.Equal(t, uint64(2), s.stats.oversizeSummarized.Load())
+}
+
+func TestOversizeSplitSummarizesSingleItem(t *testing.T) {
+	s := newOversizeExtension(t, oversizeSplit)
+	ld := plog.NewLogs()
+	rl := ld.ResourceLogs().AppendEmpty()
+	rl.Resource().Attributes().PutStr(serviceNameKey, "auth")
+	rl.ScopeLogs().AppendEmpty().LogRecords().AppendEmpty().Body().SetStr(strings.Repeat("boom ", 4*testMessageLimit))
+	payload, err := (&plog.JSONMarshaler{}).MarshalLogs(ld)
+	require.NoError(t, err)
+
+	envs := s.resourceEnvelopes(decodeTelemetry(payload, "logs"), payload)
+	require.Len(t, envs, 1)
+	assertFits(t, envs)
+	assert.Contains(t, string(envs[0].Payload), `"items":1`)
+}
+
+func TestOversizeMsgpack(t *testing.T) {
+	s := newOversizeExtension(t, oversizeSummarize)
+	// Short floats take 4 bytes each in JSON and 9 in MessagePack
+	floats := strings.TrimSuffix(strings.Repeat("1.5,", 1000), ",")
+	env := &envelope{Type: "metrics", Payload: json.RawMessage(`{"values":[` + floats + `]}`)}
+	data, err := json.Marshal(env)
+	require.NoError(t, err)
+	require.Less(t, len(data), testMessageLimit)
+	packed, err := encodeMsgpack(env)
+	require.NoError(t, err)
+	require.Greater(t, len(packed), testMessageLimit)
+
+	fitted := s.fitEnvelope(env, &decodedTelemetry{})
+	assert.Contains(t, string(fitted.Payload), `"oversize"`)
+	assertFits(t, []*envelope{fitted})
+
+	// Within the limit either way, it is left alone
+	small := &envelope{Type: "metrics", Payload: json.RawMessage(`{"values":[1.5]}`)}
+	assert.Same(t, small, s.fitEnvelope(small, &decodedTelemetry{}))
+}
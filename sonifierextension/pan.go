@@ -0,0 +1,156 @@
+package sonifierextension
+
+import (
+	"fmt"
+	"hash/fnv"
+	"math"
+
+	"go.opentelemetry.io/collector/pdata/pcommon"
+	"go.opentelemetry.io/collector/pdata/pmetric"
+)
+
+const (
+	panMappingHash  = "hash"
+	panMappingFixed = "fixed"
+)
+
+// panner places broadcast messages in the stereo field from the value of
+// one attribute, so messages sharing a value always pan alike.
+type panner struct {
+	attribute string
+	mapping   string
+	positions map[string]float64
+}
+
+func newPanner(cfg PanConfig) (*panner, error) {
+	if cfg.Attribute == "" {
+		if len(cfg.Positions) > 0 {
+			return nil, fmt.Errorf("pan.positions requires pan.attribute")
+		}
+		return nil, nil
+	}
+	p := &panner{attribute: cfg.Attribute, mapping: cfg.Mapping, positions: cfg.Positions}
+	switch cfg.Mapping {
+	case "":
+		p.mapping = panMappingHash
+	case panMappingHash:
+	case panMappingFixed:
+		if len(cfg.Positions) == 0 {
+			return nil, fmt.Errorf("pan.positions is required with the fixed mapping")
+		}
+	default:
+		return nil, fmt.Errorf("unknown pan.mapping %q, expected hash or fixed", cfg.Mapping)
+	}
+	for value, pos := range cfg.Positions {
+		if pos < -1 || pos > 1 {
+			return nil, fmt.Errorf("pan.positions[%s] must be between -1 and 1, got %v", value, pos)
+		}
+	}
+	return p, nil
+}
+
+// position maps an attribute value to a pan between -1 (left) and 1
+// (right). Hashing spreads values evenly; the fixed mapping centers values
+// without a position.
+func (p *panner) position(value string) float64 {
+	if p.mapping == panMappingFixed {
+		return p.positions[value]
+	}
+	h := fnv.New32a()
+	h.Write([]byte(value))
+	return float64(h.Sum32())/math.MaxUint32*2 - 1
+}
+
+// pan returns the pan for a parsed payload from the first span, data point
+// or log record carrying the attribute, falling back to resource
+// attributes. It reports false when no item has the attribute.
+func (p *panner) pan(d *decodedTelemetry) (float64, bool) {
+	value, ok := p.find(d)
+	if !ok {
+		return 0, false
+	}
+	return p.position(value), true
+}
+
+func (p *panner) find(d *decodedTelemetry) (string, bool) {
+	if !d.parsed {
+		return "", false
+	}
+	var resources []pcommon.Map
+	switch d.dataType {
+	case "traces":
+		rs := d.traces.ResourceSpans()
+		for i := 0; i < rs.Len(); i++ {
+			resources = append(resources, rs.At(i).Resource().Attributes())
+			ss := rs.At(i).ScopeSpans()
+			for j := 0; j < ss.Len(); j++ {
+				spans := ss.At(j).Spans()
+				for k := 0; k < spans.Len(); k++ {
+					if v, ok := spans.At(k).Attributes().Get(p.attribute); ok {
+						return v.AsString(), true
+					}
+				}
+			}
+		}
+	case "metrics":
+		rm := d.metrics.ResourceMetrics()
+		for i := 0; i < rm.Len(); i++ {
+			resources = append(resources, rm.At(i).Resource().Attributes())
+			sm := rm.At(i).ScopeMetrics()
+			for j := 0; j < sm.Len(); j++ {
+				metrics := sm.At(j).Metrics()
+				for k := 0; k < metrics.Len(); k++ {
+					if v, ok := p.findInMetric(metrics.At(k)); ok {
+						return v, true
+					}
+				}
+			}
+		}
+	case "logs":
+		rl := d.logs.ResourceLogs()
+		for i := 0; i < rl.Len(); i++ {
+			resources = append(resources, rl.At(i).Resource().Attributes())
+			sl := rl.At(i).ScopeLogs()
+			for j := 0; j < sl.Len(); j++ {
+				records := sl.At(j).LogRecords()
+				for k := 0; k < records.Len(); k++ {
+					if v, ok := records.At(k).Attributes().Get(p.attribute); ok {
+						return v.AsString(), true
+					}
+				}
+			}
+		}
+	}
+	for _, attrs := range resources {
+		if v, ok := attrs.Get(p.attribute); ok {
+			return v.AsString(), true
+		}
+	}
+	return "", false
+}
+
+// findInMetric returns the attribute from the metric's first data point
+// that has it.
+func (p *panner) findInMetric(m pmetric.Metric) (string, bool) {
+	var attrs []pcommon.Map
+	switch m.Type() {
+	case pmetric.MetricTypeGauge:
+		for i := 0; i < m.Gauge().DataPoints().Len(); i++ {
+			attrs = append(attrs, m.Gauge().DataPoints().At(i).Attributes())
+		}
+	case pmetric.MetricTypeSum:
+		for i := 0; i < m.Sum().DataPoints().Len(); i++ {
+			attrs = append(attrs, m.Sum().DataPoints().At(i).Attributes())
+		}
+	case pmetric.MetricTypeHistogram:
+		for i := 0; i < m.Histogram().DataPoints().Len(); i++ {
+			attrs = append(attrs, m.Histogram().DataPoints().At(i).Attributes())
+		}
+	}
+	for _, a := range attrs {
+		if v, ok := a.Get(p.attribute); ok {
+			return v.AsString(), true
+		}
+	}
+	return "", false
+}
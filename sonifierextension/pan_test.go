@@ -0,0 +1,113 @@
+package sonifierextension
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewPanner(t *testing.T) {
+	p, err := newPanner(PanConfig{})
+	require.NoError(t, err)
+	assert.Nil(t, p, "disabled")
+
+	for _, tt := range []struct {
+		name    string
+		cfg     PanConfig
+		wantErr string
+	}{
+		{"positions without attribute", PanConfig{Positions: map[string]float64{"a": 1}}, "pan.positions requires pan.attribute"},
+		{"fixed without positions", PanConfig{Attribute: "http.route", Mapping: "fixed"}, "pan.positions is required with the fixed mapping"},
+		{"unknown mapping", PanConfig{Attribute: "http.route", Mapping: "random"}, `unknown pan.mapping "random", expected hash or fixed`},
+		{"position out of range", PanConfig{Attribute: "http.route", Mapping: "fixed", Positions: map[string]float64{"/cart": -1.5}}, "pan.positions[/cart] must be between -1 and 1, got -1.5"},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := newPanner(tt.cfg)
+			assert.EqualError(t, err, tt.wantErr)
+		})
+	}
+}
+
+func TestPannerPosition(t *testing.T) {
+	p, err := newPanner(PanConfig{Attribute: "http.route"})
+	require.NoError(t, err)
+	assert.Equal(t, panMappingHash, p.mapping, "hash by default")
+	seen := make(map[float64]bool)
+	for _, route := range []string{"/cart", "/checkout", "/login", "/search", ""} {
+		pos := p.position(route)
+		assert.GreaterOrEqual(t, pos, -1.0, route)
+		assert.LessOrEqual(t, pos, 1.0, route)
+		assert.Equal(t, pos, p.position(route), "a value always pans alike")
+		seen[pos] = true
+	}
+	assert.Len(t, seen, 5, "values spread out")
+
+	p, err = newPanner(PanConfig{Attribute: "http.route", Mapping: "fixed", Positions: map[string]float64{"/cart": -1, "/checkout": 0.5}})
+	require.NoError(t, err)
+	assert.Equal(t, -1.0, p.position("/cart"))
+	assert.Equal(t, 0.5, p.position("/checkout"))
+	assert.Zero(t, p.position("/login"), "values without a position are centered")
+}
+
+func TestPannerPan(t *testing.T) {
+	p, err := newPanner(PanConfig{Attribute: "host.name", Mapping: "fixed", Positions: map[string]float64{
+		"span-host": -1, "point-host": -0.5, "record-host": 0.5, "resource-host": 1,
+	}})
+	require.NoError(t, err)
+	for _, tt := range []struct {
+		name, signal, payload string
+		want                  float64
+		ok                    bool
+	}{
+		{
+			name:    "span attribute before the resource",
+			signal:  "traces",
+			payload: `{"resourceSpans":[{"resource":{"attributes":[{"key":"host.name","value":{"stringValue":"resource-host"}}]},"scopeSpans":[{"spans":[{"traceId":"0102030405060708090a0b0c0d0e0f10","spanId":"0102030405060708","name":"a"},{"traceId":"0102030405060708090a0b0c0d0e0f10","spanId":"0102030405060709","name":"b","attributes":[{"key":"host.name","value":{"stringValue":"span-host"}}]}]}]}]}`,
+			want:    -1,
+			ok:      true,
+		},
+		{
+			name:    "resource when no span has it",
+			signal:  "traces",
+			payload: `{"resourceSpans":[{"resource":{"attributes":[{"key":"host.name","value":{"stringValue":"resource-host"}}]},"scopeSpans":[{"spans":[{"traceId":"0102030405060708090a0b0c0d0e0f10","spanId":"0102030405060708","name":"a"}]}]}]}`,
+			want:    1,
+			ok:      true,
+		},
+		{
+			name:    "histogram data point",
+			signal:  "metrics",
+			payload: `{"resourceMetrics":[{"scopeMetrics":[{"metrics":[{"name":"a","gauge":{"dataPoints":[{"asDouble":1}]}},{"name":"b","histogram":{"dataPoints":[{"count":"1","attributes":[{"key":"host.name","value":{"stringValue":"point-host"}}]}]}}]}]}]}`,
+			want:    -0.5,
+			ok:      true,
+		},
+		{
+			name:    "log record",
+			signal:  "logs",
+			payload: `{"resourceLogs":[{"scopeLogs":[{"logRecords":[{"body":{"stringValue":"a"},"attributes":[{"key":"host.name","value":{"stringValue":"record-host"}}]}]}]}]}`,
+			want:    0.5,
+			ok:      true,
+		},
+		{
+			name:    "unknown value",
+			signal:  "logs",
+			payload: `{"resourceLogs":[{"resource":{"attributes":[{"key":"host.name","value":{"stringValue":"other-host"}}]},"scopeLogs":[{"logRecords":[{"body":{"stringValue":"a"}}]}]}]}`,
+			ok:      true,
+		},
+		{
+			name:    "no attribute",
+			signal:  "logs",
+			payload: testLogs,
+		},
+		{
+			name:    "unparsed",
+			payload: `{"hello":"world"}`,
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := p.pan(decodeTelemetry([]byte(tt.payload), tt.signal))
+			assert.Equal(t, tt.ok, ok)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
@@ -0,0 +1,177 @@
+package sonifierextension
+
+import (
+	"bytes"
+	"fmt"
+	"maps"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// metricsPrefix starts every metric name on /metrics, matching the names
+// the collector gives the self-metrics.
+const metricsPrefix = "otelcol_sonifier_"
+
+// promWriter encodes metrics in the Prometheus text exposition format.
+type promWriter struct {
+	buf bytes.Buffer
+}
+
+// family starts a metric family, writing its HELP and TYPE lines.
+func (p *promWriter) family(name, kind, help string) {
+	fmt.Fprintf(&p.buf, "# HELP %s%s %s\n# TYPE %s%s %s\n", metricsPrefix, name, help, metricsPrefix, name, kind)
+}
+
+// sample writes one sample. labels alternate names and values.
+func (p *promWriter) sample(name string, value float64, labels ...string) {
+	p.buf.WriteString(metricsPrefix)
+	p.buf.WriteString(name)
+	if len(labels) > 0 {
+		p.buf.WriteByte('{')
+		for i := 0; i+1 < len(labels); i += 2 {
+			if i > 0 {
+				p.buf.WriteByte(',')
+			}
+			p.buf.WriteString(labels[i])
+			p.buf.WriteString(`="`)
+			p.buf.WriteString(promLabelEscaper.Replace(labels[i+1]))
+			p.buf.WriteByte('"')
+		}
+		p.buf.WriteByte('}')
+	}
+	p.buf.WriteByte(' ')
+	p.buf.WriteString(strconv.FormatFloat(value, 'g', -1, 64))
+	p.buf.WriteByte('\n')
+}
+
+// single writes a family holding a single unlabeled sample.
+func (p *promWriter) single(name, kind, help string, value float64) {
+	p.family(name, kind, help)
+	p.sample(name, value)
+}
+
+var promLabelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+// promBool returns 1 for true and 0 for false.
+func promBool(b bool) float64 {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+// handleMetrics serves the /stats counters in the Prometheus text format,
+// for scraping without the collector's telemetry pipeline. Like /stats it
+// only reads atomics, so scrapes don't slow ingestion down.
+func (s *sonifierExtension) handleMetrics(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	st := s.stats
+	var p promWriter
+	p.single("start_time_seconds", "gauge", "Time the extension started, in seconds since the epoch.",
+		float64(st.started.UnixNano())/float64(time.Second))
+
+	p.family("telemetry_received_total", "counter", "Payloads received.")
+	for _, dataType := range statsTypes {
+		p.sample("telemetry_received_total", float64(st.signals[dataType].received.Load()), "type", dataType)
+	}
+	p.family("telemetry_last_received_timestamp_seconds", "gauge", "Time the last payload was received, in seconds since the epoch.")
+	for _, dataType := range statsTypes {
+		if ns := st.signals[dataType].lastReceived.Load(); ns != 0 {
+			p.sample("telemetry_last_received_timestamp_seconds", float64(ns)/float64(time.Second), "type", dataType)
+		}
+	}
+	p.family("filter_passed_total", "counter", "Spans, metrics or log records passed by filters.")
+	for _, dataType := range statsTypes {
+		p.sample("filter_passed_total", float64(st.signals[dataType].passed.Load()), "type", dataType)
+	}
+	p.family("filter_dropped_total", "counter", "Spans, metrics or log records dropped by filters.")
+	for _, dataType := range statsTypes {
+		p.sample("filter_dropped_total", float64(st.signals[dataType].dropped.Load()), "type", dataType)
+	}
+	p.family("telemetry_rate_limited_total", "counter", "Payloads rejected by ingest_limits.")
+	for _, dataType := range statsTypes {
+		p.sample("telemetry_rate_limited_total", float64(st.signals[dataType].limited.Load()), "type", dataType)
+	}
+	p.family("rate_limited_forwarded_total", "counter", "Messages forwarded by broadcast.max_messages_per_sec.")
+	for _, dataType := range statsTypes {
+		p.sample("rate_limited_forwarded_total", float64(st.signals[dataType].throttle.forwarded.Load()), "type", dataType)
+	}
+	p.family("rate_limited_dropped_total", "counter", "Messages dropped by broadcast.max_messages_per_sec.")
+	for _, dataType := range statsTypes {
+		p.sample("rate_limited_dropped_total", float64(st.signals[dataType].throttle.dropped.Load()), "type", dataType)
+	}
+	p.single("telemetry_rejected_total", "counter", "Ingest requests rejected.", float64(st.rejected.Load()))
+	p.single("unauthorized_total", "counter", "Requests rejected for a missing or invalid token.", float64(st.unauthorized.Load()))
+	p.single("traces_kept_total", "counter", "Traces kept by sampling, once per export request.", float64(st.keptTraces.Load()))
+	p.single("traces_sampled_out_total", "counter", "Traces dropped by sampling, once per export request.", float64(st.droppedTraces.Load()))
+
+	p.family("clients", "gauge", "Connected WebSocket and SSE clients.")
+	for _, transport := range slices.Sorted(maps.Keys(st.clients)) {
+		p.sample("clients", float64(st.clients[transport].Load()), "transport", transport)
+	}
+	p.single("clients_slow_disconnects_total", "counter", "Clients disconnected for dropping too many messages in a row.",
+		float64(st.slowDisconnects.Load()))
+	p.single("clients_idle_disconnects_total", "counter", "Clients disconnected by websocket.idle_timeout.",
+		float64(st.idleDisconnects.Load()))
+	p.single("clients_refused_total", "counter", "WebSocket upgrades refused by websocket.max_clients.",
+		float64(st.refusedClients.Load()))
+
+	p.single("messages_broadcast_total", "counter", "Messages broadcast.", float64(st.broadcast.Load()))
+	p.single("messages_dropped_total", "counter", "Messages dropped for slow clients, once per client.", float64(st.dropped.Load()))
+	p.single("messages_split_total", "counter", "Resources split to fit broadcast.max_message_bytes.", float64(st.oversizeSplit.Load()))
+	p.single("messages_summarized_total", "counter", "Messages replaced by a summary for exceeding broadcast.max_message_bytes.",
+		float64(st.oversizeSummarized.Load()))
+
+	// The buckets are counted one by one and exposed cumulatively
+	p.family("message_size_bytes", "histogram", "Size of broadcast messages as JSON.")
+	var count uint64
+	for i := range st.messageSizes {
+		count += st.messageSizes[i].Load()
+		le := "+Inf"
+		if i < len(messageSizeBounds) {
+			le = strconv.Itoa(messageSizeBounds[i])
+		}
+		p.sample("message_size_bytes_bucket", float64(count), "le", le)
+	}
+	p.sample("message_size_bytes_sum", float64(st.messageBytes.Load()))
+	p.sample("message_size_bytes_count", float64(count))
+
+	p.single("buffer_entries", "gauge", "Messages held in the history buffer.", float64(s.broadcaster.historyLen()))
+	p.single("buffer_capacity", "gauge", "Capacity of the history buffer.", float64(s.config.Buffer.MaxEntries))
+	p.single("muted", "gauge", "Whether broadcasting is muted.", promBool(s.mute.state().Muted))
+
+	if hooks := s.webhooks.stats(); len(hooks) > 0 {
+		for _, m := range []struct {
+			name, kind, help string
+			value            func(webhookStats) float64
+		}{
+			{"webhook_delivered_total", "counter", "Events delivered to the webhook.", func(h webhookStats) float64 { return float64(h.Delivered) }},
+			{"webhook_failed_total", "counter", "Events the webhook failed to take after all retries.", func(h webhookStats) float64 { return float64(h.Failed) }},
+			{"webhook_retried_total", "counter", "Delivery attempts retried.", func(h webhookStats) float64 { return float64(h.Retried) }},
+			{"webhook_dropped_total", "counter", "Events dropped for a full queue or an open circuit.", func(h webhookStats) float64 { return float64(h.Dropped) }},
+			{"webhook_queued", "gauge", "Events waiting for delivery.", func(h webhookStats) float64 { return float64(h.Queued) }},
+			{"webhook_circuit_open", "gauge", "Whether deliveries are paused after repeated failures.", func(h webhookStats) float64 { return promBool(h.CircuitOpen) }},
+		} {
+			p.family(m.name, m.kind, m.help)
+			// Webhooks are numbered in configuration order, since two of
+			// them can share a host
+			for i, h := range hooks {
+				p.sample(m.name, m.value(h), "webhook", strconv.Itoa(i), "target", h.Target)
+			}
+		}
+	}
+
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	if _, err := w.Write(p.buf.Bytes()); err != nil {
+		s.logger.Debug("Failed to write metrics response", zap.Error(err))
+	}
+}
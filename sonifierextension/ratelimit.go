@@ -0,0 +1,180 @@
+package sonifierextension
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"go.opentelemetry.io/collector/pdata/plog/plogotlp"
+)
+
+// coalesceMode decides which messages survive when a signal type exceeds
+// its rate limit.
+type coalesceMode int
+
+const (
+	// coalesceSample forwards the first message of each interval and
+	// drops the rest.
+	coalesceSample coalesceMode = iota
+	// coalesceLatest holds back excess messages and forwards the most
+	// recent one at the end of the interval.
+	coalesceLatest
+	// coalesceSeverity holds back excess messages and forwards the one
+	// with the highest log severity at the end of the interval.
+	coalesceSeverity
+)
+
+// modeForType returns how excess messages of dataType are coalesced.
+func modeForType(dataType string) coalesceMode {
+	switch dataType {
+	case "metrics":
+		return coalesceLatest
+	case "logs":
+		return coalesceSeverity
+	default:
+		return coalesceSample
+	}
+}
+
+// rateLimiter caps how often one signal type is broadcast. Each forwarded
+// envelope is annotated with the number dropped since the previous one.
+type rateLimiter struct {
+	interval time.Duration
+	mode     coalesceMode
+	emit     func(*envelope)
+	counters *rateLimitCounters
+
+	mu              sync.Mutex
+	last            time.Time
+	pending         *envelope
+	pendingSeverity int32
+	timer           *time.Timer
+	droppedSince    int
+}
+
+// newRateLimiter returns a limiter forwarding up to perSec messages a
+// second to emit. It adds what it forwards and drops to counters.
+func newRateLimiter(perSec int, mode coalesceMode, counters *rateLimitCounters, emit func(*envelope)) *rateLimiter {
+	return &rateLimiter{
+		interval: time.Second / time.Duration(perSec),
+		mode:     mode,
+		emit:     emit,
+		counters: counters,
+	}
+}
+
+// offer forwards env now, holds it for the end of the interval, or drops it.
+func (l *rateLimiter) offer(env *envelope) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := time.Now()
+	if l.pending == nil && now.Sub(l.last) >= l.interval {
+		l.send(env, now)
+		return
+	}
+
+	if l.mode == coalesceSample {
+		l.drop()
+		return
+	}
+
+	var severity int32
+	if l.mode == coalesceSeverity {
+		severity = maxLogSeverity(env.Payload)
+	}
+	if l.pending == nil {
+		l.pending, l.pendingSeverity = env, severity
+		l.timer = time.AfterFunc(l.last.Add(l.interval).Sub(now), l.flush)
+		return
+	}
+	l.drop()
+	if l.mode == coalesceLatest || severity >= l.pendingSeverity {
+		l.pending, l.pendingSeverity = env, severity
+	}
+}
+
+// flush forwards the held envelope once the interval has elapsed.
+func (l *rateLimiter) flush() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.pending != nil {
+		l.send(l.pending, time.Now())
+		l.pending = nil
+	}
+}
+
+// stop cancels any pending flush.
+func (l *rateLimiter) stop() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.timer != nil {
+		l.timer.Stop()
+	}
+	l.pending = nil
+}
+
+// reset discards any held envelope and the count of drops not yet
+// reported, keeping the lifetime counters.
+func (l *rateLimiter) reset() {
+	l.stop()
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.droppedSince = 0
+}
+
+func (l *rateLimiter) send(env *envelope, now time.Time) {
+	env.Dropped = l.droppedSince
+	l.droppedSince = 0
+	l.last = now
+	l.counters.forwarded.Add(1)
+	l.emit(env)
+}
+
+func (l *rateLimiter) drop() {
+	l.droppedSince++
+	l.counters.dropped.Add(1)
+}
+
+// rateLimitCounters are the lifetime counters of one signal type's rate
+// limit. The extension keeps them rather than the limiter, so they carry
+// on when PUT /config replaces the limiter.
+type rateLimitCounters struct {
+	forwarded atomic.Uint64
+	dropped   atomic.Uint64
+}
+
+// rateLimitStats are the /stats entry of one signal type's rate limit.
+type rateLimitStats struct {
+	Forwarded uint64 `json:"forwarded"`
+	Dropped   uint64 `json:"dropped"`
+}
+
+func (c *rateLimitCounters) stats() rateLimitStats {
+	return rateLimitStats{Forwarded: c.forwarded.Load(), Dropped: c.dropped.Load()}
+}
+
+// maxLogSeverity returns the highest severity number in an OTLP JSON logs
+// payload, or zero if it can't be parsed.
+func maxLogSeverity(payload []byte) int32 {
+	req := plogotlp.NewExportRequest()
+	if req.UnmarshalJSON(payload) != nil {
+		return 0
+	}
+	var highest int32
+	rl := req.Logs().ResourceLogs()
+	for i := 0; i < rl.Len(); i++ {
+		sl := rl.At(i).ScopeLogs()
+		for j := 0; j < sl.Len(); j++ {
+			records := sl.At(j).LogRecords()
+			for k := 0; k < records.Len(); k++ {
+				if sev := int32(records.At(k).SeverityNumber()); sev > highest {
+					highest = sev
+				}
+			}
+		}
+	}
+	return highest
+}
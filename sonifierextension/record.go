@@ -0,0 +1,265 @@
+package sonifierextension
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"sync/atomic"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+const (
+	// recordQueueSize is how many entries may wait for the writer before
+	// further ones are dropped.
+	recordQueueSize = 1024
+	// recordFlushInterval is how often buffered entries are written out.
+	recordFlushInterval = time.Second
+)
+
+// recordEntry is one line of a recording.
+type recordEntry struct {
+	Timestamp time.Time       `json:"ts"`
+	Type      string          `json:"type"`
+	Payload   json.RawMessage `json:"payload"`
+}
+
+// recorder appends accepted payloads to a JSONL file. Ingestion only hands
+// entries to a channel; a single writer goroutine owns the file, so disk
+// latency never blocks ingestion or broadcasting.
+type recorder struct {
+	cfg    RecordConfig
+	logger *zap.Logger
+
+	active  atomic.Bool
+	dropped atomic.Uint64
+	entries chan *recordEntry
+	syncs   chan chan error
+}
+
+func newRecorder(cfg RecordConfig, logger *zap.Logger) *recorder {
+	r := &recorder{
+		cfg:     cfg,
+		logger:  logger,
+		entries: make(chan *recordEntry, recordQueueSize),
+		syncs:   make(chan chan error),
+	}
+	r.active.Store(cfg.Enabled)
+	return r
+}
+
+// record queues a payload if recording is on. It never blocks.
+func (r *recorder) record(dataType string, payload json.RawMessage) {
+	if !r.active.Load() {
+		return
+	}
+	select {
+	case r.entries <- &recordEntry{Timestamp: time.Now(), Type: dataType, Payload: payload}:
+	default:
+		r.dropped.Add(1)
+	}
+}
+
+// recordStatus is returned by the /record endpoints.
+type recordStatus struct {
+	Recording bool   `json:"recording"`
+	Path      string `json:"path"`
+	Dropped   uint64 `json:"dropped"`
+}
+
+func (r *recorder) status() recordStatus {
+	return recordStatus{Recording: r.active.Load(), Path: r.cfg.Path, Dropped: r.dropped.Load()}
+}
+
+// run writes queued entries to w until stop is closed, then writes what is
+// left and syncs the file.
+func (r *recorder) run(w *recordFile, stop <-chan struct{}) {
+	defer func() {
+		if err := w.close(); err != nil {
+			r.logger.Error("Failed to close recording", zap.Error(err))
+		}
+	}()
+
+	ticker := time.NewTicker(recordFlushInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-stop:
+			for {
+				select {
+				case entry := <-r.entries:
+					r.write(w, entry)
+				default:
+					return
+				}
+			}
+		case entry := <-r.entries:
+			r.write(w, entry)
+		case done := <-r.syncs:
+			// Write everything queued before the sync was requested
+			for len(r.entries) > 0 {
+				r.write(w, <-r.entries)
+			}
+			done <- w.sync()
+		case <-ticker.C:
+			if err := w.buf.Flush(); err != nil {
+				r.logger.Error("Failed to flush recording", zap.Error(err))
+			}
+		}
+	}
+}
+
+func (r *recorder) write(w *recordFile, entry *recordEntry) {
+	line, err := json.Marshal(entry)
+	if err != nil {
+		r.logger.Error("Failed to encode recording entry", zap.Error(err))
+		return
+	}
+	line = append(line, '\n')
+	if r.cfg.MaxSize > 0 && w.size > 0 && w.size+int64(len(line)) > r.cfg.MaxSize {
+		if err := w.rotate(r.cfg.MaxBackups); err != nil {
+			r.logger.Error("Failed to rotate recording", zap.Error(err))
+		}
+	}
+	if _, err := w.buf.Write(line); err != nil {
+		r.logger.Error("Failed to write recording", zap.Error(err))
+		return
+	}
+	w.size += int64(len(line))
+}
+
+// sync writes out everything queued so far and fsyncs the file.
+func (r *recorder) sync(stop <-chan struct{}) error {
+	done := make(chan error, 1)
+	select {
+	case r.syncs <- done:
+	case <-stop:
+		return errors.New("recording stopped")
+	}
+	return <-done
+}
+
+// recordFile is the open recording and its current size.
+type recordFile struct {
+	path string
+	file *os.File
+	buf  *bufio.Writer
+	size int64
+}
+
+func openRecordFile(path string) (*recordFile, error) {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return nil, err
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	return &recordFile{path: path, file: f, buf: bufio.NewWriter(f), size: info.Size()}, nil
+}
+
+func (w *recordFile) sync() error {
+	if err := w.buf.Flush(); err != nil {
+		return err
+	}
+	return w.file.Sync()
+}
+
+func (w *recordFile) close() error {
+	if err := w.sync(); err != nil {
+		w.file.Close()
+		return err
+	}
+	return w.file.Close()
+}
+
+// rotate syncs and closes the current file, shifts it to path.1 (and older
+// files up to path.N for N backups) and starts a new one.
+func (w *recordFile) rotate(backups int) error {
+	if err := w.close(); err != nil {
+		return err
+	}
+	for i := backups; i > 1; i-- {
+		os.Rename(fmt.Sprintf("%s.%d", w.path, i-1), fmt.Sprintf("%s.%d", w.path, i))
+	}
+	if backups > 0 {
+		if err := os.Rename(w.path, w.path+".1"); err != nil {
+			return err
+		}
+	} else if err := os.Remove(w.path); err != nil {
+		return err
+	}
+	next, err := openRecordFile(w.path)
+	if err != nil {
+		return err
+	}
+	*w = *next
+	return nil
+}
+
+// readRecording calls fn with every entry of a recording, in order.
+func readRecording(r io.Reader, fn func(recordEntry) error) error {
+	br := bufio.NewReader(r)
+	for lineNo := 1; ; lineNo++ {
+		line, err := br.ReadBytes('\n')
+		if len(line) > 0 {
+			var entry recordEntry
+			if jsonErr := json.Unmarshal(line, &entry); jsonErr != nil {
+				return fmt.Errorf("line %d: %w", lineNo, jsonErr)
+			}
+			if fnErr := fn(entry); fnErr != nil {
+				return fnErr
+			}
+		}
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// handleRecordStart turns recording on.
+func (s *sonifierExtension) handleRecordStart(w http.ResponseWriter, r *http.Request) {
+	s.setRecording(w, r, true)
+}
+
+// handleRecordStop turns recording off and syncs the file, so everything
+// recorded so far is on disk when it returns.
+func (s *sonifierExtension) handleRecordStop(w http.ResponseWriter, r *http.Request) {
+	s.setRecording(w, r, false)
+}
+
+func (s *sonifierExtension) setRecording(w http.ResponseWriter, r *http.Request, on bool) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if s.recorder == nil {
+		http.Error(w, "Recording is not configured, set record.path", http.StatusConflict)
+		return
+	}
+	s.recorder.active.Store(on)
+	if !on {
+		if err := s.recorder.sync(s.stop); err != nil {
+			s.logger.Error("Failed to sync recording", zap.Error(err))
+			http.Error(w, "Failed to sync recording", http.StatusInternalServerError)
+			return
+		}
+	}
+	s.logger.Info("Changed recording state", zap.Bool("recording", on))
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(s.recorder.status()); err != nil {
+		s.logger.Error("Failed to write recording status response", zap.Error(err))
+	}
+}
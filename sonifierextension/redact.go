@@ -0,0 +1,222 @@
+package sonifierextension
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+
+	"go.opentelemetry.io/collector/pdata/pcommon"
+	"go.opentelemetry.io/collector/pdata/plog"
+	"go.opentelemetry.io/collector/pdata/pmetric"
+	"go.opentelemetry.io/collector/pdata/ptrace"
+)
+
+// redactedValue replaces the value of every redacted attribute.
+const redactedValue = "[REDACTED]"
+
+// redactor masks the values of sensitive attributes in parsed telemetry.
+type redactor struct {
+	keys     map[string]bool
+	patterns []*regexp.Regexp
+}
+
+// newRedactor compiles the redaction settings. It returns nil when nothing
+// is redacted.
+func newRedactor(cfg RedactConfig) (*redactor, error) {
+	if len(cfg.Keys) == 0 && len(cfg.Patterns) == 0 {
+		return nil, nil
+	}
+	patterns, err := compilePatterns("patterns", cfg.Patterns)
+	if err != nil {
+		return nil, fmt.Errorf("redact.%w", err)
+	}
+	r := &redactor{keys: make(map[string]bool), patterns: patterns}
+	for _, key := range cfg.Keys {
+		r.keys[key] = true
+	}
+	return r, nil
+}
+
+func (r *redactor) matches(key string) bool {
+	if r.keys[key] {
+		return true
+	}
+	for _, re := range r.patterns {
+		if re.MatchString(key) {
+			return true
+		}
+	}
+	return false
+}
+
+// redactMap masks matching attributes in m, including those nested in map
+// and slice values, and returns how many it masked.
+func (r *redactor) redactMap(m pcommon.Map) int {
+	n := 0
+	m.Range(func(key string, v pcommon.Value) bool {
+		if r.matches(key) {
+			v.SetStr(redactedValue)
+			n++
+			return true
+		}
+		n += r.redactValue(v)
+		return true
+	})
+	return n
+}
+
+func (r *redactor) redactValue(v pcommon.Value) int {
+	switch v.Type() {
+	case pcommon.ValueTypeMap:
+		return r.redactMap(v.Map())
+	case pcommon.ValueTypeSlice:
+		n := 0
+		for i := 0; i < v.Slice().Len(); i++ {
+			n += r.redactValue(v.Slice().At(i))
+		}
+		return n
+	}
+	return 0
+}
+
+// apply masks matching resource, scope, span, metric data point and log
+// record attributes in d and returns how many it masked.
+func (r *redactor) apply(d *decodedTelemetry) int {
+	if !d.parsed {
+		return 0
+	}
+	switch d.dataType {
+	case "traces":
+		return r.redactTraces(d.traces)
+	case "metrics":
+		return r.redactMetrics(d.metrics)
+	case "logs":
+		return r.redactLogs(d.logs)
+	}
+	return 0
+}
+
+func (r *redactor) redactTraces(td ptrace.Traces) int {
+	n := 0
+	rs := td.ResourceSpans()
+	for i := 0; i < rs.Len(); i++ {
+		n += r.redactMap(rs.At(i).Resource().Attributes())
+		ss := rs.At(i).ScopeSpans()
+		for j := 0; j < ss.Len(); j++ {
+			n += r.redactMap(ss.At(j).Scope().Attributes())
+			spans := ss.At(j).Spans()
+			for k := 0; k < spans.Len(); k++ {
+				span := spans.At(k)
+				n += r.redactMap(span.Attributes())
+				for e := 0; e < span.Events().Len(); e++ {
+					n += r.redactMap(span.Events().At(e).Attributes())
+				}
+				for l := 0; l < span.Links().Len(); l++ {
+					n += r.redactMap(span.Links().At(l).Attributes())
+				}
+			}
+		}
+	}
+	return n
+}
+
+func (r *redactor) redactMetrics(md pmetric.Metrics) int {
+	n := 0
+	rm := md.ResourceMetrics()
+	for i := 0; i < rm.Len(); i++ {
+		n += r.redactMap(rm.At(i).Resource().Attributes())
+		sm := rm.At(i).ScopeMetrics()
+		for j := 0; j < sm.Len(); j++ {
+			n += r.redactMap(sm.At(j).Scope().Attributes())
+			metrics := sm.At(j).Metrics()
+			for k := 0; k < metrics.Len(); k++ {
+				n += r.redactMetric(metrics.At(k))
+			}
+		}
+	}
+	return n
+}
+
+func (r *redactor) redactMetric(m pmetric.Metric) int {
+	n := 0
+	redactNumbers := func(points pmetric.NumberDataPointSlice) {
+		for i := 0; i < points.Len(); i++ {
+			n += r.redactMap(points.At(i).Attributes())
+			n += r.redactExemplars(points.At(i).Exemplars())
+		}
+	}
+	switch m.Type() {
+	case pmetric.MetricTypeGauge:
+		redactNumbers(m.Gauge().DataPoints())
+	case pmetric.MetricTypeSum:
+		redactNumbers(m.Sum().DataPoints())
+	case pmetric.MetricTypeHistogram:
+		points := m.Histogram().DataPoints()
+		for i := 0; i < points.Len(); i++ {
+			n += r.redactMap(points.At(i).Attributes())
+			n += r.redactExemplars(points.At(i).Exemplars())
+		}
+	case pmetric.MetricTypeExponentialHistogram:
+		points := m.ExponentialHistogram().DataPoints()
+		for i := 0; i < points.Len(); i++ {
+			n += r.redactMap(points.At(i).Attributes())
+			n += r.redactExemplars(points.At(i).Exemplars())
+		}
+	case pmetric.MetricTypeSummary:
+		points := m.Summary().DataPoints()
+		for i := 0; i < points.Len(); i++ {
+			n += r.redactMap(points.At(i).Attributes())
+		}
+	}
+	return n
+}
+
+func (r *redactor) redactExemplars(exemplars pmetric.ExemplarSlice) int {
+	n := 0
+	for i := 0; i < exemplars.Len(); i++ {
+		n += r.redactMap(exemplars.At(i).FilteredAttributes())
+	}
+	return n
+}
+
+func (r *redactor) redactLogs(ld plog.Logs) int {
+	n := 0
+	rl := ld.ResourceLogs()
+	for i := 0; i < rl.Len(); i++ {
+		n += r.redactMap(rl.At(i).Resource().Attributes())
+		sl := rl.At(i).ScopeLogs()
+		for j := 0; j < sl.Len(); j++ {
+			n += r.redactMap(sl.At(j).Scope().Attributes())
+			records := sl.At(j).LogRecords()
+			for k := 0; k < records.Len(); k++ {
+				n += r.redactMap(records.At(k).Attributes())
+			}
+		}
+	}
+	return n
+}
+
+// errRedactionFailed is returned for a payload whose redacted form
+// couldn't be encoded. It is dropped, as only the original is left.
+var errRedactionFailed = errors.New("failed to encode redacted telemetry")
+
+// applyRedaction masks sensitive attributes in d and re-encodes its JSON.
+// It returns the JSON from before redaction when /telemetry-data keeps the
+// original values, and nil otherwise. When re-encoding fails d.json still
+// holds the original, so the payload must not be broadcast.
+func (s *sonifierExtension) applyRedaction(d *decodedTelemetry) ([]byte, error) {
+	if s.redactor == nil {
+		return nil, nil
+	}
+	original := d.json
+	if s.redactor.apply(d) == 0 {
+		return nil, nil
+	}
+	if err := d.encodeJSON(); err != nil {
+		return nil, fmt.Errorf("%w: %w", errRedactionFailed, err)
+	}
+	if s.config.Redact.KeepInTelemetryData {
+		return original, nil
+	}
+	return nil, nil
+}
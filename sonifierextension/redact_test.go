@@ -0,0 +1,50 @@
+package sonifierextension
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testSecretLogs = `{"resourceLogs":[{"resource":{"attributes":[{"key":"service.name","value":{"stringValue":"auth"}}]},"scopeLogs":[{"logRecords":[{"severityNumber":9,"body":{"stringValue":"login"},"attributes":[{"key":"user.email","value":{"stringValue":"jane@example.com"}}]}]}]}]}`
+
+func TestRedaction(t *testing.T) {
+	tests := []struct {
+		name         string
+		keepOriginal bool
+	}{
+		{name: "masks telemetry-data"},
+		{name: "keeps original in telemetry-data", keepOriginal: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, _ := startTestExtension(t, func(cfg *Config) {
+				cfg.Redact.Patterns = []string{`^user\.`}
+				cfg.Redact.KeepInTelemetryData = tt.keepOriginal
+			})
+			require.NoError(t, s.Ingest(context.Background(), "logs", []byte(testSecretLogs)))
+
+			msgs := history(s.broadcaster)
+			require.Len(t, msgs, 1)
+			broadcast := string(msgs[0].data)
+			assert.NotContains(t, broadcast, "jane@example.com")
+			assert.Contains(t, broadcast, redactedValue)
+
+			s.mu.Lock()
+			stored := s.telemetryData.String()
+			s.mu.Unlock()
+			assert.Equal(t, tt.keepOriginal, strings.Contains(stored, "jane@example.com"))
+		})
+	}
+}
+
+func TestRedactionRefusesUnknownPayloads(t *testing.T) {
+	cfg := createDefaultConfig().(*Config)
+	cfg.AcceptUnknown = true
+	require.NoError(t, cfg.Validate())
+	cfg.Redact.Keys = []string{"user.id"}
+	assert.ErrorContains(t, cfg.Validate(), "accept_unknown can't be combined with redact")
+}
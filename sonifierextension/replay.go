@@ -0,0 +1,265 @@
+package sonifierextension
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+const (
+	// maxReplayBody is the largest recording accepted in a POST /replay
+	// body.
+	maxReplayBody = 64 << 20
+	// replayLoopGap is the pause between passes of a looping replay.
+	replayLoopGap = time.Second
+)
+
+// replayStatus is returned by the /replay endpoints.
+type replayStatus struct {
+	Running bool `json:"running"`
+	// Source is the replayed file, or "upload" for a recording sent in the
+	// request body.
+	Source   string  `json:"source,omitempty"`
+	Position int     `json:"position"`
+	Total    int     `json:"total"`
+	Speed    float64 `json:"speed,omitempty"`
+	Loop     bool    `json:"loop"`
+	// Loops is how many full passes a looping replay has completed.
+	Loops int `json:"loops"`
+}
+
+// replayer tracks the replay in progress. At most one runs at a time.
+type replayer struct {
+	mu     sync.Mutex
+	status replayStatus
+	cancel chan struct{}
+	done   chan struct{}
+}
+
+func (r *replayer) running() bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.done != nil
+}
+
+func (r *replayer) snapshot() replayStatus {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.status
+}
+
+func (r *replayer) advance(position int, loops int) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.status.Position, r.status.Loops = position, loops
+}
+
+// stop cancels the running replay, if any, and waits for it to finish.
+func (r *replayer) stop() {
+	r.mu.Lock()
+	cancel, done := r.cancel, r.done
+	// Only the first caller cancels, later ones wait along with it
+	r.cancel = nil
+	r.mu.Unlock()
+
+	if done == nil {
+		return
+	}
+	if cancel != nil {
+		close(cancel)
+	}
+	<-done
+}
+
+// replayHidesLive reports whether live telemetry is kept from streaming
+// clients because a replay is running.
+func (s *sonifierExtension) replayHidesLive() bool {
+	return s.config.Replay.SuppressLive && s.replay.running()
+}
+
+// startReplay plays entries in the background unless a replay is already
+// running.
+func (s *sonifierExtension) startReplay(entries []recordEntry, source string, speed float64, loop bool) bool {
+	s.replay.mu.Lock()
+	defer s.replay.mu.Unlock()
+
+	if s.replay.done != nil {
+		return false
+	}
+	cancel, done := make(chan struct{}), make(chan struct{})
+	s.replay.cancel, s.replay.done = cancel, done
+	s.replay.status = replayStatus{Running: true, Source: source, Total: len(entries), Speed: speed, Loop: loop}
+
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		s.runReplay(entries, speed, loop, cancel)
+
+		s.replay.mu.Lock()
+		defer s.replay.mu.Unlock()
+		s.replay.status.Running = false
+		s.replay.cancel, s.replay.done = nil, nil
+		close(done)
+	}()
+	return true
+}
+
+// runReplay forwards entries to streaming clients, keeping the recorded
+// gaps between them scaled by speed, until they run out or the replay is
+// cancelled.
+func (s *sonifierExtension) runReplay(entries []recordEntry, speed float64, loop bool, cancel <-chan struct{}) {
+	wait := func(d time.Duration) bool {
+		timer := time.NewTimer(d)
+		defer timer.Stop()
+		select {
+		case <-timer.C:
+			return true
+		case <-cancel:
+		case <-s.stop:
+		}
+		return false
+	}
+
+	for loops := 0; ; loops++ {
+		for i, entry := range entries {
+			if i > 0 {
+				gap := entry.Timestamp.Sub(entries[i-1].Timestamp)
+				if !wait(time.Duration(float64(max(gap, 0)) / speed)) {
+					return
+				}
+			}
+			decoded := decodeTelemetry(entry.Payload, "")
+			if !decoded.parsed {
+				decoded.dataType = entry.Type
+			}
+			s.configMu.RLock()
+			for _, env := range s.resourceEnvelopes(decoded, entry.Payload) {
+				s.forward(env)
+			}
+			s.configMu.RUnlock()
+			s.replay.advance(i+1, loops)
+		}
+		if !loop {
+			s.logger.Info("Replay finished", zap.Int("entries", len(entries)))
+			return
+		}
+		s.replay.advance(0, loops+1)
+		if !wait(replayLoopGap) {
+			return
+		}
+	}
+}
+
+// handleReplay starts a replay on POST, cancels it on DELETE and reports
+// its progress on GET. POST replays the JSONL recording in the body, or
+// the configured recording when the body is empty; the speed and loop
+// query parameters control playback.
+func (s *sonifierExtension) handleReplay(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		s.writeReplayStatus(w, http.StatusOK)
+	case http.MethodDelete:
+		s.replay.stop()
+		s.writeReplayStatus(w, http.StatusOK)
+	case http.MethodPost:
+		s.handleReplayStart(w, r)
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+func (s *sonifierExtension) handleReplayStart(w http.ResponseWriter, r *http.Request) {
+	speed := 1.0
+	if v := r.URL.Query().Get("speed"); v != "" {
+		parsed, err := strconv.ParseFloat(v, 64)
+		if err != nil || parsed <= 0 {
+			http.Error(w, "Invalid speed, expected a positive number", http.StatusBadRequest)
+			return
+		}
+		speed = parsed
+	}
+	loop := false
+	if v := r.URL.Query().Get("loop"); v != "" {
+		parsed, err := strconv.ParseBool(v)
+		if err != nil {
+			http.Error(w, "Invalid loop, expected true or false", http.StatusBadRequest)
+			return
+		}
+		loop = parsed
+	}
+
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxReplayBody))
+	if err != nil {
+		http.Error(w, "Error reading request body", http.StatusRequestEntityTooLarge)
+		return
+	}
+	source := "upload"
+	if len(body) == 0 {
+		source = s.config.Replay.Path
+		if source == "" {
+			source = s.config.Record.Path
+		}
+		if source == "" {
+			http.Error(w, "No recording to replay, send one in the body or set replay.path", http.StatusConflict)
+			return
+		}
+	}
+
+	entries, err := s.loadRecording(body, source)
+	if err != nil {
+		s.logger.Warn("Failed to load recording", zap.String("source", source), zap.Error(err))
+		http.Error(w, fmt.Sprintf("Invalid recording: %v", err), http.StatusBadRequest)
+		return
+	}
+	if len(entries) == 0 {
+		http.Error(w, "Recording is empty", http.StatusBadRequest)
+		return
+	}
+
+	if !s.startReplay(entries, source, speed, loop) {
+		http.Error(w, "A replay is already running", http.StatusConflict)
+		return
+	}
+	s.logger.Info("Started replay", zap.String("source", source), zap.Int("entries", len(entries)),
+		zap.Float64("speed", speed), zap.Bool("loop", loop))
+	s.writeReplayStatus(w, http.StatusAccepted)
+}
+
+// loadRecording parses body, or the file at source when body is empty.
+func (s *sonifierExtension) loadRecording(body []byte, source string) ([]recordEntry, error) {
+	var in io.Reader = bytes.NewReader(body)
+	if len(body) == 0 {
+		f, err := os.Open(source)
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+		in = f
+	}
+
+	var entries []recordEntry
+	err := readRecording(in, func(entry recordEntry) error {
+		if entry.Type == "" || len(entry.Payload) == 0 {
+			return fmt.Errorf("entry %d has no type or payload", len(entries)+1)
+		}
+		entries = append(entries, entry)
+		return nil
+	})
+	return entries, err
+}
+
+func (s *sonifierExtension) writeReplayStatus(w http.ResponseWriter, code int) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	if err := json.NewEncoder(w).Encode(s.replay.snapshot()); err != nil {
+		s.logger.Error("Failed to write replay status response", zap.Error(err))
+	}
+}
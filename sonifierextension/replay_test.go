@@ -0,0 +1,59 @@
+package sonifierextension
+
+import (
+	"context"
+	"encoding/json"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReplayStopWhileStopping(t *testing.T) {
+	cancel, done := make(chan struct{}), make(chan struct{})
+	r := &replayer{cancel: cancel, done: done}
+
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		r.stop()
+	}()
+	<-cancel
+	go func() {
+		defer wg.Done()
+		r.stop()
+	}()
+	close(done)
+	wg.Wait()
+}
+
+func TestReplayConcurrentStop(t *testing.T) {
+	s, _ := startTestExtension(t)
+	start := time.Now()
+	entries := []recordEntry{
+		{Timestamp: start, Type: "logs", Payload: json.RawMessage(testLogs)},
+		// Far enough apart that the replay is still waiting when stopped
+		{Timestamp: start.Add(time.Hour), Type: "logs", Payload: json.RawMessage(testLogs)},
+	}
+	require.True(t, s.startReplay(entries, "upload", 1, false))
+	assert.False(t, s.startReplay(entries, "upload", 1, false), "only one replay runs at a time")
+
+	var wg sync.WaitGroup
+	for range 8 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			s.replay.stop()
+		}()
+	}
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		assert.NoError(t, s.Shutdown(context.Background()))
+	}()
+	wg.Wait()
+	assert.False(t, s.replay.running())
+}
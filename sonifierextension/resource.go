@@ -0,0 +1,66 @@
+package sonifierextension
+
+import (
+	"encoding/json"
+
+	"go.opentelemetry.io/collector/pdata/pcommon"
+	"go.opentelemetry.io/collector/pdata/plog/plogotlp"
+	"go.opentelemetry.io/collector/pdata/pmetric/pmetricotlp"
+	"go.opentelemetry.io/collector/pdata/ptrace/ptraceotlp"
+)
+
+const serviceNameKey = "service.name"
+
+// serviceNames returns the distinct service.name resource attributes in an
+// OTLP JSON payload of the given type, or the service a sound event was
+// produced for.
+func serviceNames(dataType string, data []byte) []string {
+	var resources []pcommon.Resource
+	switch dataType {
+	case "sound_event":
+		var event soundEvent
+		if json.Unmarshal(data, &event) != nil || event.Service == "" {
+			return nil
+		}
+		return []string{event.Service}
+	case "traces":
+		req := ptraceotlp.NewExportRequest()
+		if req.UnmarshalJSON(data) != nil {
+			return nil
+		}
+		rs := req.Traces().ResourceSpans()
+		for i := 0; i < rs.Len(); i++ {
+			resources = append(resources, rs.At(i).Resource())
+		}
+	case "metrics":
+		req := pmetricotlp.NewExportRequest()
+		if req.UnmarshalJSON(data) != nil {
+			return nil
+		}
+		rm := req.Metrics().ResourceMetrics()
+		for i := 0; i < rm.Len(); i++ {
+			resources = append(resources, rm.At(i).Resource())
+		}
+	case "logs":
+		req := plogotlp.NewExportRequest()
+		if req.UnmarshalJSON(data) != nil {
+			return nil
+		}
+		rl := req.Logs().ResourceLogs()
+		for i := 0; i < rl.Len(); i++ {
+			resources = append(resources, rl.At(i).Resource())
+		}
+	}
+
+	var names []string
+	seen := make(map[string]bool)
+	for _, res := range resources {
+		v, ok := res.Attributes().Get(serviceNameKey)
+		if !ok || seen[v.AsString()] {
+			continue
+		}
+		seen[v.AsString()] = true
+		names = append(names, v.AsString())
+	}
+	return names
+}
@@ -0,0 +1,126 @@
+package sonifierextension
+
+import (
+	"errors"
+	"fmt"
+	"maps"
+	"net/http"
+	"regexp"
+	"slices"
+	"strings"
+
+	"go.opentelemetry.io/collector/pdata/pcommon"
+)
+
+// namedChannel is a ChannelConfig with its patterns compiled.
+type namedChannel struct {
+	name  string
+	match []attributeMatch
+}
+
+type attributeMatch struct {
+	key string
+	re  *regexp.Regexp
+}
+
+// channelRouter assigns messages to the named channels whose attribute
+// rules their resource matches. A nil router assigns none.
+type channelRouter struct {
+	channels []namedChannel
+}
+
+func newChannelRouter(cfg map[string]ChannelConfig) (*channelRouter, error) {
+	if len(cfg) == 0 {
+		return nil, nil
+	}
+	r := &channelRouter{}
+	var errs []error
+	for _, name := range slices.Sorted(maps.Keys(cfg)) {
+		if name == "" || strings.ContainsAny(name, ", ") {
+			errs = append(errs, fmt.Errorf("channels: invalid channel name %q, names can't be empty or contain commas or spaces", name))
+			continue
+		}
+		match := cfg[name].Match
+		if len(match) == 0 {
+			errs = append(errs, fmt.Errorf("channels.%s.match must have at least one attribute", name))
+			continue
+		}
+		channel := namedChannel{name: name}
+		for _, key := range slices.Sorted(maps.Keys(match)) {
+			re, err := regexp.Compile(match[key])
+			if err != nil {
+				errs = append(errs, fmt.Errorf("channels.%s.match.%s: invalid regular expression: %w", name, key, err))
+				continue
+			}
+			channel.match = append(channel.match, attributeMatch{key: key, re: re})
+		}
+		r.channels = append(r.channels, channel)
+	}
+	if len(errs) > 0 {
+		return nil, errors.Join(errs...)
+	}
+	return r, nil
+}
+
+// route returns the names of the channels res belongs to.
+func (r *channelRouter) route(res pcommon.Resource) []string {
+	if r == nil {
+		return nil
+	}
+	var names []string
+	attrs := res.Attributes()
+	for _, channel := range r.channels {
+		matched := true
+		for _, m := range channel.match {
+			v, ok := attrs.Get(m.key)
+			if !ok || !m.re.MatchString(v.AsString()) {
+				matched = false
+				break
+			}
+		}
+		if matched {
+			names = append(names, channel.name)
+		}
+	}
+	return names
+}
+
+// routeDecoded returns the channels of a payload split by resource.
+func (r *channelRouter) routeDecoded(d *decodedTelemetry) []string {
+	if r == nil {
+		return nil
+	}
+	res, ok := firstResource(d)
+	if !ok {
+		return nil
+	}
+	return r.route(res)
+}
+
+func (r *channelRouter) has(name string) bool {
+	return r != nil && slices.ContainsFunc(r.channels, func(c namedChannel) bool { return c.name == name })
+}
+
+// routedType reports whether messages of dataType are routed to named
+// channels. Other messages, such as control messages and alerts, reach
+// every client whatever channel it joined.
+func routedType(dataType string) bool {
+	switch signalType(dataType) {
+	case "traces", "metrics", "logs", "sound_event":
+		return true
+	}
+	return false
+}
+
+// requestChannels returns the named channels a streaming request joins
+// with the channel query parameter, a comma-separated list, or nil for
+// none.
+func (s *sonifierExtension) requestChannels(r *http.Request) (map[string]bool, error) {
+	names := splitList(r.URL.Query().Get("channel"))
+	for _, name := range names {
+		if !s.router.has(name) {
+			return nil, fmt.Errorf("unknown channel %q", name)
+		}
+	}
+	return toSet(names), nil
+}
@@ -0,0 +1,106 @@
+package sonifierextension
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/collector/pdata/pcommon"
+)
+
+// testChannels routes prod, checkout and the checkout service in prod to
+// channels of their own.
+var testChannels = map[string]ChannelConfig{
+	"prod":          {Match: map[string]string{"deployment.environment": "^prod"}},
+	"checkout":      {Match: map[string]string{"service.name": "^checkout$"}},
+	"prod-checkout": {Match: map[string]string{"service.name": "^checkout$", "deployment.environment": "^prod"}},
+}
+
+func TestNewChannelRouter(t *testing.T) {
+	r, err := newChannelRouter(nil)
+	require.NoError(t, err)
+	assert.Nil(t, r)
+	assert.Nil(t, r.route(pcommon.NewResource()))
+	assert.False(t, r.has("prod"))
+
+	_, err = newChannelRouter(map[string]ChannelConfig{
+		"a,b":  {Match: map[string]string{"service.name": "x"}},
+		"":     {Match: map[string]string{"service.name": "x"}},
+		"none": {},
+		"bad":  {Match: map[string]string{"service.name": "("}},
+		"good": {Match: map[string]string{"service.name": "x"}},
+	})
+	require.Error(t, err)
+	for _, want := range []string{
+		`channels: invalid channel name "a,b", names can't be empty or contain commas or spaces`,
+		`channels: invalid channel name "", names can't be empty or contain commas or spaces`,
+		"channels.none.match must have at least one attribute",
+		"channels.bad.match.service.name: invalid regular expression",
+	} {
+		assert.ErrorContains(t, err, want)
+	}
+	assert.NotContains(t, err.Error(), "good")
+}
+
+func TestChannelRouterRoute(t *testing.T) {
+	r, err := newChannelRouter(testChannels)
+	require.NoError(t, err)
+	assert.True(t, r.has("prod"))
+	assert.False(t, r.has("staging"))
+
+	resource := func(attrs map[string]any) pcommon.Resource {
+		res := pcommon.NewResource()
+		require.NoError(t, res.Attributes().FromRaw(attrs))
+		return res
+	}
+	for _, tt := range []struct {
+		attrs map[string]any
+		want  []string
+	}{
+		{map[string]any{"service.name": "checkout", "deployment.environment": "production"}, []string{"checkout", "prod", "prod-checkout"}},
+		{map[string]any{"service.name": "checkout", "deployment.environment": "dev"}, []string{"checkout"}},
+		{map[string]any{"service.name": "checkout-worker", "deployment.environment": "prod"}, []string{"prod"}},
+		{map[string]any{"service.name": "auth"}, nil},
+		{map[string]any{}, nil},
+	} {
+		assert.Equal(t, tt.want, r.route(resource(tt.attrs)), tt.attrs)
+	}
+
+	// Payloads are routed by their first resource
+	assert.Equal(t, []string{"checkout"}, r.routeDecoded(decodeTelemetry([]byte(testTraces), "traces")))
+	assert.Nil(t, r.routeDecoded(decodeTelemetry([]byte("hello"), "")))
+}
+
+func TestRoutedType(t *testing.T) {
+	for _, dataType := range []string{"traces", "notes", "metrics", "logs", "sound_event"} {
+		assert.True(t, routedType(dataType), dataType)
+	}
+	for _, dataType := range []string{"alert", "summary", "topology", "heartbeat", "unknown"} {
+		assert.False(t, routedType(dataType), dataType)
+	}
+}
+
+func TestWebSocketChannels(t *testing.T) {
+	s, base := startTestExtension(t, func(cfg *Config) { cfg.Channels = testChannels })
+
+	resp, err := http.Get(base + "/ws?channel=prod,staging")
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+
+	conn := dialWebSocket(t, base, "?channel=checkout")
+	waitSubscription(t, s, func(*subscription) bool { return true })
+
+	// Only checkout's traces are in the channel, while messages that
+	// aren't routed reach it too
+	require.NoError(t, s.Ingest(context.Background(), "logs", []byte(testLogs)))
+	require.NoError(t, s.Ingest(context.Background(), "traces", []byte(testTraces)))
+	s.broadcastJSON("topology", topologyGraph{})
+	env := readEnvelope(t, conn)
+	assert.Equal(t, "traces", env.Type)
+	assert.Equal(t, "checkout", env.Service)
+	assert.Equal(t, "topology", readEnvelope(t, conn).Type)
+
+}
@@ -0,0 +1,262 @@
+package sonifierextension
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+	"net/http"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.opentelemetry.io/collector/confmap"
+	"go.uber.org/zap"
+)
+
+// runtimeSettings are the settings GET /config reports and PUT /config
+// can change without restarting the collector. They use the keys of the
+// extension's configuration, with the broadcast rate limits and the mute
+// switch at the top level. Muted is only kept in the mute state.
+type runtimeSettings struct {
+	Mappings          MappingsConfig `mapstructure:"mappings"`
+	Filters           FiltersConfig  `mapstructure:"filters"`
+	MaxMessagesPerSec SignalRates    `mapstructure:"max_messages_per_sec"`
+	Muted             bool           `mapstructure:"muted"`
+}
+
+// runtimeState is what a set of runtime settings compiles to.
+type runtimeState struct {
+	filters  map[string]*signalFilter
+	mapper   *mapper
+	voicer   *voicer
+	limiters map[string]*rateLimiter
+}
+
+// configValidationError is the 422 response to a PUT /config that was
+// rejected, with one entry per problem.
+type configValidationError struct {
+	Error   string   `json:"error"`
+	Details []string `json:"details"`
+}
+
+// configChange is one setting changed by PUT /config.
+type configChange struct {
+	Key  string `json:"key"`
+	From any    `json:"from"`
+	To   any    `json:"to"`
+}
+
+// compile validates rs and builds its filters, mapper, voicer and rate
+// limiters.
+// Limiters whose rate is unchanged are taken over from current rather
+// than replaced, so they keep their held message.
+func (s *sonifierExtension) compile(rs runtimeSettings, current map[string]*rateLimiter) (*runtimeState, error) {
+	var errs []error
+	state := &runtimeState{limiters: make(map[string]*rateLimiter)}
+	filters, err := newFilters(rs.Filters)
+	if err != nil {
+		errs = append(errs, err)
+	}
+	state.filters = filters
+	if len(rs.Mappings.Rules) > 0 {
+		if state.mapper, err = newMapper(rs.Mappings.Rules); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if state.voicer, err = newVoicer(rs.Mappings.Voices); err != nil {
+		errs = append(errs, err)
+	}
+	rates := rs.MaxMessagesPerSec.byType()
+	for _, dataType := range slices.Sorted(maps.Keys(rates)) {
+		if rates[dataType] < 0 {
+			errs = append(errs, fmt.Errorf("max_messages_per_sec.%s must not be negative", dataType))
+		}
+	}
+	if len(errs) > 0 {
+		return nil, errors.Join(errs...)
+	}
+	for dataType, rate := range rates {
+		if rate == 0 {
+			continue
+		}
+		if limiter, ok := current[dataType]; ok && limiter.interval == time.Second/time.Duration(rate) {
+			state.limiters[dataType] = limiter
+			continue
+		}
+		state.limiters[dataType] = newRateLimiter(rate, modeForType(dataType), &s.stats.signals[dataType].throttle, s.broadcast)
+	}
+	return state, nil
+}
+
+// runtimeConfig returns the current runtime settings as a map keyed like
+// the configuration.
+func (s *sonifierExtension) runtimeConfig() (map[string]any, error) {
+	s.configMu.RLock()
+	rs := s.runtime
+	s.configMu.RUnlock()
+	rs.Muted = s.mute.state().Muted
+	return settingsMap(rs)
+}
+
+func settingsMap(rs runtimeSettings) (map[string]any, error) {
+	conf := confmap.New()
+	if err := conf.Marshal(rs); err != nil {
+		return nil, err
+	}
+	return conf.ToStringMap(), nil
+}
+
+// handleConfig returns the runtime settings on GET and changes them on
+// PUT.
+func (s *sonifierExtension) handleConfig(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		current, err := s.runtimeConfig()
+		if err != nil {
+			s.logger.Error("Failed to encode runtime config", zap.Error(err))
+			http.Error(w, "Failed to encode runtime config", http.StatusInternalServerError)
+			return
+		}
+		s.writeRuntimeConfig(w, current)
+	case http.MethodPut:
+		s.updateConfig(w, r)
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// updateConfig applies the JSON merge patch (RFC 7396) in the request body
+// to the runtime settings. Objects in the patch are merged into the
+// current settings, any other value replaces the current one, and null
+// restores the zero value. The new settings take effect all at once, or
+// not at all when any of them is invalid. Unmuting discards the messages
+// held while muted unless ?flush=true is set, like POST /resume.
+func (s *sonifierExtension) updateConfig(w http.ResponseWriter, r *http.Request) {
+	var patch map[string]any
+	if err := json.NewDecoder(r.Body).Decode(&patch); err != nil || patch == nil {
+		writeJSONError(w, http.StatusBadRequest, "request body must be a JSON object")
+		return
+	}
+
+	s.configMu.Lock()
+	defer s.configMu.Unlock()
+	current := s.runtime
+	current.Muted = s.mute.state().Muted
+	before, err := settingsMap(current)
+	if err != nil {
+		s.logger.Error("Failed to encode runtime config", zap.Error(err))
+		http.Error(w, "Failed to encode runtime config", http.StatusInternalServerError)
+		return
+	}
+	var next runtimeSettings
+	var state *runtimeState
+	err = confmap.NewFromStringMap(mergePatch(before, patch)).Unmarshal(&next)
+	if err == nil {
+		state, err = s.compile(next, s.limiters)
+	}
+	if err != nil {
+		s.writeConfigRejected(w, err)
+		return
+	}
+	for dataType, limiter := range s.limiters {
+		if state.limiters[dataType] != limiter {
+			limiter.stop()
+		}
+	}
+	s.filters, s.mapper, s.voicer, s.limiters = state.filters, state.mapper, state.voicer, state.limiters
+	s.runtime = next
+	flush, _ := strconv.ParseBool(r.URL.Query().Get("flush"))
+	s.setMuted(next.Muted, flush)
+	after, _ := settingsMap(next)
+	changes := diffSettings(before, after)
+	if len(changes) > 0 {
+		fields := make([]string, 0, len(changes))
+		for _, c := range changes {
+			from, _ := json.Marshal(c.From)
+			to, _ := json.Marshal(c.To)
+			fields = append(fields, fmt.Sprintf("%s: %s -> %s", c.Key, from, to))
+		}
+		s.logger.Info("Changed runtime config", zap.Strings("changes", fields))
+		// Published directly so muted clients hear about it too
+		payload, _ := json.Marshal(after)
+		s.publish(&envelope{Type: "config", Payload: payload})
+	}
+	s.writeRuntimeConfig(w, after)
+}
+
+// writeConfigRejected responds 422 with one detail per joined error.
+func (s *sonifierExtension) writeConfigRejected(w http.ResponseWriter, err error) {
+	resp := configValidationError{Error: "invalid runtime config"}
+	for _, line := range strings.Split(err.Error(), "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			resp.Details = append(resp.Details, line)
+		}
+	}
+	s.logger.Warn("Rejected runtime config change", zap.Strings("details", resp.Details))
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusUnprocessableEntity)
+	if err := json.NewEncoder(w).Encode(resp); err != nil {
+		s.logger.Error("Failed to write config response", zap.Error(err))
+	}
+}
+
+func (s *sonifierExtension) writeRuntimeConfig(w http.ResponseWriter, settings map[string]any) {
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(settings); err != nil {
+		s.logger.Error("Failed to write config response", zap.Error(err))
+	}
+}
+
+// mergePatch returns target with patch merged into it as RFC 7396
+// describes. target is not modified.
+func mergePatch(target, patch map[string]any) map[string]any {
+	merged := make(map[string]any, len(target))
+	for k, v := range target {
+		merged[k] = v
+	}
+	for k, v := range patch {
+		if v == nil {
+			delete(merged, k)
+			continue
+		}
+		if p, ok := v.(map[string]any); ok {
+			t, _ := merged[k].(map[string]any)
+			merged[k] = mergePatch(t, p)
+			continue
+		}
+		merged[k] = v
+	}
+	return merged
+}
+
+// diffSettings lists the settings that differ between before and after,
+// with nested keys joined by dots. Lists are compared as a whole.
+func diffSettings(before, after map[string]any) []configChange {
+	var changes []configChange
+	var walk func(prefix string, a, b map[string]any)
+	walk = func(prefix string, a, b map[string]any) {
+		keys := make(map[string]bool)
+		for k := range a {
+			keys[k] = true
+		}
+		for k := range b {
+			keys[k] = true
+		}
+		for _, k := range slices.Sorted(maps.Keys(keys)) {
+			am, aok := a[k].(map[string]any)
+			bm, bok := b[k].(map[string]any)
+			if aok && bok {
+				walk(prefix+k+".", am, bm)
+				continue
+			}
+			if !reflect.DeepEqual(a[k], b[k]) {
+				changes = append(changes, configChange{Key: prefix + k, From: a[k], To: b[k]})
+			}
+		}
+	}
+	walk("", before, after)
+	return changes
+}
@@ -0,0 +1,52 @@
+package sonifierextension
+
+import (
+	"net/http"
+	"slices"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestConfigPutNeedsAdminToken(t *testing.T) {
+	t.Run("disabled without admin token", func(t *testing.T) {
+		_, url := startTestExtension(t)
+		assert.Equal(t, http.StatusOK, do(t, http.MethodGet, url+"/config", "", ""))
+		assert.Equal(t, http.StatusForbidden, do(t, http.MethodPut, url+"/config", "", `{"muted":true}`))
+	})
+
+	t.Run("admin token", func(t *testing.T) {
+		s, url := startTestExtension(t, func(cfg *Config) {
+			cfg.AdminToken = "admin"
+			cfg.Auth.ListenerToken = "listener"
+		})
+		assert.Equal(t, http.StatusOK, do(t, http.MethodGet, url+"/config", "listener", ""))
+		assert.Equal(t, http.StatusUnauthorized, do(t, http.MethodPut, url+"/config", "listener", `{"muted":true}`))
+		assert.False(t, s.mute.state().Muted)
+		assert.Equal(t, http.StatusOK, do(t, http.MethodPut, url+"/config", "admin", `{"muted":true}`))
+		assert.True(t, s.mute.state().Muted)
+	})
+}
+
+func TestConfigUnmuteFlush(t *testing.T) {
+	tests := []struct {
+		name  string
+		query string
+		held  bool
+	}{
+		{name: "discards held messages", query: ""},
+		{name: "flushes held messages", query: "?flush=true", held: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, url := startTestExtension(t, func(cfg *Config) { cfg.AdminToken = "admin" })
+			require.Equal(t, http.StatusOK, do(t, http.MethodPut, url+"/config", "admin", `{"muted":true}`))
+			require.Equal(t, http.StatusOK, do(t, http.MethodPost, url+"/v1/logs", "", testLogs))
+			require.Equal(t, http.StatusOK, do(t, http.MethodPut, url+"/config"+tt.query, "admin", `{"muted":false}`))
+
+			types := historyTypes(s.broadcaster)
+			assert.Equal(t, tt.held, slices.Contains(types, "logs"), "broadcast %v", types)
+		})
+	}
+}
@@ -0,0 +1,198 @@
+package sonifierextension
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math/bits"
+	"sync"
+
+	"go.opentelemetry.io/collector/pdata/pcommon"
+	"go.opentelemetry.io/collector/pdata/ptrace"
+	"go.uber.org/zap"
+)
+
+// Trace IDs are hashed with 32-bit MurmurHash3 into this many buckets, as
+// in the hash_seed mode of the collector's probabilistic sampler
+// processor, so the two keep the same traces for the same ratio and seed.
+const (
+	sampleHashBuckets = 0x4000
+	sampleBucketMask  = sampleHashBuckets - 1
+)
+
+// maxSampleDecisions bounds the trace decisions remembered for
+// always_keep_errors. The least recently seen trace is forgotten first.
+const maxSampleDecisions = 10000
+
+// traceSampler keeps a deterministic share of traces, chosen by hashing
+// the trace ID, so every span of a trace gets the same decision whichever
+// export request it arrives in. A nil sampler keeps everything.
+type traceSampler struct {
+	threshold  uint32
+	seed       uint32
+	keepErrors bool
+
+	// decisions remembers, with keepErrors, whether each recent trace was
+	// kept, since an error span in one request can't be seen from another.
+	mu        sync.Mutex
+	decisions *seriesCache[bool]
+}
+
+func newTraceSampler(cfg TracesConfig) (*traceSampler, error) {
+	if cfg.SampleRatio < 0 || cfg.SampleRatio > 1 {
+		return nil, fmt.Errorf("traces.sample_ratio must be between 0 and 1, got %v", cfg.SampleRatio)
+	}
+	if cfg.SampleRatio == 1 {
+		return nil, nil
+	}
+	s := &traceSampler{
+		threshold:  uint32(cfg.SampleRatio * sampleHashBuckets),
+		seed:       cfg.HashSeed,
+		keepErrors: cfg.AlwaysKeepErrors,
+	}
+	if s.keepErrors {
+		s.decisions = newSeriesCache[bool](maxSampleDecisions)
+	}
+	return s, nil
+}
+
+// keep reports whether the trace with id is sampled.
+func (s *traceSampler) keep(id pcommon.TraceID) bool {
+	return murmur3(id[:], s.seed)&sampleBucketMask < s.threshold
+}
+
+// decide returns the decisions for the traces in td. Without keepErrors
+// they come from the hash alone. With it, a trace seen for the first time
+// is also kept when td has an error span of it, and the decision is
+// remembered, so the trace's spans in later requests follow it whether
+// they have errors or not.
+func (s *traceSampler) decide(td ptrace.Traces) map[pcommon.TraceID]bool {
+	decisions := make(map[pcommon.TraceID]bool)
+	if !s.keepErrors {
+		forEachSpan(td, func(span ptrace.Span) {
+			if _, ok := decisions[span.TraceID()]; !ok {
+				decisions[span.TraceID()] = s.keep(span.TraceID())
+			}
+		})
+		return decisions
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	fresh := make(map[pcommon.TraceID]bool)
+	forEachSpan(td, func(span ptrace.Span) {
+		id := span.TraceID()
+		if _, ok := decisions[id]; !ok {
+			if known, ok := s.decisions.lookup(string(id[:])); ok {
+				decisions[id] = *known
+			} else {
+				decisions[id] = s.keep(id)
+				fresh[id] = true
+			}
+		}
+		if fresh[id] && span.Status().Code() == ptrace.StatusCodeError {
+			decisions[id] = true
+		}
+	})
+	for id, keep := range decisions {
+		*s.decisions.get(string(id[:])) = keep
+	}
+	return decisions
+}
+
+// apply removes the spans of unsampled traces from td, dropping resources
+// and scopes left empty, and returns how many distinct traces were kept
+// and dropped.
+func (s *traceSampler) apply(td ptrace.Traces) (kept, dropped int) {
+	decisions := s.decide(td)
+	td.ResourceSpans().RemoveIf(func(rs ptrace.ResourceSpans) bool {
+		rs.ScopeSpans().RemoveIf(func(ss ptrace.ScopeSpans) bool {
+			ss.Spans().RemoveIf(func(span ptrace.Span) bool {
+				return !decisions[span.TraceID()]
+			})
+			return ss.Spans().Len() == 0
+		})
+		return rs.ScopeSpans().Len() == 0
+	})
+	for _, keep := range decisions {
+		if keep {
+			kept++
+		} else {
+			dropped++
+		}
+	}
+	return kept, dropped
+}
+
+// murmur3 returns the 32-bit MurmurHash3 of data with seed.
+func murmur3(data []byte, seed uint32) uint32 {
+	const (
+		c1 = 0xcc9e2d51
+		c2 = 0x1b873593
+	)
+	h := seed
+	blocks := len(data) / 4
+	for i := 0; i < blocks; i++ {
+		k := binary.LittleEndian.Uint32(data[i*4:])
+		k *= c1
+		k = bits.RotateLeft32(k, 15)
+		k *= c2
+		h ^= k
+		h = bits.RotateLeft32(h, 13)
+		h = h*5 + 0xe6546b64
+	}
+	var k uint32
+	tail := data[blocks*4:]
+	switch len(tail) {
+	case 3:
+		k ^= uint32(tail[2]) << 16
+		fallthrough
+	case 2:
+		k ^= uint32(tail[1]) << 8
+		fallthrough
+	case 1:
+		k ^= uint32(tail[0])
+		k *= c1
+		k = bits.RotateLeft32(k, 15)
+		k *= c2
+		h ^= k
+	}
+	h ^= uint32(len(data))
+	h ^= h >> 16
+	h *= 0x85ebca6b
+	h ^= h >> 13
+	h *= 0xc2b2ae35
+	h ^= h >> 16
+	return h
+}
+
+func forEachSpan(td ptrace.Traces, fn func(span ptrace.Span)) {
+	rs := td.ResourceSpans()
+	for i := 0; i < rs.Len(); i++ {
+		ss := rs.At(i).ScopeSpans()
+		for j := 0; j < ss.Len(); j++ {
+			spans := ss.At(j).Spans()
+			for k := 0; k < spans.Len(); k++ {
+				fn(spans.At(k))
+			}
+		}
+	}
+}
+
+// applySampling removes unsampled traces from d and returns the number of
+// spans removed. Unparsed payloads pass through.
+func (s *sonifierExtension) applySampling(d *decodedTelemetry) int {
+	if s.sampler == nil || !d.parsed || d.dataType != "traces" {
+		return 0
+	}
+	before := d.count()
+	kept, dropped := s.sampler.apply(d.traces)
+	s.stats.keptTraces.Add(uint64(kept))
+	s.stats.droppedTraces.Add(uint64(dropped))
+	removed := before - d.count()
+	if removed > 0 && d.count() > 0 {
+		if err := d.encodeJSON(); err != nil {
+			s.logger.Error("Failed to encode sampled telemetry", zap.Error(err))
+		}
+	}
+	return removed
+}
@@ -0,0 +1,119 @@
+package sonifierextension
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/collector/pdata/pcommon"
+	"go.opentelemetry.io/collector/pdata/ptrace"
+)
+
+func TestMurmur3(t *testing.T) {
+	for _, tc := range []struct {
+		data string
+		seed uint32
+		want uint32
+	}{
+		{"", 0, 0},
+		{"", 1, 0x514e28b7},
+		{"", 0xffffffff, 0x81f16f39},
+		{"\x00\x00\x00\x00", 0, 0x2362f9de},
+		{"aaaa", 0x9747b28c, 0x5a97808a},
+		{"abc", 0x9747b28c, 0xc84a62dd},
+		{"Hello, world!", 0x9747b28c, 0x24884cba},
+		{"The quick brown fox jumps over the lazy dog", 0x9747b28c, 0x2fa826cd},
+	} {
+		assert.Equal(t, tc.want, murmur3([]byte(tc.data), tc.seed), "%q seed %#x", tc.data, tc.seed)
+	}
+}
+
+// testSpan is a span of a generated trace.
+type testSpan struct {
+	trace int
+	err   bool
+}
+
+// sampleTraces returns traces with spans, each in trace number trace.
+func sampleTraces(spans ...testSpan) ptrace.Traces {
+	td := ptrace.NewTraces()
+	ss := td.ResourceSpans().AppendEmpty().ScopeSpans().AppendEmpty()
+	for i, s := range spans {
+		span := ss.Spans().AppendEmpty()
+		span.SetTraceID(testTraceID(s.trace))
+		span.SetSpanID(pcommon.SpanID{byte(i + 1)})
+		if s.err {
+			span.Status().SetCode(ptrace.StatusCodeError)
+		}
+	}
+	return td
+}
+
+func testTraceID(n int) pcommon.TraceID {
+	var id pcommon.TraceID
+	copy(id[:], fmt.Sprintf("trace-%010d", n))
+	return id
+}
+
+// keptTraces returns the trace numbers left in td, once each.
+func keptTraces(td ptrace.Traces) map[int]bool {
+	kept := make(map[int]bool)
+	forEachSpan(td, func(span ptrace.Span) {
+		var n int
+		id := span.TraceID()
+		fmt.Sscanf(string(id[:]), "trace-%d", &n)
+		kept[n] = true
+	})
+	return kept
+}
+
+func TestTraceSamplerConsistentAcrossRequests(t *testing.T) {
+	s, err := newTraceSampler(TracesConfig{SampleRatio: 0.5, HashSeed: 42})
+	require.NoError(t, err)
+
+	var first, second []testSpan
+	for n := range 1000 {
+		first = append(first, testSpan{trace: n})
+		second = append(second, testSpan{trace: n}, testSpan{trace: n})
+	}
+	a, b := sampleTraces(first...), sampleTraces(second...)
+	keptA, droppedA := s.apply(a)
+	keptB, droppedB := s.apply(b)
+
+	assert.Equal(t, keptTraces(a), keptTraces(b))
+	assert.Equal(t, keptA, keptB)
+	assert.Equal(t, droppedA, droppedB)
+	assert.Equal(t, 1000, keptA+droppedA)
+	assert.InDelta(t, 500, keptA, 60)
+}
+
+func TestTraceSamplerKeepErrorsOncePerTrace(t *testing.T) {
+	// Only errors keep a trace at ratio 0
+	s, err := newTraceSampler(TracesConfig{SampleRatio: 0, AlwaysKeepErrors: true})
+	require.NoError(t, err)
+
+	// Trace 1 has an error in its first request, trace 2 only in its second
+	td := sampleTraces(testSpan{trace: 1}, testSpan{trace: 1, err: true}, testSpan{trace: 2})
+	kept, dropped := s.apply(td)
+	assert.Equal(t, map[int]bool{1: true}, keptTraces(td))
+	assert.Equal(t, 1, kept)
+	assert.Equal(t, 1, dropped)
+	assert.Equal(t, 2, td.SpanCount())
+
+	td = sampleTraces(testSpan{trace: 1}, testSpan{trace: 2, err: true}, testSpan{trace: 3, err: true})
+	kept, dropped = s.apply(td)
+	assert.Equal(t, map[int]bool{1: true, 3: true}, keptTraces(td),
+		"trace 1 stays kept, trace 2 stays dropped, and trace 3 is new")
+	assert.Equal(t, 2, kept)
+	assert.Equal(t, 1, dropped)
+}
+
+func TestTraceSamplerNil(t *testing.T) {
+	s, err := newTraceSampler(TracesConfig{SampleRatio: 1})
+	require.NoError(t, err)
+	assert.Nil(t, s)
+
+	_, err = newTraceSampler(TracesConfig{SampleRatio: 1.5})
+	assert.Error(t, err)
+}
@@ -0,0 +1,187 @@
+package sonifierextension
+
+import (
+	"container/list"
+	"encoding/json"
+
+	"go.opentelemetry.io/collector/pdata/pcommon"
+	"go.opentelemetry.io/collector/pdata/pmetric"
+)
+
+// seriesCache holds per-series state in least recently used order. Beyond
+// max series, the least recently used one is evicted. It isn't safe for
+// concurrent use.
+type seriesCache[T any] struct {
+	max   int
+	items map[string]*list.Element
+	order *list.List
+	// evicted, if set, is called with each series evicted to make room.
+	evicted func(key string, state *T)
+}
+
+type seriesEntry[T any] struct {
+	key   string
+	state T
+}
+
+func newSeriesCache[T any](max int) *seriesCache[T] {
+	return &seriesCache[T]{
+		max:   max,
+		items: make(map[string]*list.Element),
+		order: list.New(),
+	}
+}
+
+// get returns the state for key, creating it and evicting the least
+// recently used series if needed.
+func (c *seriesCache[T]) get(key string) *T {
+	if e, ok := c.items[key]; ok {
+		c.order.MoveToFront(e)
+		return &e.Value.(*seriesEntry[T]).state
+	}
+	if c.order.Len() >= c.max {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		entry := oldest.Value.(*seriesEntry[T])
+		delete(c.items, entry.key)
+		if c.evicted != nil {
+			c.evicted(entry.key, &entry.state)
+		}
+	}
+	entry := &seriesEntry[T]{key: key}
+	c.items[key] = c.order.PushFront(entry)
+	return &entry.state
+}
+
+// lookup returns the state for key without creating it or marking it as
+// recently used.
+func (c *seriesCache[T]) lookup(key string) (*T, bool) {
+	e, ok := c.items[key]
+	if !ok {
+		return nil, false
+	}
+	return &e.Value.(*seriesEntry[T]).state, true
+}
+
+// remove forgets key.
+func (c *seriesCache[T]) remove(key string) {
+	if e, ok := c.items[key]; ok {
+		c.order.Remove(e)
+		delete(c.items, key)
+	}
+}
+
+// each calls fn for every series, most recently used first. fn may remove
+// the series it is called with.
+func (c *seriesCache[T]) each(fn func(key string, state *T)) {
+	for e := c.order.Front(); e != nil; {
+		next := e.Next()
+		entry := e.Value.(*seriesEntry[T])
+		fn(entry.key, &entry.state)
+		e = next
+	}
+}
+
+func (c *seriesCache[T]) len() int {
+	return c.order.Len()
+}
+
+// reset forgets every series.
+func (c *seriesCache[T]) reset() {
+	clear(c.items)
+	c.order.Init()
+}
+
+// numberPoint is a gauge or sum data point and the series it belongs to:
+// the metric name plus its resource and data point attributes.
+type numberPoint struct {
+	key      string
+	metric   string
+	resource pcommon.Map
+	dp       pmetric.NumberDataPoint
+	// counter is set for monotonic sums, which are tracked as rates.
+	counter bool
+	delta   bool
+}
+
+// numberPoints calls fn for every gauge and sum data point in md.
+// Histograms and summaries are skipped.
+func numberPoints(md pmetric.Metrics, fn func(p numberPoint)) {
+	rm := md.ResourceMetrics()
+	for i := 0; i < rm.Len(); i++ {
+		resource := rm.At(i).Resource().Attributes()
+		resourceKey := attributesKey(resource)
+		sm := rm.At(i).ScopeMetrics()
+		for j := 0; j < sm.Len(); j++ {
+			metrics := sm.At(j).Metrics()
+			for k := 0; k < metrics.Len(); k++ {
+				m := metrics.At(k)
+				p := numberPoint{metric: m.Name(), resource: resource}
+				var points pmetric.NumberDataPointSlice
+				switch m.Type() {
+				case pmetric.MetricTypeGauge:
+					points = m.Gauge().DataPoints()
+				case pmetric.MetricTypeSum:
+					points = m.Sum().DataPoints()
+					p.counter = m.Sum().IsMonotonic()
+					p.delta = m.Sum().AggregationTemporality() == pmetric.AggregationTemporalityDelta
+				default:
+					continue
+				}
+				for l := 0; l < points.Len(); l++ {
+					p.dp = points.At(l)
+					p.key = m.Name() + "\x00" + resourceKey + "\x00" + attributesKey(p.dp.Attributes())
+					fn(p)
+				}
+			}
+		}
+	}
+}
+
+// counterRate holds a cumulative counter's previous point, to turn it
+// into a rate.
+type counterRate struct {
+	start     pcommon.Timestamp
+	last      pcommon.Timestamp
+	lastValue float64
+	hasLast   bool
+}
+
+// value returns p's value, or its per-second rate for monotonic sums.
+// Cumulative points are compared with the previous point of the series,
+// which is replaced when the start time changes or the value drops, as
+// both mean the counter restarted. ok is false for points without a rate:
+// a counter's first point and its first after a restart.
+func (c *counterRate) value(p numberPoint) (v float64, ok bool) {
+	v = numberValue(p.dp)
+	if !p.counter {
+		return v, true
+	}
+	if p.delta {
+		elapsed := p.dp.Timestamp().AsTime().Sub(p.dp.StartTimestamp().AsTime())
+		if p.dp.StartTimestamp() == 0 || elapsed <= 0 {
+			return 0, false
+		}
+		return v / elapsed.Seconds(), true
+	}
+
+	if !c.hasLast || p.dp.StartTimestamp() != c.start || v < c.lastValue {
+		c.start, c.last, c.lastValue, c.hasLast = p.dp.StartTimestamp(), p.dp.Timestamp(), v, true
+		return 0, false
+	}
+	elapsed := p.dp.Timestamp().AsTime().Sub(c.last.AsTime())
+	if elapsed <= 0 {
+		return 0, false
+	}
+	rate := (v - c.lastValue) / elapsed.Seconds()
+	c.last, c.lastValue = p.dp.Timestamp(), v
+	return rate, true
+}
+
+// attributesKey returns a string identifying an attribute set regardless
+// of the order of its keys.
+func attributesKey(attrs pcommon.Map) string {
+	// encoding/json sorts map keys
+	b, _ := json.Marshal(attrs.AsRaw())
+	return string(b)
+}
@@ -0,0 +1,90 @@
+// Package sonifierextensiontest provides a sonifier server for testing
+// streaming clients, the way net/http/httptest does for HTTP clients.
+package sonifierextensiontest
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"go.opentelemetry.io/collector/component"
+	"go.opentelemetry.io/collector/component/componenttest"
+	"go.opentelemetry.io/collector/extension"
+
+	"github.com/gemini/sonifierextension"
+)
+
+// TestServer is a sonifier listening on an ephemeral loopback port, for
+// testing streaming clients without running a collector. It mirrors
+// httptest.Server: create one with NewTestServer and connect to URL.
+type TestServer struct {
+	// URL is the base URL of the server, e.g. http://127.0.0.1:54321.
+	// WebSocket clients connect to the same host at /ws.
+	URL string
+
+	ext extension.Extension
+}
+
+// NewTestServer starts a sonifier with the default configuration, adjusted
+// by any options, and stops it when the test finishes. It fails the test
+// if the configuration is invalid or the server can't start.
+func NewTestServer(tb testing.TB, options ...func(*sonifierextension.Config)) *TestServer {
+	tb.Helper()
+
+	addr, err := freeAddr()
+	if err != nil {
+		tb.Fatalf("sonifier test server: failed to pick a port: %v", err)
+	}
+	factory := sonifierextension.NewFactory()
+	cfg := factory.CreateDefaultConfig().(*sonifierextension.Config)
+	cfg.Endpoint = addr
+	for _, option := range options {
+		option(cfg)
+	}
+	if err := cfg.Validate(); err != nil {
+		tb.Fatalf("sonifier test server: invalid config: %v", err)
+	}
+
+	set := extension.Settings{
+		ID:                component.NewID(factory.Type()),
+		TelemetrySettings: componenttest.NewNopTelemetrySettings(),
+		BuildInfo:         component.NewDefaultBuildInfo(),
+	}
+	ext, err := factory.Create(context.Background(), set, cfg)
+	if err != nil {
+		tb.Fatalf("sonifier test server: %v", err)
+	}
+	if err := ext.Start(context.Background(), componenttest.NewNopHost()); err != nil {
+		tb.Fatalf("sonifier test server: failed to start: %v", err)
+	}
+	ts := &TestServer{URL: "http://" + cfg.Endpoint, ext: ext}
+	tb.Cleanup(ts.Close)
+	return ts
+}
+
+// freeAddr returns a loopback address with a port that was free a moment
+// ago. The extension only reports the port it bound to internally.
+func freeAddr() (string, error) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return "", err
+	}
+	defer ln.Close()
+	return ln.Addr().String(), nil
+}
+
+// Inject ingests an OTLP JSON or protobuf export request as if it had
+// been posted to /telemetry, which detects its signal type. It returns
+// the errors sonifierextension.Ingester documents.
+func (ts *TestServer) Inject(body []byte) error {
+	return ts.ext.(sonifierextension.Ingester).Ingest(context.Background(), "", body)
+}
+
+// Close disconnects all clients and stops the server. It is called
+// automatically when the test finishes.
+func (ts *TestServer) Close() {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	ts.ext.Shutdown(ctx)
+}
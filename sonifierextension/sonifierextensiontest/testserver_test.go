@@ -0,0 +1,50 @@
+package sonifierextensiontest
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/gemini/sonifierextension"
+)
+
+const testLogs = `{"resourceLogs":[{"resource":{"attributes":[{"key":"service.name","value":{"stringValue":"auth"}}]},"scopeLogs":[{"logRecords":[{"severityNumber":17,"severityText":"ERROR","body":{"stringValue":"boom"}}]}]}]}`
+
+func TestTestServer(t *testing.T) {
+	ts := NewTestServer(t, func(cfg *sonifierextension.Config) {
+		cfg.Buffer.MaxEntries = 10
+	})
+	conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(ts.URL, "http")+"/ws", nil)
+	require.NoError(t, err)
+	defer conn.Close()
+
+	require.NoError(t, ts.Inject([]byte(testLogs)))
+	require.NoError(t, conn.SetReadDeadline(time.Now().Add(5*time.Second)))
+	for {
+		_, data, err := conn.ReadMessage()
+		require.NoError(t, err)
+		var msg struct {
+			Type    string          `json:"type"`
+			Service string          `json:"service"`
+			Payload json.RawMessage `json:"payload"`
+		}
+		require.NoError(t, json.Unmarshal(data, &msg))
+		if msg.Type != "logs" {
+			continue
+		}
+		assert.Equal(t, "auth", msg.Service)
+		assert.JSONEq(t, testLogs, string(msg.Payload))
+		return
+	}
+}
+
+func TestTestServerInjectRejects(t *testing.T) {
+	ts := NewTestServer(t)
+	var rejected *sonifierextension.RejectedError
+	assert.ErrorAs(t, ts.Inject([]byte(`{"hello":"world"}`)), &rejected)
+}
@@ -0,0 +1,146 @@
+package sonifierextension
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// sseClient is a Server-Sent Events subscriber. Its filters come from the
+// types and services query parameters, e.g. /events?types=traces,logs.
+type sseClient struct {
+	*queuedClient
+}
+
+func (c *sseClient) kind() string {
+	return "sse"
+}
+
+// handleEvents streams broadcast messages as text/event-stream for clients
+// that can't use WebSockets. Each event carries the broadcast id so clients
+// resume from the history buffer via Last-Event-ID.
+func (s *sonifierExtension) handleEvents(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if !s.origins.allowed(r) {
+		s.stats.unauthorized.Add(1)
+		http.Error(w, "Forbidden: origin not allowed", http.StatusForbidden)
+		return
+	}
+	if !s.awaitReady(w, r) {
+		return
+	}
+
+	var lastID uint64
+	if header := r.Header.Get("Last-Event-ID"); header != "" {
+		id, err := strconv.ParseUint(header, 10, 64)
+		if err != nil {
+			http.Error(w, "Invalid Last-Event-ID", http.StatusBadRequest)
+			return
+		}
+		lastID = id
+	}
+
+	channels, err := s.requestChannels(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	client := &sseClient{queuedClient: newQueuedClient(r.RemoteAddr, s.config.ClientQueueSize, s.config.MaxClientDrops)}
+	client.types = toSet(splitList(r.URL.Query().Get("types")))
+	client.services = toSet(splitList(r.URL.Query().Get("services")))
+	client.channels = channels
+
+	rc := http.NewResponseController(w)
+	// The server's read and write timeouts are meant for ingest and would
+	// cut the stream off; WebSocket upgrades are exempt because the
+	// upgrader clears the hijacked connection's deadlines
+	rc.SetReadDeadline(time.Time{})
+	rc.SetWriteDeadline(time.Time{})
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.WriteHeader(http.StatusOK)
+
+	backlog := s.broadcaster.subscribe(client, lastID)
+	s.countClients(r.Context(), client.kind(), 1)
+	defer func() {
+		s.broadcaster.unsubscribe(client)
+		s.countClients(context.Background(), client.kind(), -1)
+		s.telemetry.recordClientDropped(context.Background(), client.kind(), client.droppedCount())
+	}()
+
+	s.logger.Info("SSE connection established", zap.Int("backlog", len(backlog)))
+	defer s.logger.Info("SSE connection closed")
+
+	for _, msg := range backlog {
+		n, err := writeEvent(w, msg)
+		client.sent.Add(uint64(n))
+		if err != nil {
+			return
+		}
+		client.messages.Add(1)
+	}
+	if err := rc.Flush(); err != nil {
+		s.logger.Error("SSE streaming not supported", zap.Error(err))
+		return
+	}
+
+	var heartbeat <-chan time.Time
+	if interval := s.config.SSE.HeartbeatInterval; interval > 0 {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		heartbeat = ticker.C
+	}
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-client.kicked:
+			return
+		case <-s.stop:
+			return
+		case msg := <-client.queue:
+			if client.expired(msg, s.config.Broadcast.MessageTTL, time.Now()) {
+				s.telemetry.recordExpired(r.Context(), client.kind(), msg.dataType)
+				continue
+			}
+			n, err := writeEvent(w, msg)
+			client.sent.Add(uint64(n))
+			if err != nil {
+				return
+			}
+			client.messages.Add(1)
+		case <-heartbeat:
+			if _, err := fmt.Fprint(w, ": heartbeat\n\n"); err != nil {
+				return
+			}
+		}
+		if err := rc.Flush(); err != nil {
+			return
+		}
+	}
+}
+
+// writeEvent writes msg as a single SSE event and returns how many bytes
+// were written.
+func writeEvent(w http.ResponseWriter, msg *broadcastMessage) (int, error) {
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "id: %d\n", msg.id)
+	for _, line := range bytes.Split(msg.data, []byte("\n")) {
+		buf.WriteString("data: ")
+		buf.Write(line)
+		buf.WriteByte('\n')
+	}
+	buf.WriteByte('\n')
+	return w.Write(buf.Bytes())
+}
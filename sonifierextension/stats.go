@@ -0,0 +1,317 @@
+package sonifierextension
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"slices"
+	"sync/atomic"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// statsTypes are the signal types ingest classifies payloads as.
+var statsTypes = []string{"traces", "metrics", "logs", "unknown"}
+
+// messageSizeBounds are the upper bounds, in bytes, of the broadcast
+// message size buckets. Larger messages fall in a final unbounded bucket.
+var messageSizeBounds = []int{1 << 10, 4 << 10, 16 << 10, 64 << 10, 256 << 10, 1 << 20, 4 << 20}
+
+// signalCounters are the /stats counters of one signal type.
+type signalCounters struct {
+	received     atomic.Uint64
+	lastReceived atomic.Int64 // Unix nanoseconds, zero until the first payload
+	passed       atomic.Uint64
+	dropped      atomic.Uint64
+	limited      atomic.Uint64
+	// throttle counts the messages forwarded and dropped by
+	// broadcast.max_messages_per_sec.
+	throttle rateLimitCounters
+}
+
+// ingestStats holds the counters behind /stats. They are updated with
+// atomics, so reading them never contends with ingestion.
+type ingestStats struct {
+	started      time.Time
+	signals      map[string]*signalCounters
+	rejected     atomic.Uint64
+	unauthorized atomic.Uint64
+	broadcast    atomic.Uint64
+	dropped      atomic.Uint64
+	// slowDisconnects counts clients disconnected for dropping too many
+	// messages in a row.
+	slowDisconnects atomic.Uint64
+	// refusedClients counts WebSocket upgrades refused by
+	// websocket.max_clients, and idleDisconnects clients dropped by
+	// websocket.idle_timeout.
+	refusedClients  atomic.Uint64
+	idleDisconnects atomic.Uint64
+	clients         map[string]*atomic.Int64
+	seen            map[string]*atomic.Uint64
+	// keptTraces and droppedTraces count sampling decisions, once per
+	// trace in each export request.
+	keptTraces    atomic.Uint64
+	droppedTraces atomic.Uint64
+	// messageSizes counts broadcast messages per messageSizeBounds bucket.
+	messageSizes   []atomic.Uint64
+	messageBytes   atomic.Uint64
+	maxMessageSize atomic.Int64
+	// oversizeSplit counts resources split to fit
+	// broadcast.max_message_bytes, and oversizeSummarized messages
+	// replaced by a summary.
+	oversizeSplit      atomic.Uint64
+	oversizeSummarized atomic.Uint64
+}
+
+func newIngestStats() *ingestStats {
+	st := &ingestStats{
+		started: time.Now(),
+		signals: make(map[string]*signalCounters, len(statsTypes)),
+		clients: map[string]*atomic.Int64{"websocket": {}, "sse": {}},
+		seen:    map[string]*atomic.Uint64{"traces": {}, "metrics": {}, "logs": {}},
+
+		messageSizes: make([]atomic.Uint64, len(messageSizeBounds)+1),
+	}
+	for _, dataType := range statsTypes {
+		st.signals[dataType] = &signalCounters{}
+	}
+	return st
+}
+
+func (st *ingestStats) received(dataType string, at time.Time) {
+	if c, ok := st.signals[dataType]; ok {
+		c.received.Add(1)
+		c.lastReceived.Store(at.UnixNano())
+	}
+}
+
+// seenCounts is the running tally of broadcast payloads per signal type
+// carried by telemetry envelopes, so clients can show totals and derive
+// rates without counting themselves.
+type seenCounts struct {
+	Traces  uint64 `json:"traces"`
+	Metrics uint64 `json:"metrics"`
+	Logs    uint64 `json:"logs"`
+}
+
+// countSeen adds a payload of dataType to the tally and returns the new
+// counts.
+func (st *ingestStats) countSeen(dataType string) *seenCounts {
+	if c, ok := st.seen[dataType]; ok {
+		c.Add(1)
+	}
+	return st.seenCounts()
+}
+
+func (st *ingestStats) seenCounts() *seenCounts {
+	return &seenCounts{
+		Traces:  st.seen["traces"].Load(),
+		Metrics: st.seen["metrics"].Load(),
+		Logs:    st.seen["logs"].Load(),
+	}
+}
+
+// resetSeen zeroes the tally. Unlike the /stats counters, it can be reset
+// by clients.
+func (st *ingestStats) resetSeen() {
+	for _, c := range st.seen {
+		c.Store(0)
+	}
+}
+
+// messageSize records the encoded size of a broadcast message.
+func (st *ingestStats) messageSize(n int) {
+	i, _ := slices.BinarySearch(messageSizeBounds, n)
+	st.messageSizes[i].Add(1)
+	st.messageBytes.Add(uint64(n))
+	for {
+		current := st.maxMessageSize.Load()
+		if int64(n) <= current || st.maxMessageSize.CompareAndSwap(current, int64(n)) {
+			return
+		}
+	}
+}
+
+// rateLimited counts a payload of dataType rejected by ingest_limits.
+func (st *ingestStats) rateLimited(dataType string) {
+	if c, ok := st.signals[dataType]; ok {
+		c.limited.Add(1)
+	}
+}
+
+func (st *ingestStats) filtered(dataType string, passed, dropped int) {
+	if c, ok := st.signals[dataType]; ok {
+		c.passed.Add(uint64(passed))
+		c.dropped.Add(uint64(dropped))
+	}
+}
+
+// signalStats is the /stats entry of one signal type. Passed and dropped
+// count spans, metrics or log records and are only kept for filtered
+// types. RateLimited counts payloads rejected by ingest_limits.
+type signalStats struct {
+	Received     uint64     `json:"received"`
+	LastReceived *time.Time `json:"last_received,omitempty"`
+	Filtered     bool       `json:"filtered"`
+	Passed       uint64     `json:"passed,omitempty"`
+	Dropped      uint64     `json:"dropped,omitempty"`
+	RateLimited  uint64     `json:"rate_limited,omitempty"`
+}
+
+// samplingStats counts the traces kept and dropped by traces.sample_ratio.
+type samplingStats struct {
+	KeptTraces    uint64 `json:"kept_traces"`
+	DroppedTraces uint64 `json:"dropped_traces"`
+}
+
+// messageSizeStats is the size distribution of broadcast messages, as
+// encoded for JSON clients.
+type messageSizeStats struct {
+	Count   uint64              `json:"count"`
+	Max     int64               `json:"max"`
+	Buckets []messageSizeBucket `json:"buckets"`
+}
+
+// messageSizeBucket counts the messages larger than the previous bucket's
+// bound and at most LE bytes. LE is absent for the last bucket.
+type messageSizeBucket struct {
+	LE    *int   `json:"le,omitempty"`
+	Count uint64 `json:"count"`
+}
+
+// oversizeStats counts the messages over broadcast.max_message_bytes.
+type oversizeStats struct {
+	Split      uint64 `json:"split"`
+	Summarized uint64 `json:"summarized"`
+}
+
+// bufferStats is the occupancy of the broadcast history.
+type bufferStats struct {
+	Entries  int `json:"entries"`
+	Capacity int `json:"capacity"`
+}
+
+// statsResponse is returned by /stats.
+type statsResponse struct {
+	StartedAt      time.Time              `json:"started_at"`
+	UptimeSeconds  float64                `json:"uptime_seconds"`
+	Signals        map[string]signalStats `json:"signals"`
+	Rejected       uint64                 `json:"rejected"`
+	Unauthorized   uint64                 `json:"unauthorized"`
+	Clients        map[string]int64       `json:"clients"`
+	Broadcast      uint64                 `json:"broadcast"`
+	Dropped        uint64                 `json:"dropped"`
+	SlowClients    uint64                 `json:"slow_disconnects"`
+	RefusedClients uint64                 `json:"refused_clients"`
+	IdleClients    uint64                 `json:"idle_disconnects"`
+	MessageBytes   messageSizeStats       `json:"message_bytes"`
+	Oversize       oversizeStats          `json:"oversize"`
+	Buffer         bufferStats            `json:"buffer"`
+	Muted          bool                   `json:"muted"`
+	// Sampling is only reported while traces are sampled.
+	Sampling *samplingStats `json:"sampling,omitempty"`
+	// Webhooks are the delivery counters of each webhook, in configuration
+	// order.
+	Webhooks []webhookStats `json:"webhooks,omitempty"`
+	// RateLimits are the messages forwarded and dropped by
+	// broadcast.max_messages_per_sec, for signal types that are limited or
+	// have been since startup.
+	RateLimits map[string]rateLimitStats `json:"rate_limits,omitempty"`
+}
+
+// countRejected counts a rejected ingest request.
+func (s *sonifierExtension) countRejected(ctx context.Context, reason string) {
+	s.stats.rejected.Add(1)
+	s.telemetry.recordRejected(ctx, reason)
+}
+
+// countClients adds delta to the connected clients of a transport.
+func (s *sonifierExtension) countClients(ctx context.Context, transport string, delta int64) {
+	if c, ok := s.stats.clients[transport]; ok {
+		c.Add(delta)
+	}
+	s.telemetry.recordClients(ctx, transport, delta)
+}
+
+// handleStats reports ingest and broadcast counters for a quick health
+// check.
+func (s *sonifierExtension) handleStats(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	st := s.stats
+	resp := statsResponse{
+		StartedAt:      st.started,
+		UptimeSeconds:  time.Since(st.started).Seconds(),
+		Signals:        make(map[string]signalStats, len(st.signals)),
+		Rejected:       st.rejected.Load(),
+		Unauthorized:   st.unauthorized.Load(),
+		Clients:        make(map[string]int64, len(st.clients)),
+		Broadcast:      st.broadcast.Load(),
+		Dropped:        st.dropped.Load(),
+		SlowClients:    st.slowDisconnects.Load(),
+		RefusedClients: st.refusedClients.Load(),
+		IdleClients:    st.idleDisconnects.Load(),
+		MessageBytes:   messageSizeStats{Max: st.maxMessageSize.Load()},
+		Oversize: oversizeStats{
+			Split:      st.oversizeSplit.Load(),
+			Summarized: st.oversizeSummarized.Load(),
+		},
+		Buffer: bufferStats{
+			Entries:  s.broadcaster.historyLen(),
+			Capacity: s.config.Buffer.MaxEntries,
+		},
+		Muted:    s.mute.state().Muted,
+		Webhooks: s.webhooks.stats(),
+	}
+	s.configMu.RLock()
+	for dataType, c := range st.signals {
+		_, filtered := s.filters[dataType]
+		entry := signalStats{
+			Received:    c.received.Load(),
+			Filtered:    filtered,
+			Passed:      c.passed.Load(),
+			Dropped:     c.dropped.Load(),
+			RateLimited: c.limited.Load(),
+		}
+		if ns := c.lastReceived.Load(); ns != 0 {
+			t := time.Unix(0, ns)
+			entry.LastReceived = &t
+		}
+		resp.Signals[dataType] = entry
+
+		_, limited := s.limiters[dataType]
+		if throttle := c.throttle.stats(); limited || throttle != (rateLimitStats{}) {
+			if resp.RateLimits == nil {
+				resp.RateLimits = make(map[string]rateLimitStats)
+			}
+			resp.RateLimits[dataType] = throttle
+		}
+	}
+	s.configMu.RUnlock()
+	if s.sampler != nil {
+		resp.Sampling = &samplingStats{
+			KeptTraces:    st.keptTraces.Load(),
+			DroppedTraces: st.droppedTraces.Load(),
+		}
+	}
+	for transport, c := range st.clients {
+		resp.Clients[transport] = c.Load()
+	}
+	for i := range st.messageSizes {
+		bucket := messageSizeBucket{Count: st.messageSizes[i].Load()}
+		if i < len(messageSizeBounds) {
+			bucket.LE = &messageSizeBounds[i]
+		}
+		resp.MessageBytes.Count += bucket.Count
+		resp.MessageBytes.Buckets = append(resp.MessageBytes.Buckets, bucket)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(resp); err != nil {
+		s.logger.Error("Failed to write stats response", zap.Error(err))
+	}
+}
@@ -0,0 +1,130 @@
+package sonifierextension
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// getStats decodes the /stats response of the extension at url.
+func getStats(t *testing.T, url string) statsResponse {
+	t.Helper()
+	resp, err := http.Get(url + "/stats")
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	var stats statsResponse
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&stats))
+	return stats
+}
+
+func TestStats(t *testing.T) {
+	_, url := startTestExtension(t, func(cfg *Config) { cfg.Buffer.MaxEntries = 4 })
+
+	stats := getStats(t, url)
+	for _, dataType := range statsTypes {
+		assert.Zero(t, stats.Signals[dataType].Received, dataType)
+		assert.Nil(t, stats.Signals[dataType].LastReceived, dataType)
+	}
+	assert.Equal(t, map[string]int64{"websocket": 0, "sse": 0}, stats.Clients)
+	assert.Equal(t, bufferStats{Entries: 0, Capacity: 4}, stats.Buffer)
+
+	ws, _, err := websocket.DefaultDialer.Dial("ws://"+strings.TrimPrefix(url, "http://")+"/ws", nil)
+	require.NoError(t, err)
+	defer ws.Close()
+	require.Eventually(t, func() bool {
+		return getStats(t, url).Clients["websocket"] == 1
+	}, 5*time.Second, 10*time.Millisecond)
+
+	before := time.Now()
+	for _, post := range []struct {
+		path, body string
+		n          int
+	}{
+		{"/v1/traces", testTraces, 2},
+		{"/v1/metrics", testMetrics, 3},
+		{"/v1/logs", testLogs, 1},
+	} {
+		for range post.n {
+			status, _ := postTelemetry(t, url, post.path, post.body)
+			require.Equal(t, http.StatusOK, status, post.path)
+		}
+	}
+	status, _ := postTelemetry(t, url, "/v1/logs", "not telemetry")
+	require.Equal(t, http.StatusBadRequest, status)
+
+	stats = getStats(t, url)
+	for dataType, want := range map[string]uint64{"traces": 2, "metrics": 3, "logs": 1, "unknown": 0} {
+		signal := stats.Signals[dataType]
+		assert.Equal(t, want, signal.Received, dataType)
+		if want == 0 {
+			assert.Nil(t, signal.LastReceived, dataType)
+			continue
+		}
+		require.NotNil(t, signal.LastReceived, dataType)
+		assert.False(t, signal.LastReceived.Before(before.Truncate(time.Second)), dataType)
+		assert.False(t, signal.LastReceived.After(time.Now()), dataType)
+		assert.False(t, signal.Filtered, dataType)
+	}
+	assert.Equal(t, uint64(1), stats.Rejected)
+	assert.Equal(t, uint64(6), stats.Broadcast)
+	assert.Zero(t, stats.Dropped)
+	assert.Equal(t, uint64(6), stats.MessageBytes.Count)
+	assert.Equal(t, map[string]int64{"websocket": 1, "sse": 0}, stats.Clients)
+	// Only the newest four messages are kept
+	assert.Equal(t, bufferStats{Entries: 4, Capacity: 4}, stats.Buffer)
+	assert.False(t, stats.Muted)
+	assert.Nil(t, stats.Sampling)
+
+	ws.Close()
+	require.Eventually(t, func() bool {
+		return getStats(t, url).Clients["websocket"] == 0
+	}, 5*time.Second, 10*time.Millisecond)
+}
+
+func TestRateLimitStats(t *testing.T) {
+	_, url := startTestExtension(t, func(cfg *Config) {
+		cfg.AdminToken = "admin"
+		cfg.Broadcast.MaxMessagesPerSec.Traces = 1
+	})
+	postTraces := func(n int) {
+		t.Helper()
+		for range n {
+			status, _ := postTelemetry(t, url, "/v1/traces", testTraces)
+			require.Equal(t, http.StatusOK, status)
+		}
+	}
+
+	// Excess traces within the interval are sampled out
+	postTraces(3)
+	stats := getStats(t, url)
+	assert.Equal(t, map[string]rateLimitStats{"traces": {Forwarded: 1, Dropped: 2}}, stats.RateLimits)
+
+	// A new limit replaces the limiter but not its counts
+	require.Equal(t, http.StatusOK, do(t, http.MethodPut, url+"/config", "admin", `{"max_messages_per_sec":{"traces":2}}`))
+	postTraces(2)
+	stats = getStats(t, url)
+	assert.Equal(t, map[string]rateLimitStats{"traces": {Forwarded: 2, Dropped: 3}}, stats.RateLimits)
+
+	resp, err := http.Get(url + "/metrics")
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	assert.Contains(t, string(body), `otelcol_sonifier_rate_limited_forwarded_total{type="traces"} 2`+"\n")
+	assert.Contains(t, string(body), `otelcol_sonifier_rate_limited_dropped_total{type="traces"} 3`+"\n")
+	assert.Contains(t, string(body), `otelcol_sonifier_rate_limited_dropped_total{type="logs"} 0`+"\n")
+
+	// Lifting the limit keeps reporting what it counted
+	require.Equal(t, http.StatusOK, do(t, http.MethodPut, url+"/config", "admin", `{"max_messages_per_sec":{"traces":0}}`))
+	postTraces(2)
+	stats = getStats(t, url)
+	assert.Equal(t, map[string]rateLimitStats{"traces": {Forwarded: 2, Dropped: 3}}, stats.RateLimits)
+}
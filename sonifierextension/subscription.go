@@ -0,0 +1,232 @@
+package sonifierextension
+
+import (
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// controlMessage is sent by WebSocket clients to change what they receive:
+//
+//	{"action":"subscribe","types":["traces","logs"],"services":["checkout"]}
+//	{"action":"pause"}
+//	{"action":"resume"}
+//	{"action":"reset_counts"}
+//
+// A subscribe with empty types or services removes that filter.
+// reset_counts zeroes the seen tally for every client. Replaying missed
+// messages is only done when connecting, with /ws?from_seq=N, so a resume
+// carrying from_seq is rejected rather than taken for an unpause.
+type controlMessage struct {
+	Action   string   `json:"action"`
+	Types    []string `json:"types,omitempty"`
+	Services []string `json:"services,omitempty"`
+	FromSeq  uint64   `json:"from_seq,omitempty"`
+}
+
+// subscription is the filter state shared by all subscriber kinds. A nil
+// filter set matches everything.
+type subscription struct {
+	mu       sync.Mutex
+	types    map[string]bool
+	services map[string]bool
+	// channels are the named channels the client joined when connecting.
+	channels map[string]bool
+	paused   bool
+}
+
+// handleControl applies a control message received from the client.
+func (c *subscription) handleControl(data []byte) error {
+	var msg controlMessage
+	if err := json.Unmarshal(data, &msg); err != nil {
+		return fmt.Errorf("invalid control message: %w", err)
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	switch msg.Action {
+	case "subscribe":
+		c.types = toSet(msg.Types)
+		c.services = toSet(msg.Services)
+	case "pause":
+		c.paused = true
+	case "resume":
+		if msg.FromSeq != 0 {
+			return fmt.Errorf("from_seq is only accepted when connecting, as /ws?from_seq=%d", msg.FromSeq)
+		}
+		c.paused = false
+	default:
+		return fmt.Errorf("unknown control action %q", msg.Action)
+	}
+	return nil
+}
+
+// wants reports whether msg should be sent. Service names are only parsed
+// out of the payload when the subscription filters on them.
+func (c *subscription) wants(msg *broadcastMessage) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.paused {
+		return false
+	}
+	if c.types != nil && !c.types[msg.dataType] && !c.types[signalType(msg.dataType)] {
+		return false
+	}
+	if c.channels != nil && routedType(msg.dataType) && !slices.ContainsFunc(msg.env.Routes, func(name string) bool { return c.channels[name] }) {
+		return false
+	}
+	if c.services == nil {
+		return true
+	}
+	for _, service := range msg.serviceNames() {
+		if c.services[service] {
+			return true
+		}
+	}
+	return false
+}
+
+// dropWarningInterval spaces out the warnings logged about one client's
+// dropped messages.
+const dropWarningInterval = 10 * time.Second
+
+// queuedClient is embedded by subscribers that deliver from their own
+// writer goroutine.
+type queuedClient struct {
+	subscription
+	queue       chan *broadcastMessage
+	done        chan struct{}
+	remote      string
+	connectedAt time.Time
+
+	// sent counts the bytes written to the client, and messages the
+	// messages.
+	sent     atomic.Uint64
+	messages atomic.Uint64
+
+	// dropped counts messages dropped because the queue was full.
+	dropped atomic.Uint64
+	// consecutive counts drops since the last successful enqueue. It is
+	// only touched by enqueue, under the broadcaster's lock.
+	consecutive int
+	maxDrops    int
+	// lastDropWarning is when a drop was last logged, also guarded by the
+	// broadcaster's lock.
+	lastDropWarning time.Time
+
+	// kicked is closed when the server ends the stream.
+	kicked   chan struct{}
+	kickOnce sync.Once
+}
+
+// newQueuedClient returns a client for the peer at remote that queues up
+// to size messages and is too slow after maxDrops consecutive drops, or
+// never when maxDrops is zero.
+func newQueuedClient(remote string, size, maxDrops int) *queuedClient {
+	return &queuedClient{
+		queue:       make(chan *broadcastMessage, size),
+		done:        make(chan struct{}),
+		remote:      remote,
+		connectedAt: time.Now(),
+		maxDrops:    maxDrops,
+		kicked:      make(chan struct{}),
+	}
+}
+
+func (c *queuedClient) disconnect(closeReason) {
+	c.kickOnce.Do(func() { close(c.kicked) })
+}
+
+func (c *queuedClient) enqueue(msg *broadcastMessage) bool {
+	select {
+	case c.queue <- msg:
+		c.consecutive = 0
+		return true
+	default:
+		c.dropped.Add(1)
+		c.consecutive++
+		return false
+	}
+}
+
+// tooSlow reports whether the drop that just happened was the one that
+// reached the limit, so a client is only reported once.
+func (c *queuedClient) tooSlow() bool {
+	return c.maxDrops > 0 && c.consecutive == c.maxDrops
+}
+
+// warnDrop reports whether a drop that just happened should be logged,
+// which is at most once per dropWarningInterval per client.
+func (c *queuedClient) warnDrop(now time.Time) bool {
+	if now.Sub(c.lastDropWarning) < dropWarningInterval {
+		return false
+	}
+	c.lastDropWarning = now
+	return true
+}
+
+func (c *queuedClient) remoteAddr() string {
+	return c.remote
+}
+
+func (c *queuedClient) droppedCount() uint64 {
+	return c.dropped.Load()
+}
+
+// expired reports whether msg was broadcast more than ttl before now, and
+// counts it as dropped if so. A zero ttl never expires messages.
+func (c *queuedClient) expired(msg *broadcastMessage, ttl time.Duration, now time.Time) bool {
+	if ttl <= 0 || now.Sub(msg.env.Timestamp) <= ttl {
+		return false
+	}
+	c.dropped.Add(1)
+	return true
+}
+
+func (c *queuedClient) connection() connectionInfo {
+	types, services, paused := c.filters()
+	return connectionInfo{
+		Remote:      c.remote,
+		ConnectedAt: c.connectedAt,
+		Types:       types,
+		Services:    services,
+		Channels:    setList(c.channels),
+		Paused:      paused,
+		BytesSent:   c.sent.Load(),
+		Messages:    c.messages.Load(),
+		Queued:      len(c.queue),
+		Dropped:     c.dropped.Load(),
+	}
+}
+
+func (c *queuedClient) queued() int {
+	return len(c.queue)
+}
+
+func toSet(values []string) map[string]bool {
+	if len(values) == 0 {
+		return nil
+	}
+	set := make(map[string]bool, len(values))
+	for _, v := range values {
+		set[v] = true
+	}
+	return set
+}
+
+// splitList splits a comma-separated query parameter into its non-empty values.
+func splitList(s string) []string {
+	var values []string
+	for _, v := range strings.Split(s, ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			values = append(values, v)
+		}
+	}
+	return values
+}
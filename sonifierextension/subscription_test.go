@@ -0,0 +1,119 @@
+package sonifierextension
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSubscriptionChanges(t *testing.T) {
+	traces := &broadcastMessage{dataType: "traces", env: envelope{Service: "checkout"}}
+	logs := &broadcastMessage{dataType: "logs", env: envelope{Service: "auth"}}
+	metrics := &broadcastMessage{dataType: "metrics", env: envelope{Service: "checkout"}}
+	var c subscription
+	wanted := func() []string {
+		var types []string
+		for _, msg := range []*broadcastMessage{traces, logs, metrics} {
+			if c.wants(msg) {
+				types = append(types, msg.dataType)
+			}
+		}
+		return types
+	}
+
+	assert.Equal(t, []string{"traces", "logs", "metrics"}, wanted(), "unfiltered")
+
+	require.NoError(t, c.handleControl([]byte(`{"action":"subscribe","types":["traces","logs"]}`)))
+	assert.Equal(t, []string{"traces", "logs"}, wanted())
+
+	// A later subscribe replaces the filters rather than adding to them
+	require.NoError(t, c.handleControl([]byte(`{"action":"subscribe","services":["checkout"]}`)))
+	assert.Equal(t, []string{"traces", "metrics"}, wanted())
+	require.NoError(t, c.handleControl([]byte(`{"action":"subscribe","types":["traces","logs"],"services":["checkout"]}`)))
+	assert.Equal(t, []string{"traces"}, wanted())
+
+	require.NoError(t, c.handleControl([]byte(`{"action":"pause"}`)))
+	assert.Empty(t, wanted())
+	// Replay is only asked for when connecting, so a resume from a seq
+	// leaves the client paused
+	assert.ErrorContains(t, c.handleControl([]byte(`{"action":"resume","from_seq":42}`)), "/ws?from_seq=42")
+	assert.Empty(t, wanted())
+	require.NoError(t, c.handleControl([]byte(`{"action":"resume"}`)))
+	assert.Equal(t, []string{"traces"}, wanted(), "resuming keeps the filters")
+
+	require.NoError(t, c.handleControl([]byte(`{"action":"subscribe"}`)))
+	assert.Equal(t, []string{"traces", "logs", "metrics"}, wanted())
+
+	assert.ErrorContains(t, c.handleControl([]byte(`{"action":"mute"}`)), `unknown control action "mute"`)
+	assert.ErrorContains(t, c.handleControl([]byte(`not json`)), "invalid control message")
+}
+
+// waitSubscription waits until the extension's only WebSocket client has
+// applied the control messages sent so far, as ok reports.
+func waitSubscription(t *testing.T, s *sonifierExtension, ok func(c *subscription) bool) {
+	t.Helper()
+	require.Eventually(t, func() bool {
+		s.broadcaster.mu.Lock()
+		defer s.broadcaster.mu.Unlock()
+		for sub := range s.broadcaster.subscribers {
+			client := sub.(*wsClient)
+			client.mu.Lock()
+			applied := ok(&client.subscription)
+			client.mu.Unlock()
+			return applied
+		}
+		return false
+	}, 5*time.Second, time.Millisecond)
+}
+
+func TestWebSocketFilterMidStream(t *testing.T) {
+	// Each ingested payload is one message of its own type
+	s, base := startTestExtension(t)
+	conn := dialWebSocket(t, base, "")
+	waitSubscription(t, s, func(*subscription) bool { return true })
+	ingestAll := func() {
+		for _, p := range []struct{ signal, payload string }{{"traces", testTraces}, {"metrics", testMetrics}, {"logs", testLogs}} {
+			require.NoError(t, s.Ingest(context.Background(), p.signal, []byte(p.payload)))
+		}
+	}
+	// readTypes reads the types of the next n broadcast messages
+	readTypes := func(n int) []string {
+		var types []string
+		for len(types) < n {
+			if env := readEnvelope(t, conn); env.Seq > 0 {
+				types = append(types, env.Type)
+			}
+		}
+		return types
+	}
+
+	ingestAll()
+	assert.Equal(t, []string{"traces", "metrics", "logs"}, readTypes(3))
+
+	require.NoError(t, conn.WriteJSON(controlMessage{Action: "subscribe", Types: []string{"logs"}}))
+	waitSubscription(t, s, func(c *subscription) bool { return c.types["logs"] })
+	ingestAll()
+	assert.Equal(t, []string{"logs"}, readTypes(1))
+
+	// Switching to a service filter, the logs of auth stop and checkout's
+	// traces and metrics come through
+	require.NoError(t, conn.WriteJSON(controlMessage{Action: "subscribe", Services: []string{"checkout"}}))
+	waitSubscription(t, s, func(c *subscription) bool { return c.services["checkout"] })
+	ingestAll()
+	assert.Equal(t, []string{"traces", "metrics"}, readTypes(2))
+
+	// Nothing arrives while paused; the first message after resuming is
+	// the first one published after it
+	require.NoError(t, conn.WriteJSON(controlMessage{Action: "pause"}))
+	waitSubscription(t, s, func(c *subscription) bool { return c.paused })
+	ingestAll()
+	require.NoError(t, conn.WriteJSON(controlMessage{Action: "resume"}))
+	waitSubscription(t, s, func(c *subscription) bool { return !c.paused })
+	require.NoError(t, s.Ingest(context.Background(), "metrics", []byte(testMetrics)))
+	env := readEnvelope(t, conn)
+	assert.Equal(t, "metrics", env.Type)
+	assert.Equal(t, s.broadcaster.currentID(), env.Seq)
+}
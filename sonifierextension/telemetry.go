@@ -0,0 +1,165 @@
+package sonifierextension
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+)
+
+const scopeName = "github.com/gemini/sonifierextension"
+
+// extensionTelemetry holds the extension's own instruments.
+type extensionTelemetry struct {
+	broadcastDuration metric.Float64Histogram
+	broadcastFanout   metric.Int64Histogram
+	writeDuration     metric.Float64Histogram
+	received          metric.Int64Counter
+	receivedSize      metric.Int64Counter
+	rejected          metric.Int64Counter
+	clients           metric.Int64UpDownCounter
+	broadcast         metric.Int64Counter
+	dropped           metric.Int64Counter
+	slowDisconnects   metric.Int64Counter
+	clientDropped     metric.Int64Histogram
+	expired           metric.Int64Counter
+}
+
+func newExtensionTelemetry(meter metric.Meter) (*extensionTelemetry, error) {
+	var errs, err error
+	t := &extensionTelemetry{}
+
+	t.broadcastDuration, err = meter.Float64Histogram(
+		"sonifier.broadcast.duration",
+		metric.WithDescription("Time taken to fan a message out to all subscribers."),
+		metric.WithUnit("s"),
+	)
+	errs = errors.Join(errs, err)
+
+	t.broadcastFanout, err = meter.Int64Histogram(
+		"sonifier.broadcast.subscribers",
+		metric.WithDescription("Number of subscribers a message was queued for."),
+		metric.WithUnit("{subscriber}"),
+	)
+	errs = errors.Join(errs, err)
+
+	t.writeDuration, err = meter.Float64Histogram(
+		"sonifier.websocket.write.duration",
+		metric.WithDescription("Time taken to write a message to a single WebSocket client."),
+		metric.WithUnit("s"),
+	)
+	errs = errors.Join(errs, err)
+
+	t.received, err = meter.Int64Counter(
+		"sonifier.telemetry.received",
+		metric.WithDescription("Number of telemetry payloads received, by signal type."),
+		metric.WithUnit("{payload}"),
+	)
+	errs = errors.Join(errs, err)
+
+	t.receivedSize, err = meter.Int64Counter(
+		"sonifier.telemetry.received.size",
+		metric.WithDescription("Bytes of telemetry payloads received, by signal type."),
+		metric.WithUnit("By"),
+	)
+	errs = errors.Join(errs, err)
+
+	t.rejected, err = meter.Int64Counter(
+		"sonifier.telemetry.rejected",
+		metric.WithDescription("Number of telemetry payloads rejected, by reason."),
+		metric.WithUnit("{payload}"),
+	)
+	errs = errors.Join(errs, err)
+
+	t.clients, err = meter.Int64UpDownCounter(
+		"sonifier.clients",
+		metric.WithDescription("Number of connected streaming clients, by transport."),
+		metric.WithUnit("{client}"),
+	)
+	errs = errors.Join(errs, err)
+
+	t.broadcast, err = meter.Int64Counter(
+		"sonifier.messages.broadcast",
+		metric.WithDescription("Number of messages broadcast to streaming clients, by message type."),
+		metric.WithUnit("{message}"),
+	)
+	errs = errors.Join(errs, err)
+
+	t.dropped, err = meter.Int64Counter(
+		"sonifier.messages.dropped",
+		metric.WithDescription("Number of messages dropped for slow clients, counted once per client."),
+		metric.WithUnit("{message}"),
+	)
+	errs = errors.Join(errs, err)
+
+	t.clientDropped, err = meter.Int64Histogram(
+		"sonifier.client.dropped",
+		metric.WithDescription("Number of messages dropped for a streaming client over its connection, recorded when it disconnects, by transport."),
+		metric.WithUnit("{message}"),
+	)
+	errs = errors.Join(errs, err)
+
+	t.slowDisconnects, err = meter.Int64Counter(
+		"sonifier.clients.slow_disconnects",
+		metric.WithDescription("Number of streaming clients disconnected for dropping too many messages in a row, by transport."),
+		metric.WithUnit("{client}"),
+	)
+	errs = errors.Join(errs, err)
+
+	t.expired, err = meter.Int64Counter(
+		"sonifier.messages.expired",
+		metric.WithDescription("Number of messages skipped by a client's writer for being older than broadcast.message_ttl, by transport and message type."),
+		metric.WithUnit("{message}"),
+	)
+	errs = errors.Join(errs, err)
+
+	return t, errs
+}
+
+func (t *extensionTelemetry) recordBroadcast(ctx context.Context, msgType string, elapsed time.Duration, served, dropped int) {
+	t.broadcastDuration.Record(ctx, elapsed.Seconds())
+	t.broadcastFanout.Record(ctx, int64(served))
+	typeAttr := metric.WithAttributes(attribute.String("type", msgType))
+	t.broadcast.Add(ctx, 1, typeAttr)
+	if dropped > 0 {
+		t.dropped.Add(ctx, int64(dropped), typeAttr)
+	}
+}
+
+func (t *extensionTelemetry) recordReceived(ctx context.Context, dataType string, size int) {
+	typeAttr := metric.WithAttributes(attribute.String("type", dataType))
+	t.received.Add(ctx, 1, typeAttr)
+	t.receivedSize.Add(ctx, int64(size), typeAttr)
+}
+
+func (t *extensionTelemetry) recordRejected(ctx context.Context, reason string) {
+	t.rejected.Add(ctx, 1, metric.WithAttributes(attribute.String("reason", reason)))
+}
+
+// recordClients adds delta to the connected clients of a transport.
+func (t *extensionTelemetry) recordClients(ctx context.Context, transport string, delta int64) {
+	t.clients.Add(ctx, delta, metric.WithAttributes(attribute.String("transport", transport)))
+}
+
+// recordSlowDisconnect counts a client of a transport disconnected as too slow.
+func (t *extensionTelemetry) recordSlowDisconnect(ctx context.Context, transport string) {
+	t.slowDisconnects.Add(ctx, 1, metric.WithAttributes(attribute.String("transport", transport)))
+}
+
+// recordClientDropped records how many messages were dropped for a client
+// of a transport over its connection.
+func (t *extensionTelemetry) recordClientDropped(ctx context.Context, transport string, dropped uint64) {
+	t.clientDropped.Record(ctx, int64(dropped), metric.WithAttributes(attribute.String("transport", transport)))
+}
+
+// recordExpired counts a message of a type skipped by a client of a
+// transport because it outlived the message TTL.
+func (t *extensionTelemetry) recordExpired(ctx context.Context, transport, msgType string) {
+	t.expired.Add(ctx, 1, metric.WithAttributes(attribute.String("transport", transport), attribute.String("type", msgType)))
+}
+
+func (t *extensionTelemetry) recordWrite(ctx context.Context, elapsed time.Duration) {
+	t.writeDuration.Record(ctx, elapsed.Seconds())
+}
@@ -0,0 +1,376 @@
+package sonifierextension
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/collector/pdata/pcommon"
+	"go.opentelemetry.io/collector/pdata/ptrace"
+	"go.uber.org/zap"
+)
+
+// topologyInterval is how often the edges that changed are broadcast.
+const topologyInterval = time.Second
+
+// topologyFloor is the decayed call count below which an edge has faded
+// out of the graph.
+const topologyFloor = 0.01
+
+const peerServiceKey = "peer.service"
+
+// topologyEdge is one caller to callee edge of the service graph.
+type topologyEdge struct {
+	Caller string `json:"caller"`
+	Callee string `json:"callee"`
+	// Calls and Errors are decayed counts: each call adds one, and the
+	// total shrinks by a factor of e for every window without calls.
+	Calls  float64 `json:"calls"`
+	Errors float64 `json:"errors"`
+	// Removed is set in a delta for an edge that faded out or was evicted.
+	Removed bool `json:"removed,omitempty"`
+}
+
+// topologyGraph is the payload of a {"type":"topology"} message and of
+// /topology.
+type topologyGraph struct {
+	Edges []topologyEdge `json:"edges"`
+}
+
+// edgeCounts is the decayed state of one edge, as of updated.
+type edgeCounts struct {
+	caller, callee string
+	calls, errors  float64
+	updated        time.Time
+}
+
+// at returns the edge's counts decayed to now.
+func (e *edgeCounts) at(now time.Time, window time.Duration) topologyEdge {
+	f := math.Exp(-max(now.Sub(e.updated), 0).Seconds() / window.Seconds())
+	return topologyEdge{Caller: e.caller, Callee: e.callee, Calls: e.calls * f, Errors: e.errors * f}
+}
+
+// spanKey identifies a span across export requests.
+type spanKey struct {
+	trace pcommon.TraceID
+	span  pcommon.SpanID
+}
+
+// joinedSpan is a recently seen span that later children may name as
+// their parent.
+type joinedSpan struct {
+	service string
+	// counted is set for CLIENT spans with peer.service, whose call is
+	// already on the graph, so their children don't count it again.
+	counted bool
+	seen    time.Time
+}
+
+// orphanSpan is a span whose parent hasn't arrived yet.
+type orphanSpan struct {
+	service string
+	failed  bool
+	seen    time.Time
+}
+
+// pendingSpan is an entry of the join buffer, in arrival order. orphan
+// tells whether it is held in orphans, under its parent's key, or in spans.
+type pendingSpan struct {
+	key    spanKey
+	orphan bool
+	seen   time.Time
+}
+
+// topology builds a graph of which services call which from trace
+// payloads. A CLIENT span with peer.service is a call from its service to
+// that one. Otherwise a span whose parent belongs to another service is a
+// call from the parent's service to the span's, and spans are held for the
+// join window so that parents and children exported in separate requests
+// still meet. A nil topology records nothing.
+type topology struct {
+	cfg TopologyConfig
+
+	mu      sync.Mutex
+	edges   *seriesCache[edgeCounts]
+	changed map[string]struct{}
+	removed []topologyEdge
+	spans   map[spanKey]joinedSpan
+	orphans map[spanKey][]orphanSpan
+	pending []pendingSpan
+}
+
+func newTopology(cfg TopologyConfig) (*topology, error) {
+	if !cfg.Enabled {
+		return nil, nil
+	}
+	var errs []error
+	check := func(ok bool, format string, args ...any) {
+		if !ok {
+			errs = append(errs, fmt.Errorf(format, args...))
+		}
+	}
+	check(cfg.Window > 0, "topology.window must be positive")
+	check(cfg.MaxEdges > 0, "topology.max_edges must be positive")
+	check(cfg.JoinWindow >= 0, "topology.join_window must not be negative")
+	check(cfg.MaxPendingSpans > 0, "topology.max_pending_spans must be positive")
+	if len(errs) > 0 {
+		return nil, errors.Join(errs...)
+	}
+	t := &topology{
+		cfg:     cfg,
+		edges:   newSeriesCache[edgeCounts](cfg.MaxEdges),
+		changed: make(map[string]struct{}),
+		spans:   make(map[spanKey]joinedSpan),
+		orphans: make(map[spanKey][]orphanSpan),
+	}
+	t.edges.evicted = func(key string, e *edgeCounts) {
+		delete(t.changed, key)
+		t.removed = append(t.removed, topologyEdge{Caller: e.caller, Callee: e.callee, Removed: true})
+	}
+	return t, nil
+}
+
+// observe records the calls in a decoded traces payload received at now.
+func (t *topology) observe(d *decodedTelemetry, now time.Time) {
+	if t == nil || !d.parsed || d.dataType != "traces" {
+		return
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.expire(now)
+
+	// Every span is remembered before any is joined, so that parents and
+	// children in the same payload meet regardless of their order.
+	type child struct {
+		parent spanKey
+		orphan orphanSpan
+	}
+	var arrived []spanKey
+	var children []child
+	rs := d.traces.ResourceSpans()
+	for i := 0; i < rs.Len(); i++ {
+		var service string
+		if v, ok := rs.At(i).Resource().Attributes().Get(serviceNameKey); ok {
+			service = v.AsString()
+		}
+		if service == "" {
+			continue
+		}
+		ss := rs.At(i).ScopeSpans()
+		for j := 0; j < ss.Len(); j++ {
+			spans := ss.At(j).Spans()
+			for k := 0; k < spans.Len(); k++ {
+				span := spans.At(k)
+				failed := span.Status().Code() == ptrace.StatusCodeError
+				joined := joinedSpan{service: service, seen: now}
+				if span.Kind() == ptrace.SpanKindClient {
+					if peer, ok := span.Attributes().Get(peerServiceKey); ok && peer.AsString() != "" && peer.AsString() != service {
+						t.record(service, peer.AsString(), failed, now)
+						joined.counted = true
+					}
+				}
+				key := spanKey{trace: span.TraceID(), span: span.SpanID()}
+				t.remember(key, joined)
+				arrived = append(arrived, key)
+				if !span.ParentSpanID().IsEmpty() {
+					parent := spanKey{trace: span.TraceID(), span: span.ParentSpanID()}
+					children = append(children, child{parent: parent, orphan: orphanSpan{service: service, failed: failed, seen: now}})
+				}
+			}
+		}
+	}
+
+	// Children from earlier payloads whose parents just arrived
+	for _, key := range arrived {
+		for _, o := range t.orphans[key] {
+			t.link(t.spans[key], o, now)
+		}
+		delete(t.orphans, key)
+	}
+	for _, c := range children {
+		if parent, ok := t.spans[c.parent]; ok {
+			t.link(parent, c.orphan, now)
+			continue
+		}
+		if t.cfg.JoinWindow > 0 {
+			t.orphans[c.parent] = append(t.orphans[c.parent], c.orphan)
+			t.hold(pendingSpan{key: c.parent, orphan: true, seen: now})
+		}
+	}
+}
+
+// remember holds a span for children that arrive within the join window.
+// Without a join window it is only kept for the rest of the payload.
+func (t *topology) remember(key spanKey, span joinedSpan) {
+	t.spans[key] = span
+	t.hold(pendingSpan{key: key, seen: span.seen})
+}
+
+// hold queues a buffered span for expiry, dropping the oldest beyond
+// max_pending_spans.
+func (t *topology) hold(p pendingSpan) {
+	t.pending = append(t.pending, p)
+	for len(t.pending) > t.cfg.MaxPendingSpans {
+		t.release(t.pending[0])
+		t.pending = t.pending[1:]
+	}
+}
+
+// expire drops the buffered spans older than the join window.
+func (t *topology) expire(now time.Time) {
+	cutoff := now.Add(-t.cfg.JoinWindow)
+	for len(t.pending) > 0 && !t.pending[0].seen.After(cutoff) {
+		t.release(t.pending[0])
+		t.pending = t.pending[1:]
+	}
+	if len(t.pending) == 0 {
+		t.pending = nil
+	}
+}
+
+// release forgets a buffered span, unless it has been seen again since.
+func (t *topology) release(p pendingSpan) {
+	if !p.orphan {
+		if span, ok := t.spans[p.key]; ok && !span.seen.After(p.seen) {
+			delete(t.spans, p.key)
+		}
+		return
+	}
+	orphans := t.orphans[p.key]
+	if len(orphans) > 0 && !orphans[0].seen.After(p.seen) {
+		orphans = orphans[1:]
+	}
+	if len(orphans) == 0 {
+		delete(t.orphans, p.key)
+	} else {
+		t.orphans[p.key] = orphans
+	}
+}
+
+// link records the call from a parent span to its child when they belong
+// to different services.
+func (t *topology) link(parent joinedSpan, child orphanSpan, now time.Time) {
+	if parent.counted || parent.service == child.service {
+		return
+	}
+	t.record(parent.service, child.service, child.failed, now)
+}
+
+// record adds a call to the caller to callee edge.
+func (t *topology) record(caller, callee string, failed bool, now time.Time) {
+	key := caller + "\x00" + callee
+	e := t.edges.get(key)
+	current := e.at(now, t.cfg.Window)
+	if e.updated.IsZero() {
+		current = topologyEdge{}
+	}
+	*e = edgeCounts{caller: caller, callee: callee, calls: current.Calls + 1, errors: current.Errors, updated: now}
+	if failed {
+		e.errors++
+	}
+	t.changed[key] = struct{}{}
+}
+
+// deltas returns the edges that gained calls since the last call, and those
+// that faded out or were evicted meanwhile, which are dropped.
+func (t *topology) deltas(now time.Time) []topologyEdge {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	edges := t.removed
+	t.removed = nil
+	t.edges.each(func(key string, e *edgeCounts) {
+		current := e.at(now, t.cfg.Window)
+		if current.Calls < topologyFloor {
+			t.edges.remove(key)
+			delete(t.changed, key)
+			edges = append(edges, topologyEdge{Caller: e.caller, Callee: e.callee, Removed: true})
+			return
+		}
+		if _, ok := t.changed[key]; ok {
+			edges = append(edges, current)
+		}
+	})
+	clear(t.changed)
+	sortEdges(edges)
+	return edges
+}
+
+// graph returns every edge as of now.
+func (t *topology) graph(now time.Time) topologyGraph {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	edges := make([]topologyEdge, 0, t.edges.len())
+	t.edges.each(func(_ string, e *edgeCounts) {
+		if current := e.at(now, t.cfg.Window); current.Calls >= topologyFloor {
+			edges = append(edges, current)
+		}
+	})
+	sortEdges(edges)
+	return topologyGraph{Edges: edges}
+}
+
+// reset forgets every edge and buffered span.
+func (t *topology) reset() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.edges.reset()
+	clear(t.changed)
+	t.removed = nil
+	clear(t.spans)
+	clear(t.orphans)
+	t.pending = nil
+}
+
+func sortEdges(edges []topologyEdge) {
+	slices.SortStableFunc(edges, func(a, b topologyEdge) int {
+		if c := strings.Compare(a.Caller, b.Caller); c != 0 {
+			return c
+		}
+		return strings.Compare(a.Callee, b.Callee)
+	})
+}
+
+// runTopology broadcasts the edges that changed every interval until stop
+// is closed.
+func (s *sonifierExtension) runTopology(stop <-chan struct{}) {
+	ticker := time.NewTicker(topologyInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-stop:
+			return
+		case now := <-ticker.C:
+			if edges := s.topology.deltas(now); len(edges) > 0 {
+				s.broadcastJSON("topology", topologyGraph{Edges: edges})
+			}
+		}
+	}
+}
+
+// handleTopology returns the current service graph.
+func (s *sonifierExtension) handleTopology(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if s.topology == nil {
+		http.Error(w, "Topology is not enabled, set topology.enabled", http.StatusConflict)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(s.topology.graph(time.Now())); err != nil {
+		s.logger.Error("Failed to write topology response", zap.Error(err))
+	}
+}
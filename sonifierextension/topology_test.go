@@ -0,0 +1,209 @@
+package sonifierextension
+
+import (
+	"encoding/json"
+	"math"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/collector/pdata/pcommon"
+	"go.opentelemetry.io/collector/pdata/ptrace"
+)
+
+// topologySpan is one span of a payload built by topologyTraces.
+type topologySpan struct {
+	service      string
+	span, parent byte
+	// peer is the peer.service of a CLIENT span, empty for a SERVER one.
+	peer   string
+	failed bool
+}
+
+// topologyTraces returns a decoded traces payload of spans, all in one
+// trace, with span IDs made from their bytes.
+func topologyTraces(spans ...topologySpan) *decodedTelemetry {
+	td := ptrace.NewTraces()
+	for _, s := range spans {
+		rs := td.ResourceSpans().AppendEmpty()
+		rs.Resource().Attributes().PutStr(serviceNameKey, s.service)
+		span := rs.ScopeSpans().AppendEmpty().Spans().AppendEmpty()
+		span.SetTraceID(pcommon.TraceID{1})
+		span.SetSpanID(pcommon.SpanID{s.span})
+		if s.parent != 0 {
+			span.SetParentSpanID(pcommon.SpanID{s.parent})
+		}
+		span.SetKind(ptrace.SpanKindServer)
+		if s.peer != "" {
+			span.SetKind(ptrace.SpanKindClient)
+			span.Attributes().PutStr(peerServiceKey, s.peer)
+		}
+		if s.failed {
+			span.Status().SetCode(ptrace.StatusCodeError)
+		}
+	}
+	return &decodedTelemetry{dataType: "traces", parsed: true, traces: td}
+}
+
+func newTestTopology(t *testing.T, modify func(*TopologyConfig)) *topology {
+	t.Helper()
+	cfg := createDefaultConfig().(*Config).Topology
+	cfg.Enabled = true
+	if modify != nil {
+		modify(&cfg)
+	}
+	topo, err := newTopology(cfg)
+	require.NoError(t, err)
+	return topo
+}
+
+// assertEdges checks edges against want, with counts up to rounding.
+func assertEdges(t *testing.T, want, edges []topologyEdge) {
+	t.Helper()
+	require.Len(t, edges, len(want), "%+v", edges)
+	for i := range want {
+		assert.Equal(t, want[i].Caller, edges[i].Caller, "edge %d", i)
+		assert.Equal(t, want[i].Callee, edges[i].Callee, "edge %d", i)
+		assert.InDelta(t, want[i].Calls, edges[i].Calls, 0.01, "edge %d", i)
+		assert.InDelta(t, want[i].Errors, edges[i].Errors, 0.01, "edge %d", i)
+		assert.Equal(t, want[i].Removed, edges[i].Removed, "edge %d", i)
+	}
+}
+
+func TestTopologyEndpoint(t *testing.T) {
+	_, url := startTestExtension(t, func(cfg *Config) { cfg.Topology.Enabled = true })
+	body, err := os.ReadFile(filepath.Join("testdata", "topology", "traces.json"))
+	require.NoError(t, err)
+	status, _ := postTelemetry(t, url, "/v1/traces", string(body))
+	require.Equal(t, http.StatusOK, status)
+
+	resp, err := http.Get(url + "/topology")
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	var graph topologyGraph
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&graph))
+
+	// frontend calls checkout once through a CLIENT span, which its
+	// checkout child doesn't count again, and once through a direct
+	// child; charge is internal to checkout, payments fails and so does
+	// its database call, and the span without a service is left out
+	assertEdges(t, []topologyEdge{
+		{Caller: "checkout", Callee: "payments", Calls: 1, Errors: 1},
+		{Caller: "frontend", Callee: "checkout", Calls: 2},
+		{Caller: "payments", Callee: "postgres", Calls: 1, Errors: 1},
+	}, graph.Edges)
+}
+
+func TestTopologyEndpointDisabled(t *testing.T) {
+	_, url := startTestExtension(t)
+	resp, err := http.Get(url + "/topology")
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusConflict, resp.StatusCode)
+}
+
+func TestTopologyJoinsAcrossPayloads(t *testing.T) {
+	topo := newTestTopology(t, nil)
+	now := time.Unix(1000, 0)
+
+	// The child arrives first and waits for its parent
+	topo.observe(topologyTraces(topologySpan{service: "checkout", span: 2, parent: 1, failed: true}), now)
+	assert.Empty(t, topo.graph(now).Edges)
+	topo.observe(topologyTraces(topologySpan{service: "frontend", span: 1}), now.Add(time.Second))
+	assertEdges(t, []topologyEdge{{Caller: "frontend", Callee: "checkout", Calls: 1, Errors: 1}}, topo.graph(now.Add(time.Second)).Edges)
+
+	// A child of the same parent within the join window still meets it,
+	// one after it doesn't
+	topo.observe(topologyTraces(topologySpan{service: "payments", span: 3, parent: 1}), now.Add(5*time.Second))
+	topo.observe(topologyTraces(topologySpan{service: "auth", span: 4, parent: 1}), now.Add(20*time.Second))
+	graph := topo.graph(now.Add(20 * time.Second))
+	require.Len(t, graph.Edges, 2)
+	assert.Equal(t, "payments", graph.Edges[1].Callee)
+}
+
+func TestTopologyWithoutJoinWindow(t *testing.T) {
+	topo := newTestTopology(t, func(cfg *TopologyConfig) { cfg.JoinWindow = 0 })
+	now := time.Unix(1000, 0)
+
+	topo.observe(topologyTraces(topologySpan{service: "checkout", span: 2, parent: 1}), now)
+	topo.observe(topologyTraces(topologySpan{service: "frontend", span: 1}), now)
+	assert.Empty(t, topo.graph(now).Edges, "only spans in the same payload meet")
+
+	topo.observe(topologyTraces(topologySpan{service: "checkout", span: 2, parent: 1}, topologySpan{service: "frontend", span: 1}), now)
+	assertEdges(t, []topologyEdge{{Caller: "frontend", Callee: "checkout", Calls: 1}}, topo.graph(now).Edges)
+}
+
+func TestTopologyDeltas(t *testing.T) {
+	topo := newTestTopology(t, nil)
+	now := time.Unix(1000, 0)
+	call := func(caller, callee string, at time.Time) {
+		topo.observe(topologyTraces(topologySpan{service: caller, span: 1, peer: callee}), at)
+	}
+
+	call("frontend", "checkout", now)
+	call("frontend", "checkout", now)
+	call("checkout", "payments", now)
+	assertEdges(t, []topologyEdge{
+		{Caller: "checkout", Callee: "payments", Calls: 1},
+		{Caller: "frontend", Callee: "checkout", Calls: 2},
+	}, topo.deltas(now))
+	assert.Empty(t, topo.deltas(now), "nothing changed since")
+
+	// Counts decay by e every window, and only the edge that gained a call
+	// is sent
+	later := now.Add(time.Minute)
+	call("checkout", "payments", later)
+	assertEdges(t, []topologyEdge{{Caller: "checkout", Callee: "payments", Calls: 1 + 1/math.E}}, topo.deltas(later))
+	assertEdges(t, []topologyEdge{
+		{Caller: "checkout", Callee: "payments", Calls: 1 + 1/math.E},
+		{Caller: "frontend", Callee: "checkout", Calls: 2 / math.E},
+	}, topo.graph(later).Edges)
+
+	// Faded edges are sent once as removed and leave the graph
+	faded := now.Add(10 * time.Minute)
+	assertEdges(t, []topologyEdge{
+		{Caller: "checkout", Callee: "payments", Removed: true},
+		{Caller: "frontend", Callee: "checkout", Removed: true},
+	}, topo.deltas(faded))
+	assert.Empty(t, topo.graph(faded).Edges)
+	assert.Empty(t, topo.deltas(faded))
+}
+
+func TestTopologyEvictsLeastRecentlyCalled(t *testing.T) {
+	topo := newTestTopology(t, func(cfg *TopologyConfig) { cfg.MaxEdges = 1 })
+	now := time.Unix(1000, 0)
+	topo.observe(topologyTraces(topologySpan{service: "frontend", span: 1, peer: "checkout"}), now)
+	topo.observe(topologyTraces(topologySpan{service: "checkout", span: 2, peer: "payments"}), now)
+
+	assertEdges(t, []topologyEdge{
+		{Caller: "checkout", Callee: "payments", Calls: 1},
+		{Caller: "frontend", Callee: "checkout", Removed: true},
+	}, topo.deltas(now))
+
+	topo.reset()
+	assert.Empty(t, topo.graph(now).Edges)
+	assert.Empty(t, topo.deltas(now))
+}
+
+func TestNewTopologyErrors(t *testing.T) {
+	topo, err := newTopology(TopologyConfig{})
+	require.NoError(t, err)
+	assert.Nil(t, topo, "disabled")
+	topo.observe(topologyTraces(topologySpan{service: "frontend", span: 1, peer: "checkout"}), time.Now())
+
+	_, err = newTopology(TopologyConfig{Enabled: true, JoinWindow: -time.Second})
+	require.Error(t, err)
+	for _, want := range []string{
+		"topology.window must be positive",
+		"topology.max_edges must be positive",
+		"topology.join_window must not be negative",
+		"topology.max_pending_spans must be positive",
+	} {
+		assert.ErrorContains(t, err, want)
+	}
+}
@@ -0,0 +1,147 @@
+package sonifierextension
+
+import (
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"maps"
+	"regexp"
+	"slices"
+	"strings"
+
+	"go.opentelemetry.io/collector/pdata/plog"
+)
+
+var hexColor = regexp.MustCompile(`^#([0-9a-fA-F]{3}|[0-9a-fA-F]{6})$`)
+
+// severityLevels are the severity names of severityNumbers, from the most
+// severe down.
+var severityLevels = []string{"FATAL", "ERROR", "WARN", "INFO", "DEBUG", "TRACE"}
+
+// voice is how the UI should play an operation or log severity.
+type voice struct {
+	Instrument string `json:"instrument,omitempty"`
+	Color      string `json:"color,omitempty"`
+}
+
+// voicer gives operations and log severities the voices configured for
+// them, so every client plays them the same way. A nil voicer adds
+// nothing.
+type voicer struct {
+	operations map[string]voice
+	severities map[string]voice
+	palette    []voice
+}
+
+func newVoicer(cfg VoicesConfig) (*voicer, error) {
+	if len(cfg.Operations) == 0 && len(cfg.Severities) == 0 && len(cfg.Palette) == 0 {
+		return nil, nil
+	}
+	v := &voicer{
+		operations: make(map[string]voice),
+		severities: make(map[string]voice),
+	}
+	var errs []error
+	compile := func(key string, hint VoiceHint) voice {
+		if hint.Instrument == "" && hint.Color == "" {
+			errs = append(errs, fmt.Errorf("%s needs an instrument, a color or both", key))
+		}
+		if hint.Color != "" && !hexColor.MatchString(hint.Color) {
+			errs = append(errs, fmt.Errorf("%s.color must be a hex color such as #e91e63, got %q", key, hint.Color))
+		}
+		return voice{Instrument: hint.Instrument, Color: hint.Color}
+	}
+	for _, name := range slices.Sorted(maps.Keys(cfg.Operations)) {
+		v.operations[name] = compile("mappings.voices.operations."+name, cfg.Operations[name])
+	}
+	for _, name := range slices.Sorted(maps.Keys(cfg.Severities)) {
+		level := strings.ToUpper(name)
+		if _, ok := severityNumbers[level]; !ok {
+			errs = append(errs, fmt.Errorf("mappings.voices.severities: unknown severity %q, expected TRACE, DEBUG, INFO, WARN, ERROR or FATAL", name))
+			continue
+		}
+		v.severities[level] = compile("mappings.voices.severities."+name, cfg.Severities[name])
+	}
+	for i, hint := range cfg.Palette {
+		v.palette = append(v.palette, compile(fmt.Sprintf("mappings.voices.palette[%d]", i), hint))
+	}
+	if len(errs) > 0 {
+		return nil, errors.Join(errs...)
+	}
+	return v, nil
+}
+
+// applies reports whether d is a payload with operations or severities
+// to give voices.
+func (v *voicer) applies(d *decodedTelemetry) bool {
+	return v != nil && d.parsed && (d.dataType == "traces" || d.dataType == "logs")
+}
+
+// voices returns the voice of every span name or log severity level in d
+// that has one.
+func (v *voicer) voices(d *decodedTelemetry) map[string]voice {
+	voices := make(map[string]voice)
+	switch d.dataType {
+	case "traces":
+		rs := d.traces.ResourceSpans()
+		for i := 0; i < rs.Len(); i++ {
+			ss := rs.At(i).ScopeSpans()
+			for j := 0; j < ss.Len(); j++ {
+				spans := ss.At(j).Spans()
+				for k := 0; k < spans.Len(); k++ {
+					name := spans.At(k).Name()
+					if _, ok := voices[name]; ok {
+						continue
+					}
+					if hint, ok := v.operation(name); ok {
+						voices[name] = hint
+					}
+				}
+			}
+		}
+	case "logs":
+		rl := d.logs.ResourceLogs()
+		for i := 0; i < rl.Len(); i++ {
+			sl := rl.At(i).ScopeLogs()
+			for j := 0; j < sl.Len(); j++ {
+				records := sl.At(j).LogRecords()
+				for k := 0; k < records.Len(); k++ {
+					level := severityLevel(records.At(k).SeverityNumber())
+					if hint, ok := v.severities[level]; ok {
+						voices[level] = hint
+					}
+				}
+			}
+		}
+	}
+	if len(voices) == 0 {
+		return nil
+	}
+	return voices
+}
+
+// operation returns the voice of a span name: the one configured for it,
+// or else one picked from the palette by hashing the name, which is the
+// same on every client and after every restart.
+func (v *voicer) operation(name string) (voice, bool) {
+	if hint, ok := v.operations[name]; ok {
+		return hint, true
+	}
+	if len(v.palette) == 0 {
+		return voice{}, false
+	}
+	h := fnv.New32a()
+	h.Write([]byte(name))
+	return v.palette[h.Sum32()%uint32(len(v.palette))], true
+}
+
+// severityLevel returns the name of the level a severity number falls in,
+// or an empty string when it is unset.
+func severityLevel(sev plog.SeverityNumber) string {
+	for _, level := range severityLevels {
+		if sev >= severityNumbers[level] {
+			return level
+		}
+	}
+	return ""
+}
@@ -56,6 +56,65 @@ export class RainEngine {
     }
 
 
+    // A long, bright noise crash marking a deployment
+    playCymbal() {
+        if (!this.audioContext) return;
+
+        const currentTime = this.audioContext.currentTime;
+        const duration = 1.5;
+
+        const length = Math.floor(this.audioContext.sampleRate * duration);
+        const buffer = this.audioContext.createBuffer(1, length, this.audioContext.sampleRate);
+        const output = buffer.getChannelData(0);
+        for (let i = 0; i < length; i++) {
+            output[i] = Math.random() * 2 - 1;
+        }
+
+        const noiseSource = this.audioContext.createBufferSource();
+        const filter = this.audioContext.createBiquadFilter();
+        const gainNode = this.audioContext.createGain();
+        noiseSource.buffer = buffer;
+
+        filter.type = 'highpass';
+        filter.frequency.setValueAtTime(5000, currentTime);
+
+        gainNode.gain.setValueAtTime(0, currentTime);
+        gainNode.gain.linearRampToValueAtTime(0.3, currentTime + 0.005);
+        gainNode.gain.exponentialRampToValueAtTime(0.001, currentTime + duration);
+
+        noiseSource.connect(filter);
+        filter.connect(gainNode);
+        gainNode.connect(this.audioContext.destination);
+
+        noiseSource.start(currentTime);
+        noiseSource.stop(currentTime + duration);
+    }
+
+    playSoundEvent(event) {
+        if (!this.audioContext) return;
+
+        const currentTime = this.audioContext.currentTime;
+        const duration = (event.duration_ms || 200) / 1000;
+
+        const oscillator = this.audioContext.createOscillator();
+        const gainNode = this.audioContext.createGain();
+
+        // Each instrument name picks a waveform; unknown names sound as sine
+        const waveforms = { brass: 'sawtooth', synth: 'square', bell: 'triangle' };
+        oscillator.type = waveforms[event.instrument] || 'sine';
+        oscillator.frequency.setValueAtTime(event.pitch || 440, currentTime);
+
+        const volume = 0.2 * Math.min(Math.max(event.velocity || 0, 0), 1);
+        gainNode.gain.setValueAtTime(0, currentTime);
+        gainNode.gain.linearRampToValueAtTime(volume, currentTime + 0.01);
+        gainNode.gain.exponentialRampToValueAtTime(0.001, currentTime + duration);
+
+        oscillator.connect(gainNode);
+        gainNode.connect(this.audioContext.destination);
+
+        oscillator.start(currentTime);
+        oscillator.stop(currentTime + duration);
+    }
 
     stop() {
         if (this.audioContext) {
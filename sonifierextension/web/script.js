@@ -19,8 +19,17 @@ class TelemetryVisualizer {
         // Target metric level tracking
         this.targetMetricLevel = 0;
         this.currentSkyId = 'sky-low';
+
+        // When anything, and telemetry in particular, last arrived, and how
+        // often the server promised heartbeats, to tell a quiet stream from
+        // a dead one
+        this.lastMessageAt = 0;
+        this.lastTelemetryAt = 0;
+        this.heartbeatIntervalMs = 0;
         
         this.initializeUI();
+        this.fetchControlState();
+        this.startStatsPolling();
         this.startDataFetching();
         this.setupAnimationLoop();
         this.startConstantRain();
@@ -38,25 +47,100 @@ class TelemetryVisualizer {
         });
     }
 
+    // WebSocket and EventSource can't send headers, so a listener token
+    // given to the page as ?token= is passed on in the query string
+    withToken(path) {
+        const token = new URLSearchParams(window.location.search).get('token');
+        if (!token) {
+            return path;
+        }
+        return `${path}${path.includes('?') ? '&' : '?'}token=${encodeURIComponent(token)}`;
+    }
+
+    fetchControlState() {
+        // Control messages only announce changes, so pick up a mute that predates this page
+        fetch(this.withToken('/control'))
+            .then(response => response.json())
+            .then(state => this.setMuted(state.muted))
+            .catch(error => console.error('Error fetching control state:', error));
+    }
+
+    setMuted(muted) {
+        document.getElementById('muted-indicator').hidden = !muted;
+    }
+
+    setConnectionStatus(status) {
+        const labels = { live: 'Live', idle: 'Connected, no activity', disconnected: 'Disconnected, reconnecting…' };
+        const element = document.getElementById('connection-status');
+        element.className = status;
+        element.textContent = labels[status];
+    }
+
+    // Refreshes the connection status from what arrived lately. A stream
+    // that missed its heartbeats is closed, so it reconnects.
+    checkConnection(ws) {
+        const now = Date.now();
+        const interval = this.heartbeatIntervalMs;
+        if (interval > 0 && now - this.lastMessageAt > 2.5 * interval) {
+            console.warn('Heartbeats stopped, reconnecting');
+            this.setConnectionStatus('disconnected');
+            ws.close();
+            return;
+        }
+        this.setConnectionStatus(now - this.lastTelemetryAt < Math.max(interval, 10000) ? 'live' : 'idle');
+    }
+
+    startStatsPolling() {
+        const update = () => {
+            fetch(this.withToken('/stats'))
+                .then(response => response.json())
+                .then(stats => {
+                    const { traces, metrics, logs } = stats.signals;
+                    const clients = stats.clients.websocket + stats.clients.sse;
+                    document.getElementById('stats-bar').textContent =
+                        `Received ${traces.received} traces · ${metrics.received} metrics · ${logs.received} logs · ${clients} listening`;
+                })
+                .catch(error => console.error('Error fetching stats:', error));
+        };
+        update();
+        setInterval(update, 5000);
+    }
+
     startDataFetching() {
+        // Kiosks behind proxies that block WebSocket upgrades can use ?transport=sse
+        const params = new URLSearchParams(window.location.search);
+        if (params.get('transport') === 'sse') {
+            this.startEventStream();
+            return;
+        }
+
         // Connect to WebSocket for real-time data streaming
         const protocol = window.location.protocol === 'https:' ? 'wss:' : 'ws:';
-        const wsUrl = `${protocol}//${window.location.host}/ws`;
+        const wsBase = `${protocol}//${window.location.host}/ws`;
         
+        // The last broadcast seq played, so a reconnect can pick up where
+        // the previous connection left off
+        let lastSeq = 0;
+
         const connectWebSocket = () => {
-            const ws = new WebSocket(wsUrl);
+            // A reconnect asks for what it missed before anything live
+            const wsUrl = lastSeq > 0 ? `${wsBase}?from_seq=${lastSeq + 1}` : wsBase;
+            const ws = new WebSocket(this.withToken(wsUrl));
+            let statusTimer;
             
             ws.onopen = () => {
                 console.log('WebSocket connected - real-time streaming active');
+                this.lastMessageAt = Date.now();
+                this.setConnectionStatus('idle');
+                statusTimer = setInterval(() => this.checkConnection(ws), 1000);
             };
             
             ws.onmessage = (event) => {
                 try {
                     const data = JSON.parse(event.data);
-                    if (data.payload) {
-                        const analyzedTelemetry = this.telemetryAnalyzer.analyzeTelemetry(data.payload);
-                        this.updateVisualization(analyzedTelemetry, data.type);
-                    }
+                    // Messages for this client alone have seq 0
+                    lastSeq = Math.max(lastSeq, data.seq || 0);
+                    this.handleMessage(data);
                 } catch (error) {
                     console.error('Error processing WebSocket data:', error);
                 }
@@ -64,6 +148,8 @@ class TelemetryVisualizer {
             
             ws.onclose = (event) => {
                 console.log('WebSocket disconnected, attempting to reconnect...');
+                clearInterval(statusTimer);
+                this.setConnectionStatus('disconnected');
                 // Reconnect after a short delay
                 setTimeout(connectWebSocket, 2000);
             };
@@ -76,6 +162,109 @@ class TelemetryVisualizer {
         connectWebSocket();
     }
 
+    startEventStream() {
+        // EventSource reconnects on its own and resumes via Last-Event-ID
+        const source = new EventSource(this.withToken('/events'));
+
+        source.onopen = () => {
+            console.log('Event stream connected - real-time streaming active');
+            this.setConnectionStatus('live');
+        };
+
+        source.onmessage = (event) => {
+            try {
+                this.handleMessage(JSON.parse(event.data));
+            } catch (error) {
+                console.error('Error processing event stream data:', error);
+            }
+        };
+
+        source.onerror = (error) => {
+            console.error('Event stream error:', error);
+            this.setConnectionStatus('disconnected');
+        };
+    }
+
+    handleMessage(data) {
+        if (!data.payload) {
+            return;
+        }
+        this.lastMessageAt = Date.now();
+        if (data.type === 'heartbeat') {
+            this.heartbeatIntervalMs = data.payload.interval_ms;
+            return;
+        }
+        if (['traces', 'notes', 'metrics', 'logs', 'sound_event'].includes(data.type)) {
+            this.lastTelemetryAt = this.lastMessageAt;
+        }
+        if (data.type === 'control') {
+            this.setMuted(data.payload.muted);
+            return;
+        }
+        if (data.type === 'config') {
+            console.info('Sonifier settings changed', data.payload);
+            return;
+        }
+        if (data.type === 'resume_gap') {
+            console.warn(`Missed messages before seq ${data.payload.oldest_seq} while disconnected`);
+            return;
+        }
+        if (data.type === 'reset') {
+            this.resetVisualization();
+            return;
+        }
+        if (data.type === 'alarm') {
+            console.warn(`Alarm ${data.payload.active ? 'raised' : 'cleared'}: ${data.payload.reason}`);
+            return;
+        }
+        if (data.type === 'alert') {
+            console.warn(`${data.payload.severity} ${data.payload.kind} on ${data.payload.series}: ${data.payload.value}`);
+            return;
+        }
+        if (data.type === 'topology') {
+            console.debug(`Topology: ${data.payload.edges.length} edges changed`);
+            return;
+        }
+        if (data.payload.oversize) {
+            console.warn(`Skipped ${data.type} message of ${data.payload.oversize.bytes} bytes, over the ${data.payload.oversize.limit} byte limit`);
+            return;
+        }
+        // Events from server-side mapping rules are played as-is
+        if (data.type === 'sound_event') {
+            if (this.isAudioEnabled) {
+                this.rainEngine.playSoundEvent(data.payload);
+            }
+            return;
+        }
+        // Histogram chords from the server sound all their notes at once
+        if (data.chord && this.isAudioEnabled) {
+            data.chord.forEach(chord => chord.notes.forEach(note => this.rainEngine.playSoundEvent({
+                instrument: 'bell', pitch: note.pitch, velocity: note.velocity, duration_ms: 600
+            })));
+        }
+        const analyzedTelemetry = this.telemetryAnalyzer.analyzeTelemetry(data.payload);
+        // Operations with a voice from the server look and sound the same on every client
+        if (data.voice) {
+            analyzedTelemetry.traces.traceIds?.forEach(trace => {
+                trace.voice = data.voice[trace.name];
+            });
+        }
+        this.updateVisualization(analyzedTelemetry, data.type === 'notes' ? 'traces' : data.type);
+    }
+
+    // The server cleared its state for a new session, so start from calm
+    resetVisualization() {
+        this.raindrops.concat(this.errorBlooms).forEach(element => element.remove());
+        this.currentActivity = 0;
+        this.raindrops = [];
+        this.errorBlooms = [];
+        this.traceQueue = [];
+        this.currentPlaybackRate = this.basePlaybackRate;
+        this.lastTraceCount = 0;
+        this.targetMetricLevel = 0;
+        this.updateSkyGradient(0.1);
+    }
+
     updateVisualization(telemetry, dataType) {
         // Calculate individual activities
         const traceActivity = Math.min(telemetry.traces.count / 10, 1); // More sensitive to traces
@@ -137,6 +326,11 @@ class TelemetryVisualizer {
         if (telemetry.traces.errorRate > 0.3 || telemetry.logs.errorRate > 0.5) {
             this.createErrorBloom();
         }
+
+        // Deployments get a cymbal crash
+        if (telemetry.logs.deployments > 0 && this.isAudioEnabled) {
+            this.rainEngine.playCymbal();
+        }
     }
 
     updateSkyGradient(targetLevel) {
@@ -178,6 +372,9 @@ class TelemetryVisualizer {
         // Create raindrop made of trace ID characters
         const raindrop = document.createElement('div');
         raindrop.className = `raindrop ${trace.isError ? 'error' : ''}`;
+        if (trace.voice?.color && !trace.isError) {
+            raindrop.style.color = trace.voice.color;
+        }
         
         // Use the trace ID characters
         const traceId = trace.id || trace.shortId;
@@ -209,7 +406,11 @@ class TelemetryVisualizer {
         setTimeout(() => {
             if (raindrop.parentNode) {
                 // Play raindrop sound when hitting ground
-                if (this.isAudioEnabled) {
+                if (this.isAudioEnabled && trace.voice?.instrument) {
+                    this.rainEngine.playSoundEvent({
+                        instrument: trace.voice.instrument, pitch: 660, velocity: 0.3, duration_ms: 150
+                    });
+                } else if (this.isAudioEnabled) {
                     this.rainEngine.playRaindropSound();
                 }
                 
@@ -15,6 +15,9 @@ export class TelemetryAnalyzer {
     }
 
     analyzeTraces(rawTelemetry) {
+        if (rawTelemetry.notes) {
+            return this.analyzeNotes(rawTelemetry.notes);
+        }
         if (!rawTelemetry.resourceSpans) {
             return { count: 0, errorRate: 0, averageLength: 0, traceIds: [] };
         }
@@ -36,6 +39,7 @@ export class TelemetryAnalyzer {
                         traceIds.push({
                             id: span.traceId,
                             shortId: span.traceId.substring(0, 8), // First 8 characters
+                            name: span.name,
                             isError: isError
                         });
                     }
@@ -75,6 +79,36 @@ export class TelemetryAnalyzer {
         };
     }
 
+    // Notes are spans the server already reduced to what the visualization needs
+    analyzeNotes(notes) {
+        let errorTraces = 0;
+        let totalDuration = 0;
+        const traceIds = notes.map((note) => {
+            if (note.error) {
+                errorTraces++;
+            }
+            totalDuration += note.duration_ms;
+            this.traceDurations.push(note.duration_ms);
+            return {
+                id: note.trace_id,
+                shortId: note.trace_id.substring(0, 8),
+                name: note.name,
+                isError: !!note.error
+            };
+        });
+
+        if (this.traceDurations.length > 100) {
+            this.traceDurations = this.traceDurations.slice(-100);
+        }
+
+        return {
+            count: notes.length,
+            errorRate: notes.length > 0 ? errorTraces / notes.length : 0,
+            averageLength: notes.length > 0 ? totalDuration / notes.length : 0,
+            traceIds
+        };
+    }
+
     analyzeMetrics(rawTelemetry) {
         if (!rawTelemetry.resourceMetrics) {
             return { cpu: 0, memory: 0, disk: 0, critical: false };
@@ -126,11 +160,12 @@ export class TelemetryAnalyzer {
 
     analyzeLogs(rawTelemetry) {
         if (!rawTelemetry.resourceLogs) {
-            return { errorRate: 0, totalCount: 0 };
+            return { errorRate: 0, totalCount: 0, deployments: 0 };
         }
 
         let totalLogs = 0;
         let errorLogs = 0;
+        let deployments = 0;
 
         rawTelemetry.resourceLogs.forEach((resourceLog) => {
             resourceLog.scopeLogs?.forEach((scopeLog) => {
@@ -141,13 +176,20 @@ export class TelemetryAnalyzer {
                         (log.severityNumber && log.severityNumber >= 17)) {
                         errorLogs++;
                     }
+
+                    // Deployment markers, such as those from otelgen --deploy-at
+                    const eventName = log.eventName ||
+                        log.attributes?.find(attr => attr.key === 'event.name')?.value?.stringValue;
+                    if (eventName === 'deployment') {
+                        deployments++;
+                    }
                 });
             });
         });
 
         const errorRate = totalLogs > 0 ? errorLogs / totalLogs : 0;
 
-        return { errorRate, totalCount: totalLogs };
+        return { errorRate, totalCount: totalLogs, deployments };
     }
 
 
@@ -0,0 +1,326 @@
+package sonifierextension
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// webhookEventTypes are the message types webhooks can receive.
+var webhookEventTypes = []string{"alert", "alarm", "summary"}
+
+const (
+	defaultWebhookTimeout = 5 * time.Second
+	// webhookQueueSize is how many events wait for a slow endpoint before
+	// further ones are dropped.
+	webhookQueueSize = 100
+	// webhookRetries is how many times a failed delivery is retried, with
+	// the wait doubling from webhookBackoff.
+	webhookRetries = 3
+	webhookBackoff = 500 * time.Millisecond
+	// webhookBreakerFailures is how many deliveries in a row must fail,
+	// retries included, before the circuit opens and events are dropped
+	// for webhookBreakerCooldown. The next event after that is a single
+	// attempt that closes the circuit again if it succeeds.
+	webhookBreakerFailures = 5
+	webhookBreakerCooldown = 30 * time.Second
+	// webhookSignatureHeader carries the body's HMAC-SHA256 as
+	// sha256=<hex> when the webhook has a secret.
+	webhookSignatureHeader = "X-Sonifier-Signature"
+)
+
+// webhookEvent is the body POSTed to webhooks. Text is a one-line
+// description, so chat tools such as Slack can show it as is.
+type webhookEvent struct {
+	Type      string          `json:"type"`
+	Timestamp time.Time       `json:"ts"`
+	Text      string          `json:"text"`
+	Payload   json.RawMessage `json:"payload"`
+}
+
+// webhook delivers events to one endpoint from its own goroutine, so a
+// slow or dead endpoint never holds up ingest.
+type webhook struct {
+	url     string
+	target  string
+	events  map[string]bool
+	secret  []byte
+	timeout time.Duration
+	// backoff is the wait before the first retry.
+	backoff time.Duration
+	client  *http.Client
+	queue   chan []byte
+
+	// mu guards failures, which counts failed deliveries in a row, and
+	// openUntil, when an open circuit lets the next event through.
+	mu        sync.Mutex
+	failures  int
+	openUntil time.Time
+
+	delivered atomic.Uint64
+	failed    atomic.Uint64
+	retried   atomic.Uint64
+	dropped   atomic.Uint64
+	open      atomic.Bool
+}
+
+// webhooks fans alerts and summaries out to the configured webhooks. A nil
+// set sends nothing.
+type webhooks struct {
+	hooks []*webhook
+}
+
+func newWebhooks(cfgs []WebhookConfig) (*webhooks, error) {
+	if len(cfgs) == 0 {
+		return nil, nil
+	}
+	w := &webhooks{}
+	var errs []error
+	for i, cfg := range cfgs {
+		u, err := url.Parse(cfg.URL)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			errs = append(errs, fmt.Errorf("webhooks[%d].url must be an http or https URL, got %q", i, cfg.URL))
+			continue
+		}
+		if cfg.Timeout < 0 {
+			errs = append(errs, fmt.Errorf("webhooks[%d].timeout must not be negative", i))
+		}
+		events := cfg.Events
+		if len(events) == 0 {
+			events = webhookEventTypes
+		}
+		for _, event := range events {
+			if !slices.Contains(webhookEventTypes, event) {
+				errs = append(errs, fmt.Errorf("webhooks[%d].events: unknown event %q, expected %s",
+					i, event, strings.Join(webhookEventTypes, ", ")))
+			}
+		}
+		timeout := cfg.Timeout
+		if timeout == 0 {
+			timeout = defaultWebhookTimeout
+		}
+		w.hooks = append(w.hooks, &webhook{
+			url:     cfg.URL,
+			target:  u.Host,
+			events:  toSet(events),
+			secret:  []byte(cfg.Secret),
+			timeout: timeout,
+			backoff: webhookBackoff,
+			client:  &http.Client{Timeout: timeout},
+			queue:   make(chan []byte, webhookQueueSize),
+		})
+	}
+	if len(errs) > 0 {
+		return nil, errors.Join(errs...)
+	}
+	return w, nil
+}
+
+// notify queues an event for every webhook that wants its type, dropping
+// it for webhooks whose queue is full.
+func (w *webhooks) notify(dataType string, v any, payload []byte) {
+	if w == nil || !slices.Contains(webhookEventTypes, dataType) {
+		return
+	}
+	var body []byte
+	for _, hook := range w.hooks {
+		if !hook.events[dataType] {
+			continue
+		}
+		if body == nil {
+			body, _ = json.Marshal(webhookEvent{
+				Type:      dataType,
+				Timestamp: time.Now(),
+				Text:      webhookText(dataType, v),
+				Payload:   payload,
+			})
+		}
+		select {
+		case hook.queue <- body:
+		default:
+			hook.dropped.Add(1)
+		}
+	}
+}
+
+// run delivers queued events to every webhook until stop is closed.
+// Events still queued then are abandoned.
+func (w *webhooks) run(stop <-chan struct{}, logger *zap.Logger) {
+	var wg sync.WaitGroup
+	for _, hook := range w.hooks {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			hook.run(stop, logger)
+		}()
+	}
+	wg.Wait()
+}
+
+func (h *webhook) run(stop <-chan struct{}, logger *zap.Logger) {
+	for {
+		select {
+		case <-stop:
+			return
+		case body := <-h.queue:
+			h.deliver(body, stop, logger)
+		}
+	}
+}
+
+// deliver POSTs body, retrying failures with exponential backoff unless
+// the circuit is half open, and opens the circuit after too many failed
+// deliveries in a row.
+func (h *webhook) deliver(body []byte, stop <-chan struct{}, logger *zap.Logger) {
+	h.mu.Lock()
+	open, halfOpen := time.Now().Before(h.openUntil), h.failures >= webhookBreakerFailures
+	h.mu.Unlock()
+	if open {
+		h.dropped.Add(1)
+		return
+	}
+	retries := webhookRetries
+	if halfOpen {
+		retries = 0
+	}
+
+	var err error
+	for attempt := 0; ; attempt++ {
+		if err = h.post(body); err == nil {
+			break
+		}
+		if attempt == retries {
+			break
+		}
+		h.retried.Add(1)
+		backoff := h.backoff << attempt
+		backoff += time.Duration(rand.Int64N(int64(backoff) / 4))
+		timer := time.NewTimer(backoff)
+		select {
+		case <-stop:
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if err == nil {
+		h.delivered.Add(1)
+		if h.open.Swap(false) {
+			logger.Info("Webhook recovered, closing its circuit", zap.String("target", h.target))
+		}
+		h.failures = 0
+		return
+	}
+	h.failed.Add(1)
+	h.failures++
+	if h.failures >= webhookBreakerFailures {
+		h.openUntil = time.Now().Add(webhookBreakerCooldown)
+		if !h.open.Swap(true) {
+			logger.Warn("Webhook keeps failing, pausing deliveries",
+				zap.String("target", h.target), zap.Duration("cooldown", webhookBreakerCooldown), zap.Error(err))
+		}
+		return
+	}
+	logger.Warn("Failed to deliver webhook", zap.String("target", h.target), zap.Error(err))
+}
+
+// post makes one delivery attempt. Any 2xx response is a success.
+func (h *webhook) post(body []byte) error {
+	ctx, cancel := context.WithTimeout(context.Background(), h.timeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, h.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if len(h.secret) > 0 {
+		mac := hmac.New(sha256.New, h.secret)
+		mac.Write(body)
+		req.Header.Set(webhookSignatureHeader, "sha256="+hex.EncodeToString(mac.Sum(nil)))
+	}
+	resp, err := h.client.Do(req)
+	if err != nil {
+		return err
+	}
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+	resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("endpoint returned %s", resp.Status)
+	}
+	return nil
+}
+
+// webhookText describes an event in one line.
+func webhookText(dataType string, v any) string {
+	switch v := v.(type) {
+	case alert:
+		text := fmt.Sprintf("%s %s on %s: %g (expected %g)", v.Severity, v.Kind, v.Series, v.Value, v.Expected)
+		if v.Service != "" {
+			text += " in " + v.Service
+		}
+		return text
+	case alarmState:
+		if v.Active {
+			return fmt.Sprintf("Alarm raised: %s (error ratio %.2f)", v.Reason, v.ErrorRatio)
+		}
+		return fmt.Sprintf("Alarm cleared: %s (error ratio %.2f)", v.Reason, v.ErrorRatio)
+	case summary:
+		logs := 0
+		for _, n := range v.Logs {
+			logs += n
+		}
+		return fmt.Sprintf("Last %s: %d spans, %d errors, p95 %.0f ms, %d logs",
+			time.Duration(v.WindowMillis)*time.Millisecond, v.Spans, v.ErrorSpans, v.P95DurationMs, logs)
+	}
+	return dataType
+}
+
+// webhookStats is the /stats entry of one webhook. Target is the
+// endpoint's host, since webhook URLs often embed a token.
+type webhookStats struct {
+	Target      string `json:"target"`
+	Delivered   uint64 `json:"delivered"`
+	Failed      uint64 `json:"failed"`
+	Retried     uint64 `json:"retried"`
+	Dropped     uint64 `json:"dropped"`
+	Queued      int    `json:"queued"`
+	CircuitOpen bool   `json:"circuit_open"`
+}
+
+func (w *webhooks) stats() []webhookStats {
+	if w == nil {
+		return nil
+	}
+	stats := make([]webhookStats, 0, len(w.hooks))
+	for _, h := range w.hooks {
+		stats = append(stats, webhookStats{
+			Target:      h.target,
+			Delivered:   h.delivered.Load(),
+			Failed:      h.failed.Load(),
+			Retried:     h.retried.Load(),
+			Dropped:     h.dropped.Load(),
+			Queued:      len(h.queue),
+			CircuitOpen: h.open.Load(),
+		})
+	}
+	return stats
+}
@@ -0,0 +1,147 @@
+package sonifierextension
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+// webhookServer is an endpoint that fails its first failures requests
+// with a 500 and records every request it gets.
+type webhookServer struct {
+	*httptest.Server
+	failures int
+
+	mu       sync.Mutex
+	requests []*http.Request
+	bodies   [][]byte
+}
+
+func newWebhookServer(t *testing.T, failures int) *webhookServer {
+	s := &webhookServer{failures: failures}
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		s.mu.Lock()
+		s.requests = append(s.requests, r)
+		s.bodies = append(s.bodies, body)
+		fail := len(s.requests) <= s.failures
+		s.mu.Unlock()
+		if fail {
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+	}))
+	t.Cleanup(s.Close)
+	return s
+}
+
+func (s *webhookServer) received() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.requests)
+}
+
+// startWebhooks runs webhooks for cfgs, retrying without a noticeable
+// wait, until the test ends.
+func startWebhooks(t *testing.T, cfgs ...WebhookConfig) *webhooks {
+	w, err := newWebhooks(cfgs)
+	require.NoError(t, err)
+	for _, hook := range w.hooks {
+		hook.backoff = time.Millisecond
+	}
+	stop := make(chan struct{})
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		w.run(stop, zap.NewNop())
+	}()
+	t.Cleanup(func() {
+		close(stop)
+		<-done
+	})
+	return w
+}
+
+func TestWebhookDelivery(t *testing.T) {
+	server := newWebhookServer(t, 2)
+	w := startWebhooks(t, WebhookConfig{URL: server.URL, Secret: "s3cret"})
+
+	spike := alert{Kind: "metric_spike", Series: "system.cpu.utilization", Severity: "critical", Value: 0.98, Expected: 0.4}
+	payload, err := json.Marshal(spike)
+	require.NoError(t, err)
+	w.notify("alert", spike, payload)
+
+	require.Eventually(t, func() bool { return w.stats()[0].Delivered == 1 }, 5*time.Second, time.Millisecond)
+	stats := w.stats()[0]
+	assert.Equal(t, uint64(2), stats.Retried)
+	assert.Zero(t, stats.Failed)
+	assert.False(t, stats.CircuitOpen)
+	require.Equal(t, 3, server.received())
+
+	server.mu.Lock()
+	defer server.mu.Unlock()
+	for i, body := range server.bodies {
+		req := server.requests[i]
+		assert.Equal(t, http.MethodPost, req.Method)
+		assert.Equal(t, "application/json", req.Header.Get("Content-Type"))
+		mac := hmac.New(sha256.New, []byte("s3cret"))
+		mac.Write(body)
+		assert.Equal(t, "sha256="+hex.EncodeToString(mac.Sum(nil)), req.Header.Get(webhookSignatureHeader))
+	}
+
+	var event struct {
+		Type      string    `json:"type"`
+		Timestamp time.Time `json:"ts"`
+		Text      string    `json:"text"`
+		Payload   alert     `json:"payload"`
+	}
+	require.NoError(t, json.Unmarshal(server.bodies[2], &event))
+	assert.Equal(t, "alert", event.Type)
+	assert.WithinDuration(t, time.Now(), event.Timestamp, time.Minute)
+	assert.Equal(t, "critical metric_spike on system.cpu.utilization: 0.98 (expected 0.4)", event.Text)
+	assert.Equal(t, spike, event.Payload)
+}
+
+func TestWebhookEventsAndUnsigned(t *testing.T) {
+	server := newWebhookServer(t, 0)
+	w := startWebhooks(t, WebhookConfig{URL: server.URL, Events: []string{"alarm"}})
+
+	w.notify("summary", summary{}, []byte(`{}`))
+	w.notify("alarm", alarmState{Active: true, Reason: "error_ratio", ErrorRatio: 0.5}, []byte(`{}`))
+	require.Eventually(t, func() bool { return w.stats()[0].Delivered == 1 }, 5*time.Second, time.Millisecond)
+
+	require.Equal(t, 1, server.received())
+	server.mu.Lock()
+	defer server.mu.Unlock()
+	assert.Empty(t, server.requests[0].Header.Get(webhookSignatureHeader))
+	assert.Contains(t, string(server.bodies[0]), `"type":"alarm"`)
+}
+
+func TestWebhookCircuitBreaker(t *testing.T) {
+	server := newWebhookServer(t, 1000)
+	w := startWebhooks(t, WebhookConfig{URL: server.URL})
+
+	for range webhookBreakerFailures {
+		w.notify("alert", alert{}, []byte(`{}`))
+	}
+	require.Eventually(t, func() bool { return w.stats()[0].CircuitOpen }, 5*time.Second, time.Millisecond)
+	stats := w.stats()[0]
+	assert.Equal(t, uint64(webhookBreakerFailures), stats.Failed)
+	assert.Equal(t, uint64(webhookBreakerFailures*webhookRetries), stats.Retried)
+	assert.Equal(t, webhookBreakerFailures*(webhookRetries+1), server.received())
+
+	// While the circuit is open, events are dropped without a request
+	w.notify("alert", alert{}, []byte(`{}`))
+	require.Eventually(t, func() bool { return w.stats()[0].Dropped == 1 }, 5*time.Second, time.Millisecond)
+	assert.Equal(t, webhookBreakerFailures*(webhookRetries+1), server.received())
+}
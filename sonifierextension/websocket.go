@@ -0,0 +1,450 @@
+package sonifierextension
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"go.uber.org/zap"
+)
+
+// wsClient is a WebSocket subscriber. Messages are written by its own
+// goroutine so a slow client never blocks the broadcast.
+type wsClient struct {
+	*queuedClient
+	conn *websocket.Conn
+	// binary sends MessagePack binary frames instead of JSON text frames.
+	binary bool
+	// rewound carries the messages of a resume to the writer, which sends
+	// them before any of the queue.
+	rewound chan []*broadcastMessage
+	// lastActive is when the client last sent a message or answered a
+	// ping, in Unix nanoseconds, zero until then.
+	lastActive atomic.Int64
+}
+
+const (
+	formatJSON    = "json"
+	formatMsgpack = "msgpack"
+)
+
+// readyTimeout is how long a streaming client that connects while Start is
+// still setting up waits before being turned away.
+const readyTimeout = 2 * time.Second
+
+// awaitReady waits for Start to finish setting up, so that a client
+// connecting as the server comes up gets its full handshake and replay. It
+// answers 503 and returns false when setup doesn't finish in time.
+func (s *sonifierExtension) awaitReady(w http.ResponseWriter, r *http.Request) bool {
+	select {
+	case <-s.ready:
+		return true
+	default:
+	}
+	timer := time.NewTimer(readyTimeout)
+	defer timer.Stop()
+	select {
+	case <-s.ready:
+		return true
+	case <-r.Context().Done():
+		return false
+	case <-s.stop:
+	case <-timer.C:
+	}
+	w.Header().Set("Retry-After", "1")
+	http.Error(w, "Server is starting, retry shortly", http.StatusServiceUnavailable)
+	return false
+}
+
+func validBroadcastFormat(format string) bool {
+	return format == "" || format == formatJSON || format == formatMsgpack
+}
+
+// wsFormat returns the encoding a WebSocket client asked for with the
+// format query parameter or the json or msgpack subprotocol, falling back
+// to the configured default. Other negotiated subprotocols don't select an
+// encoding.
+func (s *sonifierExtension) wsFormat(r *http.Request, conn *websocket.Conn) string {
+	if format := r.URL.Query().Get("format"); format != "" {
+		return format
+	}
+	if protocol := conn.Subprotocol(); protocol == formatJSON || protocol == formatMsgpack {
+		return protocol
+	}
+	return s.config.Broadcast.Format
+}
+
+// validSubprotocol reports whether protocol is an HTTP token, as the
+// Sec-WebSocket-Protocol header requires.
+func validSubprotocol(protocol string) bool {
+	if protocol == "" {
+		return false
+	}
+	for _, c := range protocol {
+		if c <= ' ' || c >= 0x7f || strings.ContainsRune(`()<>@,;:\"/[]?={}`, c) {
+			return false
+		}
+	}
+	return true
+}
+
+func (c *wsClient) kind() string {
+	return "websocket"
+}
+
+func (c *wsClient) connection() connectionInfo {
+	info := c.queuedClient.connection()
+	info.Format = formatJSON
+	if c.binary {
+		info.Format = formatMsgpack
+	}
+	if ns := c.lastActive.Load(); ns != 0 {
+		t := time.Unix(0, ns)
+		info.LastActive = &t
+	}
+	return info
+}
+
+// active records that the client showed signs of life and, with an idle
+// timeout, gives it that much longer before it is disconnected.
+func (c *wsClient) active(idle time.Duration) error {
+	now := time.Now()
+	c.lastActive.Store(now.UnixNano())
+	if idle > 0 {
+		return c.conn.SetReadDeadline(now.Add(idle))
+	}
+	return nil
+}
+
+// rewind is called at most once per client, when it connects, so the
+// buffered channel never blocks the broadcaster.
+func (c *wsClient) rewind(msgs []*broadcastMessage) {
+	c.rewound <- msgs
+}
+
+// shutdownCloseTimeout bounds how long the close frame may take to send.
+const shutdownCloseTimeout = time.Second
+
+// closeReason is why the server ends a stream, as sent in the WebSocket
+// close frame.
+type closeReason struct {
+	code int
+	text string
+}
+
+var (
+	closeShutdown = closeReason{code: websocket.CloseGoingAway, text: "server shutting down"}
+	closeTooSlow  = closeReason{code: websocket.CloseTryAgainLater, text: "client too slow, dropped too many messages in a row"}
+)
+
+// disconnect sends a close frame with reason and closes the socket so the
+// read loop returns even when the peer is silent.
+func (c *wsClient) disconnect(reason closeReason) {
+	c.queuedClient.disconnect(reason)
+	msg := websocket.FormatCloseMessage(reason.code, reason.text)
+	c.conn.WriteControl(websocket.CloseMessage, msg, time.Now().Add(shutdownCloseTimeout))
+	c.conn.Close()
+}
+
+func (s *sonifierExtension) handleWebSocket(w http.ResponseWriter, r *http.Request) {
+	if !s.awaitReady(w, r) {
+		return
+	}
+	if format := r.URL.Query().Get("format"); format != "" && !validBroadcastFormat(format) {
+		http.Error(w, "Invalid format, expected json or msgpack", http.StatusBadRequest)
+		return
+	}
+	channels, err := s.requestChannels(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	var fromSeq uint64
+	if param := r.URL.Query().Get("from_seq"); param != "" {
+		fromSeq, err = strconv.ParseUint(param, 10, 64)
+		if err != nil || fromSeq == 0 {
+			http.Error(w, "Invalid from_seq, expected a positive integer", http.StatusBadRequest)
+			return
+		}
+	}
+	if !s.reserveClient() {
+		s.refuseClient(w, r)
+		return
+	}
+	defer s.wsClients.Add(-1)
+	conn, err := s.wsUpgrader.Upgrade(w, r, nil)
+	if err != nil {
+		s.logger.Error("Failed to upgrade WebSocket connection", zap.Error(err))
+		return
+	}
+
+	s.connWG.Add(1)
+	defer s.connWG.Done()
+	if conn.Subprotocol() == midiSubprotocol {
+		s.serveMIDI(conn, r)
+		return
+	}
+
+	client := &wsClient{
+		queuedClient: newQueuedClient(r.RemoteAddr, s.config.ClientQueueSize, s.config.MaxClientDrops),
+		conn:         conn,
+		binary:       s.wsFormat(r, conn) == formatMsgpack,
+		rewound:      make(chan []*broadcastMessage, 1),
+	}
+	client.channels = channels
+	if fromSeq > 0 {
+		s.resumeFrom(client, fromSeq)
+	} else {
+		s.broadcaster.subscribe(client, 0)
+	}
+	s.countClients(r.Context(), client.kind(), 1)
+	select {
+	case <-s.stop:
+		// Shutdown already disconnected everyone else
+		client.disconnect(closeShutdown)
+	default:
+	}
+
+	s.logger.Info("WebSocket connection established", zap.String("subprotocol", conn.Subprotocol()))
+
+	writerDone := make(chan struct{})
+	go func() {
+		defer close(writerDone)
+		s.writeWebSocket(client)
+	}()
+
+	// Handle connection cleanup
+	defer func() {
+		s.broadcaster.unsubscribe(client)
+		s.countClients(context.Background(), client.kind(), -1)
+		s.telemetry.recordClientDropped(context.Background(), client.kind(), client.droppedCount())
+		close(client.done)
+		<-writerDone
+		conn.Close()
+		s.logger.Info("WebSocket connection closed")
+	}()
+
+	// Every message or pong pushes the idle deadline back
+	idle := s.config.WebSocket.IdleTimeout
+	if idle > 0 {
+		conn.SetReadDeadline(time.Now().Add(idle))
+	}
+	conn.SetPongHandler(func(string) error { return client.active(idle) })
+
+	// Keep connection alive and handle control messages
+	for {
+		_, data, err := conn.ReadMessage()
+		if err != nil {
+			var netErr net.Error
+			if errors.As(err, &netErr) && netErr.Timeout() {
+				s.logger.Info("Disconnecting idle WebSocket client",
+					zap.String("remote", client.remote), zap.Duration("idle_timeout", idle))
+				s.stats.idleDisconnects.Add(1)
+			} else if websocket.IsUnexpectedCloseError(err, websocket.CloseGoingAway, websocket.CloseAbnormalClosure) {
+				s.logger.Error("WebSocket error", zap.Error(err))
+			}
+			break
+		}
+		client.active(idle)
+		if s.handleServerControl(data) {
+			continue
+		}
+		if err := client.handleControl(data); err != nil {
+			s.logger.Warn("Ignoring WebSocket message", zap.Error(err))
+		}
+	}
+}
+
+// reserveClient takes one of the websocket.max_clients slots, and reports
+// false when they are all taken.
+func (s *sonifierExtension) reserveClient() bool {
+	limit := s.config.WebSocket.MaxClients
+	if s.wsClients.Add(1) > int64(limit) && limit > 0 {
+		s.wsClients.Add(-1)
+		return false
+	}
+	return true
+}
+
+// refuseClient answers an upgrade over websocket.max_clients with a 503
+// and a JSON body saying why.
+func (s *sonifierExtension) refuseClient(w http.ResponseWriter, r *http.Request) {
+	s.stats.refusedClients.Add(1)
+	s.logger.Debug("Refusing WebSocket client over websocket.max_clients",
+		zap.String("remote", r.RemoteAddr), zap.Int("max_clients", s.config.WebSocket.MaxClients))
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Retry-After", "30")
+	w.WriteHeader(http.StatusServiceUnavailable)
+	json.NewEncoder(w).Encode(map[string]any{
+		"error":       "too many WebSocket clients",
+		"max_clients": s.config.WebSocket.MaxClients,
+	})
+}
+
+// handleServerControl applies control messages that act on the server
+// rather than the sending client's subscription, and reports whether data
+// was one.
+func (s *sonifierExtension) handleServerControl(data []byte) bool {
+	var msg controlMessage
+	if json.Unmarshal(data, &msg) != nil || msg.Action != "reset_counts" {
+		return false
+	}
+	s.stats.resetSeen()
+	s.logger.Info("Reset seen counts")
+	s.broadcastJSON("counts", s.stats.seenCounts())
+	return true
+}
+
+// resumeFrom subscribes a client that connected with ?from_seq=N to
+// catch up on what it missed while reconnecting. The missed messages are
+// queued ahead of live delivery. When the history no longer reaches back to
+// N the client gets a resume_gap message instead and continues live.
+func (s *sonifierExtension) resumeFrom(client *wsClient, fromSeq uint64) {
+	replayed, oldest, ok := s.broadcaster.subscribeFrom(client, fromSeq)
+	if ok {
+		s.logger.Info("Resumed WebSocket stream", zap.Uint64("from_seq", fromSeq), zap.Int("replayed", replayed))
+		return
+	}
+	s.logger.Info("WebSocket resume gap too large", zap.Uint64("from_seq", fromSeq), zap.Uint64("oldest_seq", oldest))
+	gap, err := newDirectMessage("resume_gap", map[string]uint64{
+		"from_seq":   fromSeq,
+		"oldest_seq": oldest,
+	})
+	if err != nil {
+		s.logger.Error("Failed to encode resume gap message", zap.Error(err))
+		return
+	}
+	client.rewind([]*broadcastMessage{gap})
+}
+
+// heartbeatPayload is the payload of a {"type":"heartbeat"} message.
+type heartbeatPayload struct {
+	// IntervalMs is how often heartbeats come, so a client can tell when
+	// one is overdue.
+	IntervalMs int64 `json:"interval_ms"`
+}
+
+// newDirectMessage returns a message for a single client. It has seq 0,
+// outside the broadcast sequence, so it doesn't read as a gap.
+func newDirectMessage(dataType string, v any) (*broadcastMessage, error) {
+	payload, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	env := envelope{Type: dataType, Timestamp: time.Now(), Payload: payload}
+	data, err := json.Marshal(env)
+	if err != nil {
+		return nil, err
+	}
+	return &broadcastMessage{dataType: dataType, payload: payload, data: data, env: env}, nil
+}
+
+// writeWebSocket delivers queued messages until the client leaves. A failed
+// or timed out write closes the connection, which ends the read loop. With
+// an idle timeout it also pings the client, whose pongs keep it connected,
+// and with a heartbeat interval it sends heartbeat messages, which the
+// client's application code sees, unlike pings.
+func (s *sonifierExtension) writeWebSocket(client *wsClient) {
+	var ping <-chan time.Time
+	if idle := s.config.WebSocket.IdleTimeout; idle > 0 {
+		ticker := time.NewTicker(idle / 3)
+		defer ticker.Stop()
+		ping = ticker.C
+	}
+	var heartbeat <-chan time.Time
+	interval := s.config.WebSocket.HeartbeatInterval
+	if interval > 0 {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		heartbeat = ticker.C
+	}
+	for {
+		// A rewind goes out before anything queued after it
+		select {
+		case msgs := <-client.rewound:
+			for _, msg := range msgs {
+				if !s.writeMessage(client, msg) {
+					return
+				}
+			}
+			continue
+		default:
+		}
+
+		select {
+		case <-client.done:
+			return
+		case msgs := <-client.rewound:
+			for _, msg := range msgs {
+				if !s.writeMessage(client, msg) {
+					return
+				}
+			}
+		case msg := <-client.queue:
+			if client.expired(msg, s.config.Broadcast.MessageTTL, time.Now()) {
+				s.telemetry.recordExpired(context.Background(), client.kind(), msg.dataType)
+				continue
+			}
+			if !s.writeMessage(client, msg) {
+				return
+			}
+		case <-ping:
+			var deadline time.Time
+			if timeout := s.config.WebSocket.WriteTimeout; timeout > 0 {
+				deadline = time.Now().Add(timeout)
+			}
+			if err := client.conn.WriteControl(websocket.PingMessage, nil, deadline); err != nil {
+				client.conn.Close()
+				return
+			}
+		case <-heartbeat:
+			msg, err := newDirectMessage("heartbeat", heartbeatPayload{IntervalMs: interval.Milliseconds()})
+			if err != nil {
+				s.logger.Error("Failed to encode heartbeat message", zap.Error(err))
+				continue
+			}
+			if !s.writeMessage(client, msg) {
+				return
+			}
+		}
+	}
+}
+
+// writeMessage writes msg to the client, and closes the connection and
+// returns false when that fails.
+func (s *sonifierExtension) writeMessage(client *wsClient, msg *broadcastMessage) bool {
+	if timeout := s.config.WebSocket.WriteTimeout; timeout > 0 {
+		client.conn.SetWriteDeadline(time.Now().Add(timeout))
+	}
+	frameType, data := websocket.TextMessage, msg.data
+	if client.binary {
+		if packed, err := msg.msgpack(); err == nil {
+			frameType, data = websocket.BinaryMessage, packed
+		} else {
+			s.logger.Error("Failed to encode MessagePack, sending JSON", zap.Error(err))
+		}
+	}
+	start := time.Now()
+	err := client.conn.WriteMessage(frameType, data)
+	s.telemetry.recordWrite(context.Background(), time.Since(start))
+	if err != nil {
+		var netErr net.Error
+		if errors.As(err, &netErr) && netErr.Timeout() {
+			s.logger.Warn("WebSocket write timed out, dropping client", zap.Stringer("remote", client.conn.RemoteAddr()))
+		} else {
+			s.logger.Error("Failed to write to WebSocket", zap.Error(err))
+		}
+		client.conn.Close()
+		return false
+	}
+	client.sent.Add(uint64(len(data)))
+	client.messages.Add(1)
+	return true
+}
@@ -0,0 +1,153 @@
+package sonifierextension
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// dialWebSocket connects to the extension at base with query appended to
+// /ws, and closes the connection when the test ends.
+func dialWebSocket(t *testing.T, base, query string) *websocket.Conn {
+	t.Helper()
+	conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(base, "http")+"/ws"+query, nil)
+	require.NoError(t, err)
+	t.Cleanup(func() { conn.Close() })
+	require.NoError(t, conn.SetReadDeadline(time.Now().Add(10*time.Second)))
+	return conn
+}
+
+// readEnvelope reads the next JSON message from conn.
+func readEnvelope(t *testing.T, conn *websocket.Conn) envelope {
+	t.Helper()
+	_, data, err := conn.ReadMessage()
+	require.NoError(t, err)
+	var env envelope
+	require.NoError(t, json.Unmarshal(data, &env))
+	return env
+}
+
+// readSeqs reads broadcast messages from conn until it has n, skipping
+// those for this client alone, and returns their seqs.
+func readSeqs(t *testing.T, conn *websocket.Conn, n int) []uint64 {
+	t.Helper()
+	var seqs []uint64
+	for len(seqs) < n {
+		if env := readEnvelope(t, conn); env.Seq > 0 {
+			seqs = append(seqs, env.Seq)
+		}
+	}
+	return seqs
+}
+
+func TestWebSocketResume(t *testing.T) {
+	s, base := startTestExtension(t, func(cfg *Config) {
+		cfg.ClientQueueSize = 10000
+		cfg.Buffer.MaxEntries = 10000
+	})
+	ingest := func() {
+		require.NoError(t, s.Ingest(context.Background(), "logs", []byte(testLogs)))
+	}
+
+	first := dialWebSocket(t, base, "")
+	for range 3 {
+		ingest()
+	}
+	seen := readSeqs(t, first, 3)
+	first.Close()
+
+	// Missed while reconnecting
+	for range 5 {
+		ingest()
+	}
+
+	// Keep publishing while the client resumes, so live messages race the
+	// backlog
+	stop := make(chan struct{})
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for {
+			select {
+			case <-stop:
+				return
+			default:
+				ingest()
+			}
+		}
+	}()
+	from := seen[len(seen)-1] + 1
+	second := dialWebSocket(t, base, "?from_seq="+strconv.FormatUint(from, 10))
+	seqs := readSeqs(t, second, 200)
+	close(stop)
+	wg.Wait()
+
+	for i, seq := range seqs {
+		require.Equal(t, from+uint64(i), seq, "gap, duplicate or reordering at %d", i)
+	}
+}
+
+func TestWebSocketResumeGap(t *testing.T) {
+	s, base := startTestExtension(t, func(cfg *Config) {
+		cfg.Buffer.MaxEntries = 2
+	})
+	for range 5 {
+		require.NoError(t, s.Ingest(context.Background(), "logs", []byte(testLogs)))
+	}
+	oldest := history(s.broadcaster)[0].id
+
+	conn := dialWebSocket(t, base, "?from_seq=1")
+	for {
+		env := readEnvelope(t, conn)
+		if env.Type != "resume_gap" {
+			continue
+		}
+		assert.Zero(t, env.Seq)
+		assert.JSONEq(t, `{"from_seq":1,"oldest_seq":`+strconv.FormatUint(oldest, 10)+`}`, string(env.Payload))
+		return
+	}
+}
+
+func TestWebSocketResumeInvalid(t *testing.T) {
+	_, base := startTestExtension(t)
+	for _, query := range []string{"?from_seq=0", "?from_seq=x", "?from_seq=-1"} {
+		_, resp, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(base, "http")+"/ws"+query, nil)
+		require.Error(t, err, query)
+		assert.Equal(t, http.StatusBadRequest, resp.StatusCode, query)
+	}
+}
+
+func TestShutdownWithSilentClients(t *testing.T) {
+	s, base := startTestExtension(t)
+	// The clients never send anything, so only the server closing their
+	// sockets ends their read loops
+	var conns []*websocket.Conn
+	for range 3 {
+		conns = append(conns, dialWebSocket(t, base, ""))
+	}
+	require.Eventually(t, func() bool { return s.stats.clients["websocket"].Load() == 3 }, 5*time.Second, 10*time.Millisecond)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	start := time.Now()
+	require.NoError(t, s.Shutdown(ctx))
+	assert.Less(t, time.Since(start), shutdownCloseTimeout)
+
+	for _, conn := range conns {
+		var err error
+		for err == nil {
+			_, _, err = conn.ReadMessage()
+		}
+		assert.True(t, websocket.IsCloseError(err, websocket.CloseGoingAway), "got %v", err)
+	}
+}
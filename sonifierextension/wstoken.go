@@ -0,0 +1,145 @@
+package sonifierextension
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// streamTokenPrefix starts every signed stream token, telling it apart
+// from the listener token in the token query parameter.
+const streamTokenPrefix = "st1."
+
+// minSigningKeyLength is the shortest auth.signing_key accepted, so the
+// HMAC key can't be guessed.
+const minSigningKeyLength = 32
+
+var errStreamToken = errors.New("invalid or expired stream token")
+
+// tokenSigner issues and checks short-lived stream tokens. A token is
+// st1.<expiry>.<nonce>.<signature>, where the expiry is in Unix seconds
+// and the signature is the HMAC-SHA256 of everything before it. A nil
+// signer issues none and accepts none.
+type tokenSigner struct {
+	key []byte
+	ttl time.Duration
+}
+
+func newTokenSigner(cfg AuthConfig) (*tokenSigner, error) {
+	if cfg.SigningKey == "" {
+		return nil, nil
+	}
+	var errs []error
+	if len(cfg.SigningKey) < minSigningKeyLength {
+		errs = append(errs, fmt.Errorf("auth.signing_key must be at least %d characters long", minSigningKeyLength))
+	}
+	if cfg.ListenerToken == "" {
+		errs = append(errs, errors.New("auth.signing_key requires auth.listener_token, which POST /ws-token is authenticated with"))
+	}
+	if cfg.TokenTTL <= 0 {
+		errs = append(errs, errors.New("auth.token_ttl must be positive"))
+	}
+	if len(errs) > 0 {
+		return nil, errors.Join(errs...)
+	}
+	return &tokenSigner{key: []byte(cfg.SigningKey), ttl: cfg.TokenTTL}, nil
+}
+
+// issue returns a token valid until now plus the TTL, and that expiry.
+func (t *tokenSigner) issue(now time.Time) (string, time.Time) {
+	expires := now.Add(t.ttl).Truncate(time.Second)
+	nonce := make([]byte, 8)
+	rand.Read(nonce)
+	payload := streamTokenPrefix + strconv.FormatInt(expires.Unix(), 10) + "." + hex.EncodeToString(nonce)
+	return payload + "." + t.sign(payload), expires
+}
+
+func (t *tokenSigner) sign(payload string) string {
+	mac := hmac.New(sha256.New, t.key)
+	mac.Write([]byte(payload))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// verify checks token's signature and that it hasn't expired at now.
+func (t *tokenSigner) verify(token string, now time.Time) error {
+	if t == nil {
+		return errStreamToken
+	}
+	i := strings.LastIndexByte(token, '.')
+	if i < 0 {
+		return errStreamToken
+	}
+	payload, signature := token[:i], token[i+1:]
+	if !hmac.Equal([]byte(signature), []byte(t.sign(payload))) {
+		return errStreamToken
+	}
+	fields := strings.Split(strings.TrimPrefix(payload, streamTokenPrefix), ".")
+	if len(fields) != 2 {
+		return errStreamToken
+	}
+	expires, err := strconv.ParseInt(fields[0], 10, 64)
+	if err != nil || now.Unix() >= expires {
+		return errStreamToken
+	}
+	return nil
+}
+
+// streamTokenResponse is returned by POST /ws-token.
+type streamTokenResponse struct {
+	Token     string    `json:"token"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// handleStreamToken issues a stream token to a caller holding the listener
+// token, for a backend to hand to a browser in place of the listener
+// token itself.
+func (s *sonifierExtension) handleStreamToken(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if s.signer == nil {
+		http.Error(w, "Forbidden: endpoint disabled, no auth.signing_key configured", http.StatusForbidden)
+		return
+	}
+	token, expires := s.signer.issue(time.Now())
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	if err := json.NewEncoder(w).Encode(streamTokenResponse{Token: token, ExpiresAt: expires}); err != nil {
+		s.logger.Error("Failed to write stream token response", zap.Error(err))
+	}
+}
+
+// streamToken lets requests to a streaming endpoint that carry a signed
+// stream token in the token query parameter through to open, and sends
+// the rest to guarded, which checks the listener token as usual. Tokens
+// are only checked when the connection opens, so one expiring doesn't
+// end a stream.
+func (s *sonifierExtension) streamToken(open, guarded http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		token := r.URL.Query().Get("token")
+		if !strings.HasPrefix(token, streamTokenPrefix) || token == string(s.config.Auth.ListenerToken) {
+			guarded(w, r)
+			return
+		}
+		if err := s.signer.verify(token, time.Now()); err != nil {
+			s.stats.unauthorized.Add(1)
+			s.logger.Debug("Rejected stream token", zap.String("path", r.URL.Path), zap.String("remote", r.RemoteAddr))
+			w.Header().Set("WWW-Authenticate", "Bearer")
+			http.Error(w, "Unauthorized: "+err.Error(), http.StatusUnauthorized)
+			return
+		}
+		open(w, r)
+	}
+}